@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 请求 ID 的响应头（客户端传入的合法 ID 会被沿用，便于与反向代理日志关联）
+const requestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// 从 context 获取请求 ID
+func requestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// 生成请求 ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 记录状态码和响应大小的 ResponseWriter
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (a *accessLogWriter) WriteHeader(code int) {
+	if a.status == 0 {
+		a.status = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *accessLogWriter) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytes += int64(n)
+	return n, err
+}
+
+func (a *accessLogWriter) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// WebSocket 升级需要接管连接
+func (a *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := a.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	a.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (a *accessLogWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// 长连接类型：WebSocket 或 SSE，普通请求为空
+func streamKind(r *http.Request) string {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return "websocket"
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return "sse"
+	}
+	return ""
+}
+
+// 为每个请求分配请求 ID 并记录访问日志（access_log / ACCESS_LOG=false 时只分配请求 ID）
+// WebSocket 和 SSE 在建立连接和断开时各记录一条
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		// X-Username 只能由认证中间件设置
+		r.Header.Del("X-Username")
+
+		if !appConfig.AccessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := componentLogger("http")
+		kind := streamKind(r)
+		if kind != "" {
+			logger.InfoContext(r.Context(), "Stream connected", "kind", kind, "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+		}
+
+		start := time.Now()
+		aw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r)
+
+		status := aw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", aw.bytes,
+			"remote", r.RemoteAddr,
+		}
+		if user := r.Header.Get("X-Username"); user != "" {
+			attrs = append(attrs, "user", user)
+		} else if node := r.Header.Get("X-Node-ID"); node != "" {
+			attrs = append(attrs, "node_id", node)
+		}
+
+		msg := "Request"
+		if kind != "" {
+			msg = "Stream disconnected"
+			attrs = append(attrs, "kind", kind)
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		logger.Log(r.Context(), level, msg, attrs...)
+	})
+}
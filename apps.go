@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 应用模板：一键部署单个容器（内置模板见 apps.json，用户模板保存在 app_templates 表）
+// 部署通过 runContainer 完成，容器带有模板标签，之后可拉取新标签并按原配置重建（升级）
+
+//go:embed apps.json
+var builtinAppsJSON []byte
+
+// 容器标签：部署来源的模板和镜像标签
+const (
+	appTemplateLabel = "rabbit.app.template"
+	appTagLabel      = "rabbit.app.tag"
+)
+
+var (
+	appIDPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	appNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+	appEnvPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	appTagPattern  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+)
+
+// 应用模板
+type AppTemplate struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Image       string      `json:"image"`   // 含默认标签
+	Restart     string      `json:"restart"` // 重启策略，默认 unless-stopped
+	Ports       []AppPort   `json:"ports,omitempty"`
+	Env         []AppEnv    `json:"env,omitempty"`
+	Volumes     []AppVolume `json:"volumes,omitempty"`
+	Builtin     bool        `json:"builtin"`
+}
+
+// 端口（host 为默认的主机端口，为空时默认不映射）
+type AppPort struct {
+	Container   string `json:"container"`
+	Host        string `json:"host,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// 环境变量（prompt 为部署时的提示，default 中可使用 ${name} 表示容器名称）
+type AppEnv struct {
+	Key      string `json:"key"`
+	Prompt   string `json:"prompt,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	Secret   bool   `json:"secret,omitempty"` // 前端以密码框输入
+}
+
+// 数据卷（host 为默认的主机路径，可使用 ${name}）
+type AppVolume struct {
+	Container   string `json:"container"`
+	Host        string `json:"host"`
+	Description string `json:"description,omitempty"`
+}
+
+var builtinApps []*AppTemplate
+
+// 解析内置模板并创建用户模板表
+func initApps() error {
+	if err := json.Unmarshal(builtinAppsJSON, &builtinApps); err != nil {
+		return fmt.Errorf("解析内置应用模板失败: %v", err)
+	}
+	for _, app := range builtinApps {
+		app.Builtin = true
+		if err := app.validate(); err != nil {
+			return fmt.Errorf("内置应用模板 %s 无效: %v", app.ID, err)
+		}
+	}
+
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS app_templates (
+		id TEXT PRIMARY KEY,
+		template TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建应用模板表失败: %v", err)
+	}
+	return nil
+}
+
+// 校验模板
+func (app *AppTemplate) validate() error {
+	if !appIDPattern.MatchString(app.ID) {
+		return fmt.Errorf("模板 ID 只能包含小写字母、数字、- 和 _")
+	}
+	if strings.TrimSpace(app.Name) == "" {
+		return fmt.Errorf("模板名称不能为空")
+	}
+	if app.Image == "" || strings.ContainsAny(app.Image, " \t") {
+		return fmt.Errorf("镜像名称无效: %s", app.Image)
+	}
+	if app.Restart == "" {
+		app.Restart = "unless-stopped"
+	}
+	switch app.Restart {
+	case "no", "always", "unless-stopped", "on-failure":
+	default:
+		return fmt.Errorf("重启策略无效: %s", app.Restart)
+	}
+	for _, p := range app.Ports {
+		if !validPort(p.Container) || (p.Host != "" && !validPort(p.Host)) {
+			return fmt.Errorf("端口无效: %s:%s", p.Host, p.Container)
+		}
+	}
+	for _, e := range app.Env {
+		if !appEnvPattern.MatchString(e.Key) {
+			return fmt.Errorf("环境变量名无效: %s", e.Key)
+		}
+	}
+	for _, v := range app.Volumes {
+		if !path.IsAbs(v.Container) {
+			return fmt.Errorf("容器内路径应为绝对路径: %s", v.Container)
+		}
+	}
+	return nil
+}
+
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// 所有模板（内置在前，用户模板按 ID 排序）
+func listAppTemplates() ([]*AppTemplate, error) {
+	apps := append([]*AppTemplate{}, builtinApps...)
+	rows, err := authDB.Query("SELECT template FROM app_templates ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var app AppTemplate
+		if err := json.Unmarshal([]byte(data), &app); err != nil {
+			log.Printf("[Apps] Skip invalid template: %v", err)
+			continue
+		}
+		apps = append(apps, &app)
+	}
+	return apps, rows.Err()
+}
+
+func findAppTemplate(id string) (*AppTemplate, error) {
+	for _, app := range builtinApps {
+		if app.ID == id {
+			return app, nil
+		}
+	}
+	var data string
+	err := authDB.QueryRow("SELECT template FROM app_templates WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var app AppTemplate
+	if err := json.Unmarshal([]byte(data), &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// 拆分镜像引用为仓库和标签（没有标签时为 latest；使用 digest 的引用返回空标签）
+func splitImageTag(ref string) (repo, tag string) {
+	if strings.Contains(ref, "@") {
+		return ref, ""
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// 部署请求：ports、volumes 以容器端口/路径为键，值为主机端口/路径（未提供时使用模板默认值，值为空字符串表示不映射）
+type AppDeployRequest struct {
+	Template string            `json:"template"`
+	Name     string            `json:"name"`
+	Tag      string            `json:"tag"`
+	Restart  string            `json:"restart"`
+	Network  string            `json:"network"`
+	Ports    map[string]string `json:"ports"`
+	Env      map[string]string `json:"env"`
+	Volumes  map[string]string `json:"volumes"`
+}
+
+// 按模板和用户输入生成创建容器的参数
+func (app *AppTemplate) instantiate(req *AppDeployRequest) (*ContainerRunRequest, error) {
+	if req.Name == "" {
+		req.Name = app.ID
+	}
+	if !appNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("容器名称无效: %s", req.Name)
+	}
+	expand := func(s string) string { return strings.ReplaceAll(s, "${name}", req.Name) }
+
+	repo, tag := splitImageTag(app.Image)
+	image := app.Image
+	if req.Tag != "" {
+		if !appTagPattern.MatchString(req.Tag) || tag == "" {
+			return nil, fmt.Errorf("镜像标签无效: %s", req.Tag)
+		}
+		tag = req.Tag
+		image = repo + ":" + tag
+	}
+
+	run := &ContainerRunRequest{
+		Image:   image,
+		Name:    req.Name,
+		Restart: app.Restart,
+		Network: req.Network,
+		Labels:  map[string]string{appTemplateLabel: app.ID, appTagLabel: tag},
+	}
+	if req.Restart != "" {
+		run.Restart = req.Restart
+	}
+
+	for _, p := range app.Ports {
+		host, ok := req.Ports[p.Container]
+		if !ok {
+			host = p.Host
+		}
+		if host == "" {
+			continue
+		}
+		if !validPort(host) {
+			return nil, fmt.Errorf("端口无效: %s", host)
+		}
+		run.Ports = append(run.Ports, struct {
+			Host      string `json:"host"`
+			Container string `json:"container"`
+		}{host, p.Container})
+	}
+
+	known := make(map[string]bool, len(app.Env))
+	for _, e := range app.Env {
+		known[e.Key] = true
+		value, ok := req.Env[e.Key]
+		if !ok {
+			value = expand(e.Default)
+		}
+		if value == "" {
+			if e.Required {
+				return nil, fmt.Errorf("缺少必填环境变量: %s（%s）", e.Key, e.Prompt)
+			}
+			continue
+		}
+		run.Envs = append(run.Envs, struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}{e.Key, value})
+	}
+	// 模板外的环境变量按名称排序后追加
+	extra := make([]string, 0, len(req.Env))
+	for key := range req.Env {
+		if !known[key] {
+			if !appEnvPattern.MatchString(key) {
+				return nil, fmt.Errorf("环境变量名无效: %s", key)
+			}
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	for _, key := range extra {
+		run.Envs = append(run.Envs, struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}{key, req.Env[key]})
+	}
+
+	for _, v := range app.Volumes {
+		host, ok := req.Volumes[v.Container]
+		if !ok {
+			host = expand(v.Host)
+		}
+		if host == "" {
+			continue
+		}
+		if strings.Contains(host, ":") {
+			return nil, fmt.Errorf("主机路径无效: %s", host)
+		}
+		run.Volumes = append(run.Volumes, struct {
+			Host      string `json:"host"`
+			Container string `json:"container"`
+		}{host, v.Container})
+	}
+	return run, nil
+}
+
+// 模板列表
+func handleApps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	apps, err := listAppTemplates()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取应用模板失败: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apps)
+}
+
+// 添加或修改用户模板（内置模板不能修改，ID 不能与内置模板相同）
+func handleAppTemplateSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var app AppTemplate
+	if err := json.NewDecoder(r.Body).Decode(&app); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	app.Builtin = false
+	if err := app.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	existing, err := findAppTemplate(app.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取应用模板失败: %v", err))
+		return
+	}
+	if existing != nil && existing.Builtin {
+		writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("内置模板不能修改: %s", app.ID))
+		return
+	}
+
+	creating := strings.HasSuffix(r.URL.Path, "/create")
+	if creating && existing != nil {
+		writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("模板已存在: %s", app.ID))
+		return
+	}
+	if !creating && existing == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("模板不存在: %s", app.ID))
+		return
+	}
+
+	data, _ := json.Marshal(app)
+	action := "app_template_create"
+	if creating {
+		_, err = authDB.Exec("INSERT INTO app_templates (id, template) VALUES (?, ?)", app.ID, string(data))
+	} else {
+		action = "app_template_update"
+		_, err = authDB.Exec("UPDATE app_templates SET template = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", string(data), app.ID)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), action, app.ID, app.Image, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app)
+}
+
+// 删除用户模板（已部署的容器不受影响）
+func handleAppTemplateDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	for _, app := range builtinApps {
+		if app.ID == req.ID {
+			writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("内置模板不能删除: %s", req.ID))
+			return
+		}
+	}
+	res, err := authDB.Exec("DELETE FROM app_templates WHERE id = ?", req.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("模板不存在: %s", req.ID))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "app_template_delete", req.ID, "", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 按模板部署容器
+func handleAppDeploy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req AppDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Template == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	app, err := findAppTemplate(req.Template)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取应用模板失败: %v", err))
+		return
+	}
+	if app == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("模板不存在: %s", req.Template))
+		return
+	}
+	run, err := app.instantiate(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return
+	}
+
+	id, err := runContainer(r.Context(), run)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "app_deploy", run.Name, app.ID+" "+run.Image, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "success",
+		"id":       id,
+		"name":     run.Name,
+		"image":    run.Image,
+		"template": app.ID,
+	})
+}
+
+// 升级按模板部署的容器：拉取新标签（未指定时重新拉取当前标签），按原配置重建，新容器未正常运行时回滚
+func handleAppUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		Tag  string `json:"tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	if req.Tag != "" && !appTagPattern.MatchString(req.Tag) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("镜像标签无效: %s", req.Tag))
+		return
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+	cli := getDockerClient()
+
+	info, err := cli.ContainerInspect(ctx, req.Name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("容器不存在: %s", req.Name))
+		return
+	}
+	templateID := info.Config.Labels[appTemplateLabel]
+	if templateID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "该容器不是通过应用模板部署的")
+		return
+	}
+	repo, tag := splitImageTag(info.Config.Image)
+	if tag == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器使用 digest 引用镜像，无法升级")
+		return
+	}
+	if req.Tag != "" {
+		tag = req.Tag
+	}
+	ref := repo + ":" + tag
+
+	reader, err := cli.ImagePull(ctx, ref, types.ImagePullOptions{RegistryAuth: registryAuthFor(ref)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("拉取镜像失败: %v", err))
+		return
+	}
+	_, err = io.Copy(io.Discard, reader)
+	reader.Close()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("拉取镜像失败: %v", err))
+		return
+	}
+	pulled, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("拉取镜像失败: %v", err))
+		return
+	}
+
+	result := map[string]string{"name": req.Name, "image": ref, "template": templateID}
+	if pulled.ID == info.Image {
+		result["status"] = "up_to_date"
+	} else {
+		info.Config.Labels[appTagLabel] = tag
+		status, err := recreateWithImage(ctx, info, ref)
+		InvalidateContainers()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("升级失败（%s）: %v", status, err))
+			return
+		}
+		result["status"] = status
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "app_upgrade", req.Name, ref+" "+result["status"], r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
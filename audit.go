@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// 初始化审计日志表
+func initAuditLog() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		username TEXT,
+		action TEXT NOT NULL,
+		target TEXT,
+		detail TEXT,
+		remote_addr TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log(time);
+	CREATE TABLE IF NOT EXISTS terminal_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT,
+		container_id TEXT NOT NULL,
+		shell TEXT,
+		started_at INTEGER NOT NULL,
+		ended_at INTEGER,
+		bytes INTEGER NOT NULL DEFAULT 0,
+		file_path TEXT,
+		remote_addr TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_terminal_sessions_started ON terminal_sessions(started_at);`)
+	if err != nil {
+		return fmt.Errorf("创建审计日志表失败: %v", err)
+	}
+	return nil
+}
+
+// 写入审计日志（失败只记录到运行日志，不影响业务）
+func writeAuditLog(username, action, target, detail, remoteAddr string) {
+	log.Printf("[Audit] user=%s action=%s target=%s detail=%s remote=%s", username, action, target, detail, remoteAddr)
+
+	_, err := authDB.Exec(
+		"INSERT INTO audit_log (time, username, action, target, detail, remote_addr) VALUES (?, ?, ?, ?, ?, ?)",
+		time.Now().Unix(), username, action, target, detail, remoteAddr,
+	)
+	if err != nil {
+		log.Printf("[Audit] Save audit log failed: %v", err)
+	}
+}
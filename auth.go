@@ -19,17 +19,6 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-// 获取节点密钥（从环境变量或使用默认值）
-func getNodeSecret() string {
-	secret := os.Getenv("NODE_SECRET")
-	if secret == "" {
-		// 默认密钥（生产环境必须设置环境变量）
-		secret = "rabbit-panel-node-secret-change-in-production"
-		log.Println("警告: 使用默认节点密钥，生产环境请设置 NODE_SECRET 环境变量")
-	}
-	return secret
-}
-
 // 生成节点认证 Token
 func generateNodeToken(nodeID string) string {
 	h := hmac.New(sha256.New, []byte(nodeSecret))
@@ -58,8 +47,8 @@ func generateNodeTokenForTime(nodeID string, t time.Time) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-// 节点间通信密钥（用于 Master 和 Worker 之间的认证）
-var nodeSecret = getNodeSecret()
+// 节点间通信密钥（用于 Master 和 Worker 之间的认证，由 applyConfig 设置）
+var nodeSecret string
 
 // 节点认证中间件
 func nodeAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -69,13 +58,13 @@ func nodeAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		nodeToken := r.Header.Get("X-Node-Token")
 		
 		if nodeID == "" || nodeToken == "" {
-			http.Error(w, `{"error": "节点认证失败: 缺少节点ID或Token"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "节点认证失败: 缺少节点ID或Token")
 			return
 		}
 		
 		// 验证 Token
 		if !verifyNodeToken(nodeID, nodeToken) {
-			http.Error(w, `{"error": "节点认证失败: Token无效"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "节点认证失败: Token无效")
 			return
 		}
 		
@@ -103,7 +92,7 @@ func authOrNodeAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// JWT 密钥（生产环境应该使用环境变量或配置文件）
+// JWT 密钥（jwt_secret / JWT_SECRET，未设置时使用默认值，生产环境必须修改）
 var jwtSecret = []byte("rabbit-panel-secret-key-change-in-production")
 
 // 会话管理
@@ -151,16 +140,18 @@ type LoginResponse struct {
 // 全局数据库连接
 var authDB *sql.DB
 
+// 数据目录（data_dir / DATA_DIR，默认 ./data）
+var dataDir = "./data"
+
 // 初始化认证数据库
 func initAuthDB() error {
 	// 确保 data 目录存在
-	dataDir := "./data"
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return fmt.Errorf("创建数据目录失败: %v", err)
 	}
 
 	var err error
-	authDB, err = sql.Open("sqlite", "./data/auth.db")
+	authDB, err = sql.Open("sqlite", appConfig.DBPath)
 	if err != nil {
 		return fmt.Errorf("打开数据库失败: %v", err)
 	}
@@ -180,6 +171,9 @@ func initAuthDB() error {
 	if err != nil {
 		return fmt.Errorf("创建表失败: %v", err)
 	}
+	if err := migrateUserLanguage(); err != nil {
+		return err
+	}
 
 	// 检查是否有用户，如果没有则创建默认管理员
 	var count int
@@ -367,7 +361,7 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			// 尝试从 Cookie 获取
 			cookie, err := r.Cookie("token")
 			if err != nil || cookie.Value == "" {
-				http.Error(w, `{"error": "未授权，请先登录"}`, http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "未授权，请先登录")
 				return
 			}
 			token = cookie.Value
@@ -379,16 +373,18 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// 验证 token
 		session, err := verifyToken(token)
 		if err != nil {
-			http.Error(w, `{"error": "token 无效或已过期"}`, http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "token 无效或已过期")
 			return
 		}
 
+		// 用户设置了语言偏好时覆盖 Accept-Language
+		if lang := userLanguage(session.Username); lang != "" {
+			w.Header().Set("Content-Language", lang)
+		}
+
 		// 检查是否需要修改密码（除了修改密码接口）
 		if session.NeedChangePassword && r.URL.Path != "/api/auth/change-password" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "需要修改密码",
+			writeErrorDetails(w, http.StatusForbidden, ErrCodeNeedChangePassword, "需要修改密码", map[string]bool{
 				"need_change_password": true,
 			})
 			return
@@ -404,13 +400,13 @@ func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 // 登录处理
 func handleLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
@@ -418,18 +414,14 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	user, err := verifyUser(req.Username, req.Password)
 	if err != nil {
 		log.Printf("[Auth] Login failed, user: %s, reason: %v", req.Username, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, err.Error())
 		return
 	}
 
 	// 生成 token
 	token, err := generateToken(user.Username, user.NeedChangePassword)
 	if err != nil {
-		http.Error(w, "生成 token 失败", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "生成 token 失败")
 		return
 	}
 
@@ -446,9 +438,10 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    token,
-		Path:     "/",
+		Path:     cookiePath(),
 		MaxAge:   86400, // 24小时
 		HttpOnly: true,
+		Secure:   serverUsesTLS(),
 		SameSite: http.SameSiteStrictMode,
 	})
 
@@ -466,7 +459,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 // 修改密码处理
 func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -475,7 +468,7 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	if token == "" {
 		cookie, err := r.Cookie("token")
 		if err != nil || cookie.Value == "" {
-			http.Error(w, "未授权", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "未授权")
 			return
 		}
 		token = cookie.Value
@@ -485,23 +478,19 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 
 	session, err := verifyToken(token)
 	if err != nil {
-		http.Error(w, "token 无效", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "token 无效")
 		return
 	}
 
 	var req ChangePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	// 验证新密码强度
 	if err := validatePasswordStrength(req.NewPassword); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error": err.Error(),
-		})
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -513,7 +502,7 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	).Scan(&passwordHash)
 
 	if err != nil {
-		http.Error(w, "查询用户失败", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "查询用户失败")
 		return
 	}
 
@@ -521,11 +510,7 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	if !session.NeedChangePassword {
 		err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.OldPassword))
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "旧密码错误",
-			})
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "旧密码错误")
 			return
 		}
 	}
@@ -533,7 +518,7 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	// 生成新密码哈希
 	newHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "生成密码哈希失败", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "生成密码哈希失败")
 		return
 	}
 
@@ -543,7 +528,7 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 		string(newHash), session.Username,
 	)
 	if err != nil {
-		http.Error(w, "更新密码失败", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "更新密码失败")
 		return
 	}
 
@@ -559,15 +544,16 @@ func handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    newToken,
-		Path:     "/",
+		Path:     cookiePath(),
 		MaxAge:   86400,
 		HttpOnly: true,
+		Secure:   serverUsesTLS(),
 		SameSite: http.SameSiteStrictMode,
 	})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "密码修改成功",
+		"message": localize(w, "密码修改成功"),
 		"token":   newToken,
 	})
 }
@@ -594,13 +580,13 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    "",
-		Path:     "/",
+		Path:     cookiePath(),
 		MaxAge:   -1,
 		HttpOnly: true,
 	})
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "登出成功"})
+	json.NewEncoder(w).Encode(map[string]string{"message": localize(w, "登出成功")})
 }
 
 // 获取当前用户信息
@@ -609,7 +595,7 @@ func handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	if token == "" {
 		cookie, err := r.Cookie("token")
 		if err != nil || cookie.Value == "" {
-			http.Error(w, "未授权", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "未授权")
 			return
 		}
 		token = cookie.Value
@@ -619,7 +605,7 @@ func handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 
 	session, err := verifyToken(token)
 	if err != nil {
-		http.Error(w, "token 无效", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "token 无效")
 		return
 	}
 
@@ -627,6 +613,7 @@ func handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"username":            session.Username,
 		"need_change_password": session.NeedChangePassword,
+		"language":             userLanguage(session.Username),
 	})
 }
 
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// 容器自动更新：定期检查已开启自动更新的容器的镜像标签，仓库中的镜像有变化时拉取并重建容器，
+// 新容器未能正常运行（健康检查失败或退出）时回滚到旧容器
+// 按容器名称记录（重建后容器 ID 会变化）
+
+// 自动更新结果
+const (
+	autoUpdateUpdated    = "updated"     // 已更新
+	autoUpdateRolledBack = "rolled_back" // 新容器未正常运行，已回滚
+	autoUpdateFailed     = "failed"      // 检查、拉取或重建失败（旧容器保持不变或已恢复）
+)
+
+const (
+	defaultAutoUpdateInterval = 6 * 60           // 默认检查间隔（分钟）
+	minAutoUpdateInterval     = 5                // 最小检查间隔（分钟）
+	autoUpdateHealthTimeout   = 90 * time.Second // 等待新容器健康的时间
+	autoUpdateStableTime      = 10 * time.Second // 没有健康检查的容器需持续运行的时间
+	autoUpdateOldSuffix       = "-rabbit-old"    // 重建期间旧容器的临时名称后缀
+)
+
+// 自动更新设置
+type AutoUpdateSettings struct {
+	Paused          bool `json:"paused"`           // 全局暂停（暂停时不自动检查，仍可手动检查）
+	IntervalMinutes int  `json:"interval_minutes"` // 检查间隔
+}
+
+// 开启了自动更新的容器
+type AutoUpdateContainer struct {
+	Name      string `json:"name"`
+	Image     string `json:"image,omitempty"`
+	State     string `json:"state,omitempty"` // 容器不存在时为空
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// 更新记录
+type AutoUpdateRecord struct {
+	ID         int64  `json:"id"`
+	Time       int64  `json:"time"` // Unix 秒
+	Container  string `json:"container"`
+	Image      string `json:"image"`
+	OldImageID string `json:"old_image_id,omitempty"`
+	NewImageID string `json:"new_image_id,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+var (
+	autoUpdateMu       sync.RWMutex
+	autoUpdateNames    = map[string]bool{} // 开启自动更新的容器名称
+	autoUpdateRunning  atomic.Bool
+	autoUpdateLastScan atomic.Int64 // 上次检查的时间（Unix 秒，未检查过时为 0）
+)
+
+// 初始化自动更新表并启动后台检查（暂停和检查间隔保存在面板设置中）
+func initAutoUpdate() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS auto_update_containers (
+		name TEXT PRIMARY KEY,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS auto_update_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		container TEXT NOT NULL,
+		image TEXT,
+		old_image_id TEXT,
+		new_image_id TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		duration_ms INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_auto_update_history_time ON auto_update_history(time);`)
+	if err != nil {
+		return fmt.Errorf("创建自动更新表失败: %v", err)
+	}
+
+	rows, err := authDB.Query("SELECT name FROM auto_update_containers")
+	if err != nil {
+		return fmt.Errorf("读取自动更新容器失败: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			autoUpdateNames[name] = true
+		}
+	}
+
+	go runAutoUpdateLoop()
+	return nil
+}
+
+func currentAutoUpdateSettings() AutoUpdateSettings {
+	return AutoUpdateSettings{
+		Paused:          settingBool("auto_update.paused"),
+		IntervalMinutes: int(settingInt("auto_update.interval_minutes")),
+	}
+}
+
+// 容器是否开启了自动更新
+func autoUpdateEnabled(name string) bool {
+	autoUpdateMu.RLock()
+	defer autoUpdateMu.RUnlock()
+	return autoUpdateNames[name]
+}
+
+func autoUpdateContainerNames() []string {
+	autoUpdateMu.RLock()
+	defer autoUpdateMu.RUnlock()
+	names := make([]string, 0, len(autoUpdateNames))
+	for name := range autoUpdateNames {
+		names = append(names, name)
+	}
+	return names
+}
+
+// 后台检查：每分钟判断是否到了检查时间，暂停时跳过
+func runAutoUpdateLoop() {
+	started := time.Now() // 启动后等待一个间隔再检查
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		settings := currentAutoUpdateSettings()
+		if settings.Paused || !dockerAvailable() {
+			continue
+		}
+		last := time.Unix(autoUpdateLastScan.Load(), 0)
+		if last.Before(started) {
+			last = started
+		}
+		if time.Since(last) < time.Duration(settings.IntervalMinutes)*time.Minute {
+			continue
+		}
+		startAutoUpdateScan()
+	}
+}
+
+// 在后台检查所有开启自动更新的容器（正在检查时返回 false）
+func startAutoUpdateScan() bool {
+	if !autoUpdateRunning.CompareAndSwap(false, true) {
+		return false
+	}
+	autoUpdateLastScan.Store(time.Now().Unix())
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		defer autoUpdateRunning.Store(false)
+		defer recoverGoroutine(serverCtx, "auto update")
+
+		for _, name := range autoUpdateContainerNames() {
+			if serverCtx.Err() != nil {
+				return
+			}
+			updateContainer(serverCtx, name)
+		}
+	}()
+	return true
+}
+
+// 检查并更新单个容器，有更新或出错时写入记录并发送通知
+func updateContainer(ctx context.Context, name string) {
+	start := time.Now()
+	rec, err := checkAndUpdateContainer(ctx, name)
+	if rec == nil {
+		if err != nil {
+			log.Printf("[AutoUpdate] Skip %s: %v", name, err)
+		}
+		return
+	}
+	rec.Time = start.Unix()
+	rec.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	log.Printf("[AutoUpdate] %s (%s): %s %s", name, rec.Image, rec.Status, rec.Error)
+
+	if _, err := authDB.Exec(
+		"INSERT INTO auto_update_history (time, container, image, old_image_id, new_image_id, status, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		rec.Time, rec.Container, rec.Image, rec.OldImageID, rec.NewImageID, rec.Status, rec.Error, rec.DurationMs,
+	); err != nil {
+		log.Printf("[AutoUpdate] Save history failed: %v", err)
+	}
+	cutoff := time.Now().Add(-settingDays("retention.auto_update_history_days")).Unix()
+	if _, err := authDB.Exec("DELETE FROM auto_update_history WHERE time < ?", cutoff); err != nil {
+		log.Printf("[AutoUpdate] Prune history failed: %v", err)
+	}
+
+	n := Notification{
+		Event:  "container_auto_update",
+		Fields: map[string]string{"container": name, "image": rec.Image, "status": rec.Status},
+	}
+	switch rec.Status {
+	case autoUpdateUpdated:
+		n.Level, n.Title = notifyInfo, fmt.Sprintf("容器已自动更新: %s", name)
+		n.Message = fmt.Sprintf("%s → %s", shortImageID(rec.OldImageID), shortImageID(rec.NewImageID))
+	case autoUpdateRolledBack:
+		n.Level, n.Title, n.Message = notifyWarning, fmt.Sprintf("容器自动更新失败，已回滚: %s", name), rec.Error
+	default:
+		n.Level, n.Title, n.Message = notifyWarning, fmt.Sprintf("容器自动更新失败: %s", name), rec.Error
+	}
+	Notify(n)
+}
+
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// 检查仓库中的镜像是否有更新，有则拉取并重建容器
+// 没有更新或无法自动更新（容器不存在、未运行、镜像没有标签）时返回 nil 记录
+func checkAndUpdateContainer(ctx context.Context, name string) (*AutoUpdateRecord, error) {
+	cli := getDockerClient()
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.State.Running {
+		return nil, fmt.Errorf("容器未运行")
+	}
+	ref := info.Config.Image
+	if ref == "" || strings.HasPrefix(ref, "sha256:") {
+		return nil, fmt.Errorf("容器使用镜像 ID 创建，没有可检查的标签")
+	}
+	rec := &AutoUpdateRecord{Container: name, Image: ref, OldImageID: info.Image}
+
+	// 先比较仓库中的 digest，避免每次都拉取；仓库不支持时直接拉取，按镜像 ID 判断
+	local, _, err := cli.ImageInspectWithRaw(ctx, info.Image)
+	if err == nil {
+		if dist, err := cli.DistributionInspect(ctx, ref, registryAuthFor(ref)); err == nil {
+			for _, d := range local.RepoDigests {
+				if strings.HasSuffix(d, "@"+dist.Descriptor.Digest.String()) {
+					return nil, nil
+				}
+			}
+		}
+	}
+
+	reader, err := cli.ImagePull(ctx, ref, types.ImagePullOptions{RegistryAuth: registryAuthFor(ref)})
+	if err != nil {
+		rec.Status = autoUpdateFailed
+		return rec, fmt.Errorf("拉取镜像失败: %v", err)
+	}
+	_, err = io.Copy(io.Discard, reader)
+	reader.Close()
+	if err != nil {
+		rec.Status = autoUpdateFailed
+		return rec, fmt.Errorf("拉取镜像失败: %v", err)
+	}
+	pulled, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		rec.Status = autoUpdateFailed
+		return rec, fmt.Errorf("读取镜像信息失败: %v", err)
+	}
+	if pulled.ID == info.Image {
+		return nil, nil
+	}
+	rec.NewImageID = pulled.ID
+	InvalidateImages()
+
+	rec.Status, err = recreateWithImage(ctx, info, ref)
+	InvalidateContainers()
+	return rec, err
+}
+
+// 用新镜像重建容器，保留原容器的配置、网络和标签；新容器未正常运行时回滚
+// 过程：旧容器改名并停止 → 按原配置创建新容器 → 连接原网络 → 启动并等待健康 → 删除旧容器
+func recreateWithImage(ctx context.Context, info types.ContainerJSON, ref string) (string, error) {
+	cli := getDockerClient()
+	name := strings.TrimPrefix(info.Name, "/")
+	oldName := name + autoUpdateOldSuffix
+
+	if err := cli.ContainerRename(ctx, info.ID, oldName); err != nil {
+		return autoUpdateFailed, fmt.Errorf("重命名旧容器失败: %v", err)
+	}
+	// 恢复旧容器（新容器已删除或未创建）
+	restore := func(cause error) (string, error) {
+		rctx := context.WithoutCancel(ctx)
+		if err := cli.ContainerRename(rctx, info.ID, name); err != nil {
+			return autoUpdateFailed, fmt.Errorf("%v；恢复旧容器名称失败: %v", cause, err)
+		}
+		if err := cli.ContainerStart(rctx, info.ID, types.ContainerStartOptions{}); err != nil {
+			return autoUpdateFailed, fmt.Errorf("%v；启动旧容器失败: %v", cause, err)
+		}
+		return autoUpdateRolledBack, cause
+	}
+
+	timeout := 10
+	if err := cli.ContainerStop(ctx, info.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		return restore(fmt.Errorf("停止旧容器失败: %v", err))
+	}
+
+	created, err := createFromInspect(ctx, info, ref, name)
+	if err != nil {
+		return restore(err)
+	}
+	removeNew := func() {
+		cli.ContainerRemove(context.WithoutCancel(ctx), created, types.ContainerRemoveOptions{Force: true})
+	}
+	if err := cli.ContainerStart(ctx, created, types.ContainerStartOptions{}); err != nil {
+		removeNew()
+		return restore(fmt.Errorf("启动新容器失败: %v", err))
+	}
+	if err := waitContainerHealthy(ctx, created); err != nil {
+		removeNew()
+		return restore(err)
+	}
+
+	if err := cli.ContainerRemove(ctx, info.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+		log.Printf("[AutoUpdate] Remove old container %s failed: %v", oldName, err)
+	}
+	return autoUpdateUpdated, nil
+}
+
+// 按 inspect 结果创建容器（保留环境变量、挂载、网络、标签、重启策略等），image 为空时使用原镜像
+// 创建时只能指定一个网络，其余网络在创建后连接；连接失败时删除新容器。返回新容器 ID
+func createFromInspect(ctx context.Context, info types.ContainerJSON, image, name string) (string, error) {
+	cli := getDockerClient()
+	config := *info.Config
+	if image != "" {
+		config.Image = image
+	}
+	if len(info.ID) >= 12 && config.Hostname == info.ID[:12] {
+		config.Hostname = "" // 默认主机名为容器 ID，由新容器重新生成
+	}
+
+	var networking *network.NetworkingConfig
+	var extraNetworks []string
+	mode := info.HostConfig.NetworkMode
+	for netName, ep := range info.NetworkSettings.Networks {
+		settings := copyEndpointSettings(ep)
+		if networking == nil && (netName == string(mode) || (mode.IsDefault() && netName == "bridge")) {
+			networking = &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{netName: settings}}
+			continue
+		}
+		if !mode.IsHost() && !mode.IsNone() && !mode.IsContainer() {
+			extraNetworks = append(extraNetworks, netName)
+		}
+	}
+
+	created, err := cli.ContainerCreate(ctx, &config, info.HostConfig, networking, nil, name)
+	if err != nil {
+		return "", fmt.Errorf("创建新容器失败: %v", err)
+	}
+	for _, netName := range extraNetworks {
+		if err := cli.NetworkConnect(ctx, netName, created.ID, copyEndpointSettings(info.NetworkSettings.Networks[netName])); err != nil {
+			cli.ContainerRemove(context.WithoutCancel(ctx), created.ID, types.ContainerRemoveOptions{Force: true})
+			return "", fmt.Errorf("连接网络 %s 失败: %v", netName, err)
+		}
+	}
+	return created.ID, nil
+}
+
+// 复制网络端点的用户配置（别名、固定 IP 等），不包含运行时分配的地址
+func copyEndpointSettings(ep *network.EndpointSettings) *network.EndpointSettings {
+	if ep == nil {
+		return &network.EndpointSettings{}
+	}
+	settings := &network.EndpointSettings{
+		IPAMConfig: ep.IPAMConfig,
+		Links:      ep.Links,
+		DriverOpts: ep.DriverOpts,
+	}
+	// 去掉 Docker 自动添加的短 ID 别名
+	for _, alias := range ep.Aliases {
+		if len(alias) != 12 {
+			settings.Aliases = append(settings.Aliases, alias)
+		}
+	}
+	return settings
+}
+
+// 等待新容器正常运行：有健康检查时等待 healthy，否则需持续运行一段时间且没有重启
+func waitContainerHealthy(ctx context.Context, id string) error {
+	cli := getDockerClient()
+	deadline := time.Now().Add(autoUpdateHealthTimeout)
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+
+		info, err := cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return fmt.Errorf("检查新容器状态失败: %v", err)
+		}
+		state := info.State
+		if !state.Running || state.Restarting || info.RestartCount > 0 {
+			return fmt.Errorf("新容器未能正常运行（状态 %s，退出码 %d）", state.Status, state.ExitCode)
+		}
+		if state.Health != nil {
+			switch state.Health.Status {
+			case types.Healthy:
+				return nil
+			case types.Unhealthy:
+				return fmt.Errorf("新容器健康检查失败")
+			}
+		} else if time.Since(started) >= autoUpdateStableTime {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待新容器健康超时（%s）", autoUpdateHealthTimeout)
+		}
+	}
+}
+
+// 自动更新状态：设置、容器列表、是否正在检查
+func handleAutoUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	rows, err := authDB.Query("SELECT name, created_at FROM auto_update_containers ORDER BY name")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	defer rows.Close()
+	containers := make([]AutoUpdateContainer, 0)
+	for rows.Next() {
+		var c AutoUpdateContainer
+		if rows.Scan(&c.Name, &c.CreatedAt) != nil {
+			continue
+		}
+		if dockerAvailable() {
+			if info, err := getDockerClient().ContainerInspect(r.Context(), c.Name); err == nil {
+				c.Image, c.State = info.Config.Image, info.State.Status
+			}
+		}
+		containers = append(containers, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings":   currentAutoUpdateSettings(),
+		"containers": containers,
+		"running":    autoUpdateRunning.Load(),
+		"last_check": autoUpdateLastScan.Load(),
+	})
+}
+
+// 修改设置（全局暂停、检查间隔）
+func handleAutoUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	settings := currentAutoUpdateSettings()
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	changes, err := validateSettings(map[string]interface{}{
+		"auto_update.paused":           settings.Paused,
+		"auto_update.interval_minutes": settings.IntervalMinutes,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if _, err := saveSettings(changes); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+		return
+	}
+	data, _ := json.Marshal(settings)
+	writeAuditLog(r.Header.Get("X-Username"), "auto_update_settings", "", string(data), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// 开启或关闭容器的自动更新
+func handleAutoUpdateContainer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"` // 容器名称或 ID
+		Enabled bool   `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	name := strings.TrimPrefix(req.Name, "/")
+	if req.Enabled {
+		// 开启时按名称记录，传入 ID 时转换为名称
+		if !dockerAvailable() {
+			writeDockerUnavailable(w)
+			return
+		}
+		info, err := getDockerClient().ContainerInspect(r.Context(), req.Name)
+		if err != nil {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("容器不存在: %s", req.Name))
+			return
+		}
+		name = strings.TrimPrefix(info.Name, "/")
+		if strings.HasPrefix(info.Config.Image, "sha256:") {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器使用镜像 ID 创建，没有可检查的标签")
+			return
+		}
+		_, err = authDB.Exec("INSERT OR IGNORE INTO auto_update_containers (name) VALUES (?)", name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+			return
+		}
+	} else if _, err := authDB.Exec("DELETE FROM auto_update_containers WHERE name = ?", name); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+		return
+	}
+
+	autoUpdateMu.Lock()
+	if req.Enabled {
+		autoUpdateNames[name] = true
+	} else {
+		delete(autoUpdateNames, name)
+	}
+	autoUpdateMu.Unlock()
+	InvalidateContainers()
+	writeAuditLog(r.Header.Get("X-Username"), "auto_update_container", name, strconv.FormatBool(req.Enabled), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "enabled": req.Enabled})
+}
+
+// 立即检查（暂停时也可手动检查）
+func handleAutoUpdateCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return
+	}
+	if !startAutoUpdateScan() {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "正在检查更新")
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "auto_update_check", "", "", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+}
+
+// 更新记录（?container=web&limit=100，最新的在前）
+func handleAutoUpdateHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	q := r.URL.Query()
+	query := "SELECT id, time, container, image, old_image_id, new_image_id, status, error, duration_ms FROM auto_update_history WHERE 1 = 1"
+	var args []interface{}
+	if v := q.Get("container"); v != "" {
+		query += " AND container = ?"
+		args = append(args, v)
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := authDB.Query(query, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	records := make([]AutoUpdateRecord, 0)
+	for rows.Next() {
+		var rec AutoUpdateRecord
+		var image, oldID, newID, errMsg sql.NullString
+		var duration sql.NullInt64
+		if err := rows.Scan(&rec.ID, &rec.Time, &rec.Container, &image, &oldID, &newID, &rec.Status, &errMsg, &duration); err != nil {
+			continue
+		}
+		rec.Image, rec.OldImageID, rec.NewImageID, rec.Error = image.String, oldID.String, newID.String, errMsg.String
+		rec.DurationMs = duration.Int64
+		records = append(records, rec)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// 面板数据备份与恢复
+// 备份为 tar.gz：manifest.json、auth.db（通过 SQLite 在线备份 API 导出，与正在进行的写入一致）和 compose_projects/
+// 用户、面板设置（含镜像仓库凭据）、定时任务、通知渠道、自动更新等数据都保存在 auth.db 中
+
+const (
+	panelSchemaVersion = 1 // 数据格式版本：表结构有不兼容的变化时递增，旧版本面板拒绝恢复新版本的备份
+
+	backupManifestName = "manifest.json"
+	backupDBName       = "auth.db"
+	backupComposeDir   = "compose_projects"
+)
+
+// 备份清单
+type BackupManifest struct {
+	SchemaVersion int      `json:"schema_version"`
+	CreatedAt     string   `json:"created_at"`
+	Host          string   `json:"host"`
+	Contents      []string `json:"contents"`
+}
+
+var backupMu sync.Mutex // 备份和恢复互斥
+
+// 在线导出数据库到 dst（SQLite 备份 API，不阻塞其他连接的读写）
+func snapshotAuthDB(ctx context.Context, dst string) error {
+	return withSQLiteBackup(ctx, func(c sqliteBackupConn) (*sqlite.Backup, error) {
+		return c.NewBackup(dst)
+	})
+}
+
+// 用 src 的内容替换当前数据库（在一个 SQLite 事务中完成，失败时当前数据不变）
+func restoreAuthDB(ctx context.Context, src string) error {
+	return withSQLiteBackup(ctx, func(c sqliteBackupConn) (*sqlite.Backup, error) {
+		return c.NewRestore(src)
+	})
+}
+
+type sqliteBackupConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+func withSQLiteBackup(ctx context.Context, start func(c sqliteBackupConn) (*sqlite.Backup, error)) error {
+	conn, err := authDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(sqliteBackupConn)
+		if !ok {
+			return fmt.Errorf("数据库驱动不支持在线备份")
+		}
+		b, err := start(c)
+		if err != nil {
+			return err
+		}
+		for {
+			more, err := b.Step(-1)
+			if err != nil {
+				b.Finish()
+				return err
+			}
+			if !more {
+				break
+			}
+		}
+		return b.Finish()
+	})
+}
+
+// 导出面板数据（GET，返回 tar.gz）
+func handleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	tmpDir, err := os.MkdirTemp(dataDir, ".backup-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建临时目录失败: %v", err))
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbFile := filepath.Join(tmpDir, backupDBName)
+	if err := snapshotAuthDB(r.Context(), dbFile); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("备份数据库失败: %v", err))
+		return
+	}
+
+	now := time.Now()
+	host, _ := os.Hostname()
+	manifest := BackupManifest{
+		SchemaVersion: panelSchemaVersion,
+		CreatedAt:     now.Format(time.RFC3339),
+		Host:          host,
+		Contents:      []string{backupDBName},
+	}
+	includeCompose := dirExists(composeBaseDir)
+	if includeCompose {
+		manifest.Contents = append(manifest.Contents, backupComposeDir)
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "rabbit-panel-backup-"+now.Format("20060102-150405")+".tar.gz"))
+
+	// 响应头已发送，之后的错误只能记录日志（客户端会收到不完整的归档，解压时报错）
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err = writeBackupArchive(tw, &manifest, dbFile, includeCompose)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		log.Printf("[Backup] Write archive failed: %v", err)
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "panel_backup", "", strings.Join(manifest.Contents, ","), r.RemoteAddr)
+}
+
+func writeBackupArchive(tw *tar.Writer, manifest *BackupManifest, dbFile string, includeCompose bool) error {
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    backupManifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if err := addFileToTar(tw, dbFile, backupDBName); err != nil {
+		return err
+	}
+
+	if !includeCompose {
+		return nil
+	}
+	// 只备份目录和普通文件（符号链接可能指向目录外）
+	return filepath.WalkDir(composeBaseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(composeBaseDir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(backupComposeDir, filepath.ToSlash(rel))
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			hdr, _ := tar.FileInfoHeader(info, "")
+			hdr.Name = name + "/"
+			return tw.WriteHeader(hdr)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return addFileToTar(tw, p, name)
+	})
+}
+
+func addFileToTar(tw *tar.Writer, file, name string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// 解压备份：auth.db 和 manifest.json 写入 dbDir，compose_projects/ 写入 composeDir
+func extractBackup(r io.Reader, dbDir, composeDir string) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("不是有效的备份文件: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取备份失败: %w", err)
+		}
+		name, err := safeArchiveName(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		var dest string
+		switch {
+		case name == "":
+			continue
+		case name == backupManifestName || name == backupDBName:
+			dest = filepath.Join(dbDir, name)
+		case name == backupComposeDir:
+			continue
+		case strings.HasPrefix(name, backupComposeDir+"/"):
+			dest = filepath.Join(composeDir, filepath.FromSlash(strings.TrimPrefix(name, backupComposeDir+"/")))
+		default:
+			return nil, fmt.Errorf("备份包含未知条目: %s", name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return nil, err
+			}
+			f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0777|0600)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("读取备份失败: %w", err)
+			}
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dbDir, backupManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("备份缺少 %s，不是面板备份文件", backupManifestName)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.SchemaVersion <= 0 {
+		return nil, fmt.Errorf("备份清单无效")
+	}
+	if _, err := os.Stat(filepath.Join(dbDir, backupDBName)); err != nil {
+		return nil, fmt.Errorf("备份缺少 %s", backupDBName)
+	}
+	return &manifest, nil
+}
+
+// 检查备份中的数据库是否完整可用
+func checkBackupDB(file string) error {
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("备份数据库无法读取: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("备份数据库已损坏: %s", result)
+	}
+	var users int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&users); err != nil || users == 0 {
+		return fmt.Errorf("备份数据库中没有用户")
+	}
+	return nil
+}
+
+// 目录是否存在
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// 恢复面板数据（POST multipart：confirm=true 需在 file 之前提交）
+// 当前数据先移到 data_dir/pre-restore-<时间>/（数据库）和 <compose_dir>.pre-restore-<时间>（编排项目），
+// 数据库通过 SQLite 备份 API 整体替换；任一步失败时恢复原状
+func handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	r.Body = http.MaxBytesReader(w, r.Body, uploadMaxSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	fields := make(map[string]string)
+	var file io.Reader
+	for file == nil {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "缺少上传文件")
+			return
+		}
+		if err != nil {
+			if !writeUploadTooLarge(w, err) {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("读取上传内容失败: %v", err))
+			}
+			return
+		}
+		if part.FormName() != "file" {
+			value, _ := io.ReadAll(io.LimitReader(part, 4096))
+			fields[part.FormName()] = string(value)
+			continue
+		}
+		file = part
+	}
+	if fields["confirm"] != "true" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "恢复会覆盖当前的面板数据，请确认（confirm=true 需在文件之前提交）")
+		return
+	}
+
+	// 解压到与目标同一文件系统的临时目录，之后通过重命名替换
+	stageDir, err := os.MkdirTemp(dataDir, ".restore-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建临时目录失败: %v", err))
+		return
+	}
+	defer os.RemoveAll(stageDir)
+	composeStage := filepath.Clean(composeBaseDir) + ".restore-tmp"
+	os.RemoveAll(composeStage)
+	defer os.RemoveAll(composeStage)
+
+	manifest, err := extractBackup(file, stageDir, composeStage)
+	if err != nil {
+		if !writeUploadTooLarge(w, err) {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		}
+		return
+	}
+	if manifest.SchemaVersion > panelSchemaVersion {
+		writeError(w, http.StatusUnprocessableEntity, ErrCodeBackupTooNew,
+			fmt.Sprintf("备份的数据版本 (%d) 高于当前面板支持的版本 (%d)，请先升级面板", manifest.SchemaVersion, panelSchemaVersion))
+		return
+	}
+	stagedDB := filepath.Join(stageDir, backupDBName)
+	if err := checkBackupDB(stagedDB); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	suffix := "pre-restore-" + time.Now().Format("20060102-150405")
+	asideDir := filepath.Join(dataDir, suffix)
+	if err := os.MkdirAll(asideDir, 0700); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建目录失败: %v", err))
+		return
+	}
+	if err := snapshotAuthDB(r.Context(), filepath.Join(asideDir, backupDBName)); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存当前数据库失败: %v", err))
+		return
+	}
+
+	// 替换编排项目目录（备份中没有时保留当前目录）
+	hasCompose := false
+	for _, c := range manifest.Contents {
+		hasCompose = hasCompose || c == backupComposeDir
+	}
+	composeAside := ""
+	if hasCompose {
+		if err := os.MkdirAll(composeStage, 0755); err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建目录失败: %v", err))
+			return
+		}
+		if dirExists(composeBaseDir) {
+			composeAside = filepath.Clean(composeBaseDir) + "." + suffix
+			if err := os.Rename(composeBaseDir, composeAside); err != nil {
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("移动当前编排项目失败: %v", err))
+				return
+			}
+		}
+		if err := os.Rename(composeStage, composeBaseDir); err != nil {
+			if composeAside != "" {
+				os.Rename(composeAside, composeBaseDir)
+			}
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("替换编排项目失败: %v", err))
+			return
+		}
+	}
+
+	if err := restoreAuthDB(r.Context(), stagedDB); err != nil {
+		if hasCompose {
+			os.RemoveAll(composeBaseDir)
+			if composeAside != "" {
+				os.Rename(composeAside, composeBaseDir)
+			}
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("恢复数据库失败: %v", err))
+		return
+	}
+
+	log.Printf("[Backup] Restored backup from %s (%s), previous data moved to %s", manifest.Host, manifest.CreatedAt, asideDir)
+	writeAuditLog(r.Header.Get("X-Username"), "panel_restore", manifest.Host, manifest.CreatedAt, r.RemoteAddr)
+
+	previous := []string{asideDir}
+	if composeAside != "" {
+		previous = append(previous, composeAside)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":           "success",
+		"manifest":         manifest,
+		"previous_data":    previous,
+		"restart_required": true, // 设置、任务、通知渠道等在启动时加载，重启后生效
+	})
+}
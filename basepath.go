@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// URL 前缀（base_path / BASE_PATH，如 /docker），为空时挂载在根路径
+var basePath string
+
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// 规范化 URL 前缀：去掉末尾的 /，"/" 视为空
+func normalizeBasePath(p string) (string, error) {
+	p = strings.TrimRight(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	if !basePathPattern.MatchString(p) || strings.Contains(p, "/..") || strings.Contains(p, "/./") || strings.HasSuffix(p, "/.") {
+		return "", fmt.Errorf("base_path 配置无效: %s（应为 /path 形式）", p)
+	}
+	return p, nil
+}
+
+// Cookie 的 Path（限定在 URL 前缀下）
+func cookiePath() string {
+	return basePath + "/"
+}
+
+// 去掉请求路径中的 URL 前缀后交给后续处理器，前缀以外的路径返回 404
+// 访问不带 / 的前缀（如 /docker）时重定向到 /docker/
+func withBasePath(next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	stripped := http.StripPrefix(basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// 在首页注入 <base> 标签，前端的资源和 API 地址都是相对路径，以此解析到 URL 前缀下
+func injectBaseTag(page []byte) ([]byte, error) {
+	if !bytes.Contains(page, []byte("<head>")) {
+		return nil, fmt.Errorf("index.html 缺少 <head> 标签")
+	}
+	tag := fmt.Sprintf("<head>\n    <base href=\"%s\">", html.EscapeString(cookiePath()))
+	return bytes.Replace(page, []byte("<head>"), []byte(tag), 1), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// 事件流正常时的缓存有效期（容器和镜像的变化由 Docker 事件触发失效，TTL 只作兜底）
+const eventDrivenCacheTTL = 60 * time.Second
+
+// Docker 事件流是否正常连接（断开期间退回到 cache_ttl 的短有效期）
+var dockerEventsConnected atomic.Bool
+
+// 容器列表缓存有效期
+func containersCacheTTL() time.Duration {
+	if dockerEventsConnected.Load() {
+		return eventDrivenCacheTTL
+	}
+	return cacheTTL
+}
+
+// 镜像列表缓存有效期
+func imagesCacheTTL() time.Duration {
+	if dockerEventsConnected.Load() {
+		return eventDrivenCacheTTL
+	}
+	return cacheTTL * 2
+}
+
+// 使容器列表缓存失效
+func InvalidateContainers() {
+	containersCache.Lock()
+	containersCache.lastFetch = time.Time{}
+	containersCache.Unlock()
+}
+
+// 使镜像列表缓存失效
+func InvalidateImages() {
+	imagesCache.Lock()
+	imagesCache.lastFetch = time.Time{}
+	imagesCache.Unlock()
+}
+
+// 根据 Docker 事件使对应的缓存失效
+func invalidateCachesForEvent(msg events.Message) {
+	switch msg.Type {
+	case events.ContainerEventType:
+		// exec 事件（终端、命令执行）不影响容器列表
+		if strings.HasPrefix(string(msg.Action), "exec_") {
+			return
+		}
+		InvalidateContainers()
+	case events.ImageEventType:
+		InvalidateImages()
+	}
+}
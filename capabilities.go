@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 依赖 docker 命令行的功能：镜像构建（无命令行时改用 SDK）、docker run 命令模式、Compose 操作
+// 面板运行在容器中且只挂载了 socket 时通常没有命令行，启动时检测一次
+
+// 检测命令行的超时时间
+const cliProbeTimeout = 5 * time.Second
+
+// 命令行工具的检测结果
+type CLITool struct {
+	Available bool   `json:"available"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Hint      string `json:"hint,omitempty"` // 不可用时的解决办法
+}
+
+// 面板可用的功能
+type Capabilities struct {
+	DockerCLI  CLITool `json:"docker_cli"`
+	Compose    CLITool `json:"compose"`
+	ImageBuild string  `json:"image_build"` // cli 或 sdk（没有命令行时通过 Docker API 构建）
+	RunCommand bool    `json:"run_command"` // docker run 命令模式
+	ComposeOps bool    `json:"compose_ops"` // Compose 启动、停止、状态等操作
+}
+
+const (
+	dockerCLIHint  = "面板所在环境没有 docker 命令。容器部署时可将宿主机的 /usr/bin/docker 挂载到容器中，或使用包含 docker CLI 的镜像"
+	composeCLIHint = "docker 命令没有 compose 子命令。请安装 Docker Compose V2 插件（如 docker-compose-plugin 软件包），并挂载到 /usr/libexec/docker/cli-plugins 或 ~/.docker/cli-plugins"
+)
+
+var capabilities atomic.Pointer[Capabilities]
+
+// 检测 docker 命令和 compose 子命令
+func detectCapabilities() *Capabilities {
+	caps := &Capabilities{ImageBuild: "sdk"}
+
+	path, err := exec.LookPath("docker")
+	if err != nil {
+		caps.DockerCLI = CLITool{Error: err.Error(), Hint: dockerCLIHint}
+		caps.Compose = CLITool{Error: "docker 命令不可用", Hint: dockerCLIHint}
+		return caps
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cliProbeTimeout)
+	defer cancel()
+	// --version 只输出客户端版本，不连接守护进程
+	output, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		caps.DockerCLI = CLITool{Path: path, Error: err.Error(), Hint: dockerCLIHint}
+		caps.Compose = CLITool{Error: "docker 命令不可用", Hint: dockerCLIHint}
+		return caps
+	}
+	caps.DockerCLI = CLITool{Available: true, Path: path, Version: strings.TrimSpace(string(output))}
+	caps.ImageBuild = "cli"
+	caps.RunCommand = true
+
+	output, err = exec.CommandContext(ctx, path, "compose", "version", "--short").Output()
+	if err != nil {
+		caps.Compose = CLITool{Error: err.Error(), Hint: composeCLIHint}
+		return caps
+	}
+	caps.Compose = CLITool{Available: true, Path: path, Version: strings.TrimSpace(string(output))}
+	caps.ComposeOps = true
+	return caps
+}
+
+// 启动时检测并记录结果
+func initCapabilities() {
+	caps := detectCapabilities()
+	capabilities.Store(caps)
+	if !caps.DockerCLI.Available {
+		log.Printf("警告: 未找到 docker 命令（%s），镜像构建改用 Docker API，docker run 命令模式和 Compose 操作不可用", caps.DockerCLI.Error)
+		return
+	}
+	if !caps.Compose.Available {
+		log.Printf("警告: docker compose 不可用（%s），Compose 操作不可用", caps.Compose.Error)
+	}
+}
+
+// 当前的检测结果（未检测时视为全部可用，由命令执行时报错）
+func currentCapabilities() *Capabilities {
+	if caps := capabilities.Load(); caps != nil {
+		return caps
+	}
+	return &Capabilities{
+		DockerCLI:  CLITool{Available: true},
+		Compose:    CLITool{Available: true},
+		ImageBuild: "cli",
+		RunCommand: true,
+		ComposeOps: true,
+	}
+}
+
+// 返回命令行不可用的错误响应
+func writeCLIMissing(w http.ResponseWriter, feature string, tool CLITool) {
+	writeErrorDetails(w, http.StatusNotImplemented, ErrCodeCLIMissing, fmt.Sprintf("%s不可用: %s", feature, tool.Hint), map[string]string{
+		"error": tool.Error,
+		"hint":  tool.Hint,
+	})
+}
+
+// 需要 docker 命令的接口在命令不可用时返回 501
+func requireDockerCLI(w http.ResponseWriter, feature string) bool {
+	caps := currentCapabilities()
+	if !caps.DockerCLI.Available {
+		writeCLIMissing(w, feature, caps.DockerCLI)
+		return false
+	}
+	return true
+}
+
+// 需要 docker compose 的接口在不可用时返回 501
+func requireCompose(w http.ResponseWriter) bool {
+	caps := currentCapabilities()
+	if !caps.Compose.Available {
+		writeCLIMissing(w, "Compose 操作", caps.Compose)
+		return false
+	}
+	return true
+}
+
+// 面板可用的功能（前端据此隐藏不可用的功能）
+func handleSystemCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentCapabilities())
+}
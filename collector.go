@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"sort"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/mem"
+	psnet "github.com/shirou/gopsutil/v4/net"
+)
+
+// 系统指标采集器（Linux 默认直接读取 /proc，其他平台使用 gopsutil）
+type statsCollector interface {
+	// CPU 时间计数（总体和每个核心），顺序为 user nice system idle iowait irq softirq
+	CPUTimes() ([]uint64, [][]uint64, error)
+	MemoryUsage() (float64, error)
+	RootDiskUsage() (float64, error)
+	Disks() ([]DiskUsage, error)
+	// 每个网卡的累计收发字节
+	NetCounters() (map[string][2]uint64, error)
+	// 每个物理磁盘的累计读写字节
+	DiskIOCounters() (map[string][2]uint64, error)
+}
+
+var sysCollector = newStatsCollector()
+
+// 根据运行平台选择采集器
+func newStatsCollector() statsCollector {
+	if runtime.GOOS == "linux" {
+		if c := newProcCollector(); c != nil {
+			return c
+		}
+	}
+	log.Printf("系统指标采集: 使用 gopsutil (%s)", runtime.GOOS)
+	return gopsutilCollector{}
+}
+
+// 以下函数为各监控模块的统一入口
+
+func readCPUStats() ([]uint64, [][]uint64, error) {
+	return sysCollector.CPUTimes()
+}
+
+func getMemoryUsage() (float64, error) {
+	return sysCollector.MemoryUsage()
+}
+
+func getDiskUsage() (float64, error) {
+	return sysCollector.RootDiskUsage()
+}
+
+func listDisks() ([]DiskUsage, error) {
+	return sysCollector.Disks()
+}
+
+func readNetDev() (map[string][2]uint64, error) {
+	return sysCollector.NetCounters()
+}
+
+func readDiskIO() (map[string][2]uint64, error) {
+	return sysCollector.DiskIOCounters()
+}
+
+// ========== gopsutil 实现 ==========
+
+type gopsutilCollector struct{}
+
+// 将 gopsutil 的秒数转换为与 /proc/stat 相同量级的计数（1/100 秒）
+func cpuTimesToTicks(t cpu.TimesStat) []uint64 {
+	return []uint64{
+		uint64(t.User * 100), uint64(t.Nice * 100), uint64(t.System * 100), uint64(t.Idle * 100),
+		uint64(t.Iowait * 100), uint64(t.Irq * 100), uint64(t.Softirq * 100),
+	}
+}
+
+func (gopsutilCollector) CPUTimes() ([]uint64, [][]uint64, error) {
+	total, err := cpu.Times(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(total) == 0 {
+		return nil, nil, errors.New("无法获取 CPU 时间")
+	}
+	perCore, err := cpu.Times(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cores := make([][]uint64, 0, len(perCore))
+	for _, t := range perCore {
+		cores = append(cores, cpuTimesToTicks(t))
+	}
+	return cpuTimesToTicks(total[0]), cores, nil
+}
+
+func (gopsutilCollector) MemoryUsage() (float64, error) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return 0, err
+	}
+	return vm.UsedPercent, nil
+}
+
+func (gopsutilCollector) RootDiskUsage() (float64, error) {
+	root := "/"
+	if runtime.GOOS == "windows" {
+		root = "C:\\"
+	}
+	usage, err := disk.Usage(root)
+	if err != nil {
+		return 0, err
+	}
+	return usage.UsedPercent, nil
+}
+
+func (gopsutilCollector) Disks() ([]DiskUsage, error) {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	disks := make([]DiskUsage, 0, len(partitions))
+	for _, p := range partitions {
+		if seen[p.Device] {
+			continue
+		}
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		seen[p.Device] = true
+		disks = append(disks, DiskUsage{
+			Device:     p.Device,
+			MountPoint: p.Mountpoint,
+			FSType:     p.Fstype,
+			Total:      usage.Total,
+			Used:       usage.Used,
+			Free:       usage.Free,
+			Percent:    usage.UsedPercent,
+		})
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].MountPoint < disks[j].MountPoint })
+	return disks, nil
+}
+
+func (gopsutilCollector) NetCounters() (map[string][2]uint64, error) {
+	stats, err := psnet.IOCounters(true)
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string][2]uint64, len(stats))
+	for _, s := range stats {
+		counters[s.Name] = [2]uint64{s.BytesRecv, s.BytesSent}
+	}
+	return counters, nil
+}
+
+func (gopsutilCollector) DiskIOCounters() (map[string][2]uint64, error) {
+	stats, err := disk.IOCounters()
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string][2]uint64, len(stats))
+	for name, s := range stats {
+		counters[name] = [2]uint64{s.ReadBytes, s.WriteBytes}
+	}
+	return counters, nil
+}
@@ -0,0 +1,239 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 基于 /proc 和 statfs 的采集器（Linux 默认，无额外依赖）
+type procCollector struct{}
+
+func newProcCollector() statsCollector {
+	return procCollector{}
+}
+
+func (procCollector) CPUTimes() ([]uint64, [][]uint64, error) {
+	return readProcCPUStats()
+}
+
+func (procCollector) MemoryUsage() (float64, error) {
+	return readProcMemoryUsage()
+}
+
+func (procCollector) RootDiskUsage() (float64, error) {
+	return procRootDiskUsage()
+}
+
+func (procCollector) Disks() ([]DiskUsage, error) {
+	return listProcDisks()
+}
+
+func (procCollector) NetCounters() (map[string][2]uint64, error) {
+	return readProcNetDev()
+}
+
+func (procCollector) DiskIOCounters() (map[string][2]uint64, error) {
+	return readProcDiskStats()
+}
+
+// /proc/diskstats 中的扇区固定为 512 字节（与设备实际扇区大小无关）
+const diskstatsSectorSize = 512
+
+// diskstats 的扇区计数为内核的 unsigned long，32 位内核上会在 2^32 处回绕。
+// 位宽取决于内核而不是面板程序（64 位主机上可能运行 32 位的面板），因此根据 uname 的 machine 判断；
+// 64 位内核上计数变小视为设备重置（counterDelta 返回 0）
+var diskIOCounterWrap = kernelCounterWrap(kernelMachine())
+
+// 内核的硬件架构（uname -m），获取失败时返回空
+func kernelMachine() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return ""
+	}
+	machine := make([]byte, 0, len(uts.Machine))
+	for _, c := range uts.Machine {
+		if c == 0 {
+			break
+		}
+		machine = append(machine, byte(c))
+	}
+	return string(machine)
+}
+
+// 32 位内核的扇区计数回绕值，64 位或无法识别的架构返回 0
+func kernelCounterWrap(machine string) uint64 {
+	switch {
+	case len(machine) == 4 && machine[0] == 'i' && strings.HasSuffix(machine, "86"), // i386 ~ i686
+		strings.HasPrefix(machine, "armv") && machine != "armv8l", // armv8l 为 64 位内核上的 32 位兼容模式
+		machine == "arm", machine == "mips", machine == "mipsel", machine == "ppc", machine == "m68k":
+		return (1 << 32) * diskstatsSectorSize
+	}
+	return 0
+}
+
+// 是否为需要统计的物理磁盘（跳过 loop、ram、zram、device-mapper 以及分区）
+func isPhysicalDisk(name string) bool {
+	for _, prefix := range []string{"loop", "ram", "zram", "dm-", "md", "sr", "fd"} {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	// 分区在 sysfs 中有 partition 文件，其流量已计入所属磁盘
+	if _, err := os.Stat("/sys/class/block/" + name + "/partition"); err == nil {
+		return false
+	}
+	return true
+}
+
+// 读取 /proc/diskstats，返回每个物理磁盘的累计读写字节
+func readProcDiskStats() (map[string][2]uint64, error) {
+	file, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counters := make(map[string][2]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式: major minor name reads merged sectors_read ms writes merged sectors_written ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		name := fields[2]
+		if !isPhysicalDisk(name) {
+			continue
+		}
+		sectorsRead, err1 := strconv.ParseUint(fields[5], 10, 64)
+		sectorsWritten, err2 := strconv.ParseUint(fields[9], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		counters[name] = [2]uint64{sectorsRead * diskstatsSectorSize, sectorsWritten * diskstatsSectorSize}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// 需要跳过的伪文件系统
+var pseudoFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "tmpfs": true, "devtmpfs": true, "devpts": true,
+	"overlay": true, "aufs": true, "cgroup": true, "cgroup2": true, "mqueue": true,
+	"securityfs": true, "debugfs": true, "tracefs": true, "configfs": true,
+	"fusectl": true, "pstore": true, "bpf": true, "autofs": true, "hugetlbfs": true,
+	"nsfs": true, "squashfs": true, "ramfs": true, "binfmt_misc": true,
+	"rpc_pipefs": true, "efivarfs": true, "fuse.lxcfs": true, "selinuxfs": true,
+}
+
+// 获取指定路径所在文件系统的容量（字节）
+func statfsUsage(path string) (total, used, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	total = st.Blocks * bsize
+	free = st.Bavail * bsize
+	// 已用 = 总量 - 所有空闲块（包含 root 保留块），与 df 保持一致
+	used = total - st.Bfree*bsize
+	return total, used, free, nil
+}
+
+// 解码 /proc/mounts 中的八进制转义（如 \040 表示空格）
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// 列出所有真实文件系统的使用情况
+func listProcDisks() ([]DiskUsage, error) {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 按设备去重（bind mount 会让同一设备出现多次），保留最短的挂载点
+	byDevice := make(map[string]DiskUsage)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		device := unescapeMountPath(fields[0])
+		mountPoint := unescapeMountPath(fields[1])
+		fsType := fields[2]
+
+		if pseudoFilesystems[fsType] {
+			continue
+		}
+		// 跳过容器自身的挂载（容器 rootfs、netns 等）
+		if strings.HasPrefix(mountPoint, "/var/lib/docker/") || strings.HasPrefix(mountPoint, "/run/docker/") {
+			continue
+		}
+
+		total, used, free, err := statfsUsage(mountPoint)
+		if err != nil || total == 0 {
+			continue
+		}
+
+		if old, ok := byDevice[device]; ok && len(old.MountPoint) <= len(mountPoint) {
+			continue
+		}
+
+		byDevice[device] = DiskUsage{
+			Device:     device,
+			MountPoint: mountPoint,
+			FSType:     fsType,
+			Total:      total,
+			Used:       used,
+			Free:       free,
+			Percent:    diskPercent(used, free),
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	disks := make([]DiskUsage, 0, len(byDevice))
+	for _, d := range byDevice {
+		disks = append(disks, d)
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].MountPoint < disks[j].MountPoint })
+	return disks, nil
+}
+
+// 获取根分区磁盘使用率
+func procRootDiskUsage() (float64, error) {
+	_, used, free, err := statfsUsage("/")
+	if err != nil {
+		return 0, fmt.Errorf("获取磁盘信息失败: %v", err)
+	}
+	return diskPercent(used, free), nil
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package main
+
+import "testing"
+
+// Linux 默认使用 /proc 采集器
+func TestProcCollector(t *testing.T) {
+	c, ok := newStatsCollector().(procCollector)
+	if !ok {
+		t.Fatalf("newStatsCollector() = %T, want procCollector", newStatsCollector())
+	}
+
+	total, cores, err := c.CPUTimes()
+	if err != nil {
+		t.Fatalf("CPUTimes: %v", err)
+	}
+	if len(total) < 4 || len(cores) == 0 {
+		t.Fatalf("CPUTimes: %d total fields, %d cores", len(total), len(cores))
+	}
+	for i, core := range cores {
+		if len(core) != len(total) {
+			t.Errorf("core %d: %d fields, want %d", i, len(core), len(total))
+		}
+	}
+
+	if p, err := c.MemoryUsage(); err != nil || p < 0 || p > 100 {
+		t.Errorf("MemoryUsage = %v, %v", p, err)
+	}
+	if p, err := c.RootDiskUsage(); err != nil || p < 0 || p > 100 {
+		t.Errorf("RootDiskUsage = %v, %v", p, err)
+	}
+	if _, err := c.NetCounters(); err != nil {
+		t.Errorf("NetCounters: %v", err)
+	}
+	if _, err := c.DiskIOCounters(); err != nil {
+		t.Errorf("DiskIOCounters: %v", err)
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"/mnt/data", "/mnt/data"},
+		{`/mnt/my\040disk`, "/mnt/my disk"},
+		{`/mnt/a\011b\134c`, "/mnt/a\tb\\c"},
+		{`/mnt/bad\09`, `/mnt/bad\09`}, // 不是合法的八进制转义
+		{`/mnt/end\04`, `/mnt/end\04`}, // 转义不完整
+	}
+	for _, c := range cases {
+		if got := unescapeMountPath(c.in); got != c.want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// 扇区计数的回绕值取决于内核位宽，与面板程序本身的位宽无关
+func TestKernelCounterWrap(t *testing.T) {
+	if kernelMachine() == "" {
+		t.Error("kernelMachine() is empty")
+	}
+	wrap32 := uint64(1<<32) * diskstatsSectorSize
+	cases := []struct {
+		machine string
+		want    uint64
+	}{
+		{"x86_64", 0},
+		{"aarch64", 0},
+		{"armv8l", 0}, // 64 位内核上的 32 位兼容模式
+		{"ppc64le", 0},
+		{"s390x", 0},
+		{"riscv64", 0},
+		{"", 0},
+		{"i386", wrap32},
+		{"i686", wrap32},
+		{"armv7l", wrap32},
+		{"armv6l", wrap32},
+		{"mips", wrap32},
+	}
+	for _, c := range cases {
+		if got := kernelCounterWrap(c.machine); got != c.want {
+			t.Errorf("kernelCounterWrap(%q) = %d, want %d", c.machine, got, c.want)
+		}
+	}
+
+	// 64 位内核上计数变小视为设备重置，不会算出巨大的速率
+	if d := counterDelta(1000, 10, kernelCounterWrap("x86_64")); d != 0 {
+		t.Errorf("counterDelta after reset = %d, want 0", d)
+	}
+	if d := counterDelta(wrap32-512, 1024, kernelCounterWrap("armv7l")); d != 1536 {
+		t.Errorf("counterDelta across wrap = %d, want 1536", d)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+// 非 Linux 平台没有 /proc，统一使用 gopsutil 采集
+func newProcCollector() statsCollector {
+	return nil
+}
+
+// gopsutil 返回的是 64 位字节计数，不需要处理回绕
+const diskIOCounterWrap = 0
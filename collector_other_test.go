@@ -0,0 +1,34 @@
+//go:build !linux
+
+package main
+
+import "testing"
+
+// 非 Linux 平台使用 gopsutil 采集器
+func TestGopsutilCollector(t *testing.T) {
+	if c := newProcCollector(); c != nil {
+		t.Fatalf("newProcCollector() = %T, want nil", c)
+	}
+	c, ok := newStatsCollector().(gopsutilCollector)
+	if !ok {
+		t.Fatalf("newStatsCollector() = %T, want gopsutilCollector", newStatsCollector())
+	}
+
+	total, cores, err := c.CPUTimes()
+	if err != nil {
+		t.Fatalf("CPUTimes: %v", err)
+	}
+	if len(total) != 7 || len(cores) == 0 {
+		t.Fatalf("CPUTimes: %d total fields, %d cores", len(total), len(cores))
+	}
+
+	if p, err := c.MemoryUsage(); err != nil || p < 0 || p > 100 {
+		t.Errorf("MemoryUsage = %v, %v", p, err)
+	}
+	if p, err := c.RootDiskUsage(); err != nil || p < 0 || p > 100 {
+		t.Errorf("RootDiskUsage = %v, %v", p, err)
+	}
+	if diskIOCounterWrap != 0 {
+		t.Errorf("diskIOCounterWrap = %d, want 0", diskIOCounterWrap)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,9 +10,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
-const composeBaseDir = "./compose_projects"
+// Compose 项目目录（compose_dir / COMPOSE_DIR）
+var composeBaseDir = "./compose_projects"
 
 type ComposeProject struct {
 	Name       string             `json:"name"`
@@ -33,8 +36,9 @@ type ComposeFileRequest struct {
 }
 
 type ComposeActionRequest struct {
-	Project string `json:"project"`
-	Action  string `json:"action"` // "up", "down", "restart", "pull", "logs"
+	Project            string `json:"project"`
+	Action             string `json:"action"` // "up", "down", "restart", "pull", "logs"
+	ComposeDownOptions        // down 的选项
 }
 
 func initCompose() {
@@ -46,7 +50,7 @@ func initCompose() {
 // 获取 Compose 项目列表
 func handleComposeList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -58,7 +62,7 @@ func handleComposeList(w http.ResponseWriter, r *http.Request) {
 	entries, err := os.ReadDir(composeBaseDir)
 	if err != nil {
 		log.Printf("读取 Compose 目录失败: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
@@ -83,7 +87,7 @@ func handleComposeList(w http.ResponseWriter, r *http.Request) {
 // 创建新项目
 func handleComposeCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -91,30 +95,30 @@ func handleComposeCreate(w http.ResponseWriter, r *http.Request) {
 		Name string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "项目名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "项目名称不能为空")
 		return
 	}
 
 	projectDir := filepath.Join(composeBaseDir, req.Name)
 	if _, err := os.Stat(projectDir); !os.IsNotExist(err) {
-		http.Error(w, "项目已存在", http.StatusConflict)
+		writeError(w, http.StatusConflict, ErrCodeConflict, "项目已存在")
 		return
 	}
 
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
 	// 创建默认 docker-compose.yml
 	defaultContent := "version: '3'\nservices:\n  web:\n    image: nginx:alpine\n    ports:\n      - \"8080:80\"\n"
 	if err := ioutil.WriteFile(filepath.Join(projectDir, "docker-compose.yml"), []byte(defaultContent), 0644); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
@@ -125,7 +129,7 @@ func handleComposeCreate(w http.ResponseWriter, r *http.Request) {
 func handleComposeGetFile(w http.ResponseWriter, r *http.Request) {
 	project := r.URL.Query().Get("project")
 	if project == "" {
-		http.Error(w, "Missing project parameter", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Missing project parameter")
 		return
 	}
 
@@ -137,11 +141,11 @@ func handleComposeGetFile(w http.ResponseWriter, r *http.Request) {
 			filePath = filepath.Join(composeBaseDir, project, "docker-compose.yaml")
 			content, err = ioutil.ReadFile(filePath)
 			if err != nil {
-				http.Error(w, "File not found", http.StatusNotFound)
+				writeError(w, http.StatusNotFound, ErrCodeNotFound, "File not found")
 				return
 			}
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			return
 		}
 	}
@@ -153,19 +157,19 @@ func handleComposeGetFile(w http.ResponseWriter, r *http.Request) {
 // 保存 Compose 文件内容
 func handleComposeSaveFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req ComposeFileRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	filePath := filepath.Join(composeBaseDir, req.Project, "docker-compose.yml")
 	if err := ioutil.WriteFile(filePath, []byte(req.Content), 0644); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 
@@ -176,13 +180,17 @@ func handleComposeSaveFile(w http.ResponseWriter, r *http.Request) {
 func handleComposeStatus(w http.ResponseWriter, r *http.Request) {
 	project := r.URL.Query().Get("project")
 	if project == "" {
-		http.Error(w, "Missing project parameter", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Missing project parameter")
 		return
 	}
 
 	projectDir := filepath.Join(composeBaseDir, project)
 	if _, err := os.Stat(projectDir); os.IsNotExist(err) {
-		http.Error(w, "Project not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Project not found")
+		return
+	}
+
+	if !requireCompose(w) {
 		return
 	}
 
@@ -279,49 +287,104 @@ func splitLines(s string) []string {
 // 执行 Compose 操作
 func handleComposeAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var req ComposeActionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
-	log.Printf("[Compose] Action: %s, project: %s", req.Action, req.Project)
+	if !requireCompose(w) {
+		return
+	}
+
+	componentLogger("compose").InfoContext(r.Context(), "Action", "action", req.Action, "project", req.Project)
 
 	projectDir := filepath.Join(composeBaseDir, req.Project)
-	var cmd *exec.Cmd
+	var args []string
 
 	switch req.Action {
 	case "up":
-		cmd = exec.Command("docker", "compose", "up", "-d")
+		args = []string{"compose", "up", "-d"}
 	case "down":
-		cmd = exec.Command("docker", "compose", "down")
+		if req.Rmi != "" && req.Rmi != "local" && req.Rmi != "all" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "rmi 只能是 local 或 all")
+			return
+		}
+		args = req.ComposeDownOptions.args()
 	case "restart":
-		cmd = exec.Command("docker", "compose", "restart")
+		args = []string{"compose", "restart"}
 	case "pull":
-		cmd = exec.Command("docker", "compose", "pull")
+		args = []string{"compose", "pull"}
 	case "logs":
 		// 日志特殊处理，返回最后 100 行
-		cmd = exec.Command("docker", "compose", "logs", "--tail=100")
+		args = []string{"compose", "logs", "--tail=100"}
 	default:
-		http.Error(w, "Unknown action", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Unknown action")
 		return
 	}
 
+	// 带 dry_run 参数的 down 请求返回影响报告（?dry_run=true 只预览）：执行前按 compose 标签选出受影响的对象
+	if dryRun, present := dryRunParam(r); present {
+		if req.Action != "down" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "该操作不支持预览")
+			return
+		}
+		items, err := selectComposeDown(r.Context(), projectDir, req.Project, req.ComposeDownOptions)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+		report := applyImpact("compose_down", items, true, nil)
+		if !dryRun {
+			cmd := exec.Command("docker", args...)
+			cmd.Dir = projectDir
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				writeErrorDetails(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err), map[string]string{
+					"output": string(output),
+				})
+				return
+			}
+			report.Executed = true
+			report.Output = string(output)
+			InvalidateContainers()
+		}
+		writeImpactReport(w, r, report, req.Project)
+		return
+	}
+
+	// 后台任务方式（日志除外）：命令输出逐行保存到任务日志，取消时终止命令
+	if wantsAsync(r) && req.Action != "logs" {
+		project, action := req.Project, req.Action
+		writeJobSubmitted(w, r, "compose_"+action, project, true, func(ctx context.Context, out progressSink) (string, error) {
+			out.send("start", fmt.Sprintf("docker %s", strings.Join(args, " ")))
+			if err := runCommandProgress(ctx, out, projectDir, "docker", args...); err != nil {
+				componentLogger("compose").ErrorContext(ctx, "Action failed", "action", action, "project", project, "error", err)
+				return "", fmt.Errorf("执行失败: %v", err)
+			}
+			componentLogger("compose").InfoContext(ctx, "Action success", "action", action, "project", project)
+			return "", nil
+		})
+		return
+	}
+
+	cmd := exec.Command("docker", args...)
 	cmd.Dir = projectDir
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("[Compose] Action failed, project: %s, action: %s, error: %v", req.Project, req.Action, err)
+		componentLogger("compose").ErrorContext(r.Context(), "Action failed", "action", req.Action, "project", req.Project, "error", err)
 		// 返回错误信息和输出
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(fmt.Sprintf("Error: %v\nOutput:\n%s", err, string(output))))
+		writeErrorDetails(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err), map[string]string{
+			"output": string(output),
+		})
 		return
 	}
 
-	log.Printf("[Compose] Action success, project: %s, action: %s", req.Project, req.Action)
+	componentLogger("compose").InfoContext(r.Context(), "Action success", "action", req.Action, "project", req.Project)
 
 	w.Write(output)
 }
@@ -329,7 +392,7 @@ func handleComposeAction(w http.ResponseWriter, r *http.Request) {
 // 删除 Compose 项目
 func handleComposeDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -337,18 +400,23 @@ func handleComposeDelete(w http.ResponseWriter, r *http.Request) {
 		Project string `json:"project"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
 	if req.Project == "" {
-		http.Error(w, "项目名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "项目名称不能为空")
 		return
 	}
 
 	projectDir := filepath.Join(composeBaseDir, req.Project)
 	if _, err := os.Stat(projectDir); os.IsNotExist(err) {
-		http.Error(w, "项目不存在", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "项目不存在")
+		return
+	}
+
+	// 没有 compose 时无法停止项目的容器，直接删除目录会留下无人管理的容器
+	if !requireCompose(w) {
 		return
 	}
 
@@ -359,7 +427,7 @@ func handleComposeDelete(w http.ResponseWriter, r *http.Request) {
 
 	// 删除项目目录
 	if err := os.RemoveAll(projectDir); err != nil {
-		http.Error(w, fmt.Sprintf("删除失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
 		return
 	}
 
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 面板配置
+// 加载顺序：默认值 -> 配置文件（--config，可选）-> 环境变量（优先级最高）
+// 字段的 json 标签为配置文件中的键名，env 标签为对应的环境变量，secret 标签的字段在接口中脱敏
+type Config struct {
+	Mode        string `json:"mode" env:"MODE"`
+	Host        string `json:"host" env:"HOST"`
+	Port        string `json:"port" env:"PORT"`                 // 未设置时 Master 为 9999，Worker 为 10001
+	BasePath    string `json:"base_path" env:"BASE_PATH"`       // 反向代理下的 URL 前缀，如 /docker
+	Listen      string `json:"listen" env:"LISTEN"`             // unix:///path 时监听 unix socket，不监听 host:port
+	SocketMode  string `json:"socket_mode" env:"SOCKET_MODE"`   // unix socket 权限（八进制）
+	SocketOwner string `json:"socket_owner" env:"SOCKET_OWNER"` // unix socket 属主（user:group）
+	MasterURL   string `json:"master_url" env:"MASTER_URL"`
+	NodeName    string `json:"node_name" env:"NODE_NAME"`
+	NodeSecret  string `json:"node_secret" env:"NODE_SECRET" secret:"true"`
+	JWTSecret   string `json:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+
+	// HTTPS：配置 acme_domains 时自动申请 Let's Encrypt 证书（监听 80 和 443），否则可指定证书文件
+	TLSCertFile  string `json:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile   string `json:"tls_key_file" env:"TLS_KEY_FILE"`
+	ACMEDomains  string `json:"acme_domains" env:"ACME_DOMAINS"`     // 逗号分隔
+	ACMEEmail    string `json:"acme_email" env:"ACME_EMAIL"`         // 证书到期等通知的联系邮箱
+	ACMECacheDir string `json:"acme_cache_dir" env:"ACME_CACHE_DIR"` // 未设置时为 data_dir/acme
+
+	DataDir    string `json:"data_dir" env:"DATA_DIR"`
+	DBPath     string `json:"db_path" env:"DB_PATH"` // 未设置时为 data_dir/auth.db
+	ComposeDir string `json:"compose_dir" env:"COMPOSE_DIR"`
+
+	CacheTTL             int   `json:"cache_ttl" env:"CACHE_TTL"`                             // 容器列表缓存有效期（秒），0 关闭缓存
+	MetricsInterval      int   `json:"metrics_interval" env:"METRICS_INTERVAL"`               // 历史指标采样间隔（秒），0 关闭
+	MaxUploadSize        int64 `json:"max_upload_size" env:"MAX_UPLOAD_SIZE"`                 // 上传大小上限（MB）
+	MaxChunkedUploadSize int64 `json:"max_chunked_upload_size" env:"MAX_CHUNKED_UPLOAD_SIZE"` // 分片上传的文件大小上限（MB）
+	ShutdownTimeout      int   `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`               // 优雅关闭等待时间（秒）
+
+	AccessLog     bool   `json:"access_log" env:"ACCESS_LOG"`     // 记录每个请求的访问日志
+	LogLevel      string `json:"log_level" env:"LOG_LEVEL"`       // debug、info、warn、error
+	LogFormat     string `json:"log_format" env:"LOG_FORMAT"`     // text 或 json
+	LogFile       string `json:"log_file" env:"LOG_FILE"`         // 为空时输出到标准错误
+	LogMaxSize    int64  `json:"log_max_size" env:"LOG_MAX_SIZE"` // 单个日志文件大小上限（MB）
+	LogMaxBackups int    `json:"log_max_backups" env:"LOG_MAX_BACKUPS"`
+
+	EnableHostTerminal     bool   `json:"enable_host_terminal" env:"ENABLE_HOST_TERMINAL"`
+	EnableDebug            bool   `json:"enable_debug" env:"ENABLE_DEBUG"`         // pprof 和运行时信息接口
+	HostFilesRoots         string `json:"host_files_roots" env:"HOST_FILES_ROOTS"` // 逗号分隔
+	TerminalRecording      string `json:"terminal_recording" env:"TERMINAL_RECORDING"`
+	TerminalRecordingMaxMB int64  `json:"terminal_recording_max_mb" env:"TERMINAL_RECORDING_MAX_MB"`
+	TerminalRecordingDays  int64  `json:"terminal_recording_days" env:"TERMINAL_RECORDING_DAYS"`
+	TerminalMaxPerUser     int    `json:"terminal_max_per_user" env:"TERMINAL_MAX_PER_USER"`
+	TerminalIdleTimeout    int    `json:"terminal_idle_timeout" env:"TERMINAL_IDLE_TIMEOUT"` // 分钟
+
+	// 耗时 Docker 操作的并发上限，0 表示不限制
+	LimitStats     int `json:"limit_stats" env:"LIMIT_STATS"`
+	LimitLogs      int `json:"limit_logs" env:"LIMIT_LOGS"`
+	LimitExec      int `json:"limit_exec" env:"LIMIT_EXEC"`
+	LimitFiles     int `json:"limit_files" env:"LIMIT_FILES"`
+	LimitBuild     int `json:"limit_build" env:"LIMIT_BUILD"`
+	LimitQueueWait int `json:"limit_queue_wait" env:"LIMIT_QUEUE_WAIT"` // 占满时排队等待的秒数
+}
+
+// 当前生效的配置（main 中加载）
+var appConfig = defaultConfig()
+
+// 配置文件路径（为空时只使用默认值和环境变量）
+var configPath string
+
+// 默认节点密钥（生产环境必须修改）
+const defaultNodeSecret = "rabbit-panel-node-secret-change-in-production"
+
+// 默认配置
+func defaultConfig() *Config {
+	return &Config{
+		Mode:                   ModeMaster,
+		Host:                   "0.0.0.0",
+		SocketMode:             "0660",
+		DataDir:                "./data",
+		ComposeDir:             "./compose_projects",
+		CacheTTL:               2,
+		MetricsInterval:        60,
+		MaxUploadSize:          1024,
+		MaxChunkedUploadSize:   10240,
+		ShutdownTimeout:        30,
+		AccessLog:              true,
+		LogLevel:               "info",
+		LogFormat:              "text",
+		LogMaxSize:             100,
+		LogMaxBackups:          5,
+		HostFilesRoots:         "/opt,/srv,/mnt",
+		TerminalRecording:      "off",
+		TerminalRecordingMaxMB: 50,
+		TerminalRecordingDays:  30,
+		TerminalMaxPerUser:     5,
+		TerminalIdleTimeout:    30,
+		LimitStats:             16,
+		LimitLogs:              32,
+		LimitExec:              32,
+		LimitFiles:             8,
+		LimitBuild:             2,
+		LimitQueueWait:         3,
+	}
+}
+
+// 加载配置：默认值、配置文件、环境变量依次覆盖，最后校验
+func loadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			if err := cfg.set(key, value); err != nil {
+				return nil, fmt.Errorf("配置文件 %s: %v", path, err)
+			}
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	cfg.Mode = strings.ToLower(cfg.Mode)
+	if cfg.Port == "" {
+		if cfg.Mode == ModeWorker {
+			cfg.Port = "10001"
+		} else {
+			cfg.Port = "9999"
+		}
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = filepath.Join(cfg.DataDir, "auth.db")
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 读取配置文件，返回键值对
+// .json 按 JSON 解析；其余（.yaml/.yml/.toml）按扁平的 "key: value" 或 "key = value" 格式解析，
+// 不支持嵌套结构（所有配置项都是顶层键）
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var raw map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+		}
+		values := make(map[string]string, len(raw))
+		for key, value := range raw {
+			switch v := value.(type) {
+			case []interface{}:
+				items := make([]string, len(v))
+				for i, item := range v {
+					items[i] = fmt.Sprint(item)
+				}
+				values[key] = strings.Join(items, ",")
+			case map[string]interface{}:
+				return nil, fmt.Errorf("配置文件 %s: %s 不支持嵌套结构", path, key)
+			default:
+				values[key] = fmt.Sprint(v)
+			}
+		}
+		return values, nil
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "- ") {
+			return nil, fmt.Errorf("配置文件 %s 第 %d 行: 不支持嵌套结构", path, lineNo)
+		}
+
+		sep := strings.IndexAny(trimmed, ":=")
+		if sep <= 0 {
+			return nil, fmt.Errorf("配置文件 %s 第 %d 行: 格式错误，应为 key: value", path, lineNo)
+		}
+		key := strings.TrimSpace(trimmed[:sep])
+		value, err := parseConfigValue(strings.TrimSpace(trimmed[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("配置文件 %s 第 %d 行: %v", path, lineNo, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	return values, nil
+}
+
+// 解析配置值：去掉引号和行尾注释，行内列表 [a, b] 转换为逗号分隔
+func parseConfigValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch value[0] {
+	case '"':
+		end := strings.LastIndex(value, "\"")
+		if end == 0 {
+			return "", fmt.Errorf("引号未闭合")
+		}
+		return strconv.Unquote(value[:end+1])
+	case '\'':
+		end := strings.LastIndex(value, "'")
+		if end == 0 {
+			return "", fmt.Errorf("引号未闭合")
+		}
+		return strings.ReplaceAll(value[1:end], "''", "'"), nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		var items []string
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			item = strings.Trim(strings.TrimSpace(item), `"'`)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	return value, nil
+}
+
+// 按配置文件中的键名设置字段
+func (c *Config) set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") == key {
+			if err := setConfigField(v.Field(i), value); err != nil {
+				return fmt.Errorf("%s 配置无效: %v", key, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("未知的配置项: %s", key)
+}
+
+// 使用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("env")
+		value := os.Getenv(name)
+		if name == "" || value == "" {
+			continue
+		}
+		if err := setConfigField(v.Field(i), value); err != nil {
+			return fmt.Errorf("%s 配置无效: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("应为 true 或 false: %s", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("应为整数: %s", value)
+		}
+		field.SetInt(n)
+	}
+	return nil
+}
+
+// 校验配置
+func (c *Config) validate() error {
+	if c.Mode != ModeMaster && c.Mode != ModeWorker {
+		return fmt.Errorf("mode 配置无效: %s（应为 master 或 worker）", c.Mode)
+	}
+	if c.Mode == ModeWorker && c.MasterURL == "" {
+		return fmt.Errorf("Worker 模式需要设置 master_url（或 MASTER_URL 环境变量）")
+	}
+	host, err := normalizeListenHost(c.Host)
+	if err != nil {
+		return err
+	}
+	c.Host = host
+	if port, err := strconv.Atoi(c.Port); err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("port 配置无效: %s", c.Port)
+	}
+	if c.Listen != "" {
+		if !strings.HasPrefix(c.Listen, unixListenPrefix) || !filepath.IsAbs(c.unixSocketPath()) {
+			return fmt.Errorf("listen 配置无效: %s（应为 unix:///path/to/socket）", c.Listen)
+		}
+		if c.Mode == ModeWorker {
+			return fmt.Errorf("Worker 模式需要 Master 通过 TCP 访问，不支持监听 unix socket")
+		}
+	}
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+	prefix, err := normalizeBasePath(c.BasePath)
+	if err != nil {
+		return err
+	}
+	c.BasePath = prefix
+	if c.BasePath != "" && c.Mode == ModeWorker {
+		return fmt.Errorf("Worker 模式由 Master 直接访问，不支持 base_path")
+	}
+	if !fileModePattern.MatchString(c.SocketMode) {
+		return fmt.Errorf("socket_mode 配置无效: %s", c.SocketMode)
+	}
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir 不能为空")
+	}
+	if c.ComposeDir == "" {
+		return fmt.Errorf("compose_dir 不能为空")
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("cache_ttl 不能为负数")
+	}
+	if c.MetricsInterval < 0 {
+		return fmt.Errorf("metrics_interval 不能为负数")
+	}
+
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		return err
+	}
+	c.LogFormat = strings.ToLower(c.LogFormat)
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log_format 配置无效: %s（应为 text 或 json）", c.LogFormat)
+	}
+	if c.LogMaxBackups < 0 {
+		return fmt.Errorf("log_max_backups 不能为负数")
+	}
+	nonNegative := map[string]int{
+		"limit_stats":      c.LimitStats,
+		"limit_logs":       c.LimitLogs,
+		"limit_exec":       c.LimitExec,
+		"limit_files":      c.LimitFiles,
+		"limit_build":      c.LimitBuild,
+		"limit_queue_wait": c.LimitQueueWait,
+	}
+	for key, n := range nonNegative {
+		if n < 0 {
+			return fmt.Errorf("%s 不能为负数", key)
+		}
+	}
+
+	positive := map[string]int64{
+		"log_max_size":              c.LogMaxSize,
+		"max_upload_size":           c.MaxUploadSize,
+		"max_chunked_upload_size":   c.MaxChunkedUploadSize,
+		"shutdown_timeout":          int64(c.ShutdownTimeout),
+		"terminal_recording_max_mb": c.TerminalRecordingMaxMB,
+		"terminal_recording_days":   c.TerminalRecordingDays,
+		"terminal_max_per_user":     int64(c.TerminalMaxPerUser),
+		"terminal_idle_timeout":     int64(c.TerminalIdleTimeout),
+	}
+	for key, n := range positive {
+		if n <= 0 {
+			return fmt.Errorf("%s 必须大于 0", key)
+		}
+	}
+
+	c.TerminalRecording = strings.ToLower(c.TerminalRecording)
+	switch c.TerminalRecording {
+	case "", "off", "output", "all":
+	default:
+		return fmt.Errorf("terminal_recording 配置无效: %s（应为 off、output 或 all）", c.TerminalRecording)
+	}
+
+	for _, root := range c.hostFileRoots() {
+		if !filepath.IsAbs(root) {
+			return fmt.Errorf("host_files_roots 必须为绝对路径: %s", root)
+		}
+	}
+	return nil
+}
+
+// listen 配置的 unix socket 路径，未配置时为空
+func (c *Config) unixSocketPath() string {
+	if !strings.HasPrefix(c.Listen, unixListenPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(c.Listen, unixListenPrefix)
+}
+
+// 主机文件浏览的根目录列表
+func (c *Config) hostFileRoots() []string {
+	var roots []string
+	for _, root := range strings.Split(c.HostFilesRoots, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, filepath.Clean(root))
+		}
+	}
+	return roots
+}
+
+// 将配置应用到各模块
+func applyConfig(c *Config) {
+	appConfig = c
+
+	dataDir = c.DataDir
+	basePath = c.BasePath
+	composeBaseDir = c.ComposeDir
+	cacheTTL = time.Duration(c.CacheTTL) * time.Second
+	uploadMaxSize = c.MaxUploadSize << 20
+	chunkedUploadMaxSize = c.MaxChunkedUploadSize << 20
+	shutdownTimeout = time.Duration(c.ShutdownTimeout) * time.Second
+
+	nodeSecret = c.NodeSecret
+	if nodeSecret == "" {
+		nodeSecret = defaultNodeSecret
+		log.Println("警告: 使用默认节点密钥，生产环境请设置 NODE_SECRET 环境变量")
+	}
+	if c.JWTSecret != "" {
+		jwtSecret = []byte(c.JWTSecret)
+	} else {
+		log.Println("警告: 使用默认 JWT 密钥，生产环境请设置 JWT_SECRET 环境变量")
+	}
+
+	hostTerminalEnabled = c.EnableHostTerminal
+	debugEnabled = c.EnableDebug
+	hostFileRoots = c.hostFileRoots()
+	terminalRecordingMode = c.TerminalRecording
+	terminalRecordingMax = c.TerminalRecordingMaxMB << 20
+	terminalRecordingDays = c.TerminalRecordingDays
+	terminalMaxPerUser = c.TerminalMaxPerUser
+	terminalIdleTimeout = time.Duration(c.TerminalIdleTimeout) * time.Minute
+	configureOpLimits(c)
+}
+
+// 解析命令行参数并加载配置，配置无效时退出
+func initConfig() {
+	flag.StringVar(&configPath, "config", "", "配置文件路径（YAML/TOML/JSON，可选）")
+	flag.Parse()
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if err := initLogging(cfg); err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+	applyConfig(cfg)
+	if configPath != "" {
+		log.Printf("已加载配置文件: %s", configPath)
+	}
+}
+
+// 脱敏后的配置（secret 字段只显示是否已设置）
+func (c *Config) redacted() map[string]interface{} {
+	result := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" {
+			if v.Field(i).String() != "" {
+				value = "******"
+			} else {
+				value = ""
+			}
+		}
+		result[field.Tag.Get("json")] = value
+	}
+	return result
+}
+
+// 查看当前生效的配置（敏感字段已脱敏）
+func handleSettingsConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config_file": configPath,
+		"config":      appConfig.redacted(),
+	})
+}
@@ -3,19 +3,30 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	"github.com/gorilla/websocket"
@@ -23,53 +34,203 @@ import (
 
 // ========== 容器终端执行命令 ==========
 
+// 执行选项（工作目录、用户和环境变量）
+type ExecOptions struct {
+	WorkDir string   `json:"workdir,omitempty"` // 工作目录，需为绝对路径
+	User    string   `json:"user,omitempty"`    // 用户名或 uid，可带组：user、1000、user:group、1000:1000
+	Env     []string `json:"env,omitempty"`     // 如 ["NODE_ENV=production"]
+}
+
 // 执行命令请求
 type ExecRequest struct {
 	ContainerID string   `json:"container_id"`
 	Command     []string `json:"command"` // 如 ["ls", "-la"] 或 ["sh", "-c", "echo hello"]
+	ExecOptions
+	Stdin          string `json:"stdin,omitempty"`           // 写入命令标准输入的内容
+	StdinEncoding  string `json:"stdin_encoding,omitempty"`  // stdin 编码：plain（默认）或 base64
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // 超时时间（秒），默认 30，最大 600
+}
+
+// 标准输入内容大小上限
+const maxExecStdinSize = 10 << 20
+
+// stdout、stderr 各自保留的输出上限（超出时保留末尾部分）
+const maxExecOutputSize = 2 << 20
+
+// 命令执行超时（默认值和上限）
+const (
+	defaultExecTimeout = 30 * time.Second
+	maxExecTimeout     = 10 * time.Minute
+)
+
+// 用户格式：名称或数字 ID，可选 ":组"
+var execUserPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+
+// 校验执行选项（用户是否存在由 Docker 在执行时检查）
+func (o ExecOptions) validate() error {
+	if o.WorkDir != "" && !path.IsAbs(o.WorkDir) {
+		return fmt.Errorf("工作目录必须为绝对路径: %s", o.WorkDir)
+	}
+	if o.User != "" && !execUserPattern.MatchString(o.User) {
+		return fmt.Errorf("用户格式无效（应为 user、uid 或 user:group、uid:gid）: %s", o.User)
+	}
+	for _, e := range o.Env {
+		if key, _, ok := strings.Cut(e, "="); !ok || key == "" {
+			return fmt.Errorf("环境变量格式无效（应为 KEY=VALUE）: %s", e)
+		}
+	}
+	return nil
+}
+
+// 将执行选项应用到 exec 配置
+func (o ExecOptions) apply(config *types.ExecConfig) {
+	config.WorkingDir = o.WorkDir
+	config.User = o.User
+	config.Env = o.Env
 }
 
 // 执行命令响应
 type ExecResponse struct {
-	Output   string `json:"output"`
-	ExitCode int    `json:"exit_code"`
-	Error    string `json:"error,omitempty"`
+	Output    string `json:"output"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	TimedOut  bool   `json:"timed_out,omitempty"` // 是否因超时中止
+	Killed    bool   `json:"killed,omitempty"`    // 超时后是否已成功终止进程
+	Truncated bool   `json:"truncated,omitempty"` // 输出超过上限，只保留了末尾部分
+}
+
+// exec 标记使用的环境变量：面板可能运行在容器中（没有 pid: host），无法通过主机 /proc 换算 Docker 返回的 PID，
+// 因此给 exec 带上唯一标记，发送信号时在容器内通过 /proc/*/environ 找到对应进程
+const execTagEnv = "RABBIT_PANEL_EXEC"
+
+// 生成 exec 标记（"变量=值"），追加到 ExecConfig.Env
+func newExecTag() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return execTagEnv + "=" + hex.EncodeToString(buf)
+}
+
+// 在容器内查找环境变量中带有标记的进程并发送信号（标记会被子进程继承，子进程也会收到信号）
+// 参数：$1 标记，$2 信号
+const execSignalScript = `n=0
+for d in /proc/[0-9]*; do
+	grep -qF "$1" "$d/environ" 2>/dev/null || continue
+	kill -"$2" "${d#/proc/}" 2>/dev/null && n=$((n+1))
+done
+[ "$n" -gt 0 ] || { echo "未找到 exec 进程" >&2; exit 1; }`
+
+// 向 exec 启动的进程发送信号（如 KILL、HUP）：Engine API 没有终止 exec 的接口，在容器中执行 kill
+// tag 为创建 exec 时设置的标记（newExecTag）
+func signalExecProcess(containerID, execID, tag, signal string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	inspect, err := getDockerClient().ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return err
+	}
+	if !inspect.Running {
+		return nil
+	}
+
+	// 以 root 执行，才能读取其他用户进程的 environ 并发送信号
+	res, err := runContainerExecAs(ctx, containerID, "0", []string{"sh", "-c", execSignalScript, "sh", tag, signal})
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		// 查找期间进程已自行退出
+		if inspect, err := getDockerClient().ContainerExecInspect(ctx, execID); err == nil && !inspect.Running {
+			return nil
+		}
+		if res.commandMissing() {
+			return fmt.Errorf("容器中没有 %s 命令", "sh")
+		}
+		return errors.New(res.errorMessage())
+	}
+	return nil
 }
 
 // 执行容器命令
 func handleContainerExec(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
+	// 限制请求体大小：stdin 以 Base64 编码时体积约为原内容的 4/3，另留出命令和选项的空间
+	r.Body = http.MaxBytesReader(w, r.Body, maxExecStdinSize/3*4+64<<10)
+
 	var req ExecRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("stdin 内容超过上限 (%d MB)", maxExecStdinSize>>20))
+			return
+		}
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	if req.ContainerID == "" {
-		http.Error(w, "容器ID不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
 		return
 	}
 
 	if len(req.Command) == 0 {
-		http.Error(w, "命令不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "命令不能为空")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := req.ExecOptions.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	var stdin []byte
+	switch req.StdinEncoding {
+	case "", "plain":
+		stdin = []byte(req.Stdin)
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(req.Stdin)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "stdin 解码失败")
+			return
+		}
+		stdin = data
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "stdin_encoding 只支持 plain 或 base64")
+		return
+	}
+	if len(stdin) > maxExecStdinSize {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("stdin 内容超过上限 (%d MB)", maxExecStdinSize>>20))
+		return
+	}
+
+	timeout := defaultExecTimeout
+	if req.TimeoutSeconds < 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "timeout_seconds 参数无效")
+		return
+	} else if req.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(req.TimeoutSeconds)*time.Second, maxExecTimeout)
+	}
+
+	// 客户端断开连接时同样取消
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
-	// 创建 exec 实例
+	// 创建 exec 实例（用户在镜像中不存在时，Docker 返回的错误会原样放在输出或 error 中）
 	execConfig := types.ExecConfig{
 		AttachStdout: true,
 		AttachStderr: true,
+		AttachStdin:  len(stdin) > 0,
 		Cmd:          req.Command,
 	}
+	req.ExecOptions.apply(&execConfig)
+	tag := newExecTag() // 超时时用于终止进程
+	execConfig.Env = append(execConfig.Env, tag)
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, req.ContainerID, execConfig)
+	execID, err := getDockerClient().ContainerExecCreate(ctx, req.ContainerID, execConfig)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(ExecResponse{Error: fmt.Sprintf("创建执行实例失败: %v", err)})
@@ -77,7 +238,7 @@ func handleContainerExec(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 附加到 exec 实例
-	resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(ExecResponse{Error: fmt.Sprintf("附加执行实例失败: %v", err)})
@@ -85,35 +246,75 @@ func handleContainerExec(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Close()
 
+	// 超时或取消时关闭连接，使输出读取立即返回
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	// 写入标准输入后关闭写端，让命令读到 EOF；与读取输出并行进行，
+	// 命令提前退出不再读取输入时，写入会因连接关闭而返回，不会阻塞
+	if len(stdin) > 0 {
+		go func() {
+			defer recoverGoroutine(r.Context(), "exec stdin")
+			if _, err := resp.Conn.Write(stdin); err == nil {
+				resp.CloseWrite()
+			}
+		}()
+	}
+
 	// 读取输出
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	stdout := &tailBuffer{limit: maxExecOutputSize}
+	stderr := &tailBuffer{limit: maxExecOutputSize}
+	_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
+
+	// 合并输出（超时时为已捕获的部分输出）
+	output := tailOutput(stdout.String(), maxExecOutputSize)
+	if errOutput := tailOutput(stderr.String(), maxExecOutputSize); errOutput != "" {
+		if output != "" {
+			output += "\n"
+		}
+		output += errOutput
+	}
+	truncated := stdout.truncated() || stderr.truncated()
+
+	if ctx.Err() != nil {
+		killErr := signalExecProcess(req.ContainerID, execID.ID, tag, "KILL")
+		if killErr != nil {
+			log.Printf("[Exec] Kill exec %s in container %s failed: %v", execID.ID, req.ContainerID, killErr)
+		}
+		if r.Context().Err() != nil {
+			// 客户端已断开，无需响应
+			return
+		}
+
+		result := ExecResponse{Output: output, ExitCode: -1, TimedOut: true, Killed: killErr == nil, Truncated: truncated}
+		if killErr == nil {
+			result.Error = fmt.Sprintf("命令执行超时（%s），已终止进程", timeout)
+		} else {
+			result.Error = fmt.Sprintf("命令执行超时（%s），进程可能仍在运行: %v", timeout, killErr)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
 	if err != nil && err != io.EOF {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(ExecResponse{Error: fmt.Sprintf("读取输出失败: %v", err)})
+		json.NewEncoder(w).Encode(ExecResponse{Output: output, Error: fmt.Sprintf("读取输出失败: %v", err), Truncated: truncated})
 		return
 	}
 
 	// 获取退出码
-	inspectResp, err := dockerClient.ContainerExecInspect(ctx, execID.ID)
+	inspectResp, err := getDockerClient().ContainerExecInspect(ctx, execID.ID)
 	exitCode := 0
 	if err == nil {
 		exitCode = inspectResp.ExitCode
 	}
 
-	// 合并输出
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		if output != "" {
-			output += "\n"
-		}
-		output += stderr.String()
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(ExecResponse{
-		Output:   output,
-		ExitCode: exitCode,
+		Output:    output,
+		ExitCode:  exitCode,
+		Truncated: truncated,
 	})
 }
 
@@ -121,21 +322,22 @@ func handleContainerExec(w http.ResponseWriter, r *http.Request) {
 
 // 文件信息
 type FileInfo struct {
-	Name    string `json:"name"`
-	Path    string `json:"path"`
-	Size    int64  `json:"size"`
-	Mode    string `json:"mode"`
-	ModTime string `json:"mod_time"`
-	IsDir   bool   `json:"is_dir"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	ModTime    string `json:"mod_time"`
+	IsDir      bool   `json:"is_dir"`
+	LinkTarget string `json:"link_target,omitempty"` // 符号链接目标
 }
 
-// 列出目录内容
+// 列出目录内容（优先使用归档 API，不依赖容器内的 ls）
 func handleContainerFilesList(w http.ResponseWriter, r *http.Request) {
 	containerID := r.URL.Query().Get("id")
 	dirPath := r.URL.Query().Get("path")
 
 	if containerID == "" {
-		http.Error(w, "容器ID不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
 		return
 	}
 
@@ -144,25 +346,179 @@ func handleContainerFilesList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	files, err := listFilesByArchive(ctx, containerID, dirPath)
+	cancel()
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "目录不存在")
+			return
+		}
+		if err == errNotDirectory {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不是目录")
+			return
+		}
+
+		// 归档 API 失败时回退到 ls
+		// 使用新的 ctx：归档读取可能已耗尽原来的超时时间
+		log.Printf("[Files] Archive listing failed, fallback to ls: %v", err)
+		lsCtx, lsCancel := context.WithTimeout(context.Background(), 15*time.Second)
+		files, err = listFilesByLs(lsCtx, containerID, dirPath)
+		lsCancel()
+		if err != nil {
+			if err == errDirNotFound {
+				writeError(w, http.StatusNotFound, ErrCodeNotFound, "目录不存在")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+var (
+	errNotDirectory = errors.New("不是目录")
+	errDirNotFound  = errors.New("目录不存在")
+	errArchiveLimit = errors.New("目录过大，超出归档读取上限")
+)
+
+// 归档列目录时最多读取的条目数和数据量（CopyFromContainer 会递归打包整个目录，
+// 超出后改用 ls，避免列出 / 这类大目录时传输整个文件系统）
+const (
+	archiveListMaxEntries = 20000
+	archiveListMaxBytes   = 4 << 20
+)
+
+// 通过 ContainerStatPath + CopyFromContainer 读取 tar 头列出目录
+func listFilesByArchive(ctx context.Context, containerID, dirPath string) ([]FileInfo, error) {
+	stat, err := getDockerClient().ContainerStatPath(ctx, containerID, dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 目录本身是符号链接时，列出链接目标
+	srcPath := dirPath
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		srcPath = stat.LinkTarget
+		stat, err = getDockerClient().ContainerStatPath(ctx, containerID, srcPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !stat.Mode.IsDir() {
+		return nil, errNotDirectory
+	}
+
+	reader, _, err := getDockerClient().CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// 数据量上限在读取时检查：单个大文件的内容在 tr.Next() 跳过时就会被读完
+	tr := tar.NewReader(&cappedReader{r: reader, max: archiveListMaxBytes})
+
+	files := make([]FileInfo, 0)
+	rootName, rootSeen := "", false
+	for entries := 0; ; entries++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entries > archiveListMaxEntries {
+			return nil, errArchiveLimit
+		}
+
+		// 第一个条目是目录本身，其余条目以它为前缀（列出 / 时前缀可能为空）
+		name := normalizeTarName(hdr.Name)
+		if !rootSeen {
+			rootName, rootSeen = name, true
+			continue
+		}
+		rel := name
+		if rootName != "" {
+			rel = strings.TrimPrefix(name, rootName+"/")
+			if rel == name {
+				continue
+			}
+		}
+		if rel == "" || strings.Contains(rel, "/") {
+			continue // 只列出直接子项
+		}
+
+		info := hdr.FileInfo()
+		mode := info.Mode().String()
+		if info.Mode()&os.ModeSymlink != 0 {
+			mode = "l" + mode[1:] // 与 ls 输出保持一致
+		}
+
+		files = append(files, FileInfo{
+			Name:       rel,
+			Path:       path.Join(dirPath, rel),
+			Size:       hdr.Size,
+			Mode:       mode,
+			ModTime:    hdr.ModTime.Local().Format("2006-01-02 15:04"),
+			IsDir:      hdr.Typeflag == tar.TypeDir,
+			LinkTarget: hdr.Linkname,
+		})
+	}
+	return files, nil
+}
+
+// 规范化 tar 条目名（去掉开头的 / 和 ./ 以及结尾的 /）
+func normalizeTarName(name string) string {
+	name = strings.TrimLeft(name, "/")
+	for strings.HasPrefix(name, "./") {
+		name = strings.TrimLeft(strings.TrimPrefix(name, "./"), "/")
+	}
+	if name == "." {
+		return ""
+	}
+	return strings.TrimSuffix(name, "/")
+}
+
+// 读取超过 max 字节时返回 errArchiveLimit
+type cappedReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	// 最多多读一个字节，用于判断是否超出上限
+	if remain := c.max + 1 - c.n; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n > c.max {
+		return n, errArchiveLimit
+	}
+	return n, err
+}
 
-	// 使用 ls 命令列出目录（不使用 --time-style，兼容 BusyBox）
+// 使用 ls 列出目录（归档 API 不可用时的回退方案）
+func listFilesByLs(ctx context.Context, containerID, dirPath string) ([]FileInfo, error) {
+	// 不使用 --time-style，兼容 BusyBox
 	execConfig := types.ExecConfig{
 		AttachStdout: true,
 		AttachStderr: true,
 		Cmd:          []string{"ls", "-la", dirPath},
 	}
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, containerID, execConfig)
+	execID, err := getDockerClient().ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("执行命令失败: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("执行命令失败: %v", err)
 	}
 
-	resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("附加执行失败: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("附加执行失败: %v", err)
 	}
 	defer resp.Close()
 
@@ -172,15 +528,10 @@ func handleContainerFilesList(w http.ResponseWriter, r *http.Request) {
 	// 检查错误输出
 	stderrStr := stderr.String()
 	if stderrStr != "" && (strings.Contains(stderrStr, "No such file") || strings.Contains(stderrStr, "not found")) {
-		http.Error(w, "目录不存在", http.StatusNotFound)
-		return
+		return nil, errDirNotFound
 	}
 
-	// 解析 ls 输出
-	files := parseLsOutput(stdout.String(), dirPath)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(files)
+	return parseLsOutput(stdout.String(), dirPath), nil
 }
 
 // 解析 ls -la 输出（兼容 GNU ls 和 BusyBox ls）
@@ -257,17 +608,24 @@ func parseLsOutput(output string, basePath string) []FileInfo {
 // 创建目录
 func handleContainerFileMkdir(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
 	var req struct {
 		ContainerID string `json:"container_id"`
 		Path        string `json:"path"`
+		User        string `json:"user,omitempty"` // 以指定用户创建，使目录属主正确
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	opts := ExecOptions{User: req.User}
+	if err := opts.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
@@ -278,16 +636,17 @@ func handleContainerFileMkdir(w http.ResponseWriter, r *http.Request) {
 		AttachStderr: true,
 		Cmd:          []string{"mkdir", "-p", req.Path},
 	}
+	opts.apply(&execConfig)
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, req.ContainerID, execConfig)
+	execID, err := getDockerClient().ContainerExecCreate(ctx, req.ContainerID, execConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("创建目录失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建目录失败: %v", err))
 		return
 	}
 
-	resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("执行失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
 		return
 	}
 	defer resp.Close()
@@ -296,7 +655,7 @@ func handleContainerFileMkdir(w http.ResponseWriter, r *http.Request) {
 	stdcopy.StdCopy(io.Discard, &stderr, resp.Reader)
 
 	if stderr.Len() > 0 {
-		http.Error(w, stderr.String(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, stderr.String())
 		return
 	}
 
@@ -304,10 +663,40 @@ func handleContainerFileMkdir(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// 系统关键目录（禁止删除或递归修改权限）
+var protectedPaths = []string{"/", "/bin", "/sbin", "/usr", "/lib", "/etc", "/var", "/root", "/home"}
+
+// 虚拟文件系统，其下的任何路径都禁止修改
+var protectedTrees = []string{"/proc", "/sys", "/dev"}
+
+// 是否为系统关键目录（相对路径无法确定实际位置，一律视为受保护）
+func isProtectedPath(p string) bool {
+	if !path.IsAbs(p) {
+		return true
+	}
+	cleanPath := path.Clean(p)
+	for _, pp := range protectedPaths {
+		if cleanPath == pp {
+			return true
+		}
+	}
+	for _, tree := range protectedTrees {
+		if cleanPath == tree || strings.HasPrefix(cleanPath, tree+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 检查路径及其解析符号链接后的实际路径是否受保护
+func isProtectedContainerPath(ctx context.Context, containerID, p string) bool {
+	return isProtectedPath(p) || isProtectedPath(resolveContainerPath(ctx, containerID, p))
+}
+
 // 删除文件或目录
 func handleContainerFileDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -317,37 +706,39 @@ func handleContainerFileDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
-	// 安全检查：禁止删除根目录和关键系统目录
-	dangerousPaths := []string{"/", "/bin", "/sbin", "/usr", "/lib", "/etc", "/var", "/root", "/home"}
-	cleanPath := path.Clean(req.Path)
-	for _, dp := range dangerousPaths {
-		if cleanPath == dp {
-			http.Error(w, "禁止删除系统关键目录", http.StatusForbidden)
-			return
-		}
+	p, err := cleanContainerPath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// 安全检查：禁止删除根目录和关键系统目录（包括指向它们的符号链接）
+	if isProtectedContainerPath(ctx, req.ContainerID, p) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止删除系统关键目录")
+		return
+	}
+
 	execConfig := types.ExecConfig{
 		AttachStderr: true,
-		Cmd:          []string{"rm", "-rf", req.Path},
+		Cmd:          []string{"rm", "-rf", "--", p},
 	}
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, req.ContainerID, execConfig)
+	execID, err := getDockerClient().ContainerExecCreate(ctx, req.ContainerID, execConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("删除失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
 		return
 	}
 
-	resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("执行失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
 		return
 	}
 	defer resp.Close()
@@ -356,7 +747,7 @@ func handleContainerFileDelete(w http.ResponseWriter, r *http.Request) {
 	stdcopy.StdCopy(io.Discard, &stderr, resp.Reader)
 
 	if stderr.Len() > 0 {
-		http.Error(w, stderr.String(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, stderr.String())
 		return
 	}
 
@@ -364,13 +755,21 @@ func handleContainerFileDelete(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
-// 上传文件到容器
+// 上传文件到容器（multipart 请求以流的方式写入，JSON 请求适用于小文件）
 func handleContainerFileUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		handleContainerMultipartUpload(w, r)
+		return
+	}
+
+	// Base64 编码后体积约为原文件的 4/3
+	r.Body = http.MaxBytesReader(w, r.Body, uploadMaxSize/3*4+4096)
+
 	var req struct {
 		ContainerID string `json:"container_id"`
 		Path        string `json:"path"`     // 目标目录
@@ -379,14 +778,16 @@ func handleContainerFileUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		if !writeUploadTooLarge(w, err) {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		}
 		return
 	}
 
 	// 解码 Base64 内容
 	fileContent, err := base64.StdEncoding.DecodeString(req.Content)
 	if err != nil {
-		http.Error(w, "文件内容解码失败", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "文件内容解码失败")
 		return
 	}
 
@@ -404,29 +805,32 @@ func handleContainerFileUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := tw.WriteHeader(hdr); err != nil {
-		http.Error(w, fmt.Sprintf("创建归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建归档失败: %v", err))
 		return
 	}
 
 	if _, err := tw.Write(fileContent); err != nil {
-		http.Error(w, fmt.Sprintf("写入归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("写入归档失败: %v", err))
 		return
 	}
 
 	if err := tw.Close(); err != nil {
-		http.Error(w, fmt.Sprintf("关闭归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("关闭归档失败: %v", err))
 		return
 	}
 
 	// 复制到容器
-	err = dockerClient.CopyToContainer(ctx, req.ContainerID, req.Path, &buf, types.CopyToContainerOptions{})
+	err = getDockerClient().CopyToContainer(ctx, req.ContainerID, req.Path, &buf, types.CopyToContainerOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("上传失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("上传失败: %v", err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"bytes":  len(fileContent),
+	})
 }
 
 // 从容器下载文件
@@ -435,95 +839,317 @@ func handleContainerFileDownload(w http.ResponseWriter, r *http.Request) {
 	filePath := r.URL.Query().Get("path")
 
 	if containerID == "" || filePath == "" {
-		http.Error(w, "参数不完整", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "参数不完整")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	// 目录打包下载可能较慢，超时放宽到 10 分钟
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
+	// 符号链接下载其指向的文件
+	srcPath := filePath
+	if stat, err := getDockerClient().ContainerStatPath(ctx, containerID, filePath); err == nil &&
+		stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		srcPath = stat.LinkTarget
+	}
+
 	// 从容器复制文件
-	reader, stat, err := dockerClient.CopyFromContainer(ctx, containerID, filePath)
+	reader, stat, err := getDockerClient().CopyFromContainer(ctx, containerID, srcPath)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("下载失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("下载失败: %v", err))
 		return
 	}
 	defer reader.Close()
 
-	// 解析 tar 归档
+	fileName := path.Base(filePath)
+
+	// 目录：将整个 tar 流压缩为 tar.gz 输出（大小未知，不设置 Content-Length）
+	if stat.Mode.IsDir() {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.tar.gz\"", fileName))
+
+		gw := gzip.NewWriter(w)
+		if _, err := io.Copy(gw, reader); err != nil {
+			log.Printf("[Files] Download directory failed: %v", err)
+			return
+		}
+		if err := gw.Close(); err != nil {
+			log.Printf("[Files] Download directory failed: %v", err)
+		}
+		return
+	}
+
+	// 文件：解析 tar 归档，输出第一个条目的内容
 	tr := tar.NewReader(reader)
 	hdr, err := tr.Next()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("读取文件失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取文件失败: %v", err))
 		return
 	}
 
-	// 设置响应头
-	fileName := path.Base(filePath)
-	if stat.Mode.IsDir() {
-		fileName += ".tar"
-		w.Header().Set("Content-Type", "application/x-tar")
-	} else {
-		w.Header().Set("Content-Type", "application/octet-stream")
-	}
+	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", hdr.Size))
+	if stat.Mode.IsRegular() && hdr.Typeflag == tar.TypeReg {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", hdr.Size))
+	}
 
 	// 写入响应
 	io.Copy(w, tr)
 }
 
-// 读取文件内容（用于编辑）
+// 编辑器读取文件的大小上限
+const maxEditorReadSize = 1 << 20
+
+// 二进制检测时检查的前缀长度
+const binarySniffSize = 8000
+
+// 根据文件开头判断是否为二进制（包含 NUL 字节或不是合法 UTF-8）
+func isBinaryContent(data []byte) bool {
+	if len(data) > binarySniffSize {
+		data = data[:binarySniffSize]
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	// 截断处可能切开多字节字符，忽略末尾不完整的部分
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return !utf8.Valid(data)
+}
+
+// 文件读取结果
+type FileContent struct {
+	Content   string `json:"content"`
+	Encoding  string `json:"encoding"`  // utf-8 / base64，二进制且未请求 base64 时为空
+	Truncated bool   `json:"truncated"` // 是否只返回了文件的一部分
+	Size      int64  `json:"size"`
+	Binary    bool   `json:"binary"`
+	Offset    int64  `json:"offset"` // 返回内容在文件中的起始位置
+	Length    int64  `json:"length"` // 返回内容的字节数
+}
+
+// dd 读取时使用的块大小（按块对齐以兼容 BusyBox 的 dd）
+const ddBlockSize = 4096
+
+// 读取文件的指定字节范围：优先在容器内执行 dd，失败时在归档流中跳过前面的内容
+func readFileRange(ctx context.Context, containerID, filePath string, offset, length int64) ([]byte, error) {
+	skip := offset / ddBlockSize
+	count := (offset%ddBlockSize + length + ddBlockSize - 1) / ddBlockSize
+	res, err := runContainerExec(ctx, containerID, []string{
+		"dd", "if=" + filePath, "bs=" + strconv.Itoa(ddBlockSize),
+		"skip=" + strconv.FormatInt(skip, 10), "count=" + strconv.FormatInt(count, 10),
+	})
+	if err == nil && res.ExitCode == 0 {
+		data := []byte(res.Stdout)
+		start := offset % ddBlockSize
+		if start > int64(len(data)) {
+			return nil, nil
+		}
+		data = data[start:]
+		if int64(len(data)) > length {
+			data = data[:length]
+		}
+		return data, nil
+	}
+
+	reader, _, err := getDockerClient().CopyFromContainer(ctx, containerID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	if _, err := tr.Next(); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, tr, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(tr, length))
+}
+
+// 读取文件末尾的 lines 行（最多 maxEditorReadSize 字节），返回内容及其起始位置
+func readFileTail(ctx context.Context, containerID, filePath string, size int64, lines int) ([]byte, int64, error) {
+	window := min(size, maxEditorReadSize)
+	var data []byte
+	res, err := runContainerExec(ctx, containerID, []string{"tail", "-c", strconv.FormatInt(window, 10), filePath})
+	if err == nil && res.ExitCode == 0 {
+		data = []byte(res.Stdout)
+	} else {
+		data, err = readFileRange(ctx, containerID, filePath, size-window, window)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	start := size - int64(len(data))
+
+	// 从末尾向前数 lines 个换行（忽略文件最后的换行符）
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	cut := -1
+	for i, found := end-1, 0; i >= 0; i-- {
+		if data[i] == '\n' {
+			found++
+			if found == lines {
+				cut = i
+				break
+			}
+		}
+	}
+	switch {
+	case cut >= 0:
+		data = data[cut+1:]
+		start += int64(cut + 1)
+	case start > 0:
+		// 窗口内行数不足且没有从文件开头读取，丢弃不完整的第一行
+		if i := bytes.IndexByte(data, '\n'); i >= 0 && i < end {
+			data = data[i+1:]
+			start += int64(i + 1)
+		}
+	}
+	return data, start, nil
+}
+
+// 读取文件内容
+// 参数：offset/length 读取指定字节范围；tail=true&lines=N 读取末尾 N 行；
+// encoding=base64 时以 base64 返回二进制文件
 func handleContainerFileRead(w http.ResponseWriter, r *http.Request) {
-	containerID := r.URL.Query().Get("id")
-	filePath := r.URL.Query().Get("path")
+	query := r.URL.Query()
+	containerID := query.Get("id")
+	filePath := query.Get("path")
+	encoding := query.Get("encoding")
 
 	if containerID == "" || filePath == "" {
-		http.Error(w, "参数不完整", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "参数不完整")
+		return
+	}
+	if encoding != "" && encoding != "utf-8" && encoding != "base64" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "encoding 只支持 utf-8 或 base64")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	// 使用 cat 命令读取文件（限制大小）
-	execConfig := types.ExecConfig{
-		AttachStdout: true,
-		AttachStderr: true,
-		Cmd:          []string{"head", "-c", "1048576", filePath}, // 限制 1MB
+	var offset int64
+	length := int64(maxEditorReadSize)
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "offset 参数无效")
+			return
+		}
+		offset = n
+	}
+	if v := query.Get("length"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "length 参数无效")
+			return
+		}
+		length = min(n, maxEditorReadSize)
+	}
+	tail := query.Get("tail") == "true"
+	lines := 100
+	if v := query.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "lines 参数无效")
+			return
+		}
+		lines = min(n, 10000)
 	}
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, containerID, execConfig)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 符号链接读取其指向的文件
+	stat, err := getDockerClient().ContainerStatPath(ctx, containerID, filePath)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("读取失败: %v", err), http.StatusInternalServerError)
+		if client.IsErrNotFound(err) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "文件不存在")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取失败: %v", err))
+		return
+	}
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" {
+		filePath = stat.LinkTarget
+		stat, err = getDockerClient().ContainerStatPath(ctx, containerID, filePath)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取失败: %v", err))
+			return
+		}
+	}
+	if !stat.Mode.IsRegular() {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不是普通文件")
 		return
 	}
 
-	resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	var data []byte
+	if tail {
+		data, offset, err = readFileTail(ctx, containerID, filePath, stat.Size, lines)
+	} else if offset < stat.Size {
+		data, err = readFileRange(ctx, containerID, filePath, offset, length)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("执行失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取失败: %v", err))
 		return
 	}
-	defer resp.Close()
 
-	var stdout, stderr bytes.Buffer
-	stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	// 从文件中间开始时，开头可能切开多字节字符
+	sniff := data
+	for i := 0; offset > 0 && i < utf8.UTFMax-1 && len(sniff) > 0 && !utf8.RuneStart(sniff[0]); i++ {
+		sniff = sniff[1:]
+	}
 
-	if stderr.Len() > 0 && strings.Contains(stderr.String(), "No such file") {
-		http.Error(w, "文件不存在", http.StatusNotFound)
-		return
+	result := FileContent{
+		Size:      stat.Size,
+		Binary:    isBinaryContent(sniff),
+		Offset:    min(offset, stat.Size),
+		Length:    int64(len(data)),
+		Truncated: offset > 0 || offset+int64(len(data)) < stat.Size,
+	}
+	switch {
+	case result.Binary && encoding == "base64":
+		result.Content = base64.StdEncoding.EncodeToString(data)
+		result.Encoding = "base64"
+	case result.Binary:
+		// 未明确请求时不返回二进制内容，避免编辑器显示乱码
+	default:
+		result.Content = string(data)
+		result.Encoding = "utf-8"
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"content": stdout.String(),
-	})
+	json.NewEncoder(w).Encode(result)
+}
+
+// 读取容器中已有文件的 tar 头（包含权限、属主等信息），并检测是否为二进制
+func existingFileHeader(ctx context.Context, containerID, filePath string) (*tar.Header, bool, error) {
+	reader, _, err := getDockerClient().CopyFromContainer(ctx, containerID, filePath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, false, err
+	}
+	head, err := io.ReadAll(io.LimitReader(tr, binarySniffSize))
+	if err != nil {
+		return nil, false, err
+	}
+	return hdr, isBinaryContent(head), nil
 }
 
 // 写入文件内容
 func handleContainerFileWrite(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -531,48 +1157,89 @@ func handleContainerFileWrite(w http.ResponseWriter, r *http.Request) {
 		ContainerID string `json:"container_id"`
 		Path        string `json:"path"`
 		Content     string `json:"content"`
+		Mode        string `json:"mode,omitempty"`  // 新建文件的权限（八进制），已有文件保持原权限
+		Force       bool   `json:"force,omitempty"` // 允许覆盖二进制文件
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	if req.Mode != "" && !fileModePattern.MatchString(req.Mode) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "权限格式无效（应为八进制，如 644 或 0755）")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// 已有文件保留原来的权限和属主（符号链接写入其指向的文件）
+	targetPath := req.Path
+	hdr, binary, err := existingFileHeader(ctx, req.ContainerID, targetPath)
+	if err == nil && hdr.Typeflag == tar.TypeSymlink {
+		if stat, statErr := getDockerClient().ContainerStatPath(ctx, req.ContainerID, targetPath); statErr == nil && stat.LinkTarget != "" {
+			targetPath = stat.LinkTarget
+			hdr, binary, err = existingFileHeader(ctx, req.ContainerID, targetPath)
+		}
+	}
+	switch {
+	case err == nil && hdr.Typeflag != tar.TypeReg:
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "目标不是普通文件")
+		return
+	case err == nil && binary && !req.Force:
+		writeError(w, http.StatusConflict, ErrCodeConflict, "目标是二进制文件，覆盖请设置 force=true")
+		return
+	case err == nil:
+		hdr = &tar.Header{
+			Mode:  hdr.Mode & 07777,
+			Uid:   hdr.Uid,
+			Gid:   hdr.Gid,
+			Uname: hdr.Uname,
+			Gname: hdr.Gname,
+		}
+	case client.IsErrNotFound(err):
+		mode := int64(0644)
+		if req.Mode != "" {
+			mode, _ = strconv.ParseInt(req.Mode, 8, 64)
+		}
+		hdr = &tar.Header{Mode: mode}
+	default:
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取文件信息失败: %v", err))
+		return
+	}
+
 	// 创建 tar 归档
 	var buf bytes.Buffer
 	tw := tar.NewWriter(&buf)
 
-	fileName := path.Base(req.Path)
-	dirPath := path.Dir(req.Path)
+	fileName := path.Base(targetPath)
+	dirPath := path.Dir(targetPath)
 
-	hdr := &tar.Header{
-		Name: fileName,
-		Mode: 0644,
-		Size: int64(len(req.Content)),
-	}
+	hdr.Name = fileName
+	hdr.Typeflag = tar.TypeReg
+	hdr.Size = int64(len(req.Content))
+	hdr.ModTime = time.Now()
 
 	if err := tw.WriteHeader(hdr); err != nil {
-		http.Error(w, fmt.Sprintf("创建归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建归档失败: %v", err))
 		return
 	}
 
 	if _, err := tw.Write([]byte(req.Content)); err != nil {
-		http.Error(w, fmt.Sprintf("写入归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("写入归档失败: %v", err))
 		return
 	}
 
 	if err := tw.Close(); err != nil {
-		http.Error(w, fmt.Sprintf("关闭归档失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("关闭归档失败: %v", err))
 		return
 	}
 
 	// 复制到容器
-	err := dockerClient.CopyToContainer(ctx, req.ContainerID, dirPath, &buf, types.CopyToContainerOptions{})
+	err = getDockerClient().CopyToContainer(ctx, req.ContainerID, dirPath, &buf, types.CopyToContainerOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("写入失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("写入失败: %v", err))
 		return
 	}
 
@@ -586,14 +1253,14 @@ func handleContainerFileWrite(w http.ResponseWriter, r *http.Request) {
 func handleContainerInspect(w http.ResponseWriter, r *http.Request) {
 	containerID := r.URL.Query().Get("id")
 	if containerID == "" {
-		http.Error(w, "容器ID不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
 		return
 	}
 
 	ctx := context.Background()
-	info, err := dockerClient.ContainerInspect(ctx, containerID)
+	info, err := getDockerClient().ContainerInspect(ctx, containerID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取容器信息失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取容器信息失败: %v", err))
 		return
 	}
 
@@ -685,6 +1352,10 @@ func handleContainerInspect(w http.ResponseWriter, r *http.Request) {
 		"restart":         string(info.HostConfig.RestartPolicy.Name),
 		"restartMaxRetry": info.HostConfig.RestartPolicy.MaximumRetryCount,
 
+		// 启动依赖
+		"dependsOn":  containerDepsOf(strings.TrimPrefix(info.Name, "/")),
+		"dependents": containerDependents(strings.TrimPrefix(info.Name, "/")),
+
 		// 资源限制
 		"memory":       info.HostConfig.Memory,
 		"memorySwap":   info.HostConfig.MemorySwap,
@@ -719,7 +1390,7 @@ func handleContainerInspect(w http.ResponseWriter, r *http.Request) {
 // 更新容器配置（需要重建容器）
 func handleContainerUpdate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -732,7 +1403,7 @@ func handleContainerUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
@@ -762,16 +1433,14 @@ func handleContainerUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 更新容器
-	_, err := dockerClient.ContainerUpdate(ctx, req.ContainerID, updateConfig)
+	_, err := getDockerClient().ContainerUpdate(ctx, req.ContainerID, updateConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("更新失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("更新失败: %v", err))
 		return
 	}
 
 	// 清除缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -780,7 +1449,7 @@ func handleContainerUpdate(w http.ResponseWriter, r *http.Request) {
 // 重命名容器
 func handleContainerRename(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -790,21 +1459,32 @@ func handleContainerRename(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	ctx := context.Background()
-	err := dockerClient.ContainerRename(ctx, req.ContainerID, req.NewName)
+	var oldName string
+	if info, err := getDockerClient().ContainerInspect(ctx, req.ContainerID); err == nil {
+		oldName = strings.TrimPrefix(info.Name, "/")
+	}
+	err := getDockerClient().ContainerRename(ctx, req.ContainerID, req.NewName)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("重命名失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("重命名失败: %v", err))
 		return
 	}
+	// 依赖和收藏按名称保存，随容器一起改名
+	if oldName != "" {
+		if err := renameContainerDeps(oldName, strings.TrimPrefix(req.NewName, "/")); err != nil {
+			log.Printf("[Deps] Rename %s dependencies failed: %v", oldName, err)
+		}
+		if err := renameContainerFavorites(oldName, strings.TrimPrefix(req.NewName, "/")); err != nil {
+			log.Printf("[Favorites] Rename %s favorites failed: %v", oldName, err)
+		}
+	}
 
 	// 清除缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -827,6 +1507,7 @@ type RecreateContainerRequest struct {
 	CPUs        float64           `json:"cpus"`
 	Privileged  bool              `json:"privileged"`
 	TTY         bool              `json:"tty"`
+	Force       bool              `json:"force"` // 忽略主机端口冲突
 }
 
 type PortMapping struct {
@@ -847,33 +1528,48 @@ type EnvVar struct {
 // 重建容器处理
 func handleContainerRecreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
 	var req RecreateContainerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误: "+err.Error())
 		return
 	}
 
+	// 检查端口占用（排除旧容器自己占用的端口）
+	if !req.Force {
+		ports := make([]portRequest, 0, len(req.Ports))
+		for _, p := range req.Ports {
+			if p.Host != "" && p.Container != "" {
+				_, proto, _ := strings.Cut(p.Container, "/")
+				ports = append(ports, portRequest{Port: p.Host, Proto: proto})
+			}
+		}
+		if err := checkPortConflicts(r.Context(), ports, req.ContainerID); err != nil {
+			writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+	}
+
 	ctx := context.Background()
 
 	// 1. 停止旧容器
 	timeout := 10
 	stopOptions := container.StopOptions{Timeout: &timeout}
-	if err := dockerClient.ContainerStop(ctx, req.ContainerID, stopOptions); err != nil {
+	if err := getDockerClient().ContainerStop(ctx, req.ContainerID, stopOptions); err != nil {
 		// 忽略已停止的容器错误
 		if !strings.Contains(err.Error(), "is not running") {
-			http.Error(w, "停止容器失败: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "停止容器失败: "+err.Error())
 			return
 		}
 	}
 
 	// 2. 删除旧容器
 	removeOptions := container.RemoveOptions{Force: true}
-	if err := dockerClient.ContainerRemove(ctx, req.ContainerID, removeOptions); err != nil {
-		http.Error(w, "删除容器失败: "+err.Error(), http.StatusInternalServerError)
+	if err := getDockerClient().ContainerRemove(ctx, req.ContainerID, removeOptions); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "删除容器失败: "+err.Error())
 		return
 	}
 
@@ -944,22 +1640,20 @@ func handleContainerRecreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 4. 创建新容器
-	resp, err := dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, req.Name)
+	resp, err := getDockerClient().ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, req.Name)
 	if err != nil {
-		http.Error(w, "创建容器失败: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "创建容器失败: "+err.Error())
 		return
 	}
 
 	// 5. 启动新容器
-	if err := dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		http.Error(w, "启动容器失败: "+err.Error(), http.StatusInternalServerError)
+	if err := getDockerClient().ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "启动容器失败: "+err.Error())
 		return
 	}
 
 	// 清除缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -988,7 +1682,7 @@ type ContainerStats struct {
 func handleContainerStats(w http.ResponseWriter, r *http.Request) {
 	containerID := r.URL.Query().Get("id")
 	if containerID == "" {
-		http.Error(w, "容器ID不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
 		return
 	}
 
@@ -996,19 +1690,27 @@ func handleContainerStats(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// 获取容器统计信息（非流式，只获取一次）
-	statsResp, err := dockerClient.ContainerStats(ctx, containerID, false)
+	statsResp, err := getDockerClient().ContainerStats(ctx, containerID, false)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取统计信息失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取统计信息失败: %v", err))
 		return
 	}
 	defer statsResp.Body.Close()
 
 	var stats types.StatsJSON
 	if err := json.NewDecoder(statsResp.Body).Decode(&stats); err != nil {
-		http.Error(w, fmt.Sprintf("解析统计信息失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("解析统计信息失败: %v", err))
 		return
 	}
 
+	result := calcContainerStats(&stats)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// 根据 Docker 原始统计计算资源使用
+func calcContainerStats(stats *types.StatsJSON) ContainerStats {
 	// 计算 CPU 使用率
 	cpuPercent := 0.0
 	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
@@ -1041,7 +1743,7 @@ func handleContainerStats(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result := ContainerStats{
+	return ContainerStats{
 		CPUPercent:    cpuPercent,
 		CPUCores:      int(stats.CPUStats.OnlineCPUs),
 		MemoryUsage:   int64(stats.MemoryStats.Usage),
@@ -1053,9 +1755,172 @@ func handleContainerStats(w http.ResponseWriter, r *http.Request) {
 		BlockWrite:    blockWrite,
 		PIDs:          stats.PidsStats.Current,
 	}
+}
+
+// ========== 批量容器资源统计 ==========
+
+// 单个容器的资源统计（批量接口使用）
+type ContainerStatsItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	ContainerStats
+}
+
+// 批量统计的并发数和缓存时间
+const (
+	bulkStatsConcurrency = 8
+	bulkStatsCacheTTL    = 5 * time.Second
+)
+
+// 批量统计缓存
+var (
+	bulkStatsCache struct {
+		sync.Mutex
+		data      []ContainerStatsItem
+		skipped   int
+		lastFetch time.Time
+	}
+)
+
+// 采集所有运行中容器的资源统计（带缓存，限制并发），返回统计结果和获取失败被跳过的容器数。
+// 采集使用独立的 ctx：持有锁期间其他请求都在等待结果，不能因为第一个请求断开而得到空结果
+func collectAllContainerStats() ([]ContainerStatsItem, int, error) {
+	// 持有锁期间采集，避免并发请求重复调用 ContainerStats
+	bulkStatsCache.Lock()
+	defer bulkStatsCache.Unlock()
+
+	if bulkStatsCache.data != nil && time.Since(bulkStatsCache.lastFetch) < bulkStatsCacheTTL {
+		return bulkStatsCache.data, bulkStatsCache.skipped, nil
+	}
+
+	ctx, cancel := context.WithTimeout(serverCtx, 30*time.Second)
+	defer cancel()
+
+	containers, err := getDockerClient().ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]ContainerStatsItem, len(containers))
+	ok := make([]bool, len(containers))
+	sem := make(chan struct{}, bulkStatsConcurrency)
+	var wg sync.WaitGroup
+
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, c types.Container) {
+			defer recoverGoroutine(ctx, "container stats")
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			statsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+
+			// 非流式请求会等待一个采样周期，返回的数据包含 PreCPUStats，可计算 CPU 使用率
+			resp, err := getDockerClient().ContainerStats(statsCtx, c.ID, false)
+			if err != nil {
+				log.Printf("[Stats] Get stats of %s failed: %v", c.ID[:12], err)
+				return
+			}
+			defer resp.Body.Close()
+
+			var stats types.StatsJSON
+			if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+				log.Printf("[Stats] Decode stats of %s failed: %v", c.ID[:12], err)
+				return
+			}
+
+			name := c.ID[:12]
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			items[i] = ContainerStatsItem{
+				ID:             c.ID[:12],
+				Name:           name,
+				ContainerStats: calcContainerStats(&stats),
+			}
+			ok[i] = true
+		}(i, c)
+	}
+	wg.Wait()
+
+	result := make([]ContainerStatsItem, 0, len(items))
+	for i, item := range items {
+		if ok[i] {
+			result = append(result, item)
+		}
+	}
+	skipped := len(containers) - len(result)
+
+	// 超时或多数容器获取失败时不缓存，下一次请求重新采集
+	if ctx.Err() == nil && skipped*2 <= len(containers) {
+		bulkStatsCache.data = result
+		bulkStatsCache.skipped = skipped
+		bulkStatsCache.lastFetch = time.Now()
+	}
+	return result, skipped, nil
+}
+
+// 获取失败被跳过的容器数通过响应头返回
+const statsSkippedHeader = "X-Stats-Skipped"
+
+// 获取所有运行中容器的资源统计
+func handleContainerStatsAll(w http.ResponseWriter, r *http.Request) {
+	items, skipped, err := collectAllContainerStats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取统计信息失败: %v", err))
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	w.Header().Set(statsSkippedHeader, strconv.Itoa(skipped))
+	json.NewEncoder(w).Encode(items)
+}
+
+// 资源占用最高的容器（?by=memory|cpu&limit=5）
+func handleTopContainers(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "memory"
+	}
+	if by != "memory" && by != "cpu" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "by 参数只支持 memory 或 cpu")
+		return
+	}
+
+	limit := 5
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
+
+	items, skipped, err := collectAllContainerStats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取统计信息失败: %v", err))
+		return
+	}
+
+	// 复制一份再排序，不修改缓存
+	sorted := make([]ContainerStatsItem, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool {
+		if by == "cpu" {
+			return sorted[i].CPUPercent > sorted[j].CPUPercent
+		}
+		return sorted[i].MemoryUsage > sorted[j].MemoryUsage
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(statsSkippedHeader, strconv.Itoa(skipped))
+	json.NewEncoder(w).Encode(sorted)
 }
 
 // ========== WebSocket 交互式终端 ==========
@@ -1068,11 +1933,52 @@ var wsUpgrader = websocket.Upgrader{
 	},
 }
 
+// WebSocket 心跳参数
+const (
+	wsPingInterval   = 30 * time.Second // 服务端发送 ping 的间隔（需小于代理的空闲超时，如 nginx 默认 60 秒）
+	wsPongWait       = 75 * time.Second // 超过该时间未收到 pong 视为连接已断开
+	wsWriteWait      = 10 * time.Second // 发送控制帧的超时
+	wsMaxMessageSize = 1 << 20          // 单条消息上限（粘贴大段文本时）
+)
+
+// 为 WebSocket 连接启用心跳：定期发送 ping，收到 pong 时延长读取截止时间。
+// 对端失联时 ReadMessage 会因超时返回错误，调用方按连接断开处理；连接结束时调用返回的 stop
+func startWSKeepalive(conn *websocket.Conn) (stop func()) {
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer recoverGoroutine(context.Background(), "websocket keepalive")
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl 可以与其他写操作并发调用
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
 // WebSocket 终端处理
 func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 	containerID := r.URL.Query().Get("id")
 	if containerID == "" {
-		http.Error(w, "容器ID不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
 		return
 	}
 
@@ -1086,25 +1992,60 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("[Terminal] WebSocket connected, container: %s", containerID)
 
+	stopKeepalive := startWSKeepalive(conn)
+	defer stopKeepalive()
+
 	ctx := context.Background()
 
-	// 检测容器中可用的 shell
-	shell := detectShell(ctx, containerID)
-	log.Printf("[Terminal] Using shell: %s for container: %s", shell, containerID)
+	writeError := func(msg string) {
+		conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[31mError: "+msg+"\x1b[0m\r\n"))
+	}
+
+	// 指定的 shell 和用户（可选），未指定 shell 时自动检测
+	shell := r.URL.Query().Get("shell")
+	user := r.URL.Query().Get("user")
+	if shell != "" && (!terminalShellPattern.MatchString(shell) || strings.Contains(shell, "..")) {
+		writeError("shell 格式无效: " + shell)
+		return
+	}
+	if user != "" && !execUserPattern.MatchString(user) {
+		writeError("用户格式无效（应为 user、uid 或 user:group、uid:gid）: " + user)
+		return
+	}
+	if shell != "" {
+		if err := probeShell(ctx, containerID, shell, user); err != nil {
+			writeError(fmt.Sprintf("无法启动 %s: %v", shell, err))
+			return
+		}
+	} else {
+		shell = detectShell(ctx, containerID, user)
+	}
+	log.Printf("[Terminal] Using shell: %s, user: %q for container: %s", shell, user, containerID)
 
-	// 创建 exec 实例
+	username := r.Header.Get("X-Username")
+	session, err := registerTerminal("container", username, containerID, shell, r.RemoteAddr)
+	if err != nil {
+		writeError(err.Error())
+		return
+	}
+	defer session.unregister()
+
+	// 创建 exec 实例，带上标记用于会话结束时挂断 shell
+	tag := newExecTag()
 	execConfig := types.ExecConfig{
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          true,
+		User:         user,
+		Env:          []string{tag},
 		Cmd:          []string{shell},
 	}
 
-	execID, err := dockerClient.ContainerExecCreate(ctx, containerID, execConfig)
+	execID, err := getDockerClient().ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
 		log.Printf("[Terminal] Exec create failed: %v", err)
-		conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[31mError: "+err.Error()+"\x1b[0m\r\n"))
+		writeError(err.Error())
 		return
 	}
 
@@ -1113,19 +2054,49 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 		Tty: true,
 	}
 
-	hijackedResp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, execAttachConfig)
+	hijackedResp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, execAttachConfig)
 	if err != nil {
 		log.Printf("[Terminal] Exec attach failed: %v", err)
-		conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[31mError: "+err.Error()+"\x1b[0m\r\n"))
+		writeError(err.Error())
 		return
 	}
 	defer hijackedResp.Close()
 
+	// 告知客户端当前使用的 shell 和用户
+	displayUser := user
+	if displayUser == "" {
+		displayUser = "default"
+		if info, err := getDockerClient().ContainerInspect(ctx, containerID); err == nil && info.Config != nil {
+			if info.Config.User != "" {
+				displayUser = info.Config.User
+			} else {
+				displayUser = "root"
+			}
+		}
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("\x1b[90mShell: %s  User: %s\x1b[0m\r\n", shell, displayUser)))
+
+	// 会话结束时如果 shell 仍在运行，发送 SIGHUP 让其退出，避免残留在容器中
+	defer func() {
+		if inspect, err := getDockerClient().ContainerExecInspect(context.Background(), execID.ID); err == nil && inspect.Running {
+			if err := signalExecProcess(containerID, execID.ID, tag, "HUP"); err != nil {
+				// shell 可能残留在容器中，写入审计日志便于管理员排查
+				log.Printf("[Terminal] Hangup exec %s failed: %v", execID.ID, err)
+				writeAuditLog(username, "terminal_hangup_failed", containerID, fmt.Sprintf("exec %s: %v", execID.ID, err), r.RemoteAddr)
+			}
+		}
+	}()
+
+	// 会话录像（未启用时 recorder 为 nil，调用均为空操作）
+	recorder := startSessionRecording(username, containerID, shell, r.RemoteAddr)
+	defer recorder.close(username, containerID, r.RemoteAddr)
+
 	// 用于通知 goroutine 退出
 	done := make(chan struct{})
 
 	// 从容器读取输出，发送到 WebSocket
 	go func() {
+		defer recoverGoroutine(r.Context(), "terminal output")
 		defer close(done)
 		buf := make([]byte, 4096)
 		for {
@@ -1137,6 +2108,7 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			if n > 0 {
+				recorder.output(buf[:n])
 				if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
 					log.Printf("[Terminal] WebSocket write error: %v", err)
 					return
@@ -1147,6 +2119,9 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 
 	// 从 WebSocket 读取输入，发送到容器
 	go func() {
+		defer recoverGoroutine(r.Context(), "terminal input")
+		// WebSocket 断开（包括心跳超时）时关闭 exec 连接，使输出读取协程退出
+		defer hijackedResp.Close()
 		for {
 			messageType, message, err := conn.ReadMessage()
 			if err != nil {
@@ -1155,6 +2130,7 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 				}
 				return
 			}
+			session.touch()
 
 			// 处理终端大小调整消息
 			if messageType == websocket.TextMessage && len(message) > 0 && message[0] == '{' {
@@ -1165,7 +2141,8 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 				}
 				if err := json.Unmarshal(message, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
 					// 调整终端大小
-					dockerClient.ContainerExecResize(ctx, execID.ID, container.ResizeOptions{
+					recorder.resize(resizeMsg.Cols, resizeMsg.Rows)
+					getDockerClient().ContainerExecResize(ctx, execID.ID, container.ResizeOptions{
 						Height: uint(resizeMsg.Rows),
 						Width:  uint(resizeMsg.Cols),
 					})
@@ -1174,6 +2151,7 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// 发送输入到容器
+			recorder.inputData(message)
 			if _, err := hijackedResp.Conn.Write(message); err != nil {
 				log.Printf("[Terminal] Write to container error: %v", err)
 				return
@@ -1181,38 +2159,61 @@ func handleContainerTerminalWS(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// 等待连接关闭
-	<-done
+	// 等待连接关闭，或会话被管理员/空闲检查关闭
+	select {
+	case <-done:
+	case <-session.closed:
+		log.Printf("[Terminal] Session %s closed: %s", session.ID, session.reason)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, session.reason), time.Now().Add(time.Second))
+	}
 	log.Printf("[Terminal] WebSocket disconnected, container: %s", containerID)
 }
 
+// 终端 shell 参数格式（绝对路径或命令名）
+var terminalShellPattern = regexp.MustCompile(`^[A-Za-z0-9_./+-]+$`)
+
+// 检查 shell 在容器中能否以指定用户运行，不能运行时返回原因
+func probeShell(ctx context.Context, containerID, shell, user string) error {
+	execID, err := getDockerClient().ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		User:         user,
+		Cmd:          []string{shell, "-c", "exit 0"},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	stdcopy.StdCopy(&output, &output, resp.Reader)
+	resp.Close()
+
+	inspectResp, err := getDockerClient().ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return err
+	}
+	if inspectResp.ExitCode != 0 {
+		if msg := strings.TrimSpace(output.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return fmt.Errorf("退出码 %d", inspectResp.ExitCode)
+	}
+	return nil
+}
+
 // 检测容器中可用的 shell
-func detectShell(ctx context.Context, containerID string) string {
+func detectShell(ctx context.Context, containerID, user string) string {
 	// 按优先级尝试不同的 shell
 	shells := []string{"/bin/sh", "/bin/bash", "/bin/ash", "sh"}
 
 	for _, shell := range shells {
 		// 直接尝试运行 shell 并立即退出，检查是否可用
-		execConfig := types.ExecConfig{
-			AttachStdout: true,
-			AttachStderr: true,
-			Cmd:          []string{shell, "-c", "exit 0"},
-		}
-
-		execID, err := dockerClient.ContainerExecCreate(ctx, containerID, execConfig)
-		if err != nil {
-			continue
-		}
-
-		resp, err := dockerClient.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
-		if err != nil {
-			continue
-		}
-		resp.Close()
-
-		// 检查退出码
-		inspectResp, err := dockerClient.ContainerExecInspect(ctx, execID.ID)
-		if err == nil && inspectResp.ExitCode == 0 {
+		if probeShell(ctx, containerID, shell, user) == nil {
 			log.Printf("[Terminal] Detected shell: %s", shell)
 			return shell
 		}
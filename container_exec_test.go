@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 归档数据量上限在读取条目内容时生效，不需要等到下一个 tar 头
+func TestCappedReaderArchive(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "dir/big", Typeflag: tar.TypeReg, Mode: 0644, Size: 1 << 20})
+	tw.Write(make([]byte, 1<<20))
+	tw.Close()
+
+	r := &cappedReader{r: bytes.NewReader(buf.Bytes()), max: 64 << 10}
+	tr := tar.NewReader(r)
+	var err error
+	for err == nil {
+		_, err = tr.Next()
+	}
+	if !errors.Is(err, errArchiveLimit) {
+		t.Fatalf("err = %v, want %v", err, errArchiveLimit)
+	}
+	if r.n > r.max+1 {
+		t.Errorf("read %d bytes, limit %d", r.n, r.max)
+	}
+
+	// 未超出上限时正常读到结尾
+	r = &cappedReader{r: bytes.NewReader(buf.Bytes()), max: int64(buf.Len())}
+	if n, err := io.Copy(io.Discard, r); err != nil || n != int64(buf.Len()) {
+		t.Errorf("within limit: n=%d err=%v", n, err)
+	}
+}
+
+// 编辑器保存已有文件时保留权限和属主，再次保存结果不变
+func TestContainerFileWritePreservesOwnership(t *testing.T) {
+	fd := newFakeDocker(t)
+	fd.add("/etc/secret", &fakeFile{mode: 0600, data: []byte("old secret\n")})
+	fd.add("/home/app/config", &fakeFile{mode: 0644, uid: 1000, gid: 1000, data: []byte("old config\n")})
+
+	cases := []struct {
+		path     string
+		mode     os.FileMode
+		uid, gid int
+	}{
+		{"/etc/secret", 0600, 0, 0},
+		{"/home/app/config", 0644, 1000, 1000},
+	}
+	for _, c := range cases {
+		for round := 1; round <= 2; round++ {
+			content := fmt.Sprintf("new content %d\n", round)
+			body, _ := json.Marshal(map[string]string{"container_id": "c1", "path": c.path, "content": content})
+			rec := httptest.NewRecorder()
+			handleContainerFileWrite(rec, httptest.NewRequest(http.MethodPost, "/api/containers/files/write", bytes.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s round %d: status %d: %s", c.path, round, rec.Code, rec.Body)
+			}
+
+			f := fd.get(c.path)
+			if string(f.data) != content {
+				t.Errorf("%s round %d: content %q, want %q", c.path, round, f.data, content)
+			}
+			if f.mode != c.mode {
+				t.Errorf("%s round %d: mode %v, want %v", c.path, round, f.mode, c.mode)
+			}
+			if f.uid != c.uid || f.gid != c.gid {
+				t.Errorf("%s round %d: owner %d:%d, want %d:%d", c.path, round, f.uid, f.gid, c.uid, c.gid)
+			}
+		}
+	}
+}
+
+// 超大的请求体在解码时就被拒绝，不会整个读入内存
+func TestContainerExecBodyLimit(t *testing.T) {
+	body := `{"container_id":"c1","command":["cat"],"stdin":"` + strings.Repeat("a", maxExecStdinSize/3*4+64<<10) + `"}`
+	rec := httptest.NewRecorder()
+	handleContainerExec(rec, httptest.NewRequest(http.MethodPost, "/api/containers/exec", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// 输出超过上限时只保留末尾部分，并标记为截断
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{limit: 8}
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(b, "line %d\n", i)
+	}
+	if !b.truncated() {
+		t.Error("truncated() = false after writing past the limit")
+	}
+	if got := tailOutput(b.String(), 8); got != "...\nline 99\n" {
+		t.Errorf("tail = %q", got)
+	}
+
+	small := &tailBuffer{limit: 8}
+	small.Write([]byte("ok\n"))
+	if small.truncated() || small.String() != "ok\n" {
+		t.Errorf("small output = %q, truncated %v", small.String(), small.truncated())
+	}
+}
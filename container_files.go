@@ -0,0 +1,1467 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ========== 容器文件操作（重命名、复制等） ==========
+
+// 执行结果
+type execResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// 在容器中执行命令并等待结束（参数直接传递，不经过 shell）
+func runContainerExec(ctx context.Context, containerID string, cmd []string) (*execResult, error) {
+	return runContainerExecAs(ctx, containerID, "", cmd)
+}
+
+// 以指定用户执行命令，user 为空时使用容器的默认用户
+func runContainerExecAs(ctx context.Context, containerID, user string, cmd []string) (*execResult, error) {
+	execID, err := getDockerClient().ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		User:         user,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	inspect, err := getDockerClient().ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &execResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: inspect.ExitCode}, nil
+}
+
+// 命令在容器中不存在（126：无法执行，127：找不到命令）
+func (r *execResult) commandMissing() bool {
+	return r.ExitCode == 126 || r.ExitCode == 127
+}
+
+// 错误信息（优先使用 stderr）
+func (r *execResult) errorMessage() string {
+	if msg := strings.TrimSpace(r.Stderr); msg != "" {
+		return msg
+	}
+	if msg := strings.TrimSpace(r.Stdout); msg != "" {
+		return msg
+	}
+	return fmt.Sprintf("退出码 %d", r.ExitCode)
+}
+
+// 路径是否存在于容器中
+func containerPathExists(ctx context.Context, containerID, p string) (bool, error) {
+	_, err := getDockerClient().ContainerStatPath(ctx, containerID, p)
+	if err == nil {
+		return true, nil
+	}
+	if client.IsErrNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// 移动/复制时被覆盖的目标。目标为目录（或符号链接）时 mv/cp 会把源放进已存在的目录，而不是替换它，
+// 因此先把旧目标移到同级的临时名称，操作成功后再删除，失败时恢复，避免失败时丢失原有内容
+type copyTarget struct {
+	containerID string
+	path        string
+	backup      string // 旧目标的临时名称，为空表示无需替换
+}
+
+// 检查移动/复制的目标，失败时已写入错误响应。
+// 目标已存在时需要 overwrite；源路径位于目标目录内部时拒绝（按解析符号链接后的实际路径判断）
+func prepareCopyTarget(ctx context.Context, w http.ResponseWriter, containerID, src, dst string, overwrite bool) (*copyTarget, bool) {
+	target := &copyTarget{containerID: containerID, path: dst}
+	stat, err := getDockerClient().ContainerStatPath(ctx, containerID, dst)
+	if client.IsErrNotFound(err) {
+		return target, true
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("检查目标路径失败: %v", err))
+		return nil, false
+	}
+	if !overwrite {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "目标已存在（如需覆盖请设置 overwrite）")
+		return nil, false
+	}
+	if !stat.Mode.IsDir() && stat.Mode&os.ModeSymlink == 0 {
+		return target, true // 普通文件由 mv -f / cp 直接替换
+	}
+	if isProtectedContainerPath(ctx, containerID, dst) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止覆盖系统关键目录")
+		return nil, false
+	}
+	srcReal, dstReal := resolveContainerPath(ctx, containerID, src), resolveContainerPath(ctx, containerID, dst)
+	if strings.HasPrefix(src, dst+"/") || srcReal == dstReal || strings.HasPrefix(srcReal, dstReal+"/") {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "源路径位于目标目录内部，不能覆盖")
+		return nil, false
+	}
+
+	// 删除旧目标需要 rm
+	probe, err := runContainerExec(ctx, containerID, []string{"rm", "-f", "--"})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+		return nil, false
+	}
+	if probe.commandMissing() {
+		writeError(w, http.StatusNotImplemented, ErrCodeNotImplemented, fmt.Sprintf("容器中没有 %s 命令", "rm"))
+		return nil, false
+	}
+
+	backup := path.Join(path.Dir(dst), fmt.Sprintf(".%s.rabbit-old-%d", path.Base(dst), time.Now().UnixNano()))
+	if err := moveContainerPath(ctx, containerID, dst, backup); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("移走目标失败: %v", err))
+		return nil, false
+	}
+	target.backup = backup
+	return target, true
+}
+
+// 操作结束后处理旧目标：成功时删除，失败时删除不完整的结果并恢复旧目标
+func (t *copyTarget) finish(succeeded bool) {
+	if t == nil || t.backup == "" {
+		return
+	}
+	// 原请求的 ctx 可能已超时，使用新的 ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if succeeded {
+		if err := removeContainerPath(ctx, t.containerID, t.backup); err != nil {
+			log.Printf("[Files] Remove replaced target %s failed: %v", t.backup, err)
+		}
+		return
+	}
+	if err := removeContainerPath(ctx, t.containerID, t.path); err != nil {
+		log.Printf("[Files] Restore %s failed, old content kept at %s: %v", t.path, t.backup, err)
+		return
+	}
+	if err := moveContainerPath(ctx, t.containerID, t.backup, t.path); err != nil {
+		log.Printf("[Files] Restore %s failed, old content kept at %s: %v", t.path, t.backup, err)
+	}
+}
+
+// 删除容器中的路径（rm -rf）
+func removeContainerPath(ctx context.Context, containerID, p string) error {
+	result, err := runContainerExec(ctx, containerID, []string{"rm", "-rf", "--", p})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return errors.New(result.errorMessage())
+	}
+	return nil
+}
+
+// 在容器中移动路径（dst 不存在）：优先 mv，容器中没有 mv 时通过归档复制后删除源路径
+func moveContainerPath(ctx context.Context, containerID, src, dst string) error {
+	result, err := runContainerExec(ctx, containerID, []string{"mv", "--", src, dst})
+	if err != nil {
+		return err
+	}
+	if !result.commandMissing() {
+		if result.ExitCode != 0 {
+			return errors.New(result.errorMessage())
+		}
+		return nil
+	}
+	if _, err := copyPathByArchive(ctx, containerID, src, dst); err != nil {
+		return err
+	}
+	return removeContainerPath(ctx, containerID, src)
+}
+
+// 通过归档 API 将 src 复制为 dst（不依赖容器内命令），返回复制的条目数
+func copyPathByArchive(ctx context.Context, containerID, src, dst string) (int, error) {
+	reader, _, err := getDockerClient().CopyFromContainer(ctx, containerID, src)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	// 将归档根目录重命名为目标名称后写回容器
+	newBase := path.Base(dst)
+	pr, pw := io.Pipe()
+	entries := 0
+	go func() {
+		defer recoverGoroutineWith(ctx, "copy archive", func(err error) { pw.CloseWithError(err) })
+		tr := tar.NewReader(reader)
+		tw := tar.NewWriter(pw)
+		var rootName string
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			name := normalizeTarName(hdr.Name)
+			if entries == 0 {
+				rootName = name
+			}
+			hdr.Name = rebaseTarName(name, rootName, newBase)
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Name += "/"
+			}
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname = rebaseTarName(normalizeTarName(hdr.Linkname), rootName, newBase)
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			entries++
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+
+	err = getDockerClient().CopyToContainer(ctx, containerID, path.Dir(dst), pr, types.CopyToContainerOptions{})
+	pr.Close()
+	if err != nil {
+		return 0, err
+	}
+	return entries, nil
+}
+
+// 替换 tar 条目名的根目录
+func rebaseTarName(name, oldRoot, newRoot string) string {
+	if name == oldRoot {
+		return newRoot
+	}
+	return newRoot + strings.TrimPrefix(name, oldRoot)
+}
+
+// 文件操作请求的公共校验：路径必须为绝对路径，返回清理后的路径
+func cleanContainerPath(p string) (string, error) {
+	if p == "" || !path.IsAbs(p) {
+		return "", fmt.Errorf("路径必须为绝对路径: %s", p)
+	}
+	return path.Clean(p), nil
+}
+
+// 解析符号链接得到实际路径：优先 readlink -f，容器中没有时使用归档接口的 stat
+// 路径不存在或无法解析时返回原路径
+func resolveContainerPath(ctx context.Context, containerID, p string) string {
+	if res, err := runContainerExec(ctx, containerID, []string{"readlink", "-f", "--", p}); err == nil && res.ExitCode == 0 {
+		if resolved := strings.TrimSpace(res.Stdout); path.IsAbs(resolved) {
+			return path.Clean(resolved)
+		}
+	}
+	if stat, err := getDockerClient().ContainerStatPath(ctx, containerID, p); err == nil && stat.Mode&os.ModeSymlink != 0 && path.IsAbs(stat.LinkTarget) {
+		return path.Clean(stat.LinkTarget)
+	}
+	return p
+}
+
+// 路径 p 是否位于目录 dir 之内（包括 p == dir）。除字面比较外，还比较解析上级目录符号链接后的实际路径：
+// 目标经过指向源目录内部的符号链接时，字面上不相交，实际会递归移动或复制到自身内部。
+// 路径本身不解析（mv、cp -a 操作的是符号链接本身），不存在的目标也能正确比较
+func containerPathWithin(ctx context.Context, containerID, p, dir string) bool {
+	within := func(p, dir string) bool {
+		return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+	}
+	if within(p, dir) {
+		return true
+	}
+	resolveParent := func(p string) string {
+		if p == "/" {
+			return p
+		}
+		return path.Join(resolveContainerPath(ctx, containerID, path.Dir(p)), path.Base(p))
+	}
+	return within(resolveParent(p), resolveParent(dir))
+}
+
+// 重命名或移动文件
+func handleContainerFileRename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Overwrite   bool   `json:"overwrite"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	src, err := cleanContainerPath(req.Source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	dst, err := cleanContainerPath(req.Destination)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if src == "/" || src == dst {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "源路径和目标路径无效")
+		return
+	}
+	if strings.HasPrefix(dst, src+"/") {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不能将目录移动到自身内部")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	if containerPathWithin(ctx, req.ContainerID, dst, src) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不能将目录移动到自身内部")
+		return
+	}
+	if isProtectedContainerPath(ctx, req.ContainerID, src) || isProtectedContainerPath(ctx, req.ContainerID, dst) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止移动或覆盖系统关键目录")
+		return
+	}
+
+	if exists, err := containerPathExists(ctx, req.ContainerID, src); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("检查源路径失败: %v", err))
+		return
+	} else if !exists {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "源文件不存在")
+		return
+	}
+
+	target, ok := prepareCopyTarget(ctx, w, req.ContainerID, src, dst, req.Overwrite)
+	if !ok {
+		return
+	}
+	succeeded := false
+	defer func() { target.finish(succeeded) }()
+
+	// 优先使用 mv（同一文件系统内为原子操作）
+	strategy := "mv"
+	result, err := runContainerExec(ctx, req.ContainerID, []string{"mv", "-f", "--", src, dst})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+		return
+	}
+
+	if result.commandMissing() {
+		// 容器中没有 mv（如 distroless）：通过归档复制后删除源文件。
+		// 先确认有 rm，否则复制后无法删除源文件，会留下两份
+		probe, err := runContainerExec(ctx, req.ContainerID, []string{"rm", "-f", "--"})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+			return
+		}
+		if probe.commandMissing() {
+			writeError(w, http.StatusNotImplemented, ErrCodeNotImplemented, fmt.Sprintf("容器中没有 %s 命令", "mv、rm"))
+			return
+		}
+		strategy = "archive"
+		if _, err := copyPathByArchive(ctx, req.ContainerID, src, dst); err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("复制失败: %v", err))
+			return
+		}
+		// 已复制到目标，旧目标不再需要恢复
+		succeeded = true
+		if err := removeContainerPath(ctx, req.ContainerID, src); err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("已复制到 %s，但删除源文件失败: %v", dst, err))
+			return
+		}
+	} else if result.ExitCode != 0 {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("移动失败: %s", result.errorMessage()))
+		return
+	}
+	succeeded = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "success",
+		"strategy": strategy,
+	})
+}
+
+// 在容器内复制文件或目录
+func handleContainerFileCopy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Recursive   bool   `json:"recursive"`
+		Overwrite   bool   `json:"overwrite"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	src, err := cleanContainerPath(req.Source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	dst, err := cleanContainerPath(req.Destination)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if src == dst {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "源路径和目标路径相同")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	stat, err := getDockerClient().ContainerStatPath(ctx, req.ContainerID, src)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "源文件不存在")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("检查源路径失败: %v", err))
+		return
+	}
+
+	if stat.Mode.IsDir() {
+		if !req.Recursive {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "复制目录需要设置 recursive")
+			return
+		}
+		if src == "/" || containerPathWithin(ctx, req.ContainerID, dst, src) {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不能将目录复制到自身内部")
+			return
+		}
+	}
+
+	target, ok := prepareCopyTarget(ctx, w, req.ContainerID, src, dst, req.Overwrite)
+	if !ok {
+		return
+	}
+	succeeded := false
+	defer func() { target.finish(succeeded) }()
+
+	response := map[string]interface{}{"status": "success", "strategy": "cp"}
+
+	result, err := runContainerExec(ctx, req.ContainerID, []string{"cp", "-a", "--", src, dst})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+		return
+	}
+
+	if result.commandMissing() {
+		// 容器中没有 cp：通过归档 API 读出后写回同一容器
+		entries, err := copyPathByArchive(ctx, req.ContainerID, src, dst)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("复制失败: %v", err))
+			return
+		}
+		response["strategy"] = "archive"
+		response["entries"] = entries
+	} else if result.ExitCode != 0 {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("复制失败: %v", result.errorMessage()))
+		return
+	}
+	succeeded = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+var (
+	fileModePattern  = regexp.MustCompile(`^[0-7]{3,4}$`)
+	ownerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+	// GNU: chmod: changing permissions of '/a/b': Operation not permitted
+	// BusyBox: chmod: /a/b: Operation not permitted
+	notPermittedQuoted  = regexp.MustCompile(`'([^']+)': Operation not permitted`)
+	notPermittedPattern = regexp.MustCompile(`: ([^:]+): Operation not permitted`)
+)
+
+// 从错误输出中找到权限不足的路径
+func notPermittedPath(stderr string) string {
+	if m := notPermittedQuoted.FindStringSubmatch(stderr); m != nil {
+		return m[1]
+	}
+	if m := notPermittedPattern.FindStringSubmatch(stderr); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+// 执行 chmod/chown 并输出结果
+func runPermissionCommand(w http.ResponseWriter, containerID string, cmd []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	result, err := runContainerExec(ctx, containerID, cmd)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+		return
+	}
+	if result.commandMissing() {
+		writeError(w, http.StatusNotImplemented, ErrCodeNotImplemented, fmt.Sprintf("容器中没有 %s 命令", cmd[0]))
+		return
+	}
+	if result.ExitCode != 0 {
+		if p := notPermittedPath(result.Stderr); p != "" {
+			writeError(w, http.StatusForbidden, ErrCodeForbidden, fmt.Sprintf("操作不允许: %s", p))
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, result.errorMessage())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 带超时的受保护路径检查（会解析符号链接）
+func protectedPathCheck(containerID, p string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return isProtectedContainerPath(ctx, containerID, p)
+}
+
+// 修改文件权限
+func handleContainerFileChmod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Path        string `json:"path"`
+		Mode        string `json:"mode"` // 八进制，如 "644"、"0755"
+		Recursive   bool   `json:"recursive"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	p, err := cleanContainerPath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if !fileModePattern.MatchString(req.Mode) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "权限格式无效（应为八进制，如 644 或 0755）")
+		return
+	}
+	if req.Recursive && protectedPathCheck(req.ContainerID, p) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止递归修改系统关键目录")
+		return
+	}
+
+	cmd := []string{"chmod"}
+	if req.Recursive {
+		cmd = append(cmd, "-R")
+	}
+	runPermissionCommand(w, req.ContainerID, append(cmd, req.Mode, "--", p))
+}
+
+// 修改文件属主
+func handleContainerFileChown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Path        string `json:"path"`
+		Owner       string `json:"owner"` // 用户名或 uid
+		Group       string `json:"group"` // 组名或 gid，可选
+		Recursive   bool   `json:"recursive"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	p, err := cleanContainerPath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if req.Owner == "" && req.Group == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "owner 和 group 不能同时为空")
+		return
+	}
+	if (req.Owner != "" && !ownerNamePattern.MatchString(req.Owner)) ||
+		(req.Group != "" && !ownerNamePattern.MatchString(req.Group)) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "用户或组格式无效")
+		return
+	}
+	if req.Recursive && protectedPathCheck(req.ContainerID, p) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止递归修改系统关键目录")
+		return
+	}
+
+	spec := req.Owner
+	if req.Group != "" {
+		spec += ":" + req.Group
+	}
+
+	cmd := []string{"chown"}
+	if req.Recursive {
+		cmd = append(cmd, "-R")
+	}
+	runPermissionCommand(w, req.ContainerID, append(cmd, spec, "--", p))
+}
+
+// ========== 归档上传（整个目录） ==========
+
+// 上传大小上限（max_upload_size / MAX_UPLOAD_SIZE，单位 MB，默认 1024），对 JSON 和 multipart 上传都生效
+var uploadMaxSize int64 = 1024 << 20
+
+// 上传超出大小上限时的错误响应，返回是否已处理
+func writeUploadTooLarge(w http.ResponseWriter, err error) bool {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("上传内容超过上限 (%d MB)", uploadMaxSize>>20))
+		return true
+	}
+	return false
+}
+
+// 校验归档条目路径，拒绝绝对路径和包含 ".." 的路径
+func safeArchiveName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("归档包含绝对路径: %s", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("归档包含非法路径: %s", name)
+		}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
+// 安全的 tar 写入器：校验路径，拒绝通过归档内符号链接写入文件
+type safeTarWriter struct {
+	tw       *tar.Writer
+	symlinks map[string]bool
+	files    int
+	strip    int      // 去掉的路径层级（同 tar --strip-components）
+	prefix   string   // 写入时添加的路径前缀
+	entries  []string // 已写入的条目（最多记录 maxListedEntries 个）
+	count    int
+	written  int64 // 已写入的文件内容字节数
+	maxBytes int64 // 文件内容总量上限，防止压缩炸弹写满容器磁盘
+}
+
+// 解压结果中最多列出的条目数
+const maxListedEntries = 1000
+
+// 解压后的内容总量上限为上传大小上限的倍数
+const archiveExpandRatio = 10
+
+var errArchiveTooLarge = errors.New("解压后的内容超过上限")
+
+func newSafeTarWriter(w io.Writer) *safeTarWriter {
+	return &safeTarWriter{tw: tar.NewWriter(w), symlinks: make(map[string]bool), maxBytes: uploadMaxSize * archiveExpandRatio}
+}
+
+// 解压内容超出上限时的错误响应，返回是否已处理
+func writeArchiveTooLarge(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, errArchiveTooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("解压后的内容超过上限 (%d MB)", uploadMaxSize*archiveExpandRatio>>20))
+		return true
+	}
+	return false
+}
+
+// 按 strip 去掉路径前几层，层级不足时返回空
+func (s *safeTarWriter) stripName(name string) string {
+	if s.strip == 0 {
+		return name
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) <= s.strip {
+		return ""
+	}
+	return strings.Join(parts[s.strip:], "/")
+}
+
+// 写入一个条目（body 为 nil 表示无内容）
+func (s *safeTarWriter) write(hdr *tar.Header, body io.Reader) error {
+	name, err := safeArchiveName(hdr.Name)
+	if err != nil {
+		return err
+	}
+	if name = s.stripName(name); name == "" {
+		return nil // 归档根目录 "./" 或被去掉的层级
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if s.symlinks[dir] {
+			return fmt.Errorf("归档条目经过符号链接: %s", name)
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		hdr.Name = s.prefix + name + "/"
+	case tar.TypeReg, tar.TypeSymlink:
+		hdr.Name = s.prefix + name
+	case tar.TypeLink:
+		link, err := safeArchiveName(hdr.Linkname)
+		if link = s.stripName(link); err != nil || link == "" {
+			return fmt.Errorf("归档包含非法硬链接: %s", name)
+		}
+		hdr.Name, hdr.Linkname = s.prefix+name, s.prefix+link
+	default:
+		return nil // 跳过设备文件等特殊条目
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		s.symlinks[name] = true
+	}
+	// tar.Writer 不允许写入超过 hdr.Size 的内容，按声明的大小累计即可
+	if hdr.Typeflag == tar.TypeReg {
+		if s.written += hdr.Size; s.written > s.maxBytes {
+			return errArchiveTooLarge
+		}
+	}
+
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	s.count++
+	if len(s.entries) < maxListedEntries {
+		s.entries = append(s.entries, name)
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		s.files++
+		if body != nil {
+			if _, err := io.Copy(s.tw, body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *safeTarWriter) close() error {
+	return s.tw.Close()
+}
+
+// 复制 tar 流（已解压）中的条目
+func copyTarEntries(dst *safeTarWriter, src io.Reader) error {
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 失败: %v", err)
+		}
+		if err := dst.write(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// 将 zip 转换为 tar 条目（zip 需要随机读取，因此先落盘）
+func copyZipEntries(dst *safeTarWriter, src io.Reader) error {
+	tmp, err := os.CreateTemp("", "rabbit-upload-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, src)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return fmt.Errorf("读取 zip 失败: %v", err)
+	}
+
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		hdr := &tar.Header{
+			Name:    f.Name,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: f.Modified,
+		}
+
+		var body io.ReadCloser
+		switch {
+		case info.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			if hdr.Mode&0100 == 0 {
+				hdr.Mode = 0755 // 非 Unix 工具创建的 zip 中目录没有执行权限
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = string(target)
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(f.UncompressedSize64)
+			if hdr.Mode == 0 {
+				hdr.Mode = 0644
+			}
+			if body, err = f.Open(); err != nil {
+				return err
+			}
+		}
+
+		err := dst.write(hdr, body)
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 根据文件名判断归档格式
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	}
+	return ""
+}
+
+// 将归档解压写入容器目录（prefix 为条目前缀，strip 为去掉的路径层级），返回写入统计
+func extractArchiveToContainer(ctx context.Context, containerID, destDir, prefix string, strip int, format string, src io.Reader) (*safeTarWriter, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	stw := newSafeTarWriter(pw)
+	stw.prefix, stw.strip = prefix, strip
+
+	go func() {
+		defer recoverGoroutineWith(ctx, "extract archive", func(err error) {
+			pw.CloseWithError(err)
+			done <- err
+		})
+		var err error
+		switch format {
+		case "tar":
+			err = copyTarEntries(stw, src)
+		case "tar.gz":
+			var gz *gzip.Reader
+			if gz, err = gzip.NewReader(src); err == nil {
+				err = copyTarEntries(stw, gz)
+				gz.Close()
+			}
+		case "zip":
+			err = copyZipEntries(stw, src)
+		}
+		if err == nil {
+			err = stw.close()
+		}
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := getDockerClient().CopyToContainer(ctx, containerID, destDir, pr, types.CopyToContainerOptions{})
+	pr.CloseWithError(io.ErrClosedPipe)
+	// 优先返回读取归档时的错误（如超出大小、非法路径）
+	if srcErr := <-done; srcErr != nil {
+		return nil, srcErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stw, nil
+}
+
+// 统计写入字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 将单个文件以流的方式写入容器（size 未知时先写入临时文件以获得大小），返回写入的字节数
+func streamFileToContainer(ctx context.Context, containerID, destDir, fileName string, size int64, src io.Reader) (int64, error) {
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "rabbit-upload-*")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if size, err = io.Copy(tmp, src); err != nil {
+			return 0, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		src = tmp
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	counter := &countingWriter{}
+	go func() {
+		defer recoverGoroutineWith(ctx, "stream file", func(err error) {
+			pw.CloseWithError(err)
+			done <- err
+		})
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{
+			Name:    fileName,
+			Mode:    0644,
+			Size:    size,
+			ModTime: time.Now(),
+		})
+		if err == nil {
+			counter.w = tw
+			// tar.Writer 会检查写入长度与 size 是否一致
+			if _, err = io.Copy(counter, src); err == nil {
+				err = tw.Close()
+			}
+		}
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := getDockerClient().CopyToContainer(ctx, containerID, destDir, pr, types.CopyToContainerOptions{})
+	pr.CloseWithError(io.ErrClosedPipe)
+	if srcErr := <-done; srcErr != nil {
+		return 0, srcErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// multipart 上传（表单字段 container_id、path、extract、size 需位于 file 之前）
+// extract=true 时将 tar/zip 归档解压到目标目录，否则作为单个文件上传
+func handleContainerMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, uploadMaxSize)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "缺少上传文件")
+			return
+		}
+		if err != nil {
+			if !writeUploadTooLarge(w, err) {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("读取上传内容失败: %v", err))
+			}
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, _ := io.ReadAll(io.LimitReader(part, 4096))
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if fields["container_id"] == "" || fields["path"] == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "container_id 和 path 需在文件之前提交")
+			return
+		}
+		destDir, err := cleanContainerPath(fields["path"])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		// 解压归档
+		if fields["extract"] == "true" {
+			format := archiveFormat(part.FileName())
+			if format == "" {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不支持的归档格式（支持 tar、tar.gz、tgz、zip）")
+				return
+			}
+
+			stw, err := extractArchiveToContainer(ctx, fields["container_id"], destDir, "", 0, format, part)
+			if err != nil {
+				if !writeUploadTooLarge(w, err) && !writeArchiveTooLarge(w, err) {
+					writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("上传失败: %v", err))
+				}
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": "success",
+				"files":  stw.files,
+			})
+			return
+		}
+
+		// 单个文件
+		fileName := path.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+		if fileName == "" || fileName == "." || fileName == "/" || fileName == ".." {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "文件名无效")
+			return
+		}
+		size := int64(-1)
+		if v := fields["size"]; v != "" {
+			if size, err = strconv.ParseInt(v, 10, 64); err != nil || size < 0 {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "size 参数无效")
+				return
+			}
+		}
+
+		written, err := streamFileToContainer(ctx, fields["container_id"], destDir, fileName, size, part)
+		if err != nil {
+			if !writeUploadTooLarge(w, err) {
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("上传失败: %v", err))
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"bytes":  written,
+		})
+		return
+	}
+}
+
+// ========== 文件搜索 ==========
+
+// 文件搜索参数
+const (
+	fileSearchMaxResults = 500
+	fileSearchTimeout    = 20 * time.Second
+	fileSearchMaxDepth   = 20
+)
+
+// 搜索结果（BusyBox find 不支持 -printf，此时没有大小和修改时间）
+type FileSearchResult struct {
+	Path    string `json:"path"`
+	IsDir   bool   `json:"is_dir"`
+	Size    *int64 `json:"size,omitempty"`
+	ModTime string `json:"mod_time,omitempty"`
+}
+
+// 执行 find 并逐行读取结果，达到上限或超时后关闭连接（find 写入失败后退出）
+func runFileSearch(ctx context.Context, containerID string, cmd []string, parse func(string) (FileSearchResult, bool)) (results []FileSearchResult, partial bool, res *execResult, err error) {
+	execID, err := getDockerClient().ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, false, nil, err
+	}
+	resp, err := getDockerClient().ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, false, nil, err
+	}
+	defer resp.Close()
+
+	var stderr bytes.Buffer
+	pr, pw := io.Pipe()
+	copied := make(chan struct{})
+	go func() {
+		defer recoverGoroutineWith(ctx, "file search output", func(err error) { pw.CloseWithError(err) })
+		defer close(copied)
+		_, err := stdcopy.StdCopy(pw, &stderr, resp.Reader)
+		pw.CloseWithError(err)
+	}()
+
+	timer := time.AfterFunc(fileSearchTimeout, resp.Close)
+
+	results = make([]FileSearchResult, 0)
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		if len(results) >= fileSearchMaxResults {
+			partial = true
+			break
+		}
+		if item, ok := parse(scanner.Text()); ok {
+			results = append(results, item)
+		}
+	}
+	// 定时器已触发说明超时
+	if !timer.Stop() {
+		partial = true
+	}
+	resp.Close()
+	pr.Close()
+	<-copied
+
+	res = &execResult{Stderr: stderr.String(), ExitCode: -1}
+	if !partial {
+		if inspect, err := getDockerClient().ContainerExecInspect(ctx, execID.ID); err == nil {
+			res.ExitCode = inspect.ExitCode
+		}
+	}
+	return results, partial, res, nil
+}
+
+// 解析 find -printf "%y\t%s\t%T@\t%p\n" 的输出行
+func parseFindPrintfLine(line string) (FileSearchResult, bool) {
+	parts := strings.SplitN(line, "\t", 4)
+	if len(parts) != 4 {
+		return FileSearchResult{}, false
+	}
+	item := FileSearchResult{Path: parts[3], IsDir: parts[0] == "d"}
+	if size, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+		item.Size = &size
+	}
+	if ts, err := strconv.ParseFloat(parts[2], 64); err == nil {
+		item.ModTime = time.Unix(int64(ts), 0).Format("2006-01-02 15:04")
+	}
+	return item, true
+}
+
+// 在容器中按名称搜索文件（?id=xxx&path=/var&name=*.log&max_depth=5）
+func handleContainerFileSearch(w http.ResponseWriter, r *http.Request) {
+	containerID := r.URL.Query().Get("id")
+	name := r.URL.Query().Get("name")
+	if containerID == "" || name == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "参数不完整")
+		return
+	}
+	if strings.Contains(name, "/") {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "名称模式不能包含 /")
+		return
+	}
+
+	searchPath := r.URL.Query().Get("path")
+	if searchPath == "" {
+		searchPath = "/"
+	}
+	searchPath, err := cleanContainerPath(searchPath)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	maxDepth := 5
+	if v := r.URL.Query().Get("max_depth"); v != "" {
+		maxDepth, err = strconv.Atoi(v)
+		if err != nil || maxDepth < 1 || maxDepth > fileSearchMaxDepth {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("max_depth 参数无效（1-%d）", fileSearchMaxDepth))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fileSearchTimeout+10*time.Second)
+	defer cancel()
+
+	// 参数直接传给 find，不经过 shell，无需转义
+	base := []string{"find", searchPath, "-maxdepth", strconv.Itoa(maxDepth), "-name", name}
+	results, partial, res, err := runFileSearch(ctx, containerID,
+		append(base, "-printf", "%y\t%s\t%T@\t%p\n"), parseFindPrintfLine)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+		return
+	}
+
+	// BusyBox find 不支持 -printf，改用只输出路径的方式
+	if len(results) == 0 && !partial && res.ExitCode != 0 && strings.Contains(res.Stderr, "printf") {
+		results, partial, res, err = runFileSearch(ctx, containerID, base, func(line string) (FileSearchResult, bool) {
+			return FileSearchResult{Path: line}, line != ""
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+			return
+		}
+	}
+
+	if res.commandMissing() {
+		writeError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "容器中没有 find 命令")
+		return
+	}
+	if len(results) == 0 && strings.Contains(res.Stderr, "No such file") {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "目录不存在")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"partial": partial,
+		"limit":   fileSearchMaxResults,
+	})
+}
+
+// ========== 解压归档 ==========
+
+// 解析 tar -v / unzip 输出中的条目名，返回前 maxListedEntries 个条目和总数
+func parseExtractOutput(output, format, dest string) ([]string, int) {
+	entries := make([]string, 0)
+	count := 0
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if format == "zip" {
+			// unzip: "  inflating: /dest/a.txt"、"   creating: /dest/dir/"
+			action, name, ok := strings.Cut(line, ": ")
+			if !ok || (action != "inflating" && action != "extracting" && action != "creating") {
+				continue
+			}
+			line = strings.TrimPrefix(strings.TrimSpace(name), strings.TrimSuffix(dest, "/")+"/")
+		}
+		count++
+		if len(entries) < maxListedEntries {
+			entries = append(entries, line)
+		}
+	}
+	return entries, count
+}
+
+// 在服务端解压：读取容器中的归档，转换为 tar 后写回容器
+func extractInContainerByArchive(ctx context.Context, containerID, archivePath, dest, format string, strip int) (*safeTarWriter, error) {
+	reader, _, err := getDockerClient().CopyFromContainer(ctx, containerID, archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("%s 不是普通文件", archivePath)
+	}
+
+	// 目标目录不存在时从根目录写入，由条目路径创建目录
+	exists, err := containerPathExists(ctx, containerID, dest)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return extractArchiveToContainer(ctx, containerID, dest, "", strip, format, tr)
+	}
+	return extractArchiveToContainer(ctx, containerID, "/", strings.TrimPrefix(dest, "/")+"/", strip, format, tr)
+}
+
+// 解压容器中的归档文件
+func handleContainerFileExtract(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID     string `json:"container_id"`
+		ArchivePath     string `json:"archive_path"`
+		Destination     string `json:"destination"`
+		StripComponents int    `json:"strip_components"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	archivePath, err := cleanContainerPath(req.ArchivePath)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if req.Destination == "" {
+		req.Destination = path.Dir(archivePath)
+	}
+	dest, err := cleanContainerPath(req.Destination)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if req.StripComponents < 0 || req.StripComponents > 32 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "strip_components 参数无效")
+		return
+	}
+	format := archiveFormat(archivePath)
+	if format == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不支持的归档格式（支持 tar、tar.gz、tgz、zip）")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	// 解压会覆盖目标目录中的同名文件，禁止直接解压到系统关键目录
+	if isProtectedContainerPath(ctx, req.ContainerID, dest) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "禁止解压到系统关键目录")
+		return
+	}
+
+	if exists, err := containerPathExists(ctx, req.ContainerID, archivePath); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("检查归档失败: %v", err))
+		return
+	} else if !exists {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "归档文件不存在")
+		return
+	}
+
+	// 优先在容器内用 tar/unzip 解压（unzip 不支持 strip_components，此时直接在服务端解压）
+	var cmd []string
+	switch {
+	case format == "zip" && req.StripComponents > 0:
+	case format == "zip":
+		cmd = []string{"unzip", "-o", archivePath, "-d", dest}
+	default:
+		flags := "-xvf"
+		if format == "tar.gz" {
+			flags = "-xzvf"
+		}
+		cmd = []string{"tar", flags, archivePath, "-C", dest}
+		if req.StripComponents > 0 {
+			cmd = append(cmd, fmt.Sprintf("--strip-components=%d", req.StripComponents))
+		}
+	}
+
+	if cmd != nil {
+		mkdir, err := runContainerExec(ctx, req.ContainerID, []string{"mkdir", "-p", dest})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+			return
+		}
+		if mkdir.ExitCode != 0 && !mkdir.commandMissing() {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建目标目录失败: %s", mkdir.errorMessage()))
+			return
+		}
+
+		if !mkdir.commandMissing() {
+			result, err := runContainerExec(ctx, req.ContainerID, cmd)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("执行失败: %v", err))
+				return
+			}
+			if !result.commandMissing() {
+				if result.ExitCode != 0 {
+					writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("解压失败: %v", result.errorMessage()))
+					return
+				}
+
+				// GNU tar 将 -v 输出写到 stdout，BusyBox 部分版本写到 stderr
+				output := result.Stdout
+				if strings.TrimSpace(output) == "" && format != "zip" {
+					output = result.Stderr
+				}
+				entries, count := parseExtractOutput(output, format, dest)
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"status":   "success",
+					"strategy": cmd[0],
+					"count":    count,
+					"entries":  entries,
+				})
+				return
+			}
+		}
+	}
+
+	// 容器中没有 tar/unzip：在服务端解压
+	stw, err := extractInContainerByArchive(ctx, req.ContainerID, archivePath, dest, format, req.StripComponents)
+	if err != nil {
+		if writeArchiveTooLarge(w, err) {
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("解压失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"strategy": "server",
+		"count":    stw.count,
+		"entries":  stw.entries,
+	})
+}
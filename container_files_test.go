@@ -0,0 +1,384 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 下载嵌套目录：tar.gz 中的每个条目（目录、文件、符号链接）都与容器中一致
+func TestContainerFileDownloadDirectory(t *testing.T) {
+	fd := newFakeDocker(t)
+	big := make([]byte, 256<<10)
+	rand.New(rand.NewSource(1)).Read(big)
+	fd.add("/data", &fakeFile{mode: os.ModeDir | 0755})
+	fd.add("/data/a.txt", &fakeFile{mode: 0644, data: []byte("hello\n")})
+	fd.add("/data/empty", &fakeFile{mode: 0600})
+	fd.add("/data/sub", &fakeFile{mode: os.ModeDir | 0700, uid: 1000, gid: 1000})
+	fd.add("/data/sub/big.bin", &fakeFile{mode: 0640, uid: 1000, gid: 1000, data: big})
+	fd.add("/data/sub/deeper", &fakeFile{mode: os.ModeDir | 0755})
+	fd.add("/data/sub/deeper/c.txt", &fakeFile{mode: 0644, data: []byte("nested")})
+	fd.add("/data/link", &fakeFile{mode: os.ModeSymlink | 0777, link: "sub/deeper/c.txt"})
+
+	rec := httptest.NewRecorder()
+	handleContainerFileDownload(rec, httptest.NewRequest(http.MethodGet, "/api/containers/files/download?id=c1&path=/data", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="data.tar.gz"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("Content-Length = %q, want none for directories", cl)
+	}
+
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got := map[string]*tar.Header{}
+	contents := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "/" + normalizeTarName(hdr.Name)
+		got[name] = hdr
+		contents[name], _ = io.ReadAll(tr)
+	}
+
+	for _, name := range fd.tree("/data") {
+		want := fd.get(name)
+		hdr := got[name]
+		if hdr == nil {
+			t.Errorf("%s: missing from archive", name)
+			continue
+		}
+		if mode := hdr.FileInfo().Mode(); mode != want.mode {
+			t.Errorf("%s: mode %v, want %v", name, mode, want.mode)
+		}
+		if hdr.Uid != want.uid || hdr.Gid != want.gid {
+			t.Errorf("%s: owner %d:%d, want %d:%d", name, hdr.Uid, hdr.Gid, want.uid, want.gid)
+		}
+		if hdr.Linkname != want.link {
+			t.Errorf("%s: link %q, want %q", name, hdr.Linkname, want.link)
+		}
+		if !bytes.Equal(contents[name], want.data) {
+			t.Errorf("%s: content differs (%d bytes, want %d)", name, len(contents[name]), len(want.data))
+		}
+		delete(got, name)
+	}
+	for name := range got {
+		t.Errorf("%s: unexpected entry", name)
+	}
+}
+
+// 下载单个文件时输出文件内容并设置 Content-Length；符号链接下载其指向的文件
+func TestContainerFileDownloadFile(t *testing.T) {
+	fd := newFakeDocker(t)
+	fd.add("/data/a.txt", &fakeFile{mode: 0644, data: []byte("hello\n")})
+	fd.add("/data/link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/data/a.txt"})
+
+	for _, p := range []string{"/data/a.txt", "/data/link"} {
+		rec := httptest.NewRecorder()
+		handleContainerFileDownload(rec, httptest.NewRequest(http.MethodGet, "/api/containers/files/download?id=c1&path="+p, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", p, rec.Code, rec.Body)
+		}
+		if body := rec.Body.String(); body != "hello\n" {
+			t.Errorf("%s: body %q", p, body)
+		}
+		if cl := rec.Header().Get("Content-Length"); cl != "6" {
+			t.Errorf("%s: Content-Length = %q", p, cl)
+		}
+	}
+}
+
+func TestCleanContainerPath(t *testing.T) {
+	cases := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"/data/a.txt", "/data/a.txt", true},
+		{"/data/", "/data", true},
+		{"//etc//passwd", "/etc/passwd", true},
+		{"/data/../etc", "/etc", true},
+		{"/../../", "/", true},
+		{"", "", false},
+		{"data", "", false},
+		{"./etc", "", false},
+		{"../etc", "", false},
+	}
+	for _, c := range cases {
+		got, err := cleanContainerPath(c.in)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("cleanContainerPath(%q) = %q, %v; want %q, ok=%v", c.in, got, err, c.want, c.ok)
+		}
+	}
+}
+
+func TestIsProtectedPath(t *testing.T) {
+	cases := []struct {
+		path      string
+		protected bool
+	}{
+		{"/", true},
+		{"/etc", true},
+		{"/etc/", true},
+		{"//usr", true},
+		{"/data/../etc", true},
+		{"/usr/./", true},
+		{"/proc/1/mem", true},
+		{"/sys/kernel", true},
+		{"/dev/sda", true},
+		{"etc", true}, // 相对路径
+		{"", true},
+		{"/etc/nginx", false},
+		{"/usr/local/app", false},
+		{"/devices", false},
+		{"/process", false},
+		{"/data", false},
+	}
+	for _, c := range cases {
+		if got := isProtectedPath(c.path); got != c.protected {
+			t.Errorf("isProtectedPath(%q) = %v, want %v", c.path, got, c.protected)
+		}
+	}
+}
+
+// 指向系统关键目录的符号链接不能绕过保护，无论容器中是否有 readlink
+func TestProtectedContainerPathSymlinks(t *testing.T) {
+	cases := []struct {
+		path      string
+		protected bool
+	}{
+		{"/app/root-link", true},
+		{"/app/etc-link", true},
+		{"/app/etc-relative", true},
+		{"/app/proc-link", true},
+		{"/app/chain", true},
+		{"/app/data-link", false},
+		{"/app/file", false},
+		{"/app/missing", false},
+	}
+	for _, withReadlink := range []bool{true, false} {
+		fd := newFakeDocker(t)
+		fd.add("/app/file", &fakeFile{mode: 0644})
+		fd.add("/srv/data", &fakeFile{mode: os.ModeDir | 0755})
+		fd.add("/app/root-link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/"})
+		fd.add("/app/etc-link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/etc"})
+		fd.add("/app/etc-relative", &fakeFile{mode: os.ModeSymlink | 0777, link: "../etc"})
+		fd.add("/app/proc-link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/proc/self"})
+		fd.add("/app/chain", &fakeFile{mode: os.ModeSymlink | 0777, link: "/app/root-link"})
+		fd.add("/app/data-link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/srv/data"})
+		if withReadlink {
+			fd.exec = func(cmd []string) (string, string, int) {
+				if len(cmd) == 4 && cmd[0] == "readlink" && cmd[1] == "-f" {
+					return fd.resolve(cmd[3]) + "\n", "", 0
+				}
+				return "", "not found", 127
+			}
+		}
+
+		for _, c := range cases {
+			got := isProtectedContainerPath(context.Background(), "c1", c.path)
+			if got != c.protected {
+				t.Errorf("readlink=%v: isProtectedContainerPath(%q) = %v, want %v", withReadlink, c.path, got, c.protected)
+			}
+		}
+
+		// 删除接口拒绝受保护的路径（包括清理后才指向关键目录的路径）
+		for _, p := range []string{"/app/root-link", "/app/etc-link", "/app/../etc", "/srv/../../usr/"} {
+			body, _ := json.Marshal(map[string]string{"container_id": "c1", "path": p})
+			rec := httptest.NewRecorder()
+			handleContainerFileDelete(rec, httptest.NewRequest(http.MethodPost, "/api/containers/files/delete", bytes.NewReader(body)))
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("readlink=%v: delete %s: status %d, want %d", withReadlink, p, rec.Code, http.StatusForbidden)
+			}
+		}
+	}
+}
+
+// 模拟容器中的 readlink、rm 和 mv；cp 总是失败
+func fakeFileCommands(fd *fakeDocker) func(cmd []string) (string, string, int) {
+	return func(cmd []string) (string, string, int) {
+		switch {
+		case len(cmd) == 4 && cmd[0] == "readlink":
+			return fd.resolve(cmd[3]) + "\n", "", 0
+		case cmd[0] == "rm":
+			if len(cmd) == 4 {
+				fd.mu.Lock()
+				for name := range fd.files {
+					if name == cmd[3] || strings.HasPrefix(name, cmd[3]+"/") {
+						delete(fd.files, name)
+					}
+				}
+				fd.mu.Unlock()
+			}
+			return "", "", 0
+		case cmd[0] == "mv":
+			src, dst := cmd[len(cmd)-2], cmd[len(cmd)-1]
+			fd.mu.Lock()
+			defer fd.mu.Unlock()
+			if fd.files[src] == nil {
+				return "", "mv: cannot stat '" + src + "': No such file or directory", 1
+			}
+			for name, f := range fd.files {
+				if name == src || strings.HasPrefix(name, src+"/") {
+					delete(fd.files, name)
+					fd.files[dst+strings.TrimPrefix(name, src)] = f
+				}
+			}
+			return "", "", 0
+		case cmd[0] == "cp":
+			return "", "cp: No space left on device", 1
+		}
+		return "", "not found", 127
+	}
+}
+
+// 覆盖目录时：源位于目标内部的请求被拒绝；操作失败时恢复原目标
+func TestContainerFileReplaceDirectory(t *testing.T) {
+	fd := newFakeDocker(t)
+	fd.add("/srv/app/sub/file", &fakeFile{mode: 0644, data: []byte("sub")})
+	fd.add("/srv/app/keep", &fakeFile{mode: 0644, data: []byte("keep")})
+	fd.add("/srv/link", &fakeFile{mode: os.ModeSymlink | 0777, link: "/srv/app"})
+	fd.add("/data/a/b", &fakeFile{mode: os.ModeDir | 0755})
+	fd.exec = fakeFileCommands(fd)
+
+	post := func(handler http.HandlerFunc, body map[string]interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data)))
+		return rec
+	}
+
+	for _, c := range []struct {
+		handler  http.HandlerFunc
+		src, dst string
+	}{
+		{handleContainerFileRename, "/srv/app/sub", "/srv/app"},
+		{handleContainerFileRename, "/srv/app/sub", "/srv/link"},
+		{handleContainerFileCopy, "/srv/app/sub", "/srv/app"},
+		{handleContainerFileCopy, "/data/a/b", "/data/a"},
+	} {
+		rec := post(c.handler, map[string]interface{}{"container_id": "c1", "source": c.src, "destination": c.dst, "overwrite": true, "recursive": true})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s -> %s: status %d, want %d", c.src, c.dst, rec.Code, http.StatusBadRequest)
+		}
+	}
+	for _, p := range []string{"/srv/app/sub/file", "/srv/app/keep", "/data/a/b"} {
+		if fd.get(p) == nil {
+			t.Errorf("%s was deleted", p)
+		}
+	}
+
+	// cp 失败后原目标恢复，临时名称被清理
+	fd.add("/data/src/new", &fakeFile{mode: 0644, data: []byte("new")})
+	rec := post(handleContainerFileCopy, map[string]interface{}{"container_id": "c1", "source": "/data/src", "destination": "/srv/app", "overwrite": true, "recursive": true})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("copy: status %d, want %d: %s", rec.Code, http.StatusInternalServerError, rec.Body.String())
+	}
+	if f := fd.get("/srv/app/keep"); f == nil || string(f.data) != "keep" {
+		t.Errorf("/srv/app/keep not restored after failed copy")
+	}
+	for _, name := range fd.tree("/srv") {
+		if strings.Contains(name, ".rabbit-old-") {
+			t.Errorf("temporary target %s left behind", name)
+		}
+	}
+
+	// mv 成功后旧目标被删除
+	rec = post(handleContainerFileRename, map[string]interface{}{"container_id": "c1", "source": "/data/src", "destination": "/srv/app", "overwrite": true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rename: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if fd.get("/srv/app/new") == nil || fd.get("/srv/app/keep") != nil || fd.get("/data/src") != nil {
+		t.Errorf("rename did not replace /srv/app: %v", fd.tree("/srv"))
+	}
+	for _, name := range fd.tree("/srv") {
+		if strings.Contains(name, ".rabbit-old-") {
+			t.Errorf("temporary target %s left behind", name)
+		}
+	}
+}
+
+// 解压后的内容超过上传上限的倍数时中止（tar 和 zip）
+func TestSafeTarWriterSizeLimit(t *testing.T) {
+	oldMax := uploadMaxSize
+	uploadMaxSize = 1 << 10
+	t.Cleanup(func() { uploadMaxSize = oldMax })
+
+	big := bytes.Repeat([]byte{0}, int(uploadMaxSize*archiveExpandRatio)+1)
+
+	if err := copyTarEntries(newSafeTarWriter(io.Discard), bytes.NewReader(tarArchive(t, big))); !errors.Is(err, errArchiveTooLarge) {
+		t.Errorf("tar: err = %v, want %v", err, errArchiveTooLarge)
+	}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for _, name := range []string{"a", "b"} {
+		f, _ := zw.Create(name)
+		f.Write(big[:len(big)/2+1])
+	}
+	zw.Close()
+	if err := copyZipEntries(newSafeTarWriter(io.Discard), &zipBuf); !errors.Is(err, errArchiveTooLarge) {
+		t.Errorf("zip: err = %v, want %v", err, errArchiveTooLarge)
+	}
+
+	// 未超出上限时正常写入
+	if err := copyTarEntries(newSafeTarWriter(io.Discard), bytes.NewReader(tarArchive(t, big[:100]))); err != nil {
+		t.Errorf("small tar: %v", err)
+	}
+}
+
+func tarArchive(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(data)
+	tw.Close()
+	return buf.Bytes()
+}
+
+// 目标经过指向源目录内部的符号链接时，不能移动或复制到自身内部
+func TestContainerFileIntoSymlinkedSelf(t *testing.T) {
+	fd := newFakeDocker(t)
+	fd.add("/srv/app/sub/file", &fakeFile{mode: 0644})
+	fd.add("/srv/alias", &fakeFile{mode: os.ModeSymlink | 0777, link: "/srv/app/sub"})
+	fd.exec = fakeFileCommands(fd)
+
+	body, _ := json.Marshal(map[string]interface{}{"container_id": "c1", "source": "/srv/app", "destination": "/srv/alias/app", "recursive": true})
+	for name, handler := range map[string]http.HandlerFunc{"rename": handleContainerFileRename, "copy": handleContainerFileCopy} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d: %s", name, rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	}
+	if fd.get("/srv/app/sub/file") == nil || fd.get("/srv/alias/app") != nil {
+		t.Errorf("source moved or copied: %v", fd.tree("/srv"))
+	}
+}
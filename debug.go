@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// 是否启用调试接口（enable_debug / ENABLE_DEBUG），默认关闭
+var debugEnabled bool
+
+// 进程启动时间
+var processStartedAt = time.Now()
+
+// 返回的最近 GC 暂停次数
+const debugRecentGCPauses = 10
+
+// 运行时信息
+type RuntimeInfo struct {
+	GoVersion   string    `json:"go_version"`
+	Uptime      string    `json:"uptime"`
+	NumCPU      int       `json:"num_cpu"`
+	GOMAXPROCS  int       `json:"gomaxprocs"`
+	Goroutines  int       `json:"goroutines"`
+	HeapAlloc   uint64    `json:"heap_alloc"`
+	HeapInuse   uint64    `json:"heap_inuse"`
+	HeapSys     uint64    `json:"heap_sys"`
+	HeapObjects uint64    `json:"heap_objects"`
+	StackInuse  uint64    `json:"stack_inuse"`
+	Sys         uint64    `json:"sys"`
+	TotalAlloc  uint64    `json:"total_alloc"`
+	NumGC       uint32    `json:"num_gc"`
+	LastGC      string    `json:"last_gc,omitempty"`
+	GCPauseMs   float64   `json:"gc_pause_total_ms"`
+	GCPausesMs  []float64 `json:"gc_recent_pauses_ms"` // 最近的 GC 暂停，最新的在前
+	Sessions    int       `json:"sessions"`
+	Terminals   int       `json:"terminals"`
+	Panics      int64     `json:"panics"` // 恢复的 panic 次数
+
+	Limits map[string]OpLimitStats `json:"limits"` // 耗时 Docker 操作的并发情况
+}
+
+// 未启用调试接口时返回 404（与未注册的路由一致）
+func debugOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !debugEnabled {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "调试接口未启用")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// 注册调试接口（需要 enable_debug，且始终需要登录）
+// net/http/pprof 在 init 中会向 http.DefaultServeMux 注册 /debug/pprof/，面板使用自己的 appMux，这些路由不会对外提供
+func registerDebugRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/debug/runtime", authMiddleware(debugOnly(handleDebugRuntime)))
+	mux.HandleFunc("/api/debug/pprof/", authMiddleware(debugOnly(handleDebugPprof)))
+	mux.HandleFunc("/api/debug/pprof/cmdline", authMiddleware(debugOnly(pprof.Cmdline)))
+	mux.HandleFunc("/api/debug/pprof/profile", authMiddleware(debugOnly(pprof.Profile)))
+	mux.HandleFunc("/api/debug/pprof/symbol", authMiddleware(debugOnly(pprof.Symbol)))
+	mux.HandleFunc("/api/debug/pprof/trace", authMiddleware(debugOnly(pprof.Trace)))
+}
+
+// pprof 处理器按 /debug/pprof/ 解析 profile 名称，去掉 /api 前缀后交给它
+func handleDebugPprof(w http.ResponseWriter, r *http.Request) {
+	http.StripPrefix("/api", http.HandlerFunc(pprof.Index)).ServeHTTP(w, r)
+}
+
+// 运行时信息：goroutine 数量、堆内存、GC 暂停、会话和终端数量
+func handleDebugRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	info := RuntimeInfo{
+		GoVersion:   runtime.Version(),
+		Uptime:      time.Since(processStartedAt).Round(time.Second).String(),
+		NumCPU:      runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapSys:     m.HeapSys,
+		HeapObjects: m.HeapObjects,
+		StackInuse:  m.StackInuse,
+		Sys:         m.Sys,
+		TotalAlloc:  m.TotalAlloc,
+		NumGC:       m.NumGC,
+		GCPauseMs:   float64(m.PauseTotalNs) / 1e6,
+		GCPausesMs:  []float64{},
+	}
+	if m.LastGC > 0 {
+		info.LastGC = time.Unix(0, int64(m.LastGC)).Format(time.RFC3339)
+	}
+	// PauseNs 是环形缓冲区，最近一次 GC 在 (NumGC+255)%256
+	for i := uint32(0); i < debugRecentGCPauses && i < m.NumGC; i++ {
+		pause := m.PauseNs[(m.NumGC-1-i)%uint32(len(m.PauseNs))]
+		info.GCPausesMs = append(info.GCPausesMs, float64(pause)/1e6)
+	}
+
+	sessionMutex.RLock()
+	info.Sessions = len(sessions)
+	sessionMutex.RUnlock()
+	activeTerminals.Lock()
+	info.Terminals = len(activeTerminals.items)
+	activeTerminals.Unlock()
+	info.Panics = panicCount.Load()
+	info.Limits = opLimitStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
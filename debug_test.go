@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pprof 只能通过需要登录的 /api/debug/pprof/ 访问；net/http/pprof 注册到 DefaultServeMux 的 /debug/pprof/ 不能对外提供
+func TestDebugPprofRoutes(t *testing.T) {
+	registerDebugRoutes(appMux)
+	handler := newServerHandler()
+
+	defer func(enabled bool) { debugEnabled = enabled }(debugEnabled)
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/api/debug/pprof/", http.StatusUnauthorized},
+		{"/api/debug/pprof/heap", http.StatusUnauthorized},
+		{"/api/debug/pprof/cmdline", http.StatusUnauthorized},
+		{"/api/debug/pprof/profile?seconds=1", http.StatusUnauthorized},
+		{"/api/debug/pprof/symbol", http.StatusUnauthorized},
+		{"/api/debug/pprof/trace?seconds=1", http.StatusUnauthorized},
+		{"/api/debug/runtime", http.StatusUnauthorized},
+		{"/debug/pprof/", http.StatusNotFound},
+		{"/debug/pprof/heap", http.StatusNotFound},
+		{"/debug/pprof/cmdline", http.StatusNotFound},
+		{"/debug/pprof/profile?seconds=1", http.StatusNotFound},
+		{"/debug/pprof/symbol", http.StatusNotFound},
+		{"/debug/pprof/trace?seconds=1", http.StatusNotFound},
+	}
+	// 无论是否启用调试接口，结果都一样
+	for _, enabled := range []bool{false, true} {
+		debugEnabled = enabled
+		for _, c := range cases {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if rec.Code != c.want {
+				t.Errorf("debug=%v GET %s: status %d, want %d", enabled, c.path, rec.Code, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// 独立容器（非 compose）的启动依赖：A 依赖 B 时先启动 B，并等待 B 运行或健康后再启动 A
+// 依赖按容器名称保存，容器重建后依然有效
+
+const (
+	depConditionRunning = "running" // 依赖容器处于运行状态即可
+	depConditionHealthy = "healthy" // 依赖容器健康检查通过（没有健康检查时按 running 处理）
+
+	depDefaultTimeout = 120 * time.Second // 每一步等待的默认超时
+	depMaxTimeout     = 600 * time.Second
+)
+
+// 一条依赖
+type ContainerDep struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition"`
+}
+
+var (
+	containerDepsMu sync.RWMutex
+	containerDeps   = map[string][]ContainerDep{} // 容器名称 -> 依赖列表
+)
+
+// 创建依赖表并加载到内存
+func initContainerDeps() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS container_deps (
+		container TEXT NOT NULL,
+		depends_on TEXT NOT NULL,
+		condition TEXT NOT NULL DEFAULT 'running',
+		PRIMARY KEY (container, depends_on)
+	)`)
+	if err != nil {
+		return fmt.Errorf("创建容器依赖表失败: %v", err)
+	}
+
+	rows, err := authDB.Query("SELECT container, depends_on, condition FROM container_deps ORDER BY container, rowid")
+	if err != nil {
+		return fmt.Errorf("加载容器依赖失败: %v", err)
+	}
+	defer rows.Close()
+
+	deps := map[string][]ContainerDep{}
+	for rows.Next() {
+		var name string
+		var d ContainerDep
+		if err := rows.Scan(&name, &d.Name, &d.Condition); err != nil {
+			continue
+		}
+		deps[name] = append(deps[name], d)
+	}
+
+	containerDepsMu.Lock()
+	containerDeps = deps
+	containerDepsMu.Unlock()
+	return nil
+}
+
+// 容器的依赖列表（返回副本）
+func containerDepsOf(name string) []ContainerDep {
+	containerDepsMu.RLock()
+	defer containerDepsMu.RUnlock()
+	return append([]ContainerDep(nil), containerDeps[name]...)
+}
+
+// 容器依赖的名称，用于容器列表
+func containerDepNames(name string) []string {
+	containerDepsMu.RLock()
+	defer containerDepsMu.RUnlock()
+	deps := containerDeps[name]
+	if len(deps) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(deps))
+	for _, d := range deps {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// 依赖该容器的其他容器
+func containerDependents(name string) []string {
+	containerDepsMu.RLock()
+	defer containerDepsMu.RUnlock()
+	names := []string{}
+	for container, deps := range containerDeps {
+		for _, d := range deps {
+			if d.Name == name {
+				names = append(names, container)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 在依赖图中查找环：以 name 的新依赖替换原有依赖后，从 name 出发能否回到 name
+// 返回环路径（如 a -> b -> a），无环时返回 nil
+func findDepCycle(graph map[string][]ContainerDep, name string) []string {
+	visited := map[string]bool{}
+	var path []string
+	var visit func(n string) bool
+	visit = func(n string) bool {
+		path = append(path, n)
+		for _, d := range graph[n] {
+			if d.Name == name {
+				path = append(path, name)
+				return true
+			}
+			if visited[d.Name] {
+				continue
+			}
+			visited[d.Name] = true
+			if visit(d.Name) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(name) {
+		return path
+	}
+	return nil
+}
+
+// 替换容器的依赖列表，存在环时拒绝
+func setContainerDeps(name string, deps []ContainerDep) error {
+	containerDepsMu.Lock()
+	defer containerDepsMu.Unlock()
+
+	graph := make(map[string][]ContainerDep, len(containerDeps)+1)
+	for k, v := range containerDeps {
+		graph[k] = v
+	}
+	graph[name] = deps
+	if cycle := findDepCycle(graph, name); cycle != nil {
+		return &depCycleError{cycle: cycle}
+	}
+
+	tx, err := authDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM container_deps WHERE container = ?", name); err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if _, err := tx.Exec("INSERT INTO container_deps (container, depends_on, condition) VALUES (?, ?, ?)", name, d.Name, d.Condition); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(deps) == 0 {
+		delete(containerDeps, name)
+	} else {
+		containerDeps[name] = deps
+	}
+	return nil
+}
+
+// 容器改名后更新依赖中的名称（作为依赖方和被依赖方）
+func renameContainerDeps(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	containerDepsMu.Lock()
+	defer containerDepsMu.Unlock()
+
+	tx, err := authDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE container_deps SET container = ? WHERE container = ?", newName, oldName); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE container_deps SET depends_on = ? WHERE depends_on = ?", newName, oldName); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if deps, ok := containerDeps[oldName]; ok {
+		delete(containerDeps, oldName)
+		containerDeps[newName] = deps
+	}
+	for _, deps := range containerDeps {
+		for i := range deps {
+			if deps[i].Name == oldName {
+				deps[i].Name = newName
+			}
+		}
+	}
+	return nil
+}
+
+type depCycleError struct {
+	cycle []string
+}
+
+func (e *depCycleError) Error() string {
+	return fmt.Sprintf("依赖存在循环: %s", strings.Join(e.cycle, " -> "))
+}
+
+// 查看（GET ?name=）或设置（POST）容器依赖
+func handleContainerDeps(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "缺少容器名称")
+			return
+		}
+		deps := containerDepsOf(name)
+		if deps == nil {
+			deps = []ContainerDep{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":       name,
+			"depends_on": deps,
+			"dependents": containerDependents(name),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Name      string         `json:"name"`
+			DependsOn []ContainerDep `json:"depends_on"` // 空列表表示清除依赖
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+			return
+		}
+		name := strings.TrimPrefix(strings.TrimSpace(req.Name), "/")
+
+		seen := map[string]bool{}
+		deps := make([]ContainerDep, 0, len(req.DependsOn))
+		for _, d := range req.DependsOn {
+			d.Name = strings.TrimPrefix(strings.TrimSpace(d.Name), "/")
+			if d.Name == "" {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "依赖容器名称不能为空")
+				return
+			}
+			if d.Name == name {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器不能依赖自身")
+				return
+			}
+			if d.Condition == "" {
+				d.Condition = depConditionRunning
+			}
+			if d.Condition != depConditionRunning && d.Condition != depConditionHealthy {
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("无效的等待条件: %s", d.Condition))
+				return
+			}
+			if seen[d.Name] {
+				continue
+			}
+			seen[d.Name] = true
+			deps = append(deps, d)
+		}
+
+		if err := setContainerDeps(name, deps); err != nil {
+			if _, ok := err.(*depCycleError); ok {
+				writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+			return
+		}
+		InvalidateContainers()
+
+		parts := make([]string, 0, len(deps))
+		for _, d := range deps {
+			parts = append(parts, d.Name+"("+d.Condition+")")
+		}
+		writeAuditLog(r.Header.Get("X-Username"), "container_deps", name, strings.Join(parts, ", "), r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "success",
+			"name":       name,
+			"depends_on": deps,
+		})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+	}
+}
+
+// 启动顺序：依赖在前，目标容器在最后；同时记录每个容器需要满足的等待条件
+// （被多个容器依赖时取最严格的条件）
+func depStartOrder(name string) ([]string, map[string]string) {
+	containerDepsMu.RLock()
+	defer containerDepsMu.RUnlock()
+
+	order := []string{}
+	conditions := map[string]string{}
+	visited := map[string]bool{}
+	var visit func(n string)
+	visit = func(n string) {
+		if visited[n] {
+			return
+		}
+		visited[n] = true
+		for _, d := range containerDeps[n] {
+			if conditions[d.Name] != depConditionHealthy {
+				conditions[d.Name] = d.Condition
+			}
+			visit(d.Name)
+		}
+		order = append(order, n)
+	}
+	visit(name)
+	return order, conditions
+}
+
+// 启动过程中的一步
+type DepStartStep struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition,omitempty"` // 目标容器没有等待条件
+	Action    string `json:"action"`              // started / already_running / failed
+	Waited    string `json:"waited,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// 等待容器满足条件；容器退出或健康检查失败时立即返回错误
+func waitDepCondition(ctx context.Context, id, condition string) error {
+	cli := getDockerClient()
+	for {
+		info, err := cli.ContainerInspect(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return depTimeoutError(condition)
+			}
+			return fmt.Errorf("检查容器状态失败: %v", err)
+		}
+		state := info.State
+		if !state.Running && !state.Restarting {
+			return fmt.Errorf("容器未能保持运行（状态 %s，退出码 %d）", state.Status, state.ExitCode)
+		}
+		if state.Running && !state.Restarting {
+			if condition != depConditionHealthy || state.Health == nil {
+				return nil
+			}
+			switch state.Health.Status {
+			case types.Healthy:
+				return nil
+			case types.Unhealthy:
+				return fmt.Errorf("容器健康检查失败")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return depTimeoutError(condition)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func depTimeoutError(condition string) error {
+	if condition == depConditionHealthy {
+		return fmt.Errorf("等待容器健康超时")
+	}
+	return fmt.Errorf("等待容器运行超时")
+}
+
+// 按依赖顺序启动容器及其依赖链
+func handleContainerStartWithDeps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"`
+		Timeout int    `json:"timeout"` // 每一步的等待超时（秒），默认 120，最大 600
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	timeout := depDefaultTimeout
+	if req.Timeout > 0 {
+		timeout = time.Duration(req.Timeout) * time.Second
+	}
+	if timeout > depMaxTimeout {
+		timeout = depMaxTimeout
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimSpace(req.Name), "/")
+	order, conditions := depStartOrder(name)
+	cli := getDockerClient()
+
+	// 先确认链上的容器都存在，避免启动到一半才发现缺失
+	ids := make(map[string]string, len(order))
+	for _, n := range order {
+		info, err := cli.ContainerInspect(r.Context(), n)
+		if client.IsErrNotFound(err) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, fmt.Sprintf("容器不存在: %s", n))
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取容器 %s 失败: %v", n, err))
+			return
+		}
+		ids[n] = info.ID
+	}
+
+	steps := make([]DepStartStep, 0, len(order))
+	var failed error
+	for _, n := range order {
+		step := DepStartStep{Name: n, Condition: conditions[n]}
+		info, err := cli.ContainerInspect(r.Context(), ids[n])
+		if err == nil && info.State.Running {
+			step.Action = "already_running"
+		} else if err == nil {
+			err = cli.ContainerStart(r.Context(), ids[n], types.ContainerStartOptions{})
+			step.Action = "started"
+		}
+
+		if err == nil && step.Condition != "" {
+			begin := time.Now()
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			err = waitDepCondition(ctx, ids[n], step.Condition)
+			cancel()
+			step.Waited = time.Since(begin).Round(time.Second).String()
+		}
+		if err != nil {
+			step.Action = "failed"
+			step.Error = err.Error()
+			failed = fmt.Errorf("%s: %v", n, err)
+		}
+		steps = append(steps, step)
+		if failed != nil {
+			break
+		}
+	}
+	InvalidateContainers()
+
+	detail := strings.Join(order, " -> ")
+	if failed != nil {
+		detail += " 失败: " + failed.Error()
+		log.Printf("[Deps] Start %s with dependencies failed: %v", name, failed)
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "container_start_with_deps", name, detail, r.RemoteAddr)
+
+	status := "success"
+	if failed != nil {
+		status = "failed"
+	}
+	resp := map[string]interface{}{
+		"status": status,
+		"name":   name,
+		"order":  order,
+		"steps":  steps,
+	}
+	if failed != nil {
+		resp["error"] = failed.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+)
+
+// Docker API 版本兼容性
+type DockerCompat struct {
+	Compatible          bool   `json:"compatible"`
+	ClientAPIVersion    string `json:"client_api_version"`     // 协商后的 API 版本
+	ClientMaxAPIVersion string `json:"client_max_api_version"` // 面板内置 SDK 支持的最高版本
+	DaemonVersion       string `json:"daemon_version,omitempty"`
+	DaemonAPIVersion    string `json:"daemon_api_version,omitempty"`
+	DaemonMinAPIVersion string `json:"daemon_min_api_version,omitempty"`
+	Message             string `json:"message,omitempty"`
+	Hint                string `json:"hint,omitempty"`
+}
+
+// 当前的兼容性检查结果（启动时检查，未检查时为 nil）
+var dockerCompat atomic.Pointer[DockerCompat]
+
+// 守护进程拒绝过旧客户端时的错误信息，如 "client version 1.43 is too old. Minimum supported API version is 1.44"
+var dockerMinVersionPattern = regexp.MustCompile(`(?i)minimum supported API version is ([0-9]+\.[0-9]+)`)
+
+// 依赖 Docker 的接口，版本不兼容时直接返回 503
+var dockerRoutePrefixes = []string{
+	"/api/containers",
+	"/api/images",
+	"/api/networks",
+	"/api/compose",
+	"/api/events",
+	"/api/system/docker",
+	"/api/system/top-containers",
+}
+
+// Docker 是否可用（守护进程断开或版本不兼容时不可用）
+func dockerAvailable() bool {
+	compat := dockerCompat.Load()
+	return dockerConnected.Load() && (compat == nil || compat.Compatible)
+}
+
+// 协商 API 版本并检查与守护进程是否兼容
+// 守护进程无法连接时返回错误；版本不兼容时不返回错误，结果记录在 dockerCompat 中
+func checkDockerCompat(ctx context.Context) (*DockerCompat, error) {
+	compat, err := dockerCompatFor(ctx, getDockerClient())
+	if err != nil {
+		return nil, err
+	}
+	dockerCompat.Store(compat)
+	return compat, nil
+}
+
+// 检查指定客户端与守护进程的兼容性（不记录结果）
+func dockerCompatFor(ctx context.Context, cli *client.Client) (*DockerCompat, error) {
+	if _, err := cli.Ping(ctx); err != nil {
+		return nil, err
+	}
+	cli.NegotiateAPIVersion(ctx)
+
+	compat := &DockerCompat{
+		Compatible:          true,
+		ClientAPIVersion:    cli.ClientVersion(),
+		ClientMaxAPIVersion: api.DefaultVersion,
+	}
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		m := dockerMinVersionPattern.FindStringSubmatch(err.Error())
+		if m == nil {
+			return nil, err
+		}
+		compat.DaemonMinAPIVersion = m[1]
+	} else {
+		compat.DaemonVersion = version.Version
+		compat.DaemonAPIVersion = version.APIVersion
+		compat.DaemonMinAPIVersion = version.MinAPIVersion
+	}
+
+	switch {
+	case compat.DaemonMinAPIVersion != "" && versions.LessThan(compat.ClientAPIVersion, compat.DaemonMinAPIVersion):
+		compat.Compatible = false
+		compat.Message = fmt.Sprintf("面板使用的 Docker API 版本 %s 低于 Docker 守护进程要求的最低版本 %s", compat.ClientAPIVersion, compat.DaemonMinAPIVersion)
+		if versions.LessThan(compat.ClientMaxAPIVersion, compat.DaemonMinAPIVersion) {
+			compat.Hint = fmt.Sprintf("请升级 rabbit-panel 到支持 Docker API %s 的版本；或在 dockerd 的环境变量中设置 DOCKER_MIN_API_VERSION=%s 后重启 Docker", compat.DaemonMinAPIVersion, compat.ClientMaxAPIVersion)
+		} else {
+			compat.Hint = "请取消 DOCKER_API_VERSION 环境变量，让面板自动协商 API 版本"
+		}
+	case compat.DaemonAPIVersion != "" && versions.GreaterThan(compat.ClientAPIVersion, compat.DaemonAPIVersion):
+		compat.Compatible = false
+		compat.Message = fmt.Sprintf("面板使用的 Docker API 版本 %s 高于 Docker 守护进程支持的最高版本 %s", compat.ClientAPIVersion, compat.DaemonAPIVersion)
+		compat.Hint = "请升级 Docker，或取消 DOCKER_API_VERSION 环境变量，让面板自动协商 API 版本"
+	}
+
+	return compat, nil
+}
+
+// 返回 Docker 版本不兼容的错误响应
+func writeDockerIncompatible(w http.ResponseWriter, compat *DockerCompat) {
+	writeErrorDetails(w, http.StatusServiceUnavailable, ErrCodeDockerIncompatible, "Docker API 版本不兼容: "+compat.Message, compat)
+}
+
+// 是否为依赖 Docker 的接口
+func isDockerRoute(p string) bool {
+	for _, prefix := range dockerRoutePrefixes {
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Docker 断开或版本不兼容时，依赖 Docker 的接口统一返回 503 和原因，而不是守护进程的原始错误
+func withDockerCompat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !dockerAvailable() && isDockerRoute(r.URL.Path) {
+			if !dockerConnected.Load() {
+				writeDockerUnavailable(w)
+			} else {
+				writeDockerIncompatible(w, dockerCompat.Load())
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Docker API 版本兼容性（前端据此显示提示横幅）
+func handleDockerCompat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	compat := dockerCompat.Load()
+	if compat == nil {
+		compat = &DockerCompat{Compatible: true, ClientMaxAPIVersion: api.DefaultVersion}
+		if cli := getDockerClient(); cli != nil {
+			compat.ClientAPIVersion = cli.ClientVersion()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compat)
+}
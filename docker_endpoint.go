@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Docker 连接地址（为空时使用 DOCKER_HOST 等环境变量）
+type DockerEndpoint struct {
+	Host        string `json:"host"`                    // unix:///var/run/docker.sock 或 tcp://host:2376
+	TLS         bool   `json:"tls"`                     // tcp 地址是否使用 TLS
+	TLSCAFile   string `json:"tls_ca_file,omitempty"`   // CA 证书路径（为空时使用系统证书）
+	TLSCertFile string `json:"tls_cert_file,omitempty"` // 客户端证书路径
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`  // 客户端私钥路径
+}
+
+// 测试连接的超时时间
+const dockerEndpointTestTimeout = 5 * time.Second
+
+// 当前使用的连接地址
+var (
+	dockerEndpointMu sync.RWMutex
+	dockerEndpoint   DockerEndpoint
+)
+
+// 切换客户端（守护进程恢复、修改连接地址）时串行执行
+var dockerSwitchMu sync.Mutex
+
+func currentDockerEndpoint() DockerEndpoint {
+	dockerEndpointMu.RLock()
+	defer dockerEndpointMu.RUnlock()
+	return dockerEndpoint
+}
+
+func setDockerEndpoint(ep DockerEndpoint) {
+	dockerEndpointMu.Lock()
+	dockerEndpoint = ep
+	dockerEndpointMu.Unlock()
+}
+
+// 校验连接地址
+func (ep *DockerEndpoint) validate() error {
+	if ep.Host == "" {
+		if ep.TLS || ep.TLSCAFile != "" || ep.TLSCertFile != "" || ep.TLSKeyFile != "" {
+			return fmt.Errorf("使用环境变量中的 Docker 地址时不能配置 TLS")
+		}
+		return nil
+	}
+	u, err := client.ParseHostURL(ep.Host)
+	if err != nil {
+		return fmt.Errorf("Docker 地址无效: %v", err)
+	}
+	switch u.Scheme {
+	case "unix", "npipe":
+		if ep.TLS {
+			return fmt.Errorf("%s 地址不支持 TLS", u.Scheme)
+		}
+		// ParseHostURL 将 socket 路径放在 Host 中
+		if u.Scheme == "unix" && !filepath.IsAbs(u.Host) {
+			return fmt.Errorf("unix socket 路径必须是绝对路径: %s", u.Host)
+		}
+	case "tcp":
+		if u.Host == "" {
+			return fmt.Errorf("Docker 地址缺少主机: %s", ep.Host)
+		}
+	default:
+		return fmt.Errorf("不支持的 Docker 地址协议: %s（支持 unix、tcp、npipe）", u.Scheme)
+	}
+	if (ep.TLSCertFile == "") != (ep.TLSKeyFile == "") {
+		return fmt.Errorf("客户端证书和私钥必须同时配置")
+	}
+	for _, p := range []string{ep.TLSCAFile, ep.TLSCertFile, ep.TLSKeyFile} {
+		if p != "" && !filepath.IsAbs(p) {
+			return fmt.Errorf("证书路径必须是绝对路径: %s", p)
+		}
+	}
+	if !ep.TLS && (ep.TLSCAFile != "" || ep.TLSCertFile != "") {
+		return fmt.Errorf("配置证书时需要启用 TLS")
+	}
+	return nil
+}
+
+// 按连接地址创建客户端
+func newDockerClientFor(ep DockerEndpoint) (*client.Client, error) {
+	// 使用空版本字符串，让客户端自动协商 API 版本
+	// 这样可以同时兼容旧版和新版 Docker
+	opts := []client.Opt{
+		client.WithAPIVersionNegotiation(),
+		client.WithVersion(""), // 不指定版本，自动协商
+	}
+	if ep.Host == "" {
+		opts = append([]client.Opt{client.FromEnv}, opts...)
+	} else {
+		opts = append(opts, client.WithHost(ep.Host))
+		if ep.TLS {
+			opts = append(opts, client.WithTLSClientConfig(ep.TLSCAFile, ep.TLSCertFile, ep.TLSKeyFile))
+		}
+	}
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("无法连接到 Docker: %v", err)
+	}
+	return cli, nil
+}
+
+// 初始化连接地址表并读取保存的地址
+func initDockerEndpoint() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS docker_endpoint (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		config TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建 Docker 连接设置表失败: %v", err)
+	}
+
+	var data string
+	err = authDB.QueryRow("SELECT config FROM docker_endpoint WHERE id = 1").Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取 Docker 连接设置失败: %v", err)
+	}
+	var ep DockerEndpoint
+	if err := json.Unmarshal([]byte(data), &ep); err != nil {
+		return fmt.Errorf("解析 Docker 连接设置失败: %v", err)
+	}
+	if err := ep.validate(); err != nil {
+		return err
+	}
+	setDockerEndpoint(ep)
+	return nil
+}
+
+// 保存连接地址
+func saveDockerEndpoint(ep DockerEndpoint) error {
+	data, _ := json.Marshal(ep)
+	_, err := authDB.Exec(`
+		INSERT INTO docker_endpoint (id, config, updated_at)
+		VALUES (1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			config = excluded.config,
+			updated_at = CURRENT_TIMESTAMP`,
+		string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("保存 Docker 连接设置失败: %v", err)
+	}
+	return nil
+}
+
+// 启动时保存的地址不可用，退回到环境变量中的地址（不修改保存的设置）
+func fallbackDockerEndpoint() bool {
+	if currentDockerEndpoint().Host == "" {
+		return false
+	}
+	log.Printf("警告: 无法连接到已保存的 Docker 地址 %s，改用环境变量中的地址", currentDockerEndpoint().Host)
+	setDockerEndpoint(DockerEndpoint{})
+	cli, err := newDockerClientFor(DockerEndpoint{})
+	if err != nil {
+		return false
+	}
+	if old := setDockerClient(cli); old != nil {
+		old.Close()
+	}
+	return true
+}
+
+// 连接测试结果
+type DockerEndpointTest struct {
+	OK            bool          `json:"ok"`
+	Host          string        `json:"host"`
+	LatencyMs     int64         `json:"latency_ms"`
+	APIVersion    string        `json:"api_version,omitempty"`
+	OSType        string        `json:"os_type,omitempty"`
+	DaemonVersion string        `json:"daemon_version,omitempty"`
+	Compat        *DockerCompat `json:"compat,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// 用临时客户端测试连接地址：Ping、版本和兼容性
+func testDockerEndpoint(ctx context.Context, ep DockerEndpoint) (*DockerEndpointTest, *client.Client) {
+	result := &DockerEndpointTest{Host: ep.Host}
+	cli, err := newDockerClientFor(ep)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Host = cli.DaemonHost()
+
+	start := time.Now()
+	ping, err := cli.Ping(ctx)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		cli.Close()
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.APIVersion = ping.APIVersion
+	result.OSType = ping.OSType
+
+	compat, err := dockerCompatFor(ctx, cli)
+	if err != nil {
+		cli.Close()
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Compat = compat
+	result.DaemonVersion = compat.DaemonVersion
+	if !compat.Compatible {
+		cli.Close()
+		result.Error = compat.Message
+		return result, nil
+	}
+	result.OK = true
+	return result, cli
+}
+
+// 解析请求中的连接地址
+func decodeDockerEndpoint(w http.ResponseWriter, r *http.Request) (DockerEndpoint, bool) {
+	var ep DockerEndpoint
+	if err := json.NewDecoder(r.Body).Decode(&ep); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return ep, false
+	}
+	if err := ep.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return ep, false
+	}
+	return ep, true
+}
+
+// 测试连接地址（不影响当前使用的连接）
+func handleDockerEndpointTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	ep, ok := decodeDockerEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dockerEndpointTestTimeout)
+	defer cancel()
+	result, cli := testDockerEndpoint(ctx, ep)
+	if cli != nil {
+		cli.Close()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// 查看或修改 Docker 连接地址
+// 修改时先用新地址测试，失败则继续使用原来的连接；成功后原子替换全局客户端，无需重启
+func handleDockerEndpoint(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"endpoint": currentDockerEndpoint(),
+			"host":     getDockerClient().DaemonHost(),
+			"status":   currentDockerStatus(),
+		})
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	ep, ok := decodeDockerEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dockerEndpointTestTimeout)
+	defer cancel()
+	result, cli := testDockerEndpoint(ctx, ep)
+	if cli == nil {
+		log.Printf("[Docker] Endpoint change rejected, keeping %s: %s", getDockerClient().DaemonHost(), result.Error)
+		writeErrorDetails(w, http.StatusBadRequest, ErrCodeEndpointUnreachable, "无法使用新的 Docker 地址，继续使用原来的连接: "+result.Error, result)
+		return
+	}
+	if err := saveDockerEndpoint(ep); err != nil {
+		cli.Close()
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	dockerSwitchMu.Lock()
+	oldHost := getDockerClient().DaemonHost()
+	setDockerEndpoint(ep)
+	if old := setDockerClient(cli); old != nil {
+		old.Close()
+	}
+	dockerCompat.Store(result.Compat)
+	markDockerConnected()
+	dockerSwitchMu.Unlock()
+
+	log.Printf("[Docker] Endpoint changed: %s -> %s", oldHost, cli.DaemonHost())
+	writeAuditLog(r.Header.Get("X-Username"), "docker_endpoint_change", cli.DaemonHost(), "from "+oldHost, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"result": result,
+	})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Docker 连接监控
+const (
+	dockerPingInterval     = 5 * time.Second  // 正常状态下的检查间隔
+	dockerPingTimeout      = 3 * time.Second  // 单次 Ping 超时
+	dockerFailureThreshold = 3                // 连续失败多少次后视为断开
+	dockerMaxBackoff       = 30 * time.Second // 断开后重试的最大退避时间
+	eventTypeDaemon        = "daemon"         // 合成事件：Docker 守护进程断开 / 恢复
+)
+
+// Docker 守护进程是否可连接（启动时检查通过后为 true）
+var dockerConnected atomic.Bool
+
+// 断开状态
+var dockerOutage struct {
+	sync.RWMutex
+	since     time.Time
+	lastError string
+}
+
+// Docker 连接状态（前端据此显示提示横幅）
+type DockerStatus struct {
+	Connected bool          `json:"connected"`
+	DownSince string        `json:"down_since,omitempty"`
+	LastError string        `json:"last_error,omitempty"`
+	Compat    *DockerCompat `json:"compat,omitempty"`
+}
+
+// 客户端被替换（守护进程恢复、修改连接地址）后通知事件消费者立即重新订阅
+var dockerClientReplaced = make(chan struct{}, 1)
+
+// 定期 Ping Docker 守护进程：连续失败后标记为断开，之后退避重试，
+// 恢复时重建客户端（重新协商 API 版本，守护进程可能已升级）并恢复事件订阅
+func superviseDocker() {
+	failures := 0
+	backoff := time.Second
+
+	for {
+		interval := dockerPingInterval
+		if !dockerConnected.Load() {
+			interval = backoff
+		}
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		ctx, cancel := context.WithTimeout(serverCtx, dockerPingTimeout)
+		_, err := getDockerClient().Ping(ctx)
+		cancel()
+		if serverCtx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			failures = 0
+			if !dockerConnected.Load() {
+				if err := restoreDocker(); err != nil {
+					log.Printf("[Docker] Reconnect failed: %v", err)
+					backoff = nextDockerBackoff(backoff)
+					continue
+				}
+			}
+			backoff = time.Second
+			continue
+		}
+
+		failures++
+		if dockerConnected.Load() {
+			if failures >= dockerFailureThreshold {
+				markDockerDown(err)
+			}
+			continue
+		}
+		setDockerOutageError(err)
+		backoff = nextDockerBackoff(backoff)
+	}
+}
+
+func nextDockerBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > dockerMaxBackoff {
+		backoff = dockerMaxBackoff
+	}
+	return backoff
+}
+
+func setDockerOutageError(err error) {
+	dockerOutage.Lock()
+	dockerOutage.lastError = err.Error()
+	dockerOutage.Unlock()
+}
+
+// 标记 Docker 断开：依赖 Docker 的接口返回 503，缓存失效
+func markDockerDown(err error) {
+	now := time.Now()
+	dockerOutage.Lock()
+	dockerOutage.since = now
+	dockerOutage.lastError = err.Error()
+	dockerOutage.Unlock()
+	dockerConnected.Store(false)
+
+	InvalidateContainers()
+	InvalidateImages()
+	log.Printf("[Docker] Daemon unreachable, retrying in background: %v", err)
+	notifyDockerDaemon("disconnect", map[string]string{"error": err.Error()})
+}
+
+// 守护进程恢复：重建客户端并重新检查版本兼容性，成功后恢复正常
+func restoreDocker() error {
+	dockerSwitchMu.Lock()
+	defer dockerSwitchMu.Unlock()
+	if dockerConnected.Load() {
+		return nil // 期间已修改连接地址
+	}
+
+	cli, err := newDockerClientFor(currentDockerEndpoint())
+	if err != nil {
+		return err
+	}
+	if old := setDockerClient(cli); old != nil {
+		old.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(serverCtx, dockerPingTimeout)
+	defer cancel()
+	compat, err := checkDockerCompat(ctx)
+	if err != nil {
+		return err
+	}
+
+	since := markDockerConnected()
+	outage := time.Since(since).Round(time.Second)
+	log.Printf("[Docker] Daemon reconnected after %s (down since %s)", outage, since.Format(time.RFC3339))
+	notifyDockerDaemon("reconnect", map[string]string{
+		"down_since": since.Format(time.RFC3339),
+		"outage":     outage.String(),
+	})
+	if !compat.Compatible {
+		log.Printf("警告: Docker API 版本不兼容，Docker 相关功能不可用: %s。%s", compat.Message, compat.Hint)
+	}
+	return nil
+}
+
+// 替换客户端后恢复正常：清除断开状态和缓存，恢复事件订阅（需持有 dockerSwitchMu）
+// 返回断开的开始时间（未断开时为零值）
+func markDockerConnected() time.Time {
+	dockerOutage.Lock()
+	since := dockerOutage.since
+	dockerOutage.since = time.Time{}
+	dockerOutage.lastError = ""
+	dockerOutage.Unlock()
+	dockerConnected.Store(true)
+
+	InvalidateContainers()
+	InvalidateImages()
+
+	if !dockerAvailable() {
+		return since
+	}
+	// 启动时版本不兼容则事件记录尚未启动
+	if !dockerEventsStarted.Load() {
+		if err := initDockerEvents(); err != nil {
+			log.Printf("警告: 初始化 Docker 事件记录失败: %v", err)
+		}
+		return since
+	}
+	select {
+	case dockerClientReplaced <- struct{}{}:
+	default:
+	}
+	return since
+}
+
+// 记录守护进程断开 / 恢复的事件（出现在事件时间线中），并发送到通知渠道
+func notifyDockerDaemon(action string, attrs map[string]string) {
+	n := Notification{Event: "docker_" + action, Fields: attrs}
+	if action == "disconnect" {
+		n.Level, n.Title, n.Message = notifyCritical, "Docker 守护进程连接断开", "面板无法连接 Docker，正在后台重试"
+	} else {
+		n.Level, n.Title, n.Message = notifyInfo, "Docker 守护进程已恢复连接", ""
+	}
+	Notify(n)
+
+	if !dockerEventsStarted.Load() {
+		return
+	}
+	storeDockerEvent(DockerEvent{
+		Time:       time.Now().UnixNano(),
+		Type:       eventTypeDaemon,
+		Action:     action,
+		Attributes: attrs,
+	})
+}
+
+// 当前的 Docker 连接状态
+func currentDockerStatus() *DockerStatus {
+	status := &DockerStatus{
+		Connected: dockerConnected.Load(),
+		Compat:    dockerCompat.Load(),
+	}
+	if !status.Connected {
+		dockerOutage.RLock()
+		if !dockerOutage.since.IsZero() {
+			status.DownSince = dockerOutage.since.Format(time.RFC3339)
+		}
+		status.LastError = dockerOutage.lastError
+		dockerOutage.RUnlock()
+	}
+	return status
+}
+
+// 返回 Docker 未连接的错误响应
+func writeDockerUnavailable(w http.ResponseWriter) {
+	writeErrorDetails(w, http.StatusServiceUnavailable, ErrCodeDockerUnavailable, "Docker 守护进程未连接，正在自动重连", currentDockerStatus())
+}
+
+// Docker 连接状态
+func handleDockerStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentDockerStatus())
+}
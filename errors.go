@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// API 错误码（机器可读，前端据此判断错误类型，message 仅用于展示）
+const (
+	ErrCodeBadRequest         = "BAD_REQUEST"
+	ErrCodeUnauthorized       = "UNAUTHORIZED"
+	ErrCodeForbidden          = "FORBIDDEN"
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	ErrCodeConflict           = "CONFLICT"
+	ErrCodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	ErrCodeRangeNotSatisfied  = "RANGE_NOT_SATISFIABLE"
+	ErrCodeUnprocessable      = "UNPROCESSABLE_ENTITY"
+	ErrCodeTooManyRequests    = "TOO_MANY_REQUESTS"
+	ErrCodeInternal           = "INTERNAL_ERROR"
+	ErrCodeNotImplemented     = "NOT_IMPLEMENTED"
+	ErrCodeBadGateway         = "BAD_GATEWAY"
+	ErrCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	ErrCodeGatewayTimeout     = "GATEWAY_TIMEOUT"
+	ErrCodeInsufficientSpace  = "INSUFFICIENT_STORAGE"
+
+	ErrCodeNeedChangePassword = "NEED_CHANGE_PASSWORD"
+	ErrCodeBackupTooNew       = "BACKUP_SCHEMA_TOO_NEW" // 备份来自更新版本的面板
+
+	// Docker 相关
+	ErrCodeDockerUnavailable    = "DOCKER_UNAVAILABLE"      // 守护进程未连接
+	ErrCodeDockerIncompatible   = "DOCKER_API_INCOMPATIBLE" // API 版本不兼容
+	ErrCodeImageInUse           = "IMAGE_IN_USE"            // 镜像正在被容器使用
+	ErrCodeImageHasDependents   = "IMAGE_HAS_DEPENDENT_CHILD"
+	ErrCodeImageReferenced      = "IMAGE_REFERENCED"
+	ErrCodeUploadChunksMissing  = "UPLOAD_CHUNKS_MISSING"
+	ErrCodeEndpointUnreachable  = "DOCKER_ENDPOINT_UNREACHABLE"
+	ErrCodeWorkerRequestFailure = "WORKER_REQUEST_FAILED"
+	ErrCodeCLIMissing           = "DOCKER_CLI_MISSING" // 缺少 docker 命令或 compose 子命令
+)
+
+// 错误响应：{"error": {"code": "...", "message": "...", "details": ..., "request_id": "..."}}
+type APIError struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// HTTP 状态码对应的默认错误码
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodePayloadTooLarge
+	case http.StatusRequestedRangeNotSatisfiable:
+		return ErrCodeRangeNotSatisfied
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrCodeTooManyRequests
+	case http.StatusNotImplemented:
+		return ErrCodeNotImplemented
+	case http.StatusBadGateway:
+		return ErrCodeBadGateway
+	case http.StatusServiceUnavailable:
+		return ErrCodeServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrCodeGatewayTimeout
+	case http.StatusInsufficientStorage:
+		return ErrCodeInsufficientSpace
+	}
+	if status >= 500 {
+		return ErrCodeInternal
+	}
+	return ErrCodeBadRequest
+}
+
+// 返回 JSON 错误响应，request_id 取自 withAccessLog 设置的响应头
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorDetails(w, status, code, message, nil)
+}
+
+// 返回带附加信息的 JSON 错误响应，message 按 withLocale 设置的响应语言翻译
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	h := w.Header()
+	message = localizeError(h.Get("Content-Language"), code, message)
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]*APIError{
+		"error": {
+			Code:      code,
+			Message:   message,
+			Details:   details,
+			RequestID: h.Get("X-Request-ID"),
+		},
+	})
+}
+
+// 解析其他节点返回的错误响应（兼容旧版本的纯文本错误）
+func parseAPIError(body []byte) (code, message string) {
+	var resp struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error != nil {
+		return resp.Error.Code, resp.Error.Message
+	}
+	return "", strings.TrimSpace(string(body))
+}
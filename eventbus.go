@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/gorilla/websocket"
+)
+
+// 前端实时事件总线：一个 WebSocket（/api/ws）按主题推送多种消息，代替各列表分别轮询
+// - containers / images / networks：Docker 事件引起的变化通知（前端收到后刷新对应列表）
+// - stats：后台采样器每次采样后的系统资源
+// - nodes：Master 模式下节点上线、离线
+// - jobs：后台任务状态变化和输出
+// 每条消息带全局递增的 seq；客户端重连时在订阅消息中带上 epoch 和最后收到的 seq，
+// 缺失的消息还在重放缓冲区中则补发，否则发送 resync 提示客户端重新获取列表。
+// 每个连接的发送缓冲区有上限，慢客户端的消息被丢弃并收到 resync，不影响其他连接和发布方
+
+const (
+	busTopicContainers = "containers"
+	busTopicImages     = "images"
+	busTopicNetworks   = "networks"
+	busTopicStats      = "stats"
+	busTopicNodes      = "nodes"
+	busTopicJobs       = "jobs"
+
+	busClientBuffer = 256 // 每个连接最多缓存的待发送消息数
+	busReplaySize   = 500 // 保留最近的消息用于重连补发（不含 stats）
+)
+
+var busTopics = map[string]bool{
+	busTopicContainers: true,
+	busTopicImages:     true,
+	busTopicNetworks:   true,
+	busTopicStats:      true,
+	busTopicNodes:      true,
+	busTopicJobs:       true,
+}
+
+// 推送给客户端的消息
+type BusMessage struct {
+	Seq   int64       `json:"seq"` // 全局递增；hello、subscribed、resync 为当前的 seq
+	Topic string      `json:"topic,omitempty"`
+	Type  string      `json:"type"` // hello / subscribed / resync / change / stats / node_status / job / job_log
+	Time  int64       `json:"time"` // Unix 毫秒
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// 客户端发送的订阅消息
+type busSubscribeRequest struct {
+	Action string   `json:"action"` // subscribe / unsubscribe
+	Topics []string `json:"topics"`
+	Epoch  int64    `json:"epoch,omitempty"` // 上次连接 hello 中的 epoch
+	Since  int64    `json:"since,omitempty"` // 上次连接最后收到的 seq
+}
+
+type busClient struct {
+	send     chan []byte
+	overflow chan struct{}   // 有消息因缓冲区已满被丢弃
+	topics   map[string]bool // 由 eventBus.mu 保护
+	dropped  map[string]bool // 被丢弃消息的主题，由 eventBus.mu 保护
+}
+
+type busRecord struct {
+	seq   int64
+	topic string
+	data  []byte
+}
+
+var eventBus = struct {
+	mu      sync.Mutex
+	epoch   int64 // 进程启动时间，seq 从 0 重新开始时 epoch 不同
+	seq     int64
+	clients map[*busClient]struct{}
+	replay  []busRecord // 环形缓冲区
+	next    int
+}{
+	epoch:   time.Now().UnixMilli(),
+	clients: map[*busClient]struct{}{},
+	replay:  make([]busRecord, 0, busReplaySize),
+}
+
+// 是否有连接订阅了该主题（用于跳过无人订阅时的采集工作）
+func busHasSubscribers(topic string) bool {
+	eventBus.mu.Lock()
+	defer eventBus.mu.Unlock()
+	for c := range eventBus.clients {
+		if c.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// 发布消息，不阻塞：连接的缓冲区已满时丢弃并记下主题，稍后发送 resync
+func publishBus(topic, msgType string, data interface{}) {
+	eventBus.mu.Lock()
+	defer eventBus.mu.Unlock()
+
+	eventBus.seq++
+	payload, err := json.Marshal(BusMessage{
+		Seq:   eventBus.seq,
+		Topic: topic,
+		Type:  msgType,
+		Time:  time.Now().UnixMilli(),
+		Data:  data,
+	})
+	if err != nil {
+		log.Printf("[Bus] Marshal %s message failed: %v", topic, err)
+		return
+	}
+
+	if topic != busTopicStats {
+		rec := busRecord{seq: eventBus.seq, topic: topic, data: payload}
+		if len(eventBus.replay) < busReplaySize {
+			eventBus.replay = append(eventBus.replay, rec)
+		} else {
+			eventBus.replay[eventBus.next] = rec
+		}
+		eventBus.next = (eventBus.next + 1) % busReplaySize
+	}
+
+	for c := range eventBus.clients {
+		if c.topics[topic] {
+			c.enqueueLocked(topic, payload)
+		}
+	}
+}
+
+// 加入连接的发送队列（调用方需持有 eventBus.mu，保证消息按 seq 顺序入队），
+// 缓冲区已满时丢弃并记下主题
+func (c *busClient) enqueueLocked(topic string, payload []byte) {
+	select {
+	case c.send <- payload:
+	default:
+		if topic == "" {
+			return // subscribed 等不属于主题的控制消息
+		}
+		c.dropped[topic] = true
+		select {
+		case c.overflow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 所有客户端重新获取这些主题的列表（如 Docker 事件流中断后无法确定丢失了哪些变化）
+func publishBusResync(reason string, topics ...string) {
+	for _, topic := range topics {
+		publishBus(topic, "resync", map[string]string{"reason": reason})
+	}
+}
+
+// 构造发给单个连接的控制消息（不占用 seq，带当前的 seq）
+func busControlMessage(topic, msgType string, data interface{}) []byte {
+	eventBus.mu.Lock()
+	seq := eventBus.seq
+	eventBus.mu.Unlock()
+	return busControlMessageLocked(seq, topic, msgType, data)
+}
+
+func busControlMessageLocked(seq int64, topic, msgType string, data interface{}) []byte {
+	payload, _ := json.Marshal(BusMessage{Seq: seq, Topic: topic, Type: msgType, Time: time.Now().UnixMilli(), Data: data})
+	return payload
+}
+
+// 处理订阅：补发的消息或 resync，以及最后的 subscribed 确认，在持有 eventBus.mu 时加入发送队列，
+// 之后发布的消息一定排在它们后面
+func (c *busClient) subscribe(req busSubscribeRequest) {
+	eventBus.mu.Lock()
+	defer eventBus.mu.Unlock()
+	defer func() {
+		c.enqueueLocked("", busControlMessageLocked(eventBus.seq, "", "subscribed", map[string]interface{}{"topics": sortedKeys(c.topics)}))
+	}()
+
+	var added []string
+	for _, t := range req.Topics {
+		if !busTopics[t] {
+			continue
+		}
+		if req.Action == "unsubscribe" {
+			delete(c.topics, t)
+			continue
+		}
+		if !c.topics[t] {
+			added = append(added, t)
+		}
+		c.topics[t] = true
+	}
+	if req.Action == "unsubscribe" || len(added) == 0 {
+		return
+	}
+
+	// 能否补发：同一进程（epoch 相同），且 since 之后的消息都还在缓冲区中
+	canReplay := req.Since > 0 && req.Epoch == eventBus.epoch && req.Since <= eventBus.seq
+	if canReplay && len(eventBus.replay) == busReplaySize {
+		oldest := eventBus.replay[eventBus.next].seq
+		canReplay = oldest <= req.Since+1
+	}
+
+	if canReplay {
+		isAdded := map[string]bool{}
+		for _, t := range added {
+			isAdded[t] = true
+		}
+		n := len(eventBus.replay)
+		start := 0
+		if n == busReplaySize {
+			start = eventBus.next
+		}
+		for i := 0; i < n; i++ {
+			rec := eventBus.replay[(start+i)%n]
+			if rec.seq > req.Since && isAdded[rec.topic] {
+				c.enqueueLocked(rec.topic, rec.data)
+			}
+		}
+		return
+	}
+
+	// 新连接或缺失的消息已被覆盖：提示重新获取（stats 为周期推送，不需要）
+	for _, t := range added {
+		if t == busTopicStats {
+			continue
+		}
+		c.enqueueLocked(t, busControlMessageLocked(eventBus.seq, t, "resync", map[string]string{"reason": "subscribe"}))
+	}
+}
+
+// 取出因缓冲区已满被丢弃消息的主题
+func (c *busClient) takeDropped() []string {
+	eventBus.mu.Lock()
+	defer eventBus.mu.Unlock()
+	topics := make([]string, 0, len(c.dropped))
+	for t := range c.dropped {
+		topics = append(topics, t)
+	}
+	c.dropped = map[string]bool{}
+	return topics
+}
+
+// ========== 事件来源 ==========
+
+// Docker 事件转换为变化通知
+func publishDockerEvent(msg events.Message) {
+	var topic string
+	switch msg.Type {
+	case events.ContainerEventType:
+		topic = busTopicContainers
+	case events.ImageEventType:
+		topic = busTopicImages
+	case events.NetworkEventType:
+		topic = busTopicNetworks
+	default:
+		return
+	}
+	action := string(msg.Action)
+	if topic == busTopicContainers && (strings.HasPrefix(action, "exec_") || action == "top" || action == "attach" || action == "archive-path") {
+		return // 不影响列表的容器事件（exec 动作带有命令后缀，如 "exec_start: sh"）
+	}
+
+	data := map[string]string{
+		"action": action,
+		"id":     msg.Actor.ID,
+		"name":   msg.Actor.Attributes["name"],
+	}
+	for _, key := range []string{"image", "container", "com.docker.compose.project", "com.docker.compose.service"} {
+		if v := msg.Actor.Attributes[key]; v != "" {
+			data[key] = v
+		}
+	}
+	publishBus(topic, "change", data)
+}
+
+// 系统资源（由后台采样器在每次采样后调用）
+func publishSystemStats() {
+	if !busHasSubscribers(busTopicStats) {
+		return
+	}
+	publishBus(busTopicStats, "stats", currentSystemStats())
+}
+
+// 节点状态变化
+func publishNodeStatus(node *NodeInfo, previous string) {
+	publishBus(busTopicNodes, "node_status", map[string]string{
+		"id":       node.ID,
+		"name":     node.Name,
+		"address":  node.Address,
+		"status":   node.Status,
+		"previous": previous,
+	})
+}
+
+// ========== WebSocket ==========
+
+// 实时事件 WebSocket
+// 连接后先收到 hello（含 epoch 和当前 seq），然后发送 {"action":"subscribe","topics":["containers","stats"]}
+func handleEventBusWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Bus] WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	stopKeepalive := startWSKeepalive(conn)
+	defer stopKeepalive()
+
+	client := &busClient{
+		send:     make(chan []byte, busClientBuffer),
+		overflow: make(chan struct{}, 1),
+		topics:   map[string]bool{},
+		dropped:  map[string]bool{},
+	}
+	eventBus.mu.Lock()
+	eventBus.clients[client] = struct{}{}
+	epoch := eventBus.epoch
+	eventBus.mu.Unlock()
+	defer func() {
+		eventBus.mu.Lock()
+		delete(eventBus.clients, client)
+		eventBus.mu.Unlock()
+	}()
+
+	// 读取订阅消息；补发和 resync 与发布的消息一样进入 client.send，由写循环按顺序发送
+	closed := make(chan struct{})
+	go func() {
+		defer recoverGoroutine(r.Context(), "event bus reader")
+		defer close(closed)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req busSubscribeRequest
+			if err := json.Unmarshal(message, &req); err != nil {
+				continue
+			}
+			if req.Action != "subscribe" && req.Action != "unsubscribe" {
+				continue
+			}
+			client.subscribe(req)
+		}
+	}()
+
+	write := func(payload []byte) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteMessage(websocket.TextMessage, payload) == nil
+	}
+
+	if !write(busControlMessage("", "hello", map[string]interface{}{"epoch": epoch, "topics": sortedKeys(busTopics)})) {
+		return
+	}
+	for {
+		select {
+		case <-closed:
+			return
+		case <-serverCtx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+			return
+		case payload := <-client.send:
+			if !write(payload) {
+				return
+			}
+		case <-client.overflow:
+			for _, t := range client.takeDropped() {
+				if !write(busControlMessage(t, "resync", map[string]string{"reason": "buffer_overflow"})) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 重新订阅时补发的消息排在之后发布的消息前面，seq 严格递增
+func TestEventBusReplayOrder(t *testing.T) {
+	client := &busClient{
+		send:     make(chan []byte, busClientBuffer),
+		overflow: make(chan struct{}, 1),
+		topics:   map[string]bool{},
+		dropped:  map[string]bool{},
+	}
+	publishBus(busTopicContainers, "change", nil) // 补发要求 since > 0
+	eventBus.mu.Lock()
+	eventBus.clients[client] = struct{}{}
+	epoch, since := eventBus.epoch, eventBus.seq
+	eventBus.mu.Unlock()
+	t.Cleanup(func() {
+		eventBus.mu.Lock()
+		delete(eventBus.clients, client)
+		eventBus.mu.Unlock()
+	})
+
+	// 订阅前发布的消息只能通过补发收到
+	for i := 0; i < 4; i++ {
+		publishBus(busTopicContainers, "change", map[string]int{"i": i})
+	}
+	client.subscribe(busSubscribeRequest{Action: "subscribe", Topics: []string{busTopicContainers}, Epoch: epoch, Since: since})
+	publishBus(busTopicContainers, "change", map[string]int{"i": 4})
+
+	var last int64
+	var types []string
+	for len(client.send) > 0 {
+		var msg BusMessage
+		if err := json.Unmarshal(<-client.send, &msg); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, msg.Type)
+		if msg.Type == "subscribed" {
+			continue
+		}
+		if msg.Seq <= last {
+			t.Errorf("seq %d after %d: %v", msg.Seq, last, types)
+		}
+		last = msg.Seq
+	}
+	if len(types) != 6 || types[len(types)-2] != "subscribed" || types[len(types)-1] != "change" {
+		t.Errorf("messages = %v, want 4 replayed, subscribed, then the new change", types)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// 事件保留策略
+const (
+	eventRingSize       = 1000             // 内存中保留的最近事件数
+	eventMaxBackoff     = 30 * time.Second // 重连最大退避时间
+	eventTypeEventsLost = "events_lost"    // 合成事件：连接中断期间事件丢失
+)
+
+// Docker 事件记录
+type DockerEvent struct {
+	Time       int64             `json:"time"` // Unix 纳秒时间戳
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	ActorID    string            `json:"actor_id"`
+	ActorName  string            `json:"actor_name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// 最近事件环形缓冲区
+var eventRing struct {
+	sync.RWMutex
+	items []DockerEvent
+	next  int
+	full  bool
+}
+
+// 事件记录是否已启动（启动时 Docker 版本不兼容则在恢复后启动）
+var dockerEventsStarted atomic.Bool
+
+// 初始化事件表并启动后台事件消费者
+func initDockerEvents() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS docker_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		action TEXT NOT NULL,
+		actor_id TEXT,
+		actor_name TEXT,
+		attributes TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_docker_events_time ON docker_events(time);`)
+	if err != nil {
+		return fmt.Errorf("创建事件表失败: %v", err)
+	}
+
+	eventRing.items = make([]DockerEvent, eventRingSize)
+
+	// 从数据库预加载最近的事件，保证重启后内存缓冲区与数据库一致
+	recent, err := recentEventsFromDB(0, "", eventRingSize)
+	if err != nil {
+		return fmt.Errorf("加载历史事件失败: %v", err)
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		pushEventRing(recent[i])
+	}
+
+	dockerEventsStarted.Store(true)
+	go consumeDockerEvents()
+	go pruneDockerEvents()
+	return nil
+}
+
+// 持续消费 Docker 事件，断开后指数退避重连
+func consumeDockerEvents() {
+	backoff := time.Second
+	var lastSeen time.Time
+	var connectedAt time.Time
+	connected, everConnected := false, false
+
+	// Events() 返回时连接还没有建立，Ping 成功或收到第一个事件后才视为已连接；
+	// 只在从断开变为连接时清空缓存并通知前端，守护进程停止期间的每次重试不会触发
+	markConnected := func() {
+		if connected {
+			return
+		}
+		connected = true
+		connectedAt = time.Now()
+		dockerEventsConnected.Store(true)
+		// 断开期间的变化可能没有补齐，清空缓存
+		InvalidateContainers()
+		InvalidateImages()
+		if everConnected {
+			// 通知前端重新获取列表
+			publishBusResync("events_reconnected", busTopicContainers, busTopicImages, busTopicNetworks)
+		}
+		everConnected = true
+	}
+	markDisconnected := func() {
+		connected = false
+		dockerEventsConnected.Store(false)
+	}
+
+reconnect:
+	for {
+		ctx, cancel := context.WithCancel(serverCtx)
+		options := types.EventsOptions{}
+		if !lastSeen.IsZero() {
+			// 重连时从上次收到的事件之后继续，尽量补齐中断期间的事件
+			options.Since = strconv.FormatInt(lastSeen.Unix(), 10)
+		}
+		cli := getDockerClient()
+		msgs, errs := cli.Events(ctx, options)
+		pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+		if _, err := cli.Ping(pingCtx); err == nil {
+			markConnected()
+		}
+		pingCancel()
+	loop:
+		for {
+			select {
+			case msg := <-msgs:
+				markConnected()
+				t := time.Unix(0, msg.TimeNano)
+				if !lastSeen.IsZero() && !t.After(lastSeen) {
+					continue // 重连后 since 会重放同一秒内的旧事件
+				}
+				lastSeen = t
+				invalidateCachesForEvent(msg)
+				publishDockerEvent(msg)
+				storeDockerEvent(fromEventMessage(msg))
+			case err := <-errs:
+				markDisconnected()
+				if serverCtx.Err() != nil {
+					cancel()
+					return
+				}
+				log.Printf("[Events] Event stream closed: %v", err)
+				break loop
+			case <-dockerClientReplaced:
+				// 修改了连接地址，旧的事件流仍连接在原来的守护进程上，立即用新客户端重新订阅
+				markDisconnected()
+				cancel()
+				continue reconnect
+			}
+		}
+		cancel()
+
+		// 连接持续一段时间后才重置退避
+		if !connectedAt.IsZero() && time.Since(connectedAt) > eventMaxBackoff {
+			backoff = time.Second
+		}
+
+		storeDockerEvent(DockerEvent{
+			Time:   time.Now().UnixNano(),
+			Type:   eventTypeEventsLost,
+			Action: "disconnect",
+			Attributes: map[string]string{
+				"retry_in": backoff.String(),
+			},
+		})
+
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-dockerClientReplaced:
+			// 守护进程已恢复（客户端已重建），立即重新订阅
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > eventMaxBackoff {
+			backoff = eventMaxBackoff
+		}
+	}
+}
+
+// 将 Docker 事件转换为记录
+func fromEventMessage(msg events.Message) DockerEvent {
+	name := msg.Actor.Attributes["name"]
+	return DockerEvent{
+		Time:       msg.TimeNano,
+		Type:       string(msg.Type),
+		Action:     string(msg.Action),
+		ActorID:    msg.Actor.ID,
+		ActorName:  name,
+		Attributes: msg.Actor.Attributes,
+	}
+}
+
+// 写入环形缓冲区
+func pushEventRing(ev DockerEvent) {
+	eventRing.Lock()
+	eventRing.items[eventRing.next] = ev
+	eventRing.next = (eventRing.next + 1) % eventRingSize
+	if eventRing.next == 0 {
+		eventRing.full = true
+	}
+	eventRing.Unlock()
+}
+
+// 保存事件到环形缓冲区和数据库
+func storeDockerEvent(ev DockerEvent) {
+	pushEventRing(ev)
+
+	attrs, _ := json.Marshal(ev.Attributes)
+	_, err := authDB.Exec(
+		"INSERT INTO docker_events (time, type, action, actor_id, actor_name, attributes) VALUES (?, ?, ?, ?, ?, ?)",
+		ev.Time, ev.Type, ev.Action, ev.ActorID, ev.ActorName, string(attrs),
+	)
+	if err != nil {
+		log.Printf("[Events] Save event failed: %v", err)
+	}
+}
+
+// 定期清理过期事件
+func pruneDockerEvents() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cutoff := time.Now().Add(-settingDays("retention.events_days")).UnixNano()
+		if _, err := authDB.Exec("DELETE FROM docker_events WHERE time < ?", cutoff); err != nil {
+			log.Printf("[Events] Prune events failed: %v", err)
+		}
+	}
+}
+
+// 从环形缓冲区读取事件（最新的在前）
+func recentEventsFromRing(since int64, eventType string, limit int) ([]DockerEvent, bool) {
+	eventRing.RLock()
+	defer eventRing.RUnlock()
+
+	count := eventRing.next
+	if eventRing.full {
+		count = eventRingSize
+	}
+
+	result := make([]DockerEvent, 0)
+	covered := false
+	for i := 0; i < count; i++ {
+		idx := (eventRing.next - 1 - i + eventRingSize) % eventRingSize
+		ev := eventRing.items[idx]
+		if ev.Time < since {
+			covered = true
+			break
+		}
+		if eventType != "" && ev.Type != eventType {
+			continue
+		}
+		result = append(result, ev)
+		if len(result) >= limit {
+			covered = true
+			break
+		}
+	}
+
+	// 缓冲区未满时说明已包含全部事件
+	if !eventRing.full {
+		covered = true
+	}
+	return result, covered
+}
+
+// 从数据库读取事件（最新的在前）
+func recentEventsFromDB(since int64, eventType string, limit int) ([]DockerEvent, error) {
+	query := "SELECT time, type, action, actor_id, actor_name, attributes FROM docker_events WHERE time >= ?"
+	args := []interface{}{since}
+	if eventType != "" {
+		query += " AND type = ?"
+		args = append(args, eventType)
+	}
+	query += " ORDER BY time DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := authDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]DockerEvent, 0)
+	for rows.Next() {
+		var ev DockerEvent
+		var attrs string
+		if err := rows.Scan(&ev.Time, &ev.Type, &ev.Action, &ev.ActorID, &ev.ActorName, &attrs); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(attrs), &ev.Attributes)
+		result = append(result, ev)
+	}
+	return result, rows.Err()
+}
+
+// 最近事件 API（?since=1h&type=container&limit=200）
+func handleRecentEvents(w http.ResponseWriter, r *http.Request) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		sinceStr = "1h"
+	}
+	sinceDur, err := parseMetricsRange(sinceStr)
+	if err != nil || sinceDur <= 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "since 参数无效")
+		return
+	}
+
+	limit := 200
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 5000 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+	}
+
+	eventType := strings.TrimSpace(r.URL.Query().Get("type"))
+	since := time.Now().Add(-sinceDur).UnixNano()
+
+	// 优先从内存读取，内存不足以覆盖时间范围再查询数据库
+	result, covered := recentEventsFromRing(since, eventType, limit)
+	if !covered {
+		result, err = recentEventsFromDB(since, eventType, limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询事件失败: %v", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
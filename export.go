@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// 资源清单导出：容器、镜像、卷、节点，支持 CSV 和 JSON
+// Master 上通过节点认证调用各在线 Worker 的同一接口（local=true 只返回 Worker 本机数据）后合并
+
+const inventoryNodeTimeout = 15 * time.Second // 获取单个 Worker 清单的超时
+
+var inventoryScopes = []string{"containers", "images", "volumes", "nodes"}
+
+// 容器清单
+type InventoryContainer struct {
+	Node    string `json:"node"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Image   string `json:"image"`
+	State   string `json:"state"`
+	Status  string `json:"status"`
+	Ports   string `json:"ports"`
+	Created string `json:"created"`
+}
+
+// 镜像清单（每个镜像一条，多个标签以空格分隔）
+type InventoryImage struct {
+	Node    string `json:"node"`
+	ID      string `json:"id"`
+	Tags    string `json:"tags"`
+	Digest  string `json:"digest"`
+	Size    int64  `json:"size"` // 字节
+	Created string `json:"created"`
+}
+
+// 卷清单
+type InventoryVolume struct {
+	Node       string `json:"node"`
+	Name       string `json:"name"`
+	Driver     string `json:"driver"`
+	Size       int64  `json:"size"` // 字节，-1 表示未知
+	RefCount   int64  `json:"ref_count"`
+	Mountpoint string `json:"mountpoint"`
+}
+
+// 节点清单
+type InventoryNode struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Mode       string `json:"mode"`
+	Status     string `json:"status"`
+	Containers int    `json:"containers"`
+	LastSeen   string `json:"last_seen"`
+}
+
+// 清单报告
+type InventoryReport struct {
+	GeneratedAt string               `json:"generated_at"`
+	Containers  []InventoryContainer `json:"containers,omitempty"`
+	Images      []InventoryImage     `json:"images,omitempty"`
+	Volumes     []InventoryVolume    `json:"volumes,omitempty"`
+	Nodes       []InventoryNode      `json:"nodes,omitempty"`
+	Errors      []string             `json:"errors,omitempty"` // 部分节点或资源获取失败（报告仍包含其余数据）
+}
+
+// 解析 scope 参数（为空时导出全部）
+func parseInventoryScope(s string) (map[string]bool, error) {
+	scopes := make(map[string]bool)
+	if strings.TrimSpace(s) == "" {
+		for _, name := range inventoryScopes {
+			scopes[name] = true
+		}
+		return scopes, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		valid := false
+		for _, known := range inventoryScopes {
+			if name == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("scope 参数无效: %s", name)
+		}
+		scopes[name] = true
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("scope 参数无效: %s", s)
+	}
+	return scopes, nil
+}
+
+// 本机节点名称（与注册到 Master 时一致）
+func localNodeName() string {
+	if appConfig.NodeName != "" {
+		return appConfig.NodeName
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// 收集本机的容器、镜像和卷
+func collectLocalInventory(ctx context.Context, scopes map[string]bool, report *InventoryReport) {
+	if !dockerAvailable() {
+		if scopes["containers"] || scopes["images"] || scopes["volumes"] {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: Docker 守护进程未连接", localNodeName()))
+		}
+		return
+	}
+	cli := getDockerClient()
+	node := localNodeName()
+
+	if scopes["containers"] {
+		containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: 获取容器列表失败: %v", node, err))
+		}
+		for _, c := range containers {
+			name := c.ID[:12]
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			ports := make([]string, 0, len(c.Ports))
+			for _, p := range c.Ports {
+				if p.PublicPort != 0 {
+					ports = append(ports, fmt.Sprintf("%d:%d/%s", p.PublicPort, p.PrivatePort, p.Type))
+				} else if p.PrivatePort != 0 {
+					ports = append(ports, fmt.Sprintf(":%d/%s", p.PrivatePort, p.Type))
+				}
+			}
+			report.Containers = append(report.Containers, InventoryContainer{
+				Node:    node,
+				ID:      c.ID[:12],
+				Name:    name,
+				Image:   c.Image,
+				State:   c.State,
+				Status:  c.Status,
+				Ports:   strings.Join(ports, ", "),
+				Created: time.Unix(c.Created, 0).Format(time.RFC3339),
+			})
+		}
+	}
+
+	if scopes["images"] {
+		images, err := cli.ImageList(ctx, types.ImageListOptions{})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: 获取镜像列表失败: %v", node, err))
+		}
+		for _, img := range images {
+			tags := make([]string, 0, len(img.RepoTags))
+			for _, t := range img.RepoTags {
+				if t != "<none>:<none>" {
+					tags = append(tags, t)
+				}
+			}
+			digests := make([]string, 0, len(img.RepoDigests))
+			for _, d := range img.RepoDigests {
+				if i := strings.LastIndex(d, "@"); i >= 0 {
+					d = d[i+1:]
+				}
+				if !containsString(digests, d) {
+					digests = append(digests, d)
+				}
+			}
+			report.Images = append(report.Images, InventoryImage{
+				Node:    node,
+				ID:      strings.TrimPrefix(img.ID, "sha256:")[:12],
+				Tags:    strings.Join(tags, " "),
+				Digest:  strings.Join(digests, " "),
+				Size:    img.Size,
+				Created: time.Unix(img.Created, 0).Format(time.RFC3339),
+			})
+		}
+	}
+
+	if scopes["volumes"] {
+		// 卷大小只能通过 DiskUsage 获取（需要遍历卷目录，卷较多时较慢）
+		du, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: 获取卷列表失败: %v", node, err))
+		}
+		for _, v := range du.Volumes {
+			report.Volumes = append(report.Volumes, inventoryVolume(node, v))
+		}
+	}
+}
+
+func inventoryVolume(node string, v *volume.Volume) InventoryVolume {
+	item := InventoryVolume{Node: node, Name: v.Name, Driver: v.Driver, Size: -1, RefCount: -1, Mountpoint: v.Mountpoint}
+	if v.UsageData != nil {
+		item.Size = v.UsageData.Size
+		item.RefCount = v.UsageData.RefCount
+	}
+	return item
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 本机节点
+func localInventoryNode(ctx context.Context) InventoryNode {
+	mode := appConfig.Mode
+	if mode == "" {
+		mode = ModeMaster
+	}
+	node := InventoryNode{
+		ID:       "local",
+		Name:     localNodeName(),
+		Address:  net.JoinHostPort(getServerIPs().primary(), appConfig.Port),
+		Mode:     mode,
+		Status:   NodeStatusOnline,
+		LastSeen: time.Now().Format(time.RFC3339),
+	}
+	if s := getHostSummary(ctx); s.Containers != nil {
+		node.Containers = *s.Containers
+	} else {
+		node.Status = NodeStatusError // Docker 未连接
+	}
+	return node
+}
+
+// Master：Worker 节点列表，并并发获取在线 Worker 的清单
+func collectClusterInventory(ctx context.Context, scopes map[string]bool, report *InventoryReport) {
+	nodes := nodeManager.GetAllNodes()
+	nodeManager.RLock()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	nodeManager.RUnlock()
+
+	if scopes["nodes"] {
+		for _, n := range nodes {
+			nodeManager.RLock()
+			report.Nodes = append(report.Nodes, InventoryNode{
+				ID:         n.ID,
+				Name:       n.Name,
+				Address:    n.Address,
+				Mode:       n.Mode,
+				Status:     n.Status,
+				Containers: n.Containers,
+				LastSeen:   n.LastSeen.Format(time.RFC3339),
+			})
+			nodeManager.RUnlock()
+		}
+	}
+
+	// 节点列表只需要 Master 的数据
+	workerScopes := make([]string, 0, len(scopes))
+	for _, name := range inventoryScopes {
+		if scopes[name] && name != "nodes" {
+			workerScopes = append(workerScopes, name)
+		}
+	}
+	if len(workerScopes) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		nodeManager.RLock()
+		id, name, address, status := n.ID, n.Name, n.Address, n.Status
+		nodeManager.RUnlock()
+		if status != NodeStatusOnline {
+			mu.Lock()
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: 节点不在线，未包含其数据", name))
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer recoverGoroutine(ctx, "inventory worker "+id)
+			part, err := fetchWorkerInventory(ctx, address, workerScopes)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				componentLogger("export").Warn("获取节点清单失败", "name", name, "node_id", id, "error", err)
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", name, err))
+				return
+			}
+			// 以 Master 记录的节点名称为准
+			for i := range part.Containers {
+				part.Containers[i].Node = name
+			}
+			for i := range part.Images {
+				part.Images[i].Node = name
+			}
+			for i := range part.Volumes {
+				part.Volumes[i].Node = name
+			}
+			report.Containers = append(report.Containers, part.Containers...)
+			report.Images = append(report.Images, part.Images...)
+			report.Volumes = append(report.Volumes, part.Volumes...)
+			report.Errors = append(report.Errors, part.Errors...)
+		}()
+	}
+	wg.Wait()
+}
+
+// 通过节点认证获取 Worker 本机的清单
+func fetchWorkerInventory(ctx context.Context, address string, scopes []string) (*InventoryReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, inventoryNodeTimeout)
+	defer cancel()
+
+	workerURL := fmt.Sprintf("http://%s/api/export/inventory?format=json&local=true&scope=%s", address, strings.Join(scopes, ","))
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", workerURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	masterNodeID := "master"
+	httpReq.Header.Set("X-Node-ID", masterNodeID)
+	httpReq.Header.Set("X-Node-Token", generateNodeToken(masterNodeID))
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("节点返回状态码 %d", resp.StatusCode)
+	}
+	var report InventoryReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return &report, nil
+}
+
+// 导出资源清单
+// GET /api/export/inventory?format=csv|json&scope=containers,images,volumes,nodes
+func handleExportInventory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "format 参数无效，应为 csv 或 json")
+		return
+	}
+	scopes, err := parseInventoryScope(r.URL.Query().Get("scope"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	localOnly := r.URL.Query().Get("local") == "true"
+
+	now := time.Now()
+	report := &InventoryReport{GeneratedAt: now.Format(time.RFC3339)}
+	collectLocalInventory(r.Context(), scopes, report)
+	if scopes["nodes"] && !localOnly {
+		report.Nodes = append(report.Nodes, localInventoryNode(r.Context()))
+	}
+	if !localOnly && nodeManager != nil && nodeManager.mode == ModeMaster {
+		collectClusterInventory(r.Context(), scopes, report)
+	}
+	report.sort()
+
+	filename := "inventory-" + now.Format("20060102-150405")
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if !localOnly {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		}
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+	w.Write([]byte("\xEF\xBB\xBF")) // UTF-8 BOM，Excel 才能正确识别中文
+	if err := report.writeCSV(w, scopes); err != nil {
+		componentLogger("export").Warn("写入清单失败", "error", err)
+	}
+}
+
+// 按节点和名称排序，便于对比不同月份的报告
+func (rep *InventoryReport) sort() {
+	sort.SliceStable(rep.Containers, func(i, j int) bool {
+		a, b := rep.Containers[i], rep.Containers[j]
+		return a.Node < b.Node || (a.Node == b.Node && a.Name < b.Name)
+	})
+	sort.SliceStable(rep.Images, func(i, j int) bool {
+		a, b := rep.Images[i], rep.Images[j]
+		return a.Node < b.Node || (a.Node == b.Node && a.Tags < b.Tags)
+	})
+	sort.SliceStable(rep.Volumes, func(i, j int) bool {
+		a, b := rep.Volumes[i], rep.Volumes[j]
+		return a.Node < b.Node || (a.Node == b.Node && a.Name < b.Name)
+	})
+	sort.Strings(rep.Errors)
+}
+
+// CSV 格式：每类资源一段，段之间空一行，段首行为资源类型，第二行为表头
+func (rep *InventoryReport) writeCSV(w http.ResponseWriter, scopes map[string]bool) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	section := func(title string, header []string, rows [][]string) {
+		cw.Write([]string{title})
+		cw.Write(header)
+		for _, row := range rows {
+			cw.Write(row)
+		}
+		cw.Write(nil)
+	}
+
+	if scopes["containers"] {
+		rows := make([][]string, 0, len(rep.Containers))
+		for _, c := range rep.Containers {
+			rows = append(rows, []string{c.Node, c.Name, c.ID, c.Image, c.State, c.Status, c.Ports, c.Created})
+		}
+		section("containers", []string{"node", "name", "id", "image", "state", "status", "ports", "created"}, rows)
+	}
+	if scopes["images"] {
+		rows := make([][]string, 0, len(rep.Images))
+		for _, img := range rep.Images {
+			rows = append(rows, []string{img.Node, img.Tags, img.ID, img.Digest, strconv.FormatInt(img.Size, 10), img.Created})
+		}
+		section("images", []string{"node", "tags", "id", "digest", "size_bytes", "created"}, rows)
+	}
+	if scopes["volumes"] {
+		rows := make([][]string, 0, len(rep.Volumes))
+		for _, v := range rep.Volumes {
+			rows = append(rows, []string{v.Node, v.Name, v.Driver, strconv.FormatInt(v.Size, 10), strconv.FormatInt(v.RefCount, 10), v.Mountpoint})
+		}
+		section("volumes", []string{"node", "name", "driver", "size_bytes", "ref_count", "mountpoint"}, rows)
+	}
+	if scopes["nodes"] {
+		rows := make([][]string, 0, len(rep.Nodes))
+		for _, n := range rep.Nodes {
+			rows = append(rows, []string{n.Name, n.ID, n.Address, n.Mode, n.Status, strconv.Itoa(n.Containers), n.LastSeen})
+		}
+		section("nodes", []string{"name", "id", "address", "mode", "status", "containers", "last_seen"}, rows)
+	}
+	if len(rep.Errors) > 0 {
+		rows := make([][]string, 0, len(rep.Errors))
+		for _, e := range rep.Errors {
+			rows = append(rows, []string{e})
+		}
+		section("errors", []string{"message"}, rows)
+	}
+	cw.Flush()
+	return cw.Error()
+}
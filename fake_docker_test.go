@@ -0,0 +1,271 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// 测试用的 Docker Engine：在内存中模拟一个容器的文件系统，支持归档接口（HEAD/GET/PUT archive）和 exec
+type fakeDocker struct {
+	mu    sync.Mutex
+	files map[string]*fakeFile // 绝对路径 -> 文件，目录也需要单独登记
+	execs map[string]*fakeExec
+
+	// 执行 exec 命令，返回标准输出、标准错误和退出码；为空时所有命令都返回 127（命令不存在）
+	exec func(cmd []string) (stdout, stderr string, exitCode int)
+}
+
+type fakeFile struct {
+	mode os.FileMode // 类型和权限，如 os.ModeDir|0755
+	uid  int
+	gid  int
+	data []byte
+	link string // 符号链接目标
+}
+
+type fakeExec struct {
+	cmd      []string
+	exitCode int
+}
+
+var fakeDockerRoute = regexp.MustCompile(`^(?:/v[0-9.]+)?/(containers|exec)/([^/]+)/([a-z]+)$`)
+
+// 启动模拟的 Docker Engine 并替换全局客户端，测试结束后恢复
+func newFakeDocker(t *testing.T) *fakeDocker {
+	t.Helper()
+	fd := &fakeDocker{
+		files: map[string]*fakeFile{"/": {mode: os.ModeDir | 0755}},
+		execs: map[string]*fakeExec{},
+	}
+	srv := httptest.NewServer(fd)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.43"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := setDockerClient(cli)
+	t.Cleanup(func() {
+		setDockerClient(old)
+		cli.Close()
+		srv.Close()
+	})
+	return fd
+}
+
+// 添加文件或目录，自动创建缺少的上级目录
+func (fd *fakeDocker) add(p string, f *fakeFile) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := fd.files[dir]; !ok {
+			fd.files[dir] = &fakeFile{mode: os.ModeDir | 0755}
+		}
+	}
+	fd.files[p] = f
+}
+
+// 逐级解析路径中的符号链接（与 readlink -f 相同）
+func (fd *fakeDocker) resolve(p string) string {
+	resolved := "/"
+	for _, part := range strings.Split(strings.Trim(path.Clean(p), "/"), "/") {
+		next := path.Join(resolved, part)
+		if f := fd.get(next); f != nil && f.mode&os.ModeSymlink != 0 {
+			target := f.link
+			if !path.IsAbs(target) {
+				target = path.Join(resolved, target)
+			}
+			next = fd.resolve(target)
+		}
+		resolved = next
+	}
+	return resolved
+}
+
+// 获取文件（不存在时返回 nil）
+func (fd *fakeDocker) get(p string) *fakeFile {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.files[p]
+}
+
+func (fd *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := fakeDockerRoute.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		return
+	}
+	switch kind, id, action := m[1], m[2], m[3]; {
+	case kind == "containers" && action == "archive":
+		fd.serveArchive(w, r)
+	case kind == "containers" && action == "exec" && r.Method == http.MethodPost:
+		var config types.ExecConfig
+		json.NewDecoder(r.Body).Decode(&config)
+		fd.mu.Lock()
+		execID := fmt.Sprintf("exec%d", len(fd.execs)+1)
+		fd.execs[execID] = &fakeExec{cmd: config.Cmd}
+		fd.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"Id": execID})
+	case kind == "exec" && action == "start" && r.Method == http.MethodPost:
+		fd.startExec(w, id)
+	case kind == "exec" && action == "json":
+		fd.mu.Lock()
+		e := fd.execs[id]
+		fd.mu.Unlock()
+		if e == nil {
+			http.Error(w, `{"message":"no such exec"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ID": id, "Running": false, "ExitCode": e.exitCode})
+	default:
+		http.Error(w, `{"message":"not implemented"}`, http.StatusNotImplemented)
+	}
+}
+
+// 执行命令并按 Docker 的多路复用格式输出（与 dockerd 一样先升级连接）
+func (fd *fakeDocker) startExec(w http.ResponseWriter, id string) {
+	fd.mu.Lock()
+	e := fd.execs[id]
+	fd.mu.Unlock()
+	if e == nil {
+		http.Error(w, `{"message":"no such exec"}`, http.StatusNotFound)
+		return
+	}
+
+	stdout, stderr, exitCode := "", "exec: not found", 127
+	if fd.exec != nil {
+		stdout, stderr, exitCode = fd.exec(e.cmd)
+	}
+	fd.mu.Lock()
+	e.exitCode = exitCode
+	fd.mu.Unlock()
+
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.multiplexed-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	if stdout != "" {
+		stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte(stdout))
+	}
+	if stderr != "" {
+		stdcopy.NewStdWriter(buf, stdcopy.Stderr).Write([]byte(stderr))
+	}
+	buf.Flush()
+}
+
+func (fd *fakeDocker) serveArchive(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean(r.URL.Query().Get("path"))
+
+	if r.Method == http.MethodPut {
+		if err := fd.extract(p, r.Body); err != nil {
+			http.Error(w, fmt.Sprintf(`{"message":%q}`, err.Error()), http.StatusNotFound)
+			return
+		}
+		return
+	}
+
+	f := fd.get(p)
+	if f == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"Could not find the file %s in container"}`, p)
+		return
+	}
+	stat := types.ContainerPathStat{Name: path.Base(p), Size: int64(len(f.data)), Mode: f.mode, Mtime: time.Unix(0, 0)}
+	if f.mode&os.ModeSymlink != 0 {
+		stat.LinkTarget = fd.resolve(p) // dockerd 返回完全解析后的绝对路径
+	}
+	data, _ := json.Marshal(stat)
+	w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(data))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	tw := tar.NewWriter(w)
+	for _, name := range fd.tree(p) {
+		ff := fd.get(name)
+		hdr := &tar.Header{
+			Name:    path.Join(path.Base(p), strings.TrimPrefix(name, p)),
+			Mode:    int64(ff.mode.Perm()),
+			Uid:     ff.uid,
+			Gid:     ff.gid,
+			ModTime: time.Unix(0, 0),
+		}
+		switch {
+		case ff.mode.IsDir():
+			hdr.Typeflag, hdr.Name = tar.TypeDir, hdr.Name+"/"
+		case ff.mode&os.ModeSymlink != 0:
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, ff.link
+		default:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(ff.data))
+		}
+		tw.WriteHeader(hdr)
+		tw.Write(ff.data)
+	}
+	tw.Close()
+}
+
+// p 及其下的所有路径（排序后，父目录在前）
+func (fd *fakeDocker) tree(p string) []string {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	names := []string{p}
+	if fd.files[p].mode.IsDir() {
+		prefix := strings.TrimSuffix(p, "/") + "/"
+		for name := range fd.files {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 将 tar 归档解压到目录 dir
+func (fd *fakeDocker) extract(dir string, r io.Reader) error {
+	if f := fd.get(dir); f == nil || !f.mode.IsDir() {
+		return fmt.Errorf("Could not find the file %s in container", dir)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		io.Copy(&buf, tr)
+		fd.add(path.Join(dir, hdr.Name), &fakeFile{
+			mode: hdr.FileInfo().Mode(),
+			uid:  hdr.Uid,
+			gid:  hdr.Gid,
+			data: buf.Bytes(),
+			link: hdr.Linkname,
+		})
+	}
+}
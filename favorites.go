@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 每个用户的收藏（置顶）：容器按名称、compose 项目按项目名保存，容器重建后收藏依然有效
+// 容器列表可以把收藏排在前面，仪表盘概要中带上收藏对象的当前状态
+
+const (
+	favoriteKindContainer = "container"
+	favoriteKindCompose   = "compose"
+)
+
+// 一条收藏
+type Favorite struct {
+	Kind      string `json:"kind"` // container / compose
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"created_at"` // Unix 秒
+}
+
+// 收藏对象的当前状态（仪表盘使用）
+type FavoriteState struct {
+	Favorite
+	Exists  bool            `json:"exists"` // 容器删除后收藏保留，重新创建同名容器即恢复
+	ID      string          `json:"id,omitempty"`
+	Image   string          `json:"image,omitempty"`
+	State   string          `json:"state,omitempty"`
+	Status  string          `json:"status,omitempty"`
+	Stats   *ContainerStats `json:"stats,omitempty"`   // 运行中容器的资源使用
+	Running int             `json:"running,omitempty"` // compose 项目运行中的容器数
+	Total   int             `json:"total,omitempty"`   // compose 项目的容器总数
+}
+
+// 创建收藏表
+func initFavorites() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS favorites (
+		username TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (username, kind, name)
+	)`)
+	if err != nil {
+		return fmt.Errorf("创建收藏表失败: %v", err)
+	}
+	return nil
+}
+
+// 用户的收藏，按收藏时间排序
+func listFavorites(username string) ([]Favorite, error) {
+	rows, err := authDB.Query("SELECT kind, name, created_at FROM favorites WHERE username = ? ORDER BY created_at, rowid", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	favorites := []Favorite{}
+	for rows.Next() {
+		var f Favorite
+		if err := rows.Scan(&f.Kind, &f.Name, &f.CreatedAt); err != nil {
+			continue
+		}
+		favorites = append(favorites, f)
+	}
+	return favorites, rows.Err()
+}
+
+// 切换收藏，返回切换后是否已收藏
+func toggleFavorite(username, kind, name string) (bool, error) {
+	res, err := authDB.Exec("DELETE FROM favorites WHERE username = ? AND kind = ? AND name = ?", username, kind, name)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return false, nil
+	}
+	_, err = authDB.Exec("INSERT INTO favorites (username, kind, name, created_at) VALUES (?, ?, ?, ?)",
+		username, kind, name, time.Now().Unix())
+	return err == nil, err
+}
+
+// 容器改名时同步所有用户的收藏
+func renameContainerFavorites(oldName, newName string) error {
+	_, err := authDB.Exec("UPDATE OR REPLACE favorites SET name = ? WHERE kind = ? AND name = ?",
+		newName, favoriteKindContainer, oldName)
+	return err
+}
+
+// 容器是否被收藏：容器名或所属 compose 项目被收藏
+func isFavoriteContainer(favorites []Favorite, c ContainerInfo) bool {
+	project := c.Labels["com.docker.compose.project"]
+	for _, f := range favorites {
+		if (f.Kind == favoriteKindContainer && f.Name == c.Name) ||
+			(f.Kind == favoriteKindCompose && project != "" && f.Name == project) {
+			return true
+		}
+	}
+	return false
+}
+
+// 标记收藏并（favoritesFirst 时）把收藏的容器排在前面，其余顺序不变
+// containers 来自共享缓存，这里返回新的切片
+func applyFavorites(containers []ContainerInfo, favorites []Favorite, favoritesFirst bool) []ContainerInfo {
+	pinned := make([]ContainerInfo, 0, len(containers))
+	rest := make([]ContainerInfo, 0, len(containers))
+	for _, c := range containers {
+		c.Favorite = isFavoriteContainer(favorites, c)
+		if c.Favorite && favoritesFirst {
+			pinned = append(pinned, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	return append(pinned, rest...)
+}
+
+// 收藏对象的当前状态；运行中的容器并发采集一次资源统计
+func favoriteStates(ctx context.Context, username string) ([]FavoriteState, error) {
+	favorites, err := listFavorites(username)
+	if err != nil || len(favorites) == 0 {
+		return []FavoriteState{}, err
+	}
+	containers, err := listContainersCached(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]FavoriteState, len(favorites))
+	var wg sync.WaitGroup
+	for i, f := range favorites {
+		states[i].Favorite = f
+		s := &states[i]
+		if f.Kind == favoriteKindCompose {
+			if info, err := os.Stat(filepath.Join(composeBaseDir, f.Name)); err == nil && info.IsDir() {
+				s.Exists = true // 项目目录存在但未启动
+			}
+			for _, c := range containers {
+				if c.Labels["com.docker.compose.project"] != f.Name {
+					continue
+				}
+				s.Exists = true
+				s.Total++
+				if c.State == "running" {
+					s.Running++
+				}
+			}
+			continue
+		}
+
+		for _, c := range containers {
+			if c.Name != f.Name {
+				continue
+			}
+			s.Exists = true
+			s.ID, s.Image, s.State, s.Status = c.ID, c.Image, c.State, c.Status
+			if c.State == "running" {
+				wg.Add(1)
+				go func(id string) {
+					defer recoverGoroutine(ctx, "favorite stats")
+					defer wg.Done()
+					s.Stats = oneShotContainerStats(ctx, id)
+				}(c.ID)
+			}
+			break
+		}
+	}
+	wg.Wait()
+	return states, nil
+}
+
+// 采集一次容器资源统计，失败时返回 nil
+func oneShotContainerStats(ctx context.Context, id string) *ContainerStats {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := getDockerClient().ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil
+	}
+	result := calcContainerStats(&stats)
+	return &result
+}
+
+// 当前用户的收藏列表
+// GET /api/favorites
+func handleFavorites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	favorites, err := listFavorites(r.Header.Get("X-Username"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favorites)
+}
+
+// 收藏或取消收藏
+// POST /api/favorites/toggle {"kind": "container", "name": "redis"}
+func handleFavoriteToggle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	var req struct {
+		Kind string `json:"kind"` // 默认 container
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	req.Name = strings.TrimPrefix(strings.TrimSpace(req.Name), "/")
+	if req.Kind == "" {
+		req.Kind = favoriteKindContainer
+	}
+	if req.Kind != favoriteKindContainer && req.Kind != favoriteKindCompose {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("不支持的收藏类型: %s", req.Kind))
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "名称不能为空")
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+	favorite, err := toggleFavorite(username, req.Kind, req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("更新失败: %v", err))
+		return
+	}
+	log.Printf("[Favorites] %s toggled %s %s: %v", username, req.Kind, req.Name, favorite)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kind":     req.Kind,
+		"name":     req.Name,
+		"favorite": favorite,
+	})
+}
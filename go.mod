@@ -5,49 +5,58 @@ go 1.24.0
 toolchain go1.24.10
 
 require (
+	github.com/creack/pty v1.1.21
 	github.com/docker/docker v25.0.6+incompatible
 	github.com/docker/go-connections v0.4.0
+	github.com/docker/go-units v0.5.0
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/shirou/gopsutil/v4 v4.25.1
 	golang.org/x/crypto v0.44.0
 	modernc.org/sqlite v1.40.1
 )
 
 require (
-github.com/Microsoft/go-winio v0.6.1 // indirect
-github.com/cespare/xxhash/v2 v2.3.0 // indirect
-github.com/containerd/log v0.1.0 // indirect
-github.com/distribution/reference v0.6.0 // indirect
-github.com/docker/go-units v0.5.0 // indirect
-github.com/dustin/go-humanize v1.0.1 // indirect
-github.com/felixge/httpsnoop v1.0.4 // indirect
-github.com/go-logr/logr v1.4.3 // indirect
-github.com/go-logr/stdr v1.2.2 // indirect
-github.com/gogo/protobuf v1.3.2 // indirect
-github.com/google/uuid v1.6.0 // indirect
-github.com/mattn/go-isatty v0.0.20 // indirect
-github.com/moby/term v0.5.0 // indirect
-github.com/morikuni/aec v1.0.0 // indirect
-github.com/ncruces/go-strftime v0.1.9 // indirect
-github.com/opencontainers/go-digest v1.0.0 // indirect
-github.com/opencontainers/image-spec v1.0.2 // indirect
-github.com/pkg/errors v0.9.1 // indirect
-github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
-go.opentelemetry.io/auto/sdk v1.2.1 // indirect
-go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.64.0 // indirect
-go.opentelemetry.io/otel v1.39.0 // indirect
-go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.39.0 // indirect
-go.opentelemetry.io/otel/metric v1.39.0 // indirect
-go.opentelemetry.io/otel/trace v1.39.0 // indirect
-golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
-golang.org/x/mod v0.27.0 // indirect
-golang.org/x/net v0.47.0 // indirect
-golang.org/x/sync v0.16.0 // indirect
-golang.org/x/sys v0.39.0 // indirect
-golang.org/x/time v0.3.0 // indirect
-golang.org/x/tools v0.36.0 // indirect
-gotest.tools/v3 v3.5.1 // indirect
-modernc.org/libc v1.66.10 // indirect
-modernc.org/mathutil v1.7.1 // indirect
-modernc.org/memory v1.11.0 // indirect
-)
\ No newline at end of file
+	github.com/Azure/go-ansiterm v0.0.0-20210617225240-d185dfc1b5a1 // indirect
+	github.com/Microsoft/go-winio v0.6.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/containerd/log v0.1.0 // indirect
+	github.com/distribution/reference v0.6.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/ebitengine/purego v0.8.2 // indirect
+	github.com/felixge/httpsnoop v1.0.4 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/go-ole/go-ole v1.2.6 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/moby/term v0.5.0 // indirect
+	github.com/morikuni/aec v1.0.0 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/opencontainers/image-spec v1.0.2 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/tklauser/go-sysconf v0.3.12 // indirect
+	github.com/tklauser/numcpus v0.6.1 // indirect
+	github.com/yusufpapurcu/wmi v1.2.4 // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.64.0 // indirect
+	go.opentelemetry.io/otel v1.39.0 // indirect
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.39.0 // indirect
+	go.opentelemetry.io/otel/metric v1.39.0 // indirect
+	go.opentelemetry.io/otel/trace v1.39.0 // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/mod v0.29.0 // indirect
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sync v0.18.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
+	golang.org/x/tools v0.38.0 // indirect
+	gotest.tools/v3 v3.5.1 // indirect
+	modernc.org/libc v1.66.10 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
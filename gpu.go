@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// GPU 读数缓存时间
+const gpuCacheTTL = 2 * time.Second
+
+// GPU 上的进程
+type GPUProcess struct {
+	PID           int    `json:"pid"`
+	Name          string `json:"name"`
+	MemoryUsed    uint64 `json:"memory_used"` // MiB
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+}
+
+// 单个 GPU 的状态（nvidia-smi 返回 N/A 的字段为 null）
+type GPUInfo struct {
+	Index       int          `json:"index"`
+	UUID        string       `json:"uuid"`
+	Name        string       `json:"name"`
+	Utilization *float64     `json:"utilization"`  // 百分比
+	MemoryUsed  *float64     `json:"memory_used"`  // MiB
+	MemoryTotal *float64     `json:"memory_total"` // MiB
+	Temperature *float64     `json:"temperature"`  // 摄氏度
+	Processes   []GPUProcess `json:"processes"`
+}
+
+// GPU 状态响应
+type GPUStatus struct {
+	Available bool      `json:"available"`
+	Reason    string    `json:"reason,omitempty"`
+	Driver    string    `json:"driver,omitempty"`
+	GPUs      []GPUInfo `json:"gpus"`
+}
+
+var gpuCache struct {
+	sync.Mutex
+	data      *GPUStatus
+	lastFetch time.Time
+}
+
+// 获取 GPU 状态（带缓存）
+func getGPUStatus(ctx context.Context) *GPUStatus {
+	gpuCache.Lock()
+	defer gpuCache.Unlock()
+
+	if gpuCache.data != nil && time.Since(gpuCache.lastFetch) < gpuCacheTTL {
+		return gpuCache.data
+	}
+
+	gpuCache.data = readGPUStatus(ctx)
+	gpuCache.lastFetch = time.Now()
+	return gpuCache.data
+}
+
+// 调用 nvidia-smi 读取 GPU 状态（不可用时返回原因而不是错误）
+func readGPUStatus(ctx context.Context) *GPUStatus {
+	smi, err := exec.LookPath("nvidia-smi")
+	if err != nil {
+		return &GPUStatus{
+			Available: false,
+			Reason:    "未检测到 nvidia-smi（没有 NVIDIA GPU 或未安装驱动）",
+			GPUs:      []GPUInfo{},
+		}
+	}
+
+	rows, err := runNvidiaSMI(ctx, smi,
+		"--query-gpu=index,uuid,name,utilization.gpu,memory.used,memory.total,temperature.gpu,driver_version")
+	if err != nil {
+		// 驱动未加载时 nvidia-smi 存在但执行失败
+		return &GPUStatus{
+			Available: false,
+			Reason:    fmt.Sprintf("nvidia-smi 执行失败: %v", err),
+			GPUs:      []GPUInfo{},
+		}
+	}
+
+	status := &GPUStatus{Available: true, GPUs: make([]GPUInfo, 0, len(rows))}
+	byUUID := make(map[string]int)
+	for _, row := range rows {
+		if len(row) < 8 {
+			continue
+		}
+		index, _ := strconv.Atoi(row[0])
+		byUUID[row[1]] = len(status.GPUs)
+		status.Driver = row[7]
+		status.GPUs = append(status.GPUs, GPUInfo{
+			Index:       index,
+			UUID:        row[1],
+			Name:        row[2],
+			Utilization: parseSMIValue(row[3]),
+			MemoryUsed:  parseSMIValue(row[4]),
+			MemoryTotal: parseSMIValue(row[5]),
+			Temperature: parseSMIValue(row[6]),
+			Processes:   []GPUProcess{},
+		})
+	}
+	if len(status.GPUs) == 0 {
+		status.Available = false
+		status.Reason = "nvidia-smi 未报告任何 GPU"
+		return status
+	}
+
+	// 进程列表失败不影响 GPU 读数
+	procRows, err := runNvidiaSMI(ctx, smi, "--query-compute-apps=gpu_uuid,pid,process_name,used_memory")
+	if err != nil {
+		return status
+	}
+
+	names := containerNamesByID(ctx)
+	for _, row := range procRows {
+		if len(row) < 4 {
+			continue
+		}
+		i, ok := byUUID[row[0]]
+		if !ok {
+			continue
+		}
+		pid, err := strconv.Atoi(row[1])
+		if err != nil {
+			continue
+		}
+		proc := GPUProcess{PID: pid, Name: row[2]}
+		if mem := parseSMIValue(row[3]); mem != nil {
+			proc.MemoryUsed = uint64(*mem)
+		}
+		if id := containerIDFromPID(pid); id != "" {
+			proc.ContainerID = id[:12]
+			proc.ContainerName = names[id]
+		}
+		status.GPUs[i].Processes = append(status.GPUs[i].Processes, proc)
+	}
+	return status
+}
+
+// 执行 nvidia-smi 查询并解析 CSV 输出
+func runNvidiaSMI(ctx context.Context, smi, query string) ([][]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, smi, query, "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(output)))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// 解析 nvidia-smi 数值（"[N/A]"、"[Not Supported]" 返回 nil）
+func parseSMIValue(s string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// 匹配 cgroup 路径中的容器 ID（docker-<id>.scope 或 /docker/<id>）
+var cgroupContainerIDPattern = regexp.MustCompile(`(?:docker[-/])([0-9a-f]{64})`)
+
+// 通过 /proc/<pid>/cgroup 找到进程所属的容器 ID
+func containerIDFromPID(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+	if m := cgroupContainerIDPattern.FindStringSubmatch(string(data)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// 获取容器 ID 到名称的映射
+func containerNamesByID(ctx context.Context) map[string]string {
+	names := make(map[string]string)
+	cli := getDockerClient()
+	if cli == nil {
+		return names
+	}
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return names
+	}
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names[c.ID] = strings.TrimPrefix(c.Names[0], "/")
+		}
+	}
+	return names
+}
+
+// GPU 状态 API
+func handleSystemGPU(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	status := getGPUStatus(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
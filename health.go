@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// 健康状态
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded" // 部分功能不可用（如 compose、磁盘空间不足、Worker 离线）
+	healthDown     = "down"     // 核心依赖（Docker、数据库）不可用
+)
+
+// 单项检查的超时时间
+const healthCheckTimeout = 5 * time.Second
+
+// DATA_DIR 所在磁盘可用空间低于该值（或低于 5%）时视为 degraded
+const healthMinFreeDisk = 1 << 30
+
+// 单项检查结果
+type HealthComponent struct {
+	Status    string                 `json:"status"`
+	Message   string                 `json:"message,omitempty"`
+	LatencyMs int64                  `json:"latency_ms,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// 详细健康状态
+type HealthReport struct {
+	Status     string                      `json:"status"`
+	Mode       string                      `json:"mode,omitempty"`
+	Time       string                      `json:"time"`
+	Components map[string]*HealthComponent `json:"components"`
+}
+
+// 执行一项检查并记录耗时
+func runHealthCheck(check func(ctx context.Context) *HealthComponent) *HealthComponent {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	result := check(ctx)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// Docker 守护进程和协商的 API 版本
+func checkDockerHealth(ctx context.Context) *HealthComponent {
+	cli := getDockerClient()
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		result := &HealthComponent{Status: healthDown, Message: err.Error()}
+		if status := currentDockerStatus(); status.DownSince != "" {
+			result.Details = map[string]interface{}{"down_since": status.DownSince}
+		}
+		return result
+	}
+	result := &HealthComponent{Status: healthOK, Details: map[string]interface{}{
+		"api_version":        ping.APIVersion,
+		"client_api_version": cli.ClientVersion(),
+		"os_type":            ping.OSType,
+	}}
+	if compat := dockerCompat.Load(); compat != nil {
+		result.Details["client_max_api_version"] = compat.ClientMaxAPIVersion
+		result.Details["daemon_version"] = compat.DaemonVersion
+		result.Details["daemon_min_api_version"] = compat.DaemonMinAPIVersion
+		if !compat.Compatible {
+			result.Status = healthDown
+			result.Message = compat.Message
+			result.Details["hint"] = compat.Hint
+		}
+	}
+	return result
+}
+
+// 认证数据库
+func checkDatabaseHealth(ctx context.Context) *HealthComponent {
+	if authDB == nil {
+		return &HealthComponent{Status: healthDown, Message: "数据库未初始化"}
+	}
+	var n int
+	if err := authDB.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil {
+		return &HealthComponent{Status: healthDown, Message: err.Error()}
+	}
+	return &HealthComponent{Status: healthOK}
+}
+
+// docker compose 命令（使用启动时的检测结果，不在每次请求时执行命令）
+func checkComposeHealth(ctx context.Context) *HealthComponent {
+	compose := currentCapabilities().Compose
+	if !compose.Available {
+		return &HealthComponent{Status: healthDegraded, Message: "docker compose 不可用: " + compose.Error}
+	}
+	return &HealthComponent{Status: healthOK, Details: map[string]interface{}{
+		"version": compose.Version,
+	}}
+}
+
+// DATA_DIR 所在磁盘的可用空间
+func checkDiskHealth(ctx context.Context) *HealthComponent {
+	usage, err := disk.UsageWithContext(ctx, dataDir)
+	if err != nil {
+		return &HealthComponent{Status: healthDegraded, Message: err.Error()}
+	}
+	result := &HealthComponent{Status: healthOK, Details: map[string]interface{}{
+		"free":         usage.Free,
+		"total":        usage.Total,
+		"used_percent": usage.UsedPercent,
+	}}
+	if usage.Free < healthMinFreeDisk || usage.UsedPercent > 95 {
+		result.Status = healthDegraded
+		result.Message = "数据目录磁盘空间不足"
+	}
+	return result
+}
+
+// Worker 节点在线情况（仅 Master）
+func checkWorkersHealth(ctx context.Context) *HealthComponent {
+	online, offline := 0, 0
+	for _, node := range nodeManager.GetAllNodes() {
+		if node.Status == NodeStatusOnline {
+			online++
+		} else {
+			offline++
+		}
+	}
+	result := &HealthComponent{Status: healthOK, Details: map[string]interface{}{
+		"online":  online,
+		"offline": offline,
+	}}
+	if offline > 0 {
+		result.Status = healthDegraded
+		result.Message = "存在离线的 Worker 节点"
+	}
+	return result
+}
+
+// 健康报告的缓存时间：频繁请求时复用上一次的结果
+const healthReportTTL = 5 * time.Second
+
+var healthCache struct {
+	mu      sync.Mutex
+	report  *HealthReport
+	expires time.Time
+}
+
+// 获取健康报告（缓存未过期时直接返回，同一时间只执行一轮检查）
+func currentHealthReport() *HealthReport {
+	healthCache.mu.Lock()
+	defer healthCache.mu.Unlock()
+	if healthCache.report != nil && time.Now().Before(healthCache.expires) {
+		return healthCache.report
+	}
+	healthCache.report = buildHealthReport()
+	healthCache.expires = time.Now().Add(healthReportTTL)
+	return healthCache.report
+}
+
+// 执行所有检查
+func buildHealthReport() *HealthReport {
+	checks := map[string]func(ctx context.Context) *HealthComponent{
+		"docker":   checkDockerHealth,
+		"database": checkDatabaseHealth,
+		"compose":  checkComposeHealth,
+		"disk":     checkDiskHealth,
+	}
+	if appConfig.Mode == ModeMaster && nodeManager != nil {
+		checks["workers"] = checkWorkersHealth
+	}
+
+	report := &HealthReport{
+		Status:     healthOK,
+		Mode:       appConfig.Mode,
+		Time:       time.Now().Format(time.RFC3339),
+		Components: make(map[string]*HealthComponent, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) *HealthComponent) {
+			defer recoverGoroutine(context.Background(), "health check "+name)
+			defer wg.Done()
+			result := runHealthCheck(check)
+			mu.Lock()
+			report.Components[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, c := range report.Components {
+		if c.Status == healthDown {
+			report.Status = healthDown
+			break
+		}
+		if c.Status == healthDegraded {
+			report.Status = healthDegraded
+		}
+	}
+	return report
+}
+
+// 输出健康报告，整体为 down 时返回 503
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	status := http.StatusOK
+	if report.Status == healthDown {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// 详细健康检查（需要认证）：各依赖的状态、版本、错误信息等，整体为 ok（200）、degraded（200）或 down（503）
+// /api/health 保持不变，供负载均衡器使用
+func handleHealthDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	writeHealthReport(w, currentHealthReport())
+}
+
+// 各依赖的健康状态（无需认证，供监控使用）：只包含状态，不包含版本、错误信息等细节
+func handleHealthStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	full := currentHealthReport()
+	report := &HealthReport{
+		Status:     full.Status,
+		Time:       full.Time,
+		Components: make(map[string]*HealthComponent, len(full.Components)),
+	}
+	for name, c := range full.Components {
+		report.Components[name] = &HealthComponent{Status: c.Status}
+	}
+	writeHealthReport(w, report)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 主机文件浏览允许访问的根目录（host_files_roots / HOST_FILES_ROOTS，逗号分隔，默认 /opt,/srv,/mnt）
+var hostFileRoots = []string{"/opt", "/srv", "/mnt"}
+
+// 主机文件下载大小上限（只用于查看小型配置文件）
+const hostFileMaxDownload = 10 << 20
+
+// 解析符号链接后检查路径是否位于允许的根目录内，返回解析后的路径
+func resolveHostPath(p string) (string, error) {
+	if p == "" || !filepath.IsAbs(p) {
+		return "", fmt.Errorf("路径必须为绝对路径: %s", p)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(p))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", os.ErrNotExist
+		}
+		return "", err
+	}
+	for _, root := range hostFileRoots {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if pathWithinRoot(resolved, realRoot) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("路径不在允许访问的目录内: %s", p)
+}
+
+// p 是否为 root 本身或位于其下（两者都已清理）。根目录 / 本身以分隔符结尾，不能再追加分隔符
+func pathWithinRoot(p, root string) bool {
+	if p == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(p, root)
+}
+
+// 根据解析错误返回对应的 HTTP 响应
+func writeHostPathError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "路径不存在")
+		return
+	}
+	writeError(w, http.StatusForbidden, ErrCodeForbidden, err.Error())
+}
+
+// 列出主机目录（未指定 path 时返回允许访问的根目录）
+func handleHostFilesList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	reqPath := r.URL.Query().Get("path")
+	if reqPath == "" {
+		files := []FileInfo{}
+		for _, root := range hostFileRoots {
+			info, err := os.Stat(root)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			files = append(files, hostFileInfo(root, info))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":  "",
+			"files": files,
+			"roots": hostFileRoots,
+		})
+		return
+	}
+
+	dir, err := resolveHostPath(reqPath)
+	if err != nil {
+		writeHostPathError(w, err)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取目录失败: %v", err))
+		return
+	}
+
+	// 返回的路径保持用户请求的形式，便于填写到挂载配置中
+	base := filepath.Clean(reqPath)
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := hostFileInfo(filepath.Join(base, entry.Name()), info)
+		if info.Mode()&os.ModeSymlink != 0 {
+			file.LinkTarget, _ = os.Readlink(filepath.Join(dir, entry.Name()))
+			// 指向目录的符号链接按目录显示，进入时会再次检查是否越界
+			if target, err := os.Stat(filepath.Join(dir, entry.Name())); err == nil {
+				file.IsDir = target.IsDir()
+			}
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].IsDir != files[j].IsDir {
+			return files[i].IsDir
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":  base,
+		"files": files,
+		"roots": hostFileRoots,
+	})
+}
+
+// 转换为文件信息
+func hostFileInfo(p string, info os.FileInfo) FileInfo {
+	mode := info.Mode().String()
+	if info.Mode()&os.ModeSymlink != 0 {
+		mode = "l" + mode[1:] // 与容器文件列表保持一致
+	}
+	return FileInfo{
+		Name:    filepath.Base(p),
+		Path:    p,
+		Size:    info.Size(),
+		Mode:    mode,
+		ModTime: info.ModTime().Local().Format("2006-01-02 15:04"),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// 在主机上创建目录
+func handleHostFileMkdir(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	if req.Path == "" || !filepath.IsAbs(req.Path) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "路径必须为绝对路径")
+		return
+	}
+
+	// 只允许在已存在的目录下创建一级子目录，父目录需位于允许的根目录内
+	target := filepath.Clean(req.Path)
+	name := filepath.Base(target)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "目录名无效")
+		return
+	}
+	parent, err := resolveHostPath(filepath.Dir(target))
+	if err != nil {
+		writeHostPathError(w, err)
+		return
+	}
+
+	if err := os.Mkdir(filepath.Join(parent, name), 0755); err != nil {
+		if os.IsExist(err) {
+			writeError(w, http.StatusConflict, ErrCodeConflict, "目录已存在")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建失败: %v", err))
+		return
+	}
+
+	writeAuditLog(r.Header.Get("X-Username"), "host_mkdir", target, "", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 下载主机上的小文件（只读）
+func handleHostFileDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	filePath, err := resolveHostPath(r.URL.Query().Get("path"))
+	if err != nil {
+		writeHostPathError(w, err)
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("打开文件失败: %v", err))
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("读取文件失败: %v", err))
+		return
+	}
+	if !info.Mode().IsRegular() {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不是普通文件")
+		return
+	}
+	if info.Size() > hostFileMaxDownload {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("文件超过下载上限 (%d MB)", hostFileMaxDownload>>20))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
+	io.Copy(w, file)
+}
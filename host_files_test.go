@@ -0,0 +1,81 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathWithinRoot(t *testing.T) {
+	cases := []struct {
+		path, root string
+		want       bool
+	}{
+		{"/opt", "/opt", true},
+		{"/opt/app/config.yml", "/opt", true},
+		{"/optional", "/opt", false},
+		{"/srv", "/opt", false},
+		{"/", "/", true},
+		{"/etc/hosts", "/", true},
+		{"/opt/app", "/", true},
+	}
+	for _, c := range cases {
+		if got := pathWithinRoot(c.path, c.root); got != c.want {
+			t.Errorf("pathWithinRoot(%q, %q) = %v, want %v", c.path, c.root, got, c.want)
+		}
+	}
+}
+
+func TestResolveHostPath(t *testing.T) {
+	defer func(roots []string) { hostFileRoots = roots }(hostFileRoots)
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "rootless") // 与根目录前缀相同的兄弟目录
+	for _, dir := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		roots []string
+		path  string
+		want  string // 为空时应被拒绝
+	}{
+		{[]string{root}, root, root},
+		{[]string{root}, filepath.Join(root, "sub"), filepath.Join(root, "sub")},
+		{[]string{root}, filepath.Join(root, "sub", "..", "sub"), filepath.Join(root, "sub")},
+		{[]string{root}, filepath.Join(root, "..", "rootless", "secret"), ""},
+		{[]string{root}, filepath.Join(root, "escape", "secret"), ""},
+		{[]string{root}, outside, ""},
+		{[]string{"/"}, filepath.Join(outside, "secret"), filepath.Join(outside, "secret")},
+		{[]string{"/"}, filepath.Join(root, "escape"), outside},
+		{[]string{"/"}, "/", "/"},
+		{[]string{"/"}, "relative/path", ""},
+	}
+	for _, c := range cases {
+		hostFileRoots = c.roots
+		got, err := resolveHostPath(c.path)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("roots %v: resolveHostPath(%q) = %q, want error", c.roots, c.path, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("roots %v: resolveHostPath(%q) = %q, %v; want %q", c.roots, c.path, got, err, c.want)
+		}
+	}
+}
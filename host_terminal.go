@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/gorilla/websocket"
+)
+
+// 是否启用主机终端（enable_host_terminal / ENABLE_HOST_TERMINAL=true，默认关闭）
+var hostTerminalEnabled bool
+
+// 获取主机终端使用的 shell（优先 $SHELL）
+func hostShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		if _, err := os.Stat(shell); err == nil {
+			return shell
+		}
+	}
+	for _, shell := range []string{"/bin/bash", "/bin/sh"} {
+		if _, err := os.Stat(shell); err == nil {
+			return shell
+		}
+	}
+	return "sh"
+}
+
+// 主机终端使用单独的 upgrader：Origin 必须与 Host 一致，拒绝其他站点页面发起的连接
+// （共享的 wsUpgrader 接受任意 Origin，对主机 root shell 来说只靠 SameSite Cookie 不够）
+var hostTerminalUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkHostTerminalOrigin,
+}
+
+// 校验 Origin 与 Host 一致，不一致或缺少 Origin 时记录日志和审计
+func checkHostTerminalOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin != "" {
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+	}
+	username := r.Header.Get("X-Username")
+	log.Printf("[HostTerminal] Rejected WebSocket: origin %q does not match host %q (user %s, remote %s)", origin, r.Host, username, r.RemoteAddr)
+	writeAuditLog(username, "host_terminal_rejected", r.Host, "origin: "+origin, r.RemoteAddr)
+	return false
+}
+
+// 主机终端 WebSocket（协议与容器终端相同：二进制/文本为输入，{"type":"resize"} 调整大小）
+func handleHostTerminalWS(w http.ResponseWriter, r *http.Request) {
+	if !hostTerminalEnabled {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "主机终端未启用（设置 ENABLE_HOST_TERMINAL=true 开启）")
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+
+	conn, err := hostTerminalUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[HostTerminal] WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	stopKeepalive := startWSKeepalive(conn)
+	defer stopKeepalive()
+
+	shell := hostShell()
+	cmd := exec.Command(shell, "-l")
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	if home, err := os.UserHomeDir(); err == nil {
+		cmd.Dir = home
+	}
+
+	session, err := registerTerminal("host", username, "", shell, r.RemoteAddr)
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[31mError: "+err.Error()+"\x1b[0m\r\n"))
+		return
+	}
+	defer session.unregister()
+
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		log.Printf("[HostTerminal] Start pty failed: %v", err)
+		conn.WriteMessage(websocket.TextMessage, []byte("\r\n\x1b[31mError: "+err.Error()+"\x1b[0m\r\n"))
+		return
+	}
+
+	startedAt := time.Now()
+	writeAuditLog(username, "host_terminal_start", shell, fmt.Sprintf("pid=%d", cmd.Process.Pid), r.RemoteAddr)
+
+	// 连接关闭时结束 shell 进程
+	defer func() {
+		ptmx.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		writeAuditLog(username, "host_terminal_end", shell,
+			fmt.Sprintf("pid=%d duration=%s", cmd.Process.Pid, time.Since(startedAt).Round(time.Second)), r.RemoteAddr)
+	}()
+
+	done := make(chan struct{})
+
+	// 从 PTY 读取输出，发送到 WebSocket
+	go func() {
+		defer recoverGoroutine(r.Context(), "host terminal output")
+		defer close(done)
+		buf := make([]byte, 4096)
+		for {
+			n, err := ptmx.Read(buf)
+			if n > 0 {
+				if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+					log.Printf("[HostTerminal] WebSocket write error: %v", err)
+					return
+				}
+			}
+			if err != nil {
+				// shell 退出后读取返回 EOF/EIO，属于正常结束
+				return
+			}
+		}
+	}()
+
+	// 从 WebSocket 读取输入，发送到 PTY
+	go func() {
+		defer recoverGoroutine(r.Context(), "host terminal input")
+		// WebSocket 断开时关闭 PTY，使读取协程退出
+		defer ptmx.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("[HostTerminal] WebSocket read error: %v", err)
+				}
+				return
+			}
+			session.touch()
+
+			// 处理终端大小调整消息
+			if messageType == websocket.TextMessage && len(message) > 0 && message[0] == '{' {
+				var resizeMsg struct {
+					Type string `json:"type"`
+					Cols int    `json:"cols"`
+					Rows int    `json:"rows"`
+				}
+				if err := json.Unmarshal(message, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
+					pty.Setsize(ptmx, &pty.Winsize{
+						Rows: uint16(resizeMsg.Rows),
+						Cols: uint16(resizeMsg.Cols),
+					})
+					continue
+				}
+			}
+
+			if _, err := ptmx.Write(message); err != nil {
+				log.Printf("[HostTerminal] Write to pty error: %v", err)
+				return
+			}
+		}
+	}()
+
+	// 等待 shell 退出，或会话被管理员/空闲检查关闭
+	select {
+	case <-done:
+	case <-session.closed:
+		log.Printf("[HostTerminal] Session %s closed: %s", session.ID, session.reason)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, session.reason), time.Now().Add(time.Second))
+	}
+}
@@ -0,0 +1,720 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// API 响应消息的多语言支持
+// 代码中的消息以中文为源语言，响应前按请求语言翻译；日志保持原样不翻译
+// 语言优先级：用户偏好（users.language）> Accept-Language > 默认中文
+const (
+	langZH      = "zh-CN"
+	langEN      = "en"
+	defaultLang = langZH
+)
+
+// 错误码的默认消息（消息为空或没有对应翻译时使用）
+var errorCodeMessages = map[string]map[string]string{
+	langZH: {
+		ErrCodeBadRequest:           "请求参数错误",
+		ErrCodeUnauthorized:         "未授权",
+		ErrCodeForbidden:            "禁止访问",
+		ErrCodeNotFound:             "资源不存在",
+		ErrCodeMethodNotAllowed:     "方法不允许",
+		ErrCodeConflict:             "资源冲突",
+		ErrCodePayloadTooLarge:      "请求内容过大",
+		ErrCodeRangeNotSatisfied:    "请求范围无效",
+		ErrCodeUnprocessable:        "无法处理的请求",
+		ErrCodeTooManyRequests:      "请求过多",
+		ErrCodeInternal:             "服务器内部错误",
+		ErrCodeNotImplemented:       "功能未实现",
+		ErrCodeBadGateway:           "上游服务错误",
+		ErrCodeServiceUnavailable:   "服务不可用",
+		ErrCodeGatewayTimeout:       "上游服务超时",
+		ErrCodeInsufficientSpace:    "存储空间不足",
+		ErrCodeNeedChangePassword:   "需要修改密码",
+		ErrCodeDockerUnavailable:    "Docker 守护进程未连接",
+		ErrCodeDockerIncompatible:   "Docker API 版本不兼容",
+		ErrCodeImageInUse:           "镜像正在被容器使用",
+		ErrCodeImageHasDependents:   "镜像有子镜像依赖",
+		ErrCodeImageReferenced:      "镜像被其他镜像引用",
+		ErrCodeUploadChunksMissing:  "分片未全部上传",
+		ErrCodeEndpointUnreachable:  "无法连接到 Docker 地址",
+		ErrCodeWorkerRequestFailure: "调用 Worker 节点失败",
+		ErrCodeCLIMissing:           "缺少 docker 命令行",
+	},
+	langEN: {
+		ErrCodeBadRequest:           "Bad request",
+		ErrCodeUnauthorized:         "Unauthorized",
+		ErrCodeForbidden:            "Forbidden",
+		ErrCodeNotFound:             "Not found",
+		ErrCodeMethodNotAllowed:     "Method not allowed",
+		ErrCodeConflict:             "Conflict",
+		ErrCodePayloadTooLarge:      "Payload too large",
+		ErrCodeRangeNotSatisfied:    "Range not satisfiable",
+		ErrCodeUnprocessable:        "Unprocessable request",
+		ErrCodeTooManyRequests:      "Too many requests",
+		ErrCodeInternal:             "Internal server error",
+		ErrCodeNotImplemented:       "Not implemented",
+		ErrCodeBadGateway:           "Bad gateway",
+		ErrCodeServiceUnavailable:   "Service unavailable",
+		ErrCodeGatewayTimeout:       "Gateway timeout",
+		ErrCodeInsufficientSpace:    "Insufficient storage",
+		ErrCodeNeedChangePassword:   "Password change required",
+		ErrCodeDockerUnavailable:    "Docker daemon is not connected",
+		ErrCodeDockerIncompatible:   "Docker API version is incompatible",
+		ErrCodeImageInUse:           "Image is in use by a container",
+		ErrCodeImageHasDependents:   "Image has dependent child images",
+		ErrCodeImageReferenced:      "Image is referenced by other images",
+		ErrCodeUploadChunksMissing:  "Some chunks have not been uploaded",
+		ErrCodeEndpointUnreachable:  "Docker endpoint is unreachable",
+		ErrCodeWorkerRequestFailure: "Worker node request failed",
+		ErrCodeCLIMissing:           "Docker CLI is not available",
+	},
+}
+
+// 消息翻译表：中文源消息（可以是带 %v/%s/%d 的格式串）-> 译文
+// 格式串中的参数按顺序填入译文，参数本身也会再翻译一次（如转发的 Worker 错误）
+var messageCatalogs = map[string]map[string]string{
+	langEN: {
+		// 通用
+		"服务器内部错误":               "Internal server error",
+		"内部错误: %v":              "Internal error: %v",
+		"方法不允许":                 "Method not allowed",
+		"请求参数错误":                "Invalid request parameters",
+		"请求参数错误: %v":            "Invalid request parameters: %v",
+		"参数错误":                  "Invalid parameters",
+		"参数不完整":                 "Missing required parameters",
+		"不支持的操作":                "Unsupported operation",
+		"接口不存在":                 "API endpoint not found",
+		"SSE 不支持":               "Server-sent events are not supported",
+		"操作繁忙，请稍后重试: %v":        "Server busy, please retry later: %v",
+		"并发数已达上限 %d":            "concurrency limit of %d reached",
+		"操作不允许: %s":             "Operation not permitted: %s",
+		"操作失败: %v":              "Operation failed: %v",
+		"执行失败: %v":              "Execution failed: %v",
+		"查询失败: %v":              "Query failed: %v",
+		"更新失败: %v":              "Update failed: %v",
+		"创建失败: %v":              "Create failed: %v",
+		"删除失败: %v":              "Delete failed: %v",
+		"读取失败: %v":              "Read failed: %v",
+		"写入失败: %v":              "Write failed: %v",
+		"连接失败: %v":              "Connection failed: %v",
+		"创建请求失败: %v":            "Failed to create request: %v",
+		"调试接口未启用":               "Debug endpoints are not enabled",
+		"历史指标记录未启用":             "Metrics history is not enabled",
+		"查询指标失败: %v":            "Failed to query metrics: %v",
+		"查询事件失败: %v":            "Failed to query events: %v",
+		"range 参数无效（最大 30d）":    "Invalid range parameter (max 30d)",
+		"since 参数无效":            "Invalid since parameter",
+		"step 参数无效":             "Invalid step parameter",
+		"limit 参数无效":            "Invalid limit parameter",
+		"offset 参数无效":           "Invalid offset parameter",
+		"length 参数无效":           "Invalid length parameter",
+		"lines 参数无效":            "Invalid lines parameter",
+		"index 参数无效":            "Invalid index parameter",
+		"size 参数无效":             "Invalid size parameter",
+		"timeout_seconds 参数无效":  "Invalid timeout_seconds parameter",
+		"by 参数只支持 memory 或 cpu": "The by parameter must be memory or cpu",
+
+		// 认证
+		"未授权":          "Unauthorized",
+		"未授权，请先登录":     "Unauthorized, please log in",
+		"token 无效":     "Invalid token",
+		"token 无效或已过期": "Token is invalid or expired",
+		"token 已过期":    "Token has expired",
+		"需要修改密码":       "Password change required",
+		"旧密码错误":        "Old password is incorrect",
+		"更新密码失败":       "Failed to update password",
+		"查询用户失败":       "Failed to query user",
+		"查询用户失败: %v":   "Failed to query user: %v",
+		"生成 token 失败":  "Failed to generate token",
+		"生成密码哈希失败":     "Failed to hash password",
+		"用户名或密码错误":     "Invalid username or password",
+		"密码长度至少8位":     "Password must be at least 8 characters",
+		"密码必须包含: %s":   "Password must contain: %s",
+		"大写字母":         "uppercase letters",
+		"小写字母":         "lowercase letters",
+		"数字":           "digits",
+		"特殊字符":         "special characters",
+		"密码修改成功":       "Password changed",
+		"登出成功":         "Logged out",
+		"不支持的语言: %s":   "Unsupported language: %s",
+		"保存语言偏好失败":     "Failed to save language preference",
+
+		// Docker 连接
+		"Docker 连接失败":                             "Failed to connect to Docker",
+		"Docker 守护进程未连接，正在自动重连":                   "Docker daemon is not connected, reconnecting automatically",
+		"Docker API 版本不兼容: %v":                    "Docker API version is incompatible: %v",
+		"获取 Docker 信息失败: %v":                      "Failed to get Docker info: %v",
+		"无法使用新的 Docker 地址，继续使用原来的连接: %v":          "Cannot use the new Docker endpoint, keeping the current connection: %v",
+		"使用环境变量中的 Docker 地址时不能配置 TLS":             "TLS cannot be configured when using the Docker address from the environment",
+		"Docker 地址无效: %v":                         "Invalid Docker address: %v",
+		"%s 地址不支持 TLS":                            "%s addresses do not support TLS",
+		"unix socket 路径必须是绝对路径: %s":               "Unix socket path must be absolute: %s",
+		"Docker 地址缺少主机: %s":                       "Docker address is missing a host: %s",
+		"不支持的 Docker 地址协议: %s（支持 unix、tcp、npipe）": "Unsupported Docker address scheme: %s (supported: unix, tcp, npipe)",
+		"客户端证书和私钥必须同时配置":                          "Client certificate and key must be configured together",
+		"证书路径必须是绝对路径: %s":                         "Certificate path must be absolute: %s",
+		"配置证书时需要启用 TLS":                           "TLS must be enabled when certificates are configured",
+		"保存 Docker 连接设置失败: %v":                    "Failed to save Docker endpoint settings: %v",
+
+		// docker 命令行
+		"%s不可用: %s":       "%s unavailable: %s",
+		"docker run 命令模式": "docker run command mode",
+		"Compose 操作":      "Compose operations",
+		"docker 命令不可用":    "docker command is unavailable",
+		dockerCLIHint:     "The docker command is not available where the panel runs. When running in a container, mount the host's /usr/bin/docker into it or use an image that includes the docker CLI",
+		composeCLIHint:    "The docker command has no compose subcommand. Install the Docker Compose V2 plugin (e.g. the docker-compose-plugin package) and mount it into /usr/libexec/docker/cli-plugins or ~/.docker/cli-plugins",
+
+		// 容器
+		"容器ID不能为空":                          "Container ID is required",
+		"容器 ID 不能为空":                        "Container ID is required",
+		"获取容器信息失败: %v":                      "Failed to get container info: %v",
+		"获取容器列表失败: %v":                      "Failed to list containers: %v",
+		"获取日志失败: %v":                        "Failed to get logs: %v",
+		"获取统计信息失败: %v":                      "Failed to get stats: %v",
+		"解析统计信息失败: %v":                      "Failed to parse stats: %v",
+		"获取磁盘信息失败: %v":                      "Failed to get disk info: %v",
+		"创建容器失败: %v":                        "Failed to create container: %v",
+		"启动容器失败: %v":                        "Failed to start container: %v",
+		"停止容器失败: %v":                        "Failed to stop container: %v",
+		"删除容器失败: %v":                        "Failed to remove container: %v",
+		"只支持 docker run 命令":                 "Only docker run commands are supported",
+		"无效的端口: %s":                         "Invalid port: %s",
+		"命令不能为空":                            "Command is required",
+		"stdin 解码失败":                        "Failed to decode stdin",
+		"stdin_encoding 只支持 plain 或 base64": "stdin_encoding must be plain or base64",
+		"stdin 内容超过上限 (%d MB)":              "stdin exceeds the limit (%d MB)",
+		"会话不存在":                             "Session not found",
+		"录像不存在":                             "Recording not found",
+		"主机终端未启用（设置 ENABLE_HOST_TERMINAL=true 开启）": "Host terminal is disabled (set ENABLE_HOST_TERMINAL=true to enable)",
+		"名称模式不能包含 /":                               "Name pattern must not contain /",
+		"最大容器数不能为负数":                               "Maximum container count must not be negative",
+
+		// 镜像
+		"镜像名称不能为空":                 "Image name is required",
+		"Dockerfile 内容不能为空":        "Dockerfile content is required",
+		"获取镜像列表失败: %v":             "Failed to list images: %v",
+		"拉取镜像失败: %v":               "Failed to pull image: %v",
+		"写入 Dockerfile 失败: %v":     "Failed to write Dockerfile: %v",
+		"创建临时目录失败: %v":             "Failed to create temporary directory: %v",
+		"删除失败: 镜像有子镜像依赖，请先删除依赖的镜像": "Delete failed: the image has dependent child images, delete them first",
+		"删除失败: 镜像正在被容器使用，请先停止并删除相关容器": "Delete failed: the image is in use, stop and remove the containers using it first",
+		"删除失败: 镜像被其他镜像引用":             "Delete failed: the image is referenced by other images",
+
+		// 网络
+		"网络 ID 不能为空":       "Network ID is required",
+		"网络名称不能为空":         "Network name is required",
+		"网络不存在":            "Network not found",
+		"网络正在被容器使用，请先断开连接": "Network is in use, disconnect its containers first",
+		"获取网络列表失败: %v":     "Failed to list networks: %v",
+		"获取网络详情失败: %v":     "Failed to get network details: %v",
+		"创建网络失败: %v":       "Failed to create network: %v",
+		"删除网络失败: %v":       "Failed to remove network: %v",
+		"断开连接失败: %v":       "Failed to disconnect: %v",
+
+		// Compose 项目
+		"项目不存在":    "Project not found",
+		"项目已存在":    "Project already exists",
+		"项目名称不能为空": "Project name is required",
+
+		// 文件
+		"文件不存在":                           "File not found",
+		"目录不存在":                           "Directory not found",
+		"路径不存在":                           "Path not found",
+		"源文件不存在":                          "Source file not found",
+		"归档文件不存在":                         "Archive not found",
+		"目录已存在":                           "Directory already exists",
+		"不是普通文件":                          "Not a regular file",
+		"不是目录":                            "Not a directory",
+		"目标不是普通文件":                        "Target is not a regular file",
+		"文件名无效":                           "Invalid file name",
+		"目录名无效":                           "Invalid directory name",
+		"路径必须为绝对路径":                       "Path must be absolute",
+		"源路径和目标路径无效":                      "Invalid source or target path",
+		"源路径和目标路径相同":                      "Source and target paths are the same",
+		"不能将目录复制到自身内部":                    "Cannot copy a directory into itself",
+		"不能将目录移动到自身内部":                    "Cannot move a directory into itself",
+		"复制目录需要设置 recursive":              "Copying a directory requires recursive",
+		"目标已存在（如需覆盖请设置 overwrite）":        "Target already exists (set overwrite to replace it)",
+		"目标是二进制文件，覆盖请设置 force=true":       "Target is a binary file, set force=true to overwrite it",
+		"禁止删除系统关键目录":                      "Deleting system directories is forbidden",
+		"禁止移动或覆盖系统关键目录":                   "Moving or overwriting system directories is forbidden",
+		"禁止解压到系统关键目录":                     "Extracting into system directories is forbidden",
+		"禁止递归修改系统关键目录":                    "Recursively modifying system directories is forbidden",
+		"权限格式无效（应为八进制，如 644 或 0755）":      "Invalid mode (must be octal, e.g. 644 or 0755)",
+		"用户或组格式无效":                        "Invalid user or group",
+		"owner 和 group 不能同时为空":            "owner and group cannot both be empty",
+		"encoding 只支持 utf-8 或 base64":     "encoding must be utf-8 or base64",
+		"文件内容解码失败":                        "Failed to decode file content",
+		"容器中没有 find 命令":                   "The container has no find command",
+		"容器中没有 %s 命令":                     "The container has no %s command",
+		"strip_components 参数无效":           "Invalid strip_components parameter",
+		"max_depth 参数无效（1-%d）":            "Invalid max_depth parameter (1-%d)",
+		"不支持的归档格式（支持 tar、tar.gz、tgz、zip）": "Unsupported archive format (supported: tar, tar.gz, tgz, zip)",
+		"文件超过下载上限 (%d MB)":                "File exceeds the download limit (%d MB)",
+		"读取文件失败: %v":                      "Failed to read file: %v",
+		"读取文件信息失败: %v":                    "Failed to stat file: %v",
+		"读取目录失败: %v":                      "Failed to read directory: %v",
+		"打开文件失败: %v":                      "Failed to open file: %v",
+		"打开临时文件失败: %v":                    "Failed to open temporary file: %v",
+		"写入容器失败: %v":                      "Failed to write to container: %v",
+		"创建目录失败: %v":                      "Failed to create directory: %v",
+		"创建目标目录失败: %s":                    "Failed to create target directory: %s",
+		"检查源路径失败: %v":                     "Failed to check source path: %v",
+		"检查目标路径失败: %v":                    "Failed to check target path: %v",
+		"复制失败: %v":                        "Copy failed: %v",
+		"移动失败: %s":                        "Move failed: %s",
+		"重命名失败: %v":                       "Rename failed: %v",
+		"禁止覆盖系统关键目录":                      "Overwriting critical system directories is not allowed",
+		"删除目标失败: %s":                      "Failed to remove the destination: %s",
+		"移走目标失败: %v":                      "Failed to move the destination aside: %v",
+		"源路径位于目标目录内部，不能覆盖":                "The source is inside the destination directory and cannot overwrite it",
+		"已复制到 %s，但删除源文件失败: %v":            "Copied to %s, but failed to delete the source: %v",
+		"下载失败: %v":                        "Download failed: %v",
+		"创建归档失败: %v":                      "Failed to create archive: %v",
+		"写入归档失败: %v":                      "Failed to write archive: %v",
+		"关闭归档失败: %v":                      "Failed to close archive: %v",
+		"检查归档失败: %v":                      "Failed to inspect archive: %v",
+		"解压失败: %v":                        "Extraction failed: %v",
+		"解压后的内容超过上限 (%d MB)":              "Extracted content exceeds the limit (%d MB)",
+
+		// 上传
+		"缺少上传文件":                       "No file uploaded",
+		"上传失败: %v":                     "Upload failed: %v",
+		"上传内容超过上限 (%d MB)":             "Upload exceeds the limit (%d MB)",
+		"读取上传内容失败: %v":                 "Failed to read upload: %v",
+		"上传任务不存在或已过期":                  "Upload session not found or expired",
+		"container_id 和 path 需在文件之前提交": "container_id and path must be sent before the file",
+		"chunk_size 参数无效（最大 %d MB）":    "Invalid chunk_size parameter (max %d MB)",
+		"缺少分片 SHA-256（X-Chunk-SHA256）": "Missing chunk SHA-256 (X-Chunk-SHA256)",
+		"分片 SHA-256 校验失败":              "Chunk SHA-256 mismatch",
+		"分片大小不匹配: 期望 %d，实际 %d":         "Chunk size mismatch: expected %d, got %d",
+		"分片大小超过 %d 字节":                 "Chunk is larger than %d bytes",
+		"分片 %d 尚未上传":                   "Chunk %d has not been uploaded",
+		"分片 %d 正在上传":                   "Chunk %d is already being uploaded",
+		"上传正在完成":                       "Upload is being completed",
+		"上传正在完成，不能再上传分片":               "Upload is being completed, no more chunks can be uploaded",
+		"写入分片失败: %v":                   "Failed to write chunk: %v",
+		"保存上传进度失败: %v":                 "Failed to save upload progress: %v",
+		"创建上传任务失败: %v":                 "Failed to create upload session: %v",
+		"创建上传目录失败: %v":                 "Failed to create upload directory: %v",
+		"生成上传ID失败: %v":                 "Failed to generate upload ID: %v",
+		"数据目录剩余空间不足":                   "Not enough free space in the data directory",
+
+		// 集群节点
+		"当前节点不是 Master 模式":     "This node is not running in master mode",
+		"节点 ID 不能为空":           "Node ID is required",
+		"节点 ID 不匹配":            "Node ID mismatch",
+		"节点地址无效: %s":           "Invalid node address: %s",
+		"节点不存在: %s":            "Node not found: %s",
+		"节点不在线: %s":            "Node is offline: %s",
+		"节点认证失败: Token无效":      "Node authentication failed: invalid token",
+		"节点认证失败: 缺少节点ID或Token": "Node authentication failed: missing node ID or token",
+		"节点容器数已达上限 (%d/%d)":    "Node container limit reached (%d/%d)",
+		"调用 Worker 节点失败: %v":   "Worker node request failed: %v",
+		"Worker 节点错误: %s":      "Worker node error: %s",
+
+		// 通知渠道
+		"保存失败: %v": "Save failed: %v",
+		"通知渠道不存在":  "Notification channel not found",
+		"渠道名称不能为空": "Channel name is required",
+		"地址无效: %s（应为 http:// 或 https:// 地址）":                       "Invalid URL: %s (must be an http:// or https:// URL)",
+		"Telegram 渠道需要 bot_token 和 chat_id":                        "Telegram channels require bot_token and chat_id",
+		"邮件渠道需要 smtp_host、from 和 to":                               "Email channels require smtp_host, from and to",
+		"smtp_port 无效: %d":                                         "Invalid smtp_port: %d",
+		"不支持的通知渠道类型: %s（支持 webhook、dingtalk、wecom、telegram、email）": "Unsupported channel type: %s (supported: webhook, dingtalk, wecom, telegram, email)",
+		// 定时任务
+		"定时任务不存在":                       "Scheduled task not found",
+		"任务正在执行中":                       "Task is already running",
+		"任务名称不能为空":                      "Task name is required",
+		"不支持的任务类型: %s":                  "Unsupported task type: %s",
+		"Compose 项目名称无效: %s":            "Invalid Compose project name: %s",
+		"卷名称无效: %s":                     "Invalid volume name: %s",
+		"keep 参数无效":                     "Invalid keep parameter",
+		"task_id 参数无效":                  "Invalid task_id parameter",
+		"cron 表达式应为 5 段（分 时 日 月 周）: %s": "Cron expression must have 5 fields (minute hour day month weekday): %s",
+		"cron 表达式不会触发: %s":              "Cron expression never fires: %s",
+		"cron 步长无效: %s":                 "Invalid cron step: %s",
+		"cron 范围无效: %s":                 "Invalid cron range: %s",
+		"cron 取值无效: %s（范围 %d-%d）":       "Invalid cron value: %s (range %d-%d)",
+		// 容器自动更新
+		"容器使用镜像 ID 创建，没有可检查的标签": "The container was created from an image ID and has no tag to check",
+		"容器不存在: %s":   "Container not found: %s",
+		"正在检查更新":      "An update check is already running",
+		"未知的设置项: %s":  "unknown setting: %s",
+		"%s 设置无效: %v": "invalid value for %s: %v",
+		"应为 1-3650 天": "must be between 1 and 3650 days",
+		"应为 0-3600 秒": "must be between 0 and 3600 seconds",
+		"不能小于 %d":     "must be at least %d",
+		"应为仓库主机名（可带端口），如 registry.example.com": "must be a registry host name (optionally with port), e.g. registry.example.com",
+		"应为字符串":                     "must be a string",
+		"应为整数":                      "must be an integer",
+		"应为 true 或 false":           "must be true or false",
+		"scope 参数无效: %s":            "invalid scope parameter: %s",
+		"format 参数无效，应为 csv 或 json": "invalid format parameter, expected csv or json",
+		"备份数据库失败: %v":               "failed to back up database: %v",
+		"数据库驱动不支持在线备份":              "database driver does not support online backup",
+		"不是有效的备份文件: %v":             "not a valid backup file: %v",
+		"读取备份失败: %v":                "failed to read backup: %v",
+		"备份包含未知条目: %s":              "backup contains unknown entry: %s",
+		"备份缺少 %s，不是面板备份文件":          "backup is missing %s, not a panel backup",
+		"备份清单无效":                    "invalid backup manifest",
+		"备份缺少 %s":                   "backup is missing %s",
+		"备份数据库无法读取: %v":             "backup database is unreadable: %v",
+		"备份数据库已损坏: %s":              "backup database is corrupt: %s",
+		"备份数据库中没有用户":                "backup database contains no users",
+		"恢复会覆盖当前的面板数据，请确认（confirm=true 需在文件之前提交）": "restoring overwrites the current panel data; confirm with confirm=true (sent before the file)",
+		"备份的数据版本 (%d) 高于当前面板支持的版本 (%d)，请先升级面板":    "backup schema version (%d) is newer than this panel supports (%d); upgrade the panel first",
+		"保存当前数据库失败: %v":           "failed to save current database: %v",
+		"移动当前编排项目失败: %v":          "failed to move current compose projects: %v",
+		"替换编排项目失败: %v":            "failed to replace compose projects: %v",
+		"恢复数据库失败: %v":             "failed to restore database: %v",
+		"主机路径无效: %s":              "invalid host path: %s",
+		"内置模板不能修改: %s":            "built-in template cannot be modified: %s",
+		"内置模板不能删除: %s":            "built-in template cannot be deleted: %s",
+		"升级失败（%s）: %v":            "upgrade failed (%s): %v",
+		"容器使用 digest 引用镜像，无法升级":   "container references its image by digest and cannot be upgraded",
+		"容器内路径应为绝对路径: %s":         "container path must be absolute: %s",
+		"容器名称无效: %s":              "invalid container name: %s",
+		"模板 ID 只能包含小写字母、数字、- 和 _": "template ID may only contain lowercase letters, digits, - and _",
+		"模板不存在: %s":               "template not found: %s",
+		"模板名称不能为空":                "template name is required",
+		"模板已存在: %s":               "template already exists: %s",
+		"环境变量名无效: %s":             "invalid environment variable name: %s",
+		"端口无效: %s":                "invalid port: %s",
+		"端口无效: %s:%s":             "invalid port: %s:%s",
+		"缺少必填环境变量: %s（%s）":        "missing required environment variable: %s (%s)",
+		"该容器不是通过应用模板部署的":          "this container was not deployed from an app template",
+		"读取应用模板失败: %v":            "failed to read app templates: %v",
+		"重启策略无效: %s":              "invalid restart policy: %s",
+		"镜像名称无效: %s":              "invalid image name: %s",
+		"镜像标签无效: %s":              "invalid image tag: %s",
+		"获取容器配置失败: %v":            "failed to inspect container: %v",
+		"保存容器配置失败: %v":            "failed to save container config: %v",
+		"保存到回收站失败，未删除容器: %v":      "failed to save to trash, container was not removed: %v",
+		"回收站中没有该容器":               "container not found in trash",
+		"镜像已不存在: %s":              "image no longer exists: %s",
+		"回收站中的容器配置已损坏":            "container config in trash is corrupt",
+		"创建新容器失败: %v":             "failed to create new container: %v",
+		"连接网络 %s 失败: %v":          "failed to connect network %s: %v",
+		"创建容器依赖表失败: %v":           "failed to create container dependency table: %v",
+		"加载容器依赖失败: %v":            "failed to load container dependencies: %v",
+		"依赖存在循环: %s":              "dependency cycle detected: %s",
+		"缺少容器名称":                  "container name is required",
+		"依赖容器名称不能为空":              "dependency container name must not be empty",
+		"容器不能依赖自身":                "a container cannot depend on itself",
+		"无效的等待条件: %s":             "invalid wait condition: %s",
+		"检查容器状态失败: %v":            "failed to check container state: %v",
+		"容器未能保持运行（状态 %s，退出码 %d）":  "container did not stay running (state %s, exit code %d)",
+		"容器健康检查失败":                "container health check failed",
+		"等待容器健康超时":                "timed out waiting for container to become healthy",
+		"等待容器运行超时":                "timed out waiting for container to run",
+		"获取容器 %s 失败: %v":          "failed to inspect container %s: %v",
+		"任务不存在或已过期":               "job not found or expired",
+		"任务已结束":                   "job has already finished",
+		"该任务不支持取消":                "this job cannot be canceled",
+		"任务已取消":                   "job canceled",
+		"排队中的任务已达上限 %d":           "job queue is full (%d)",
+		"生成任务ID失败: %v":            "failed to generate job ID: %v",
+		"任务异常退出: %v":              "job crashed: %v",
+		"操作繁忙: %v":                "operation busy: %v",
+		"启动命令失败: %v":              "failed to start command: %v",
+		"获取输出失败: %v":              "failed to get output: %v",
+		"获取错误输出失败: %v":            "failed to get error output: %v",
+		"构建失败: %v":                "build failed: %v",
+		"容器不存在":                   "container not found",
+		"该操作不支持预览":                "this action does not support dry run",
+		"该类型的任务不支持预览":             "this task type does not support dry run",
+		"rmi 只能是 local 或 all":     "rmi must be local or all",
+		"获取卷列表失败: %v":             "failed to list volumes: %v",
+		"缺少搜索关键字":                 "Missing search query",
+		"主机端口 %d/%s 已被进程 %s 占用":   "Host port %d/%s is already in use by process %s",
+		"主机端口 %d/%s 已被容器 %s 占用":   "Host port %d/%s is already in use by container %s",
+		"主机端口 %d/%s 已被进程 %s 占用（另有 %d 个端口冲突）": "Host port %d/%s is already in use by process %s (%d more conflicting ports)",
+		"主机端口 %d/%s 已被容器 %s 占用（另有 %d 个端口冲突）": "Host port %d/%s is already in use by container %s (%d more conflicting ports)",
+		"主机端口 %d/%s 已被占用":                    "Host port %d/%s is already in use",
+		"主机端口 %d/%s 已被占用（另有 %d 个端口冲突）":       "Host port %d/%s is already in use (%d more conflicting ports)",
+		"未知进程":            "unknown process",
+		"端口参数无效":          "Invalid port parameter",
+		"不支持的收藏类型: %s":    "Unsupported favorite kind: %s",
+		"名称不能为空":          "Name is required",
+		"未找到 exec 进程":     "Exec process not found",
+		"无法获取 CPU 时间":     "Failed to read CPU times",
+		"channel_id 参数无效": "Invalid channel_id parameter",
+	},
+}
+
+// 格式串中的参数（%v、%s、%d 等）
+var messageVerbPattern = regexp.MustCompile(`%[-+# 0-9.]*[vsdqx]`)
+
+// 编译后的翻译表
+type messageCatalog struct {
+	exact   map[string]string
+	formats []messageFormat
+}
+
+type messageFormat struct {
+	pattern *regexp.Regexp
+	format  string // 参数已统一为 %s
+}
+
+var (
+	compiledCatalogsOnce sync.Once
+	compiledCatalogs     map[string]*messageCatalog
+)
+
+func compileMessageCatalogs() {
+	compiledCatalogs = make(map[string]*messageCatalog, len(messageCatalogs))
+	for lang, messages := range messageCatalogs {
+		c := &messageCatalog{exact: make(map[string]string)}
+		for source, target := range messages {
+			if !messageVerbPattern.MatchString(source) {
+				c.exact[source] = target
+				continue
+			}
+			// 字面部分转义，参数替换为捕获组
+			literals := messageVerbPattern.Split(source, -1)
+			for i := range literals {
+				literals[i] = regexp.QuoteMeta(literals[i])
+			}
+			c.formats = append(c.formats, messageFormat{
+				pattern: regexp.MustCompile("^" + strings.Join(literals, "(.*)") + "$"),
+				format:  messageVerbPattern.ReplaceAllString(target, "%s"),
+			})
+		}
+		// 字面部分更长的格式优先匹配（如 "删除失败: 镜像..." 优先于 "删除失败: %v"）
+		sort.Slice(c.formats, func(i, j int) bool {
+			return len(c.formats[i].pattern.String()) > len(c.formats[j].pattern.String())
+		})
+		compiledCatalogs[lang] = c
+	}
+}
+
+// 翻译消息，没有对应翻译时返回 ok=false
+func translateMessage(lang, message string, depth int) (string, bool) {
+	compiledCatalogsOnce.Do(compileMessageCatalogs)
+	c, ok := compiledCatalogs[lang]
+	if !ok || message == "" {
+		return message, false
+	}
+	if target, ok := c.exact[message]; ok {
+		return target, true
+	}
+	for _, f := range c.formats {
+		m := f.pattern.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		args := make([]interface{}, len(m)-1)
+		for i, arg := range m[1:] {
+			args[i] = arg
+			if depth < 2 {
+				args[i] = translateArg(lang, arg, depth+1)
+			}
+		}
+		return fmt.Sprintf(f.format, args...), true
+	}
+	return message, false
+}
+
+// 翻译格式串的参数，支持 "、" 分隔的列表（如缺少的密码字符类型）
+func translateArg(lang, arg string, depth int) string {
+	if t, ok := translateMessage(lang, arg, depth); ok {
+		return t
+	}
+	if !strings.Contains(arg, "、") {
+		return arg
+	}
+	items := strings.Split(arg, "、")
+	for i, item := range items {
+		t, ok := translateMessage(lang, item, depth)
+		if !ok {
+			return arg
+		}
+		items[i] = t
+	}
+	return strings.Join(items, ", ")
+}
+
+// 是否包含中文字符
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按语言翻译错误消息
+// 没有翻译的中文消息在前面加上错误码的默认消息，保留原文便于排查
+func localizeError(lang, code, message string) string {
+	if lang == "" {
+		lang = defaultLang
+	}
+	if message == "" {
+		if m, ok := errorCodeMessages[lang][code]; ok {
+			return m
+		}
+		return errorCodeMessages[defaultLang][code]
+	}
+	if lang == defaultLang {
+		return message
+	}
+	if t, ok := translateMessage(lang, message, 0); ok {
+		return t
+	}
+	if containsHan(message) {
+		if m, ok := errorCodeMessages[lang][code]; ok {
+			return m + ": " + message
+		}
+	}
+	return message
+}
+
+// 按响应语言翻译普通状态消息
+func localize(w http.ResponseWriter, message string) string {
+	lang := w.Header().Get("Content-Language")
+	if lang == "" || lang == defaultLang {
+		return message
+	}
+	t, _ := translateMessage(lang, message, 0)
+	return t
+}
+
+// 规范化语言标签，不支持的语言返回空
+func normalizeLang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case tag == "zh" || strings.HasPrefix(tag, "zh-"):
+		return langZH
+	case tag == "en" || strings.HasPrefix(tag, "en-"):
+		return langEN
+	}
+	return ""
+}
+
+// 从 Accept-Language 中选择权重最高的支持语言
+func parseAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang := normalizeLang(tag); lang != "" && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// 按 Accept-Language 设置响应语言（Content-Language），认证后再按用户偏好覆盖
+func withLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			lang := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+			if lang == "" {
+				lang = defaultLang
+			}
+			w.Header().Set("Content-Language", lang)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 用户语言偏好缓存（username -> 语言，空字符串表示跟随 Accept-Language）
+var userLanguages sync.Map
+
+// 给用户表添加语言偏好列（旧版本数据库没有该列）
+func migrateUserLanguage() error {
+	_, err := authDB.Exec("ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("添加语言偏好列失败: %v", err)
+	}
+	return nil
+}
+
+// 读取用户的语言偏好
+func userLanguage(username string) string {
+	if v, ok := userLanguages.Load(username); ok {
+		return v.(string)
+	}
+	var lang string
+	err := authDB.QueryRow("SELECT language FROM users WHERE username = ?", username).Scan(&lang)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[I18n] Failed to load language preference for %s: %v", username, err)
+		return ""
+	}
+	userLanguages.Store(username, lang)
+	return lang
+}
+
+// 查看或修改当前用户的语言偏好
+func handleUserLanguage(w http.ResponseWriter, r *http.Request) {
+	username := r.Header.Get("X-Username")
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"language":  userLanguage(username),
+			"supported": []string{langZH, langEN},
+		})
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		Language string `json:"language"` // 为空表示跟随浏览器
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	lang := ""
+	if req.Language != "" {
+		if lang = normalizeLang(req.Language); lang == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("不支持的语言: %s", req.Language))
+			return
+		}
+	}
+
+	if _, err := authDB.Exec(
+		"UPDATE users SET language = ?, updated_at = CURRENT_TIMESTAMP WHERE username = ?",
+		lang, username,
+	); err != nil {
+		log.Printf("[I18n] Failed to save language preference for %s: %v", username, err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "保存语言偏好失败")
+		return
+	}
+	userLanguages.Store(username, lang)
+	if lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "success",
+		"language": lang,
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 需要翻译的消息参数位置
+var localizedCallArgs = map[string]int{
+	"writeError":        3,
+	"writeErrorDetails": 3,
+	"localize":          1,
+}
+
+// 取出消息参数中的中文字面量（直接的字符串或 fmt.Sprintf 的格式串）
+func localizedLiteral(expr ast.Expr) (string, bool) {
+	if call, ok := expr.(*ast.CallExpr); ok {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Sprintf" || len(call.Args) == 0 {
+			return "", false
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+			return "", false
+		}
+		expr = call.Args[0]
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil || !containsHan(s) {
+		return "", false
+	}
+	return s, true
+}
+
+// 每条返回给前端的中文消息都要有英文翻译，且参数个数一致
+func TestMessageCatalogCoverage(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := messageCatalogs[langEN]
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				ident, ok := call.Fun.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				idx, ok := localizedCallArgs[ident.Name]
+				if !ok || idx >= len(call.Args) {
+					return true
+				}
+				msg, ok := localizedLiteral(call.Args[idx])
+				if !ok {
+					return true
+				}
+				target, ok := catalog[msg]
+				if !ok {
+					t.Errorf("%s: %q 没有英文翻译", fset.Position(call.Pos()), msg)
+					return true
+				}
+				want := len(messageVerbPattern.FindAllString(msg, -1))
+				if got := len(messageVerbPattern.FindAllString(target, -1)); got != want {
+					t.Errorf("%s: %q 的译文参数个数 %d，应为 %d", fset.Position(call.Pos()), msg, got, want)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// 格式串按参数翻译，参数本身也会再翻译
+func TestLocalizeError(t *testing.T) {
+	tests := []struct {
+		lang, code, message, want string
+	}{
+		{langEN, ErrCodeBadRequest, "", "Bad request"},
+		{langZH, ErrCodeBadRequest, "参数错误", "参数错误"},
+		{langEN, ErrCodeBadRequest, "参数错误", "Invalid parameters"},
+		{langEN, ErrCodeInternal, "内部错误: 参数错误", "Internal error: Invalid parameters"},
+		{langEN, ErrCodeInternal, "内部错误: boom", "Internal error: boom"},
+		{langEN, ErrCodeInternal, "没有翻译的消息", "Internal server error: 没有翻译的消息"},
+	}
+	for _, tt := range tests {
+		if got := localizeError(tt.lang, tt.code, tt.message); got != tt.want {
+			t.Errorf("localizeError(%q, %q, %q) = %q, want %q", tt.lang, tt.code, tt.message, got, tt.want)
+		}
+	}
+}
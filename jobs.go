@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 后台任务：耗时操作（构建镜像、拉取镜像创建容器、compose 操作）可以带 ?async=true 提交，
+// 立即返回任务 ID，由固定数量的工作协程执行，输出保存在有上限的日志缓冲中，浏览器离开页面后仍可查看结果
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCanceled  = "canceled"
+)
+
+const (
+	jobWorkers     = 4    // 同时执行的任务数
+	jobQueueSize   = 64   // 排队中的任务上限，超出时拒绝提交
+	jobLogLines    = 2000 // 每个任务保留的输出行数（超出时丢弃最早的行）
+	jobLineMax     = 8192 // 单行输出的最大长度
+	jobKeepHistory = 200  // 内存中保留的已结束任务数
+)
+
+// 进度输出：SSE 流（sseStream）和后台任务（Job）都实现了该接口，同一段操作代码可用于两种方式
+type progressSink interface {
+	send(eventType, message string)
+}
+
+// 任务执行函数，返回结果（如容器 ID，可为空）
+type jobFunc func(ctx context.Context, out progressSink) (string, error)
+
+// 后台任务信息
+type JobInfo struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`   // image_build / container_run / compose_<action>
+	Target     string `json:"target"` // 镜像、容器或项目名称
+	State      string `json:"state"`
+	Cancelable bool   `json:"cancelable"` // 底层操作是否支持取消
+	CreatedBy  string `json:"created_by"`
+	CreatedAt  int64  `json:"created_at"` // Unix 秒
+	StartedAt  int64  `json:"started_at,omitempty"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	LogLines   int    `json:"log_lines"` // 累计输出行数（含已丢弃的）
+}
+
+// 后台任务
+type Job struct {
+	JobInfo
+
+	mu      sync.Mutex
+	fn      jobFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	lines   []string
+	dropped int           // 因超出缓冲上限丢弃的行数
+	updated chan struct{} // 有新输出或状态变化时关闭并替换，用于实时推送
+}
+
+var (
+	jobsMu    sync.RWMutex
+	jobs      = map[string]*Job{}
+	jobsQueue = make(chan *Job, jobQueueSize)
+)
+
+// 启动工作协程
+func initJobs() {
+	for i := 0; i < jobWorkers; i++ {
+		go runJobWorker()
+	}
+}
+
+func runJobWorker() {
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case job := <-jobsQueue:
+			job.run()
+		}
+	}
+}
+
+// 提交后台任务，队列已满时返回错误
+func submitJob(jobType, target, username string, cancelable bool, fn jobFunc) (*Job, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("生成任务ID失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(serverCtx)
+	job := &Job{
+		JobInfo: JobInfo{
+			ID:         hex.EncodeToString(idBytes),
+			Type:       jobType,
+			Target:     target,
+			State:      jobQueued,
+			Cancelable: cancelable,
+			CreatedBy:  username,
+			CreatedAt:  time.Now().Unix(),
+		},
+		fn:      fn,
+		ctx:     ctx,
+		cancel:  cancel,
+		updated: make(chan struct{}),
+	}
+
+	jobsMu.Lock()
+	select {
+	case jobsQueue <- job:
+	default:
+		jobsMu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("排队中的任务已达上限 %d", jobQueueSize)
+	}
+	jobs[job.ID] = job
+	pruneJobsLocked()
+	jobsMu.Unlock()
+
+	publishBus(busTopicJobs, "job", job.snapshot())
+	log.Printf("[Jobs] Queued %s %s (%s)", jobType, target, job.ID)
+	return job, nil
+}
+
+// 只保留最近 jobKeepHistory 个已结束的任务
+func pruneJobsLocked() {
+	var finished []*Job
+	for _, j := range jobs {
+		j.mu.Lock()
+		done := j.done()
+		j.mu.Unlock()
+		if done {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= jobKeepHistory {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].CreatedAt < finished[b].CreatedAt })
+	for _, j := range finished[:len(finished)-jobKeepHistory] {
+		delete(jobs, j.ID)
+	}
+}
+
+func getJob(id string) *Job {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	return jobs[id]
+}
+
+// 调用方需持有 j.mu
+func (j *Job) done() bool {
+	return j.State == jobSucceeded || j.State == jobFailed || j.State == jobCanceled
+}
+
+// 调用方需持有 j.mu
+func (j *Job) notifyLocked() {
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+// 推送任务状态到实时事件，调用方需持有 j.mu
+func (j *Job) publishStateLocked() {
+	publishBus(busTopicJobs, "job", j.JobInfo)
+}
+
+// 追加一行输出（实现 progressSink）
+func (j *Job) send(eventType, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var added []string
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		if len(line) > jobLineMax {
+			line = line[:jobLineMax]
+		}
+		if eventType == "error" {
+			line = "错误: " + line
+		}
+		j.lines = append(j.lines, line)
+		j.LogLines++
+		added = append(added, line)
+	}
+	publishBus(busTopicJobs, "job_log", map[string]interface{}{"id": j.ID, "lines": added, "log_lines": j.LogLines})
+	if over := len(j.lines) - jobLogLines; over > 0 {
+		j.lines = append(j.lines[:0:0], j.lines[over:]...)
+		j.dropped += over
+	}
+	j.notifyLocked()
+}
+
+func (j *Job) run() {
+	j.mu.Lock()
+	if j.State != jobQueued { // 排队时已取消
+		j.mu.Unlock()
+		return
+	}
+	j.State = jobRunning
+	j.StartedAt = time.Now().Unix()
+	j.notifyLocked()
+	j.publishStateLocked()
+	j.mu.Unlock()
+
+	result, err := j.exec()
+	canceled := j.ctx.Err() != nil && serverCtx.Err() == nil
+	j.cancel()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.FinishedAt = time.Now().Unix()
+	switch {
+	case err != nil && canceled:
+		j.State = jobCanceled
+		j.Error = "任务已取消"
+	case err != nil:
+		j.State = jobFailed
+		j.Error = err.Error()
+	default:
+		j.State = jobSucceeded
+		j.Result = result
+	}
+	j.notifyLocked()
+	j.publishStateLocked()
+	log.Printf("[Jobs] %s %s (%s): %s", j.Type, j.Target, j.ID, j.State)
+}
+
+// 执行任务函数，panic 时按失败处理
+func (j *Job) exec() (result string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			logPanic(j.ctx, "job "+j.Type, v)
+			err = fmt.Errorf("任务异常退出: %v", v)
+		}
+	}()
+	return j.fn(j.ctx, j)
+}
+
+// 取消任务：排队中的直接结束，执行中的取消其 context
+func (j *Job) cancelJob() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case j.done():
+		return fmt.Errorf("任务已结束")
+	case j.State == jobQueued:
+		j.State = jobCanceled
+		j.Error = "任务已取消"
+		j.FinishedAt = time.Now().Unix()
+		j.notifyLocked()
+		j.publishStateLocked()
+	case !j.Cancelable:
+		return fmt.Errorf("该任务不支持取消")
+	}
+	j.cancel()
+	return nil
+}
+
+// 任务信息的副本（不含输出）
+func (j *Job) snapshot() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.JobInfo
+}
+
+// 请求是否要求以后台任务方式执行
+func wantsAsync(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return v
+}
+
+// 提交后台任务并返回 202 和任务信息
+func writeJobSubmitted(w http.ResponseWriter, r *http.Request, jobType, target string, cancelable bool, fn jobFunc) {
+	job, err := submitJob(jobType, target, r.Header.Get("X-Username"), cancelable, fn)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeServiceUnavailable, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job.snapshot())
+}
+
+// 执行命令，stdout 和 stderr 逐行输出
+func runCommandProgress(ctx context.Context, out progressSink, dir, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("获取输出失败: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("获取错误输出失败: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动命令失败: %v", err)
+	}
+
+	// stdout 和 stderr 都读完后才能调用 Wait
+	var wg sync.WaitGroup
+	for _, pipe := range []io.Reader{stdout, stderr} {
+		wg.Add(1)
+		go func(pipe io.Reader) {
+			defer recoverGoroutine(ctx, "command output")
+			defer wg.Done()
+			scanner := bufio.NewScanner(pipe)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				out.send("log", scanner.Text())
+			}
+		}(pipe)
+	}
+	wg.Wait()
+	return cmd.Wait()
+}
+
+// 任务列表（?state=running&type=image_build，最新的在前）
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	q := r.URL.Query()
+	jobsMu.RLock()
+	list := make([]JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		s := j.snapshot()
+		if (q.Get("state") == "" || s.State == q.Get("state")) && (q.Get("type") == "" || s.Type == q.Get("type")) {
+			list = append(list, s)
+		}
+	}
+	jobsMu.RUnlock()
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].CreatedAt != list[b].CreatedAt {
+			return list[a].CreatedAt > list[b].CreatedAt
+		}
+		return list[a].ID < list[b].ID
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// 单个任务：{id}、{id}/log、{id}/cancel
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	job := getJob(id)
+	if job == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "任务不存在或已过期")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job.snapshot())
+	case action == "log" && r.Method == http.MethodGet:
+		handleJobLog(w, r, job)
+	case action == "cancel" && r.Method == http.MethodPost:
+		if err := job.cancelJob(); err != nil {
+			writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+		writeAuditLog(r.Header.Get("X-Username"), "job_cancel", job.Target, job.Type+" "+job.ID, r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+	}
+}
+
+// 任务输出：默认返回已保存的输出（?since=N 只返回第 N 行之后的），?follow=true 以 SSE 实时推送直到任务结束
+// SSE 事件与流式接口一致：log 为输出行，结束时发送 success（id 为结果）或 error
+func handleJobLog(w http.ResponseWriter, r *http.Request, job *Job) {
+	since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+	if since < 0 {
+		since = 0
+	}
+
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	if !follow {
+		job.mu.Lock()
+		lines, next := job.linesSinceLocked(since)
+		resp := map[string]interface{}{
+			"id":      job.ID,
+			"state":   job.State,
+			"done":    job.done(),
+			"lines":   lines,
+			"next":    next,        // 下次轮询时作为 since
+			"dropped": job.dropped, // 超出缓冲上限被丢弃的行数
+		}
+		job.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "SSE 不支持")
+		return
+	}
+
+	for {
+		job.mu.Lock()
+		lines, next := job.linesSinceLocked(since)
+		done, state, result, errMsg := job.done(), job.State, job.Result, job.Error
+		updated := job.updated
+		job.mu.Unlock()
+
+		since = next
+		for _, line := range lines {
+			if err := sseWriteJSON(w, flusher, sseEvent{Type: "log", Message: line}); err != nil {
+				return
+			}
+		}
+		if done {
+			if state == jobSucceeded {
+				sseWriteJSON(w, flusher, sseEvent{Type: "success", ID: result})
+			} else {
+				sseWriteJSON(w, flusher, sseEvent{Type: "error", Message: errMsg})
+			}
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updated:
+		}
+	}
+}
+
+// 第 since 行（从 0 开始计数，含已丢弃的行）之后的输出和下一行的序号，调用方需持有 j.mu
+func (j *Job) linesSinceLocked(since int) ([]string, int) {
+	start := since - j.dropped
+	if start < 0 {
+		start = 0
+	}
+	if start > len(j.lines) {
+		start = len(j.lines)
+	}
+	return append([]string{}, j.lines[start:]...), j.dropped + len(j.lines)
+}
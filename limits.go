@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 耗时 Docker 操作的并发限制
+// 每类操作一个信号量，占满时最多排队 limit_queue_wait 秒，仍拿不到名额则返回 429
+// 流式接口（日志、终端、下载、构建）在整个连接期间占用名额
+const (
+	opStats = "stats" // 容器资源统计
+	opLogs  = "logs"  // 日志流
+	opExec  = "exec"  // exec 命令和容器终端
+	opFiles = "files" // 容器文件操作（归档复制、上传下载）
+	opBuild = "build" // 镜像构建
+)
+
+// 操作限制器
+type opLimiter struct {
+	limit    int
+	sem      chan struct{} // limit 为 0 时为 nil，不限制
+	inFlight atomic.Int64
+	waiting  atomic.Int64
+	rejected atomic.Int64
+}
+
+// 限制器状态（调试接口）
+type OpLimitStats struct {
+	Limit    int   `json:"limit"` // 0 表示不限制
+	InFlight int64 `json:"in_flight"`
+	Waiting  int64 `json:"waiting"`
+	Rejected int64 `json:"rejected"`
+}
+
+// 各类操作的限制器（applyConfig 中创建，之后只读）
+var opLimiters = map[string]*opLimiter{}
+
+// 排队等待的最长时间
+var opQueueWait = 3 * time.Second
+
+func newOpLimiter(limit int) *opLimiter {
+	l := &opLimiter{limit: limit}
+	if limit > 0 {
+		l.sem = make(chan struct{}, limit)
+	}
+	return l
+}
+
+// 按配置创建限制器
+func configureOpLimits(c *Config) {
+	opLimiters = map[string]*opLimiter{
+		opStats: newOpLimiter(c.LimitStats),
+		opLogs:  newOpLimiter(c.LimitLogs),
+		opExec:  newOpLimiter(c.LimitExec),
+		opFiles: newOpLimiter(c.LimitFiles),
+		opBuild: newOpLimiter(c.LimitBuild),
+	}
+	opQueueWait = time.Duration(c.LimitQueueWait) * time.Second
+}
+
+// 获取名额，占满时排队等待，超时或请求取消时返回错误
+func (l *opLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			l.waiting.Add(1)
+			timer := time.NewTimer(opQueueWait)
+			select {
+			case l.sem <- struct{}{}:
+				l.waiting.Add(-1)
+				timer.Stop()
+			case <-timer.C:
+				l.waiting.Add(-1)
+				l.rejected.Add(1)
+				return nil, fmt.Errorf("并发数已达上限 %d", l.limit)
+			case <-ctx.Done():
+				l.waiting.Add(-1)
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	l.inFlight.Add(1)
+	var once atomic.Bool
+	return func() {
+		if !once.CompareAndSwap(false, true) {
+			return
+		}
+		l.inFlight.Add(-1)
+		if l.sem != nil {
+			<-l.sem
+		}
+	}, nil
+}
+
+// 获取指定操作的名额
+func acquireOp(ctx context.Context, op string) (func(), error) {
+	l, ok := opLimiters[op]
+	if !ok {
+		return func() {}, nil
+	}
+	return l.acquire(ctx)
+}
+
+// 返回操作繁忙的错误响应
+func writeOpBusy(w http.ResponseWriter, op string, err error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(opQueueWait/time.Second)+1))
+	writeErrorDetails(w, http.StatusTooManyRequests, ErrCodeTooManyRequests, fmt.Sprintf("操作繁忙，请稍后重试: %v", err), map[string]string{
+		"op": op,
+	})
+}
+
+// 在处理请求的整个过程中占用一个名额（流式响应直到连接关闭才释放）
+func withOpLimit(op string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, err := acquireOp(r.Context(), op)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return // 客户端已断开
+			}
+			writeOpBusy(w, op, err)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+// 各类操作的当前状态
+func opLimitStats() map[string]OpLimitStats {
+	stats := make(map[string]OpLimitStats, len(opLimiters))
+	for op, l := range opLimiters {
+		stats[op] = OpLimitStats{
+			Limit:    l.limit,
+			InFlight: l.inFlight.Load(),
+			Waiting:  l.waiting.Load(),
+			Rejected: l.rejected.Load(),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemd socket activation 传入的第一个文件描述符
+const systemdListenFDStart = 3
+
+// 监听 unix socket 时的地址前缀（LISTEN=unix:///run/rabbit-panel.sock）
+const unixListenPrefix = "unix://"
+
+// 创建 HTTP 监听器，返回监听器和用于日志的监听目标描述
+// 优先使用 systemd 传入的 socket（LISTEN_FDS），其次为 listen 配置的 unix socket，最后为 host:port
+func createListener(cfg *Config) (net.Listener, string, error) {
+	if listener, err := systemdListener(); err != nil {
+		return nil, "", err
+	} else if listener != nil {
+		return listener, fmt.Sprintf("%s:%s（systemd socket activation）", listener.Addr().Network(), listener.Addr()), nil
+	}
+
+	if socketPath := cfg.unixSocketPath(); socketPath != "" {
+		listener, err := listenUnixSocket(socketPath, cfg.SocketMode, cfg.SocketOwner)
+		if err != nil {
+			return nil, "", err
+		}
+		return listener, "unix:" + socketPath, nil
+	}
+
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	return listener, addr, nil
+}
+
+// 获取 systemd 传入的监听 socket，未使用 socket activation 时返回 nil
+func systemdListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+	// 避免子进程（如 docker compose）误用继承的 socket
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	// 只使用第一个 socket，其余关闭
+	for fd := systemdListenFDStart + 1; fd < systemdListenFDStart+fds; fd++ {
+		os.NewFile(uintptr(fd), "").Close()
+	}
+	file := os.NewFile(uintptr(systemdListenFDStart), "systemd-listen")
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("使用 systemd 传入的 socket 失败: %v", err)
+	}
+	return listener, nil
+}
+
+// 监听 unix socket：清理残留的 socket 文件，设置权限和属主
+func listenUnixSocket(socketPath, mode, owner string) (net.Listener, error) {
+	if err := removeStaleSocket(socketPath); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建 socket 目录失败: %v", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	perm, _ := strconv.ParseUint(mode, 8, 32) // 已在加载配置时校验
+	if err := os.Chmod(socketPath, os.FileMode(perm)); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置 socket 权限失败: %v", err)
+	}
+	if owner != "" {
+		uid, gid, err := lookupOwner(owner)
+		if err == nil {
+			err = os.Chown(socketPath, uid, gid)
+		}
+		if err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("设置 socket 属主失败: %v", err)
+		}
+	}
+	return listener, nil
+}
+
+// 删除上次异常退出残留的 socket 文件（仍有进程在监听时报错）
+func removeStaleSocket(socketPath string) error {
+	info, err := os.Lstat(socketPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s 已存在且不是 socket 文件", socketPath)
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s 正在被其他进程使用", socketPath)
+	}
+	if err := os.Remove(socketPath); err != nil {
+		return fmt.Errorf("删除残留的 socket 文件失败: %v", err)
+	}
+	return nil
+}
+
+// 解析 socket 属主（user、user:group 或 :group，支持数字 ID），未指定的部分返回 -1（不修改）
+func lookupOwner(owner string) (int, int, error) {
+	uid, gid := -1, -1
+	userName, groupName, _ := strings.Cut(owner, ":")
+
+	if userName != "" {
+		if id, err := strconv.Atoi(userName); err == nil {
+			uid = id
+		} else {
+			u, err := user.Lookup(userName)
+			if err != nil {
+				return 0, 0, err
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, errors.New("不支持的用户 ID: " + u.Uid)
+			}
+		}
+	}
+	if groupName != "" {
+		if id, err := strconv.Atoi(groupName); err == nil {
+			gid = id
+		} else {
+			g, err := user.LookupGroup(groupName)
+			if err != nil {
+				return 0, 0, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, errors.New("不支持的组 ID: " + g.Gid)
+			}
+		}
+	}
+	return uid, gid, nil
+}
+
+// 规范化监听地址：去掉 IPv6 地址的方括号（HOST=[::]），由 net.JoinHostPort 统一加上
+func normalizeListenHost(host string) (string, error) {
+	h := strings.TrimSpace(host)
+	if strings.HasPrefix(h, "[") || strings.HasSuffix(h, "]") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(h, "["), "]")
+		if ip := net.ParseIP(inner); ip == nil || ip.To4() != nil || len(inner) != len(h)-2 {
+			return "", fmt.Errorf("host 配置无效: %s（IPv6 地址应为 [::] 或 ::）", host)
+		}
+		return inner, nil
+	}
+	if strings.Contains(h, ":") && net.ParseIP(h) == nil {
+		return "", fmt.Errorf("host 配置无效: %s（不能包含端口，端口使用 port 配置）", host)
+	}
+	return h, nil
+}
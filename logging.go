@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 当前日志级别（可通过 /api/settings/log-level 在运行时修改）
+var logLevel = new(slog.LevelVar)
+
+// 日志中需要脱敏的字段名（包含即脱敏）
+var sensitiveLogKeys = []string{"password", "passwd", "token", "secret", "authorization", "cookie"}
+
+// 日志消息中的敏感信息（key=value、key: value 和 Bearer token）
+var sensitiveLogPattern = regexp.MustCompile(`(?i)((?:password|passwd|token|secret)\s*[=:]\s*)[^\s,;&"]+|(bearer\s+)[^\s,;"]+`)
+
+const redactedValue = "[REDACTED]"
+
+// 旧式日志消息的模块前缀，如 "[Container] ..."
+var logComponentPattern = regexp.MustCompile(`^\[([A-Za-z][A-Za-z0-9_-]*)\]\s*`)
+
+// 按名称获取模块日志记录器（统一使用 component 字段）
+func componentLogger(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+// 初始化日志：级别、格式（text/json）和输出（标准错误或按大小轮转的文件）
+func initLogging(cfg *Config) error {
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+
+	var out io.Writer = os.Stderr
+	if cfg.LogFile != "" {
+		file, err := newRotatingFile(cfg.LogFile, cfg.LogMaxSize<<20, cfg.LogMaxBackups)
+		if err != nil {
+			return fmt.Errorf("打开日志文件失败: %v", err)
+		}
+		out = file
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel, ReplaceAttr: redactLogAttr}
+	var handler slog.Handler
+	if cfg.LogFormat == "json" {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+	// 标准库 log 的输出也经过 slog（slog.SetDefault 会接管 log 包）
+	slog.SetDefault(slog.New(&legacyLogHandler{Handler: handler}))
+	return nil
+}
+
+// 解析日志级别名称
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("日志级别无效: %s（应为 debug、info、warn 或 error）", name)
+	}
+	return level, nil
+}
+
+// 日志级别名称（小写）
+func logLevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// 脱敏敏感字段
+func redactLogAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, k := range sensitiveLogKeys {
+		if strings.Contains(key, k) {
+			return slog.String(a.Key, redactedValue)
+		}
+	}
+	if a.Value.Kind() == slog.KindString {
+		if s := a.Value.String(); sensitiveLogPattern.MatchString(s) {
+			return slog.String(a.Key, redactSensitiveText(s))
+		}
+	}
+	return a
+}
+
+// 替换文本中的密码和 token
+func redactSensitiveText(s string) string {
+	return sensitiveLogPattern.ReplaceAllString(s, "${1}${2}"+redactedValue)
+}
+
+// 旧式日志中表示失败的消息（记为 ERROR）
+var logFailurePattern = regexp.MustCompile(`(?i)\b(?:failed|error)\b|失败|错误`)
+
+// 兼容 log.Printf 的日志处理器：
+// 从 "[Module] ..." 前缀中提取 component 字段，"警告: ..." 记为 WARN，失败消息记为 ERROR，并对消息脱敏；
+// 使用 *Context 方法记录时附带请求 ID
+type legacyLogHandler struct {
+	slog.Handler
+}
+
+// log.Printf 的记录都以 INFO 级别进入，重新分级后才能确定是否输出，
+// 因此 INFO 及以上总是放行，在 Handle 中按最终级别过滤
+func (h *legacyLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || h.Handler.Enabled(ctx, level)
+}
+
+func (h *legacyLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	msg := r.Message
+	level := r.Level
+	var attrs []slog.Attr
+
+	if m := logComponentPattern.FindStringSubmatch(msg); m != nil {
+		attrs = append(attrs, slog.String("component", strings.ToLower(m[1])))
+		msg = msg[len(m[0]):]
+	}
+	if level == slog.LevelInfo {
+		if rest, ok := strings.CutPrefix(msg, "警告: "); ok {
+			level = slog.LevelWarn
+			msg = rest
+		} else if logFailurePattern.MatchString(msg) {
+			level = slog.LevelError
+		}
+	}
+	if !h.Handler.Enabled(ctx, level) {
+		return nil
+	}
+	if id := requestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+
+	out := slog.NewRecord(r.Time, level, redactSensitiveText(msg), r.PC)
+	out.AddAttrs(attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *legacyLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &legacyLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *legacyLogHandler) WithGroup(name string) slog.Handler {
+	return &legacyLogHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// 按大小轮转的日志文件（path、path.1 ... path.N，N 为保留的旧文件数）
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			// 轮转失败时继续写入当前文件，避免丢失日志
+			fmt.Fprintf(os.Stderr, "日志文件轮转失败: %v\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// 当前文件重命名为 path.1，已有的旧文件依次后移，超出保留数量的删除
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	if f.maxBackups > 0 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			f.open()
+			return err
+		}
+	} else {
+		os.Remove(f.path)
+	}
+	return f.open()
+}
+
+// 查看或修改日志级别
+// GET 返回当前级别；POST {"level": "debug"} 修改级别（重启后恢复为配置中的级别）
+func handleSettingsLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+			return
+		}
+		level, err := parseLogLevel(req.Level)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		old := logLevel.Level()
+		logLevel.Set(level)
+		log.Printf("[Settings] Log level changed: %s -> %s", logLevelName(old), logLevelName(level))
+		writeAuditLog(r.Header.Get("X-Username"), "log_level_change", logLevelName(level), "from "+logLevelName(old), r.RemoteAddr)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": logLevelName(logLevel.Level())})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// log.Printf 的消息按重新分级后的级别过滤：警告和失败消息在 warn 级别下仍然输出
+func TestLegacyLogHandlerLevels(t *testing.T) {
+	old := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(old) })
+
+	var buf bytes.Buffer
+	handler := &legacyLogHandler{Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: logLevel})}
+	logger := slog.NewLogLogger(handler, slog.LevelInfo)
+
+	cases := []struct {
+		level  slog.Level
+		msg    string
+		output string // 为空表示不输出
+	}{
+		{slog.LevelWarn, "警告: 磁盘空间不足", "level=WARN msg=磁盘空间不足"},
+		{slog.LevelWarn, "[Container] Remove abc failed: conflict", "level=ERROR msg=\"Remove abc failed: conflict\" component=container"},
+		{slog.LevelWarn, "[Container] Removed abc", ""},
+		{slog.LevelError, "警告: 磁盘空间不足", ""},
+		{slog.LevelError, "[Backup] 备份失败: disk full", "level=ERROR msg=\"备份失败: disk full\" component=backup"},
+		{slog.LevelInfo, "[Container] Removed abc", "level=INFO msg=\"Removed abc\" component=container"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		logLevel.Set(c.level)
+		logger.Print(c.msg)
+		got := buf.String()
+		if c.output == "" {
+			if got != "" {
+				t.Errorf("level %s: %q logged as %q, want nothing", c.level, c.msg, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, c.output) {
+			t.Errorf("level %s: %q logged as %q, want %q", c.level, c.msg, got, c.output)
+		}
+	}
+}
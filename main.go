@@ -1,7 +1,9 @@
 package main
 
 import (
+	"archive/tar"
 	"bufio"
+	"bytes"
 	"context"
 	"embed"
 	"encoding/binary"
@@ -10,6 +12,7 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -25,22 +28,44 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/go-connections/nat"
 )
 
 //go:embed static
 var staticFiles embed.FS
 
-// 全局 Docker 客户端
-var dockerClient *client.Client
+// 全局 Docker 客户端（Docker 重启后会重建，通过 getDockerClient 访问）
+var (
+	dockerClientMu sync.RWMutex
+	dockerClient   *client.Client
+)
+
+// 获取当前的 Docker 客户端
+func getDockerClient() *client.Client {
+	dockerClientMu.RLock()
+	defer dockerClientMu.RUnlock()
+	return dockerClient
+}
+
+// 替换 Docker 客户端，返回旧的客户端（由调用方关闭）
+func setDockerClient(cli *client.Client) *client.Client {
+	dockerClientMu.Lock()
+	defer dockerClientMu.Unlock()
+	old := dockerClient
+	dockerClient = cli
+	return old
+}
 
 // CPU 使用率缓存（避免每次调用都等待1秒）
 var (
 	cpuStatsCache struct {
 		sync.RWMutex
-		lastCPU    []uint64
-		lastTime   time.Time
-		cpuUsage   float64
+		lastCPU   []uint64
+		lastTime  time.Time
+		cpuUsage  float64
+		lastCores [][]uint64 // 每个核心的上一次采样
+		coreUsage []float64  // 每个核心的使用率（由后台采样器计算）
 	}
 )
 
@@ -51,7 +76,7 @@ var (
 		data      []ContainerInfo
 		lastFetch time.Time
 	}
-	cacheTTL = 2 * time.Second // 缓存有效期 2 秒
+	cacheTTL = 2 * time.Second // Docker 事件流断开时的缓存有效期（cache_ttl，默认 2 秒），见 containersCacheTTL
 )
 
 // 镜像列表缓存
@@ -65,22 +90,45 @@ var (
 
 // 系统监控数据
 type SystemStats struct {
-	CPU    float64 `json:"cpu"`
-	Memory float64 `json:"memory"`
-	Disk   float64 `json:"disk"`
-	Time   string  `json:"time"`
+	CPU       float64 `json:"cpu"`
+	Memory    float64 `json:"memory"`
+	Disk      float64 `json:"disk"`
+	NetworkRx float64 `json:"network_rx"` // 接收速率（字节/秒）
+	NetworkTx float64 `json:"network_tx"` // 发送速率（字节/秒）
+	DiskRead  float64 `json:"disk_read"`  // 磁盘读取速率（字节/秒）
+	DiskWrite float64 `json:"disk_write"` // 磁盘写入速率（字节/秒）
+	Load1     float64 `json:"load1"`
+	Load5     float64 `json:"load5"`
+	Load15    float64 `json:"load15"`
+	Uptime    int64   `json:"uptime"`    // 系统运行时间（秒）
+	CPUCores  int     `json:"cpu_cores"` // 逻辑 CPU 核心数
+	CPUTemp   float64 `json:"cpu_temp"`  // 最高传感器温度（℃），无传感器时为 0
+	Time      string  `json:"time"`
+}
+
+// 面板的路由。不使用 http.DefaultServeMux：net/http/pprof 等包会在 init 中向它注册未经认证的路由
+var appMux = http.NewServeMux()
+
+// 请求 ID 和访问日志，恢复 panic 并返回 500，去掉 URL 前缀，按 Accept-Language 选择响应语言，
+// 按路由设置读写超时，Docker 版本不兼容时返回 503，压缩文本响应
+func newServerHandler() http.Handler {
+	return withAccessLog(withRecovery(withBasePath(withLocale(withRouteTimeouts(withDockerCompat(withGzip(appMux)))))))
 }
 
 // 容器信息
 type ContainerInfo struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Image    string `json:"image"`
-	Status   string `json:"status"`
-	Ports    string `json:"ports"`
-	Memory   string `json:"memory"`
-	Created  string `json:"created"`
-	State    string `json:"state"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	Status     string            `json:"status"`
+	Ports      string            `json:"ports"`
+	Memory     string            `json:"memory"`
+	Created    string            `json:"created"`
+	State      string            `json:"state"`
+	AutoUpdate bool              `json:"auto_update"`          // 是否开启自动更新
+	DependsOn  []string          `json:"depends_on,omitempty"` // 声明的启动依赖
+	Labels     map[string]string `json:"labels,omitempty"`     // 容器标签（compose 项目、应用模板等）
+	Favorite   bool              `json:"favorite,omitempty"`   // 当前用户是否收藏（容器名或 compose 项目）
 }
 
 // 镜像信息
@@ -92,79 +140,76 @@ type ImageInfo struct {
 	Created string `json:"created"`
 }
 
-// 初始化 Docker 客户端
+// 初始化 Docker 客户端（使用保存的连接地址，未保存时使用环境变量）
 func initDockerClient() error {
-	// 使用空版本字符串，让客户端自动协商 API 版本
-	// 这样可以同时兼容旧版和新版 Docker
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-		client.WithVersion(""), // 不指定版本，自动协商
-	)
+	cli, err := newDockerClientFor(currentDockerEndpoint())
 	if err != nil {
-		return fmt.Errorf("无法连接到 Docker: %v", err)
+		return err
 	}
-	dockerClient = cli
+	setDockerClient(cli)
 	return nil
 }
 
-// 读取 CPU 统计信息
-func readCPUStats() ([]uint64, error) {
-	file, err := os.Open("/proc/stat")
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("无法读取 /proc/stat")
+// 解析 /proc/stat 中的一行 CPU 统计
+func parseCPUStatLine(line string) ([]uint64, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("CPU 统计行格式错误: %s", line)
 	}
 
-	line := scanner.Text()
-	var user, nice, system, idle, iowait, irq, softirq, steal, guest, guestNice uint64
-	_, err = fmt.Sscanf(line, "cpu %d %d %d %d %d %d %d %d %d %d",
-		&user, &nice, &system, &idle, &iowait, &irq, &softirq, &steal, &guest, &guestNice)
-	if err != nil {
-		// 尝试只读取前7个字段（兼容不同系统）
-		_, err = fmt.Sscanf(line, "cpu %d %d %d %d %d %d %d",
-			&user, &nice, &system, &idle, &iowait, &irq, &softirq)
+	// 只取前 7 个字段（user nice system idle iowait irq softirq），兼容不同系统
+	values := make([]uint64, 0, 7)
+	for i := 1; i < len(fields) && i <= 7; i++ {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
 		if err != nil {
 			return nil, err
 		}
+		values = append(values, v)
 	}
-
-	return []uint64{user, nice, system, idle, iowait, irq, softirq}, nil
+	return values, nil
 }
 
-// 获取系统 CPU 使用率（使用缓存机制）
-func getCPUUsage() (float64, error) {
-	cpuStatsCache.RLock()
-	// 如果缓存存在且未过期（1秒内），直接返回
-	if cpuStatsCache.lastTime.After(time.Now().Add(-2*time.Second)) && len(cpuStatsCache.lastCPU) > 0 {
-		usage := cpuStatsCache.cpuUsage
-		cpuStatsCache.RUnlock()
-		return usage, nil
-	}
-	cpuStatsCache.RUnlock()
-
-	// 读取第一次 CPU 统计
-	cpu1, err := readCPUStats()
+// 读取 /proc/stat（返回总体统计和每个核心的统计）
+func readProcCPUStats() ([]uint64, [][]uint64, error) {
+	file, err := os.Open("/proc/stat")
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
+	defer file.Close()
 
-	// 等待 500ms
-	time.Sleep(500 * time.Millisecond)
+	var total []uint64
+	var cores [][]uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "cpu") {
+			continue
+		}
+		values, err := parseCPUStatLine(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.HasPrefix(line, "cpu ") {
+			total = values
+		} else {
+			cores = append(cores, values)
+		}
+	}
 
-	// 读取第二次 CPU 统计
-	cpu2, err := readCPUStats()
-	if err != nil {
-		return 0, err
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
 	}
+	if total == nil {
+		return nil, nil, fmt.Errorf("无法读取 /proc/stat")
+	}
+
+	return total, cores, nil
+}
 
+// 根据两次采样计算 CPU 使用率
+func calcCPUUsage(cpu1, cpu2 []uint64) float64 {
 	if len(cpu1) != len(cpu2) || len(cpu1) < 4 {
-		return 0, fmt.Errorf("CPU 统计数据不完整")
+		return 0
 	}
 
 	// 计算总 CPU 时间
@@ -178,13 +223,13 @@ func getCPUUsage() (float64, error) {
 	idle1 := cpu1[3]
 	idle2 := cpu2[3]
 
+	if total2 <= total1 || idle2 < idle1 {
+		return 0
+	}
+
 	idleDelta := idle2 - idle1
 	totalDelta := total2 - total1
 
-	if totalDelta == 0 {
-		return 0, nil
-	}
-
 	cpuUsage := 100.0 * (1.0 - float64(idleDelta)/float64(totalDelta))
 	if cpuUsage < 0 {
 		cpuUsage = 0
@@ -192,6 +237,40 @@ func getCPUUsage() (float64, error) {
 	if cpuUsage > 100 {
 		cpuUsage = 100
 	}
+	return cpuUsage
+}
+
+// 获取系统 CPU 使用率（优先使用后台采样器的缓存）
+func getCPUUsage() (float64, error) {
+	cpuStatsCache.RLock()
+	// 如果缓存存在且未过期，直接返回
+	if cpuStatsCache.lastTime.After(time.Now().Add(-2*samplerInterval)) && len(cpuStatsCache.lastCPU) > 0 {
+		usage := cpuStatsCache.cpuUsage
+		cpuStatsCache.RUnlock()
+		return usage, nil
+	}
+	cpuStatsCache.RUnlock()
+
+	// 读取第一次 CPU 统计
+	cpu1, _, err := readCPUStats()
+	if err != nil {
+		return 0, err
+	}
+
+	// 等待 500ms
+	time.Sleep(500 * time.Millisecond)
+
+	// 读取第二次 CPU 统计
+	cpu2, _, err := readCPUStats()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(cpu1) != len(cpu2) || len(cpu1) < 4 {
+		return 0, fmt.Errorf("CPU 统计数据不完整")
+	}
+
+	cpuUsage := calcCPUUsage(cpu1, cpu2)
 
 	// 更新缓存
 	cpuStatsCache.Lock()
@@ -203,8 +282,8 @@ func getCPUUsage() (float64, error) {
 	return cpuUsage, nil
 }
 
-// 获取系统内存使用率
-func getMemoryUsage() (float64, error) {
+// 读取 /proc/meminfo 计算内存使用率
+func readProcMemoryUsage() (float64, error) {
 	file, err := os.Open("/proc/meminfo")
 	if err != nil {
 		return 0, err
@@ -256,35 +335,16 @@ func getMemoryUsage() (float64, error) {
 	return memoryUsage, nil
 }
 
-// 获取磁盘使用率
-func getDiskUsage() (float64, error) {
-	cmd := exec.Command("df", "-h", "/")
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, err
-	}
-
-	lines := strings.Split(string(output), "\n")
-	if len(lines) < 2 {
-		return 0, fmt.Errorf("无法解析磁盘信息")
-	}
-
-	fields := strings.Fields(lines[1])
-	if len(fields) < 5 {
-		return 0, fmt.Errorf("磁盘信息格式错误")
-	}
-
-	usageStr := strings.TrimSuffix(fields[4], "%")
-	usage, err := strconv.ParseFloat(usageStr, 64)
-	if err != nil {
-		return 0, err
-	}
+// 系统监控 API
+func handleSystemStats(w http.ResponseWriter, r *http.Request) {
+	stats := currentSystemStats()
 
-	return usage, nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
 }
 
-// 系统监控 API
-func handleSystemStats(w http.ResponseWriter, r *http.Request) {
+// 当前系统资源（监控 API 和实时事件共用）
+func currentSystemStats() SystemStats {
 	cpu, err := getCPUUsage()
 	if err != nil {
 		cpu = 0
@@ -300,36 +360,63 @@ func handleSystemStats(w http.ResponseWriter, r *http.Request) {
 		disk = 0
 	}
 
-	stats := SystemStats{
-		CPU:    cpu,
-		Memory: memory,
-		Disk:   disk,
-		Time:   time.Now().Format("2006-01-02 15:04:05"),
+	networkRx, networkTx := getNetworkRate()
+	diskRead, diskWrite := getDiskIORate()
+	load1, load5, load15 := getLoadAverage()
+
+	return SystemStats{
+		CPU:       cpu,
+		Memory:    memory,
+		Disk:      disk,
+		NetworkRx: networkRx,
+		NetworkTx: networkTx,
+		DiskRead:  diskRead,
+		DiskWrite: diskWrite,
+		Load1:     load1,
+		Load5:     load5,
+		Load15:    load15,
+		Uptime:    getUptime(),
+		CPUCores:  runtime.NumCPU(),
+		CPUTemp:   getMaxTemperature(),
+		Time:      time.Now().Format("2006-01-02 15:04:05"),
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
 }
 
 // 获取容器列表（带缓存）
 func handleContainers(w http.ResponseWriter, r *http.Request) {
+	containerList, err := listContainersCached(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取容器列表失败: %v", err))
+		return
+	}
+
+	// 标记当前用户的收藏，favorites_first=true 时收藏的容器排在前面（节点认证请求没有用户）
+	if username := r.Header.Get("X-Username"); username != "" {
+		if favorites, err := listFavorites(username); err == nil && len(favorites) > 0 {
+			containerList = applyFavorites(containerList, favorites, r.URL.Query().Get("favorites_first") == "true")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=2") // 客户端缓存 2 秒
+	json.NewEncoder(w).Encode(containerList)
+}
+
+// 本机容器列表，缓存有效时直接返回缓存（容器列表接口和全局搜索共用）
+func listContainersCached(ctx context.Context) ([]ContainerInfo, error) {
 	// 检查缓存
 	containersCache.RLock()
-	if time.Since(containersCache.lastFetch) < cacheTTL && len(containersCache.data) > 0 {
+	if time.Since(containersCache.lastFetch) < containersCacheTTL() && len(containersCache.data) > 0 {
 		data := containersCache.data
 		containersCache.RUnlock()
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Cache-Control", "private, max-age=2") // 客户端缓存 2 秒
-		json.NewEncoder(w).Encode(data)
-		return
+		return data, nil
 	}
 	containersCache.RUnlock()
 
 	// 从 Docker API 获取
-	containers, err := dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	containers, err := getDockerClient().ContainerList(context.WithoutCancel(ctx), types.ContainerListOptions{All: true})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取容器列表失败: %v", err), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	containerList := make([]ContainerInfo, 0, len(containers)) // 预分配容量
@@ -379,14 +466,17 @@ func handleContainers(w http.ResponseWriter, r *http.Request) {
 		created := time.Unix(c.Created, 0).Format("2006-01-02 15:04:05")
 
 		containerList = append(containerList, ContainerInfo{
-			ID:      containerID,
-			Name:    name,
-			Image:   c.Image,
-			Status:  c.Status,
-			Ports:   portsStr,
-			Memory:  memory,
-			Created: created,
-			State:   c.State,
+			ID:         containerID,
+			Name:       name,
+			Image:      c.Image,
+			Status:     c.Status,
+			Ports:      portsStr,
+			Memory:     memory,
+			Created:    created,
+			State:      c.State,
+			AutoUpdate: autoUpdateEnabled(name),
+			DependsOn:  containerDepNames(name),
+			Labels:     c.Labels,
 		})
 	}
 
@@ -396,61 +486,81 @@ func handleContainers(w http.ResponseWriter, r *http.Request) {
 	containersCache.lastFetch = time.Now()
 	containersCache.Unlock()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "private, max-age=2") // 客户端缓存 2 秒
-	json.NewEncoder(w).Encode(containerList)
+	return containerList, nil
 }
 
 // 创建并运行容器 (docker run)
 func handleContainerRun(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
-	var req struct {
-		Image   string `json:"image"`
-		Name    string `json:"name"`
-		Restart string `json:"restart"`
-		Network string `json:"network"`
-		Ports   []struct {
-			Host      string `json:"host"`
-			Container string `json:"container"`
-		} `json:"ports"`
-		Envs []struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
-		} `json:"envs"`
-		Volumes []struct {
-			Host      string `json:"host"`
-			Container string `json:"container"`
-		} `json:"volumes"`
-	}
-
+	var req ContainerRunRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	if req.Image == "" {
-		http.Error(w, "镜像名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "镜像名称不能为空")
 		return
 	}
 
-	log.Printf("[Container] Creating container, image: %s, name: %s", req.Image, req.Name)
+	if !req.Force {
+		if err := checkPortConflicts(r.Context(), req.hostPorts(), ""); err != nil {
+			writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+	}
+
+	id, err := runContainer(r.Context(), &req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": id})
+}
+
+// 创建容器的参数（/api/containers/run 和应用模板部署共用）
+type ContainerRunRequest struct {
+	Image   string `json:"image"`
+	Name    string `json:"name"`
+	Restart string `json:"restart"`
+	Network string `json:"network"`
+	Ports   []struct {
+		Host      string `json:"host"`
+		Container string `json:"container"`
+	} `json:"ports"`
+	Envs []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"envs"`
+	Volumes []struct {
+		Host      string `json:"host"`
+		Container string `json:"container"`
+	} `json:"volumes"`
+	Labels map[string]string `json:"labels"`
+	Force  bool              `json:"force"` // 忽略主机端口冲突（如 SO_REUSEPORT）
+}
+
+// 拉取镜像（本地没有时）、创建并启动容器，返回容器 ID；启动失败时删除已创建的容器
+func runContainer(reqCtx context.Context, req *ContainerRunRequest) (string, error) {
+	componentLogger("container").InfoContext(reqCtx, "Creating container", "image", req.Image, "name", req.Name)
 
 	ctx := context.Background()
 
 	// 尝试拉取镜像（如果本地没有）
-	_, _, err := dockerClient.ImageInspectWithRaw(ctx, req.Image)
+	_, _, err := getDockerClient().ImageInspectWithRaw(ctx, req.Image)
 	if err != nil {
 		// 镜像不存在，尝试拉取
 		log.Printf("[Container] Image %s not found, pulling...", req.Image)
-		reader, err := dockerClient.ImagePull(ctx, req.Image, types.ImagePullOptions{})
+		reader, err := getDockerClient().ImagePull(ctx, req.Image, types.ImagePullOptions{RegistryAuth: registryAuthFor(req.Image)})
 		if err != nil {
 			log.Printf("[Container] Failed to pull image: %v", err)
-			http.Error(w, fmt.Sprintf("拉取镜像失败: %v", err), http.StatusInternalServerError)
-			return
+			return "", fmt.Errorf("拉取镜像失败: %v", err)
 		}
 		defer reader.Close()
 		// 等待拉取完成
@@ -460,7 +570,8 @@ func handleContainerRun(w http.ResponseWriter, r *http.Request) {
 
 	// 构建容器配置
 	config := &container.Config{
-		Image: req.Image,
+		Image:  req.Image,
+		Labels: req.Labels,
 	}
 
 	// 环境变量
@@ -508,66 +619,62 @@ func handleContainerRun(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 创建容器
-	resp, err := dockerClient.ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
+	resp, err := getDockerClient().ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
 	if err != nil {
-		log.Printf("[Container] Failed to create, image: %s, name: %s, error: %v", req.Image, req.Name, err)
-		http.Error(w, fmt.Sprintf("创建容器失败: %v", err), http.StatusInternalServerError)
-		return
+		componentLogger("container").ErrorContext(reqCtx, "Failed to create", "image", req.Image, "name", req.Name, "error", err)
+		return "", fmt.Errorf("创建容器失败: %v", err)
 	}
 
 	// 启动容器
-	if err := dockerClient.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		log.Printf("[Container] Failed to start, id: %s, error: %v", resp.ID, err)
+	if err := getDockerClient().ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		componentLogger("container").ErrorContext(reqCtx, "Failed to start", "id", resp.ID, "error", err)
 		// 启动失败，删除已创建的容器
-		dockerClient.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
-		http.Error(w, fmt.Sprintf("启动容器失败: %v", err), http.StatusInternalServerError)
-		return
+		getDockerClient().ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
+		return "", fmt.Errorf("启动容器失败: %v", err)
 	}
 
-	log.Printf("[Container] Created successfully, id: %s, name: %s, image: %s", resp.ID[:12], req.Name, req.Image)
+	componentLogger("container").InfoContext(reqCtx, "Created successfully", "id", resp.ID[:12], "name", req.Name, "image", req.Image)
 
 	// 清除容器列表缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": resp.ID})
+	InvalidateContainers()
+	return resp.ID, nil
 }
 
 // 创建并运行容器（流式输出）
 func handleContainerRunStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
-	var req struct {
-		Image   string `json:"image"`
-		Name    string `json:"name"`
-		Restart string `json:"restart"`
-		Network string `json:"network"`
-		Ports   []struct {
-			Host      string `json:"host"`
-			Container string `json:"container"`
-		} `json:"ports"`
-		Envs []struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
-		} `json:"envs"`
-		Volumes []struct {
-			Host      string `json:"host"`
-			Container string `json:"container"`
-		} `json:"volumes"`
-	}
+	var req ContainerRunRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	if req.Image == "" {
-		http.Error(w, "镜像名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "镜像名称不能为空")
+		return
+	}
+
+	if !req.Force {
+		if err := checkPortConflicts(r.Context(), req.hostPorts(), ""); err != nil {
+			writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+	}
+
+	// 后台任务方式：立即返回任务 ID，结果为容器 ID
+	if wantsAsync(r) {
+		target := req.Name
+		if target == "" {
+			target = req.Image
+		}
+		writeJobSubmitted(w, r, "container_run", target, true, func(ctx context.Context, out progressSink) (string, error) {
+			return runContainerWithProgress(ctx, out, &req)
+		})
 		return
 	}
 
@@ -579,44 +686,40 @@ func handleContainerRunStream(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "SSE 不支持", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "SSE 不支持")
 		return
 	}
 
-	// 发送日志的辅助函数
-	sendLog := func(msg string) {
-		fmt.Fprintf(w, "data: {\"type\":\"log\",\"message\":\"%s\"}\n\n", strings.ReplaceAll(msg, "\"", "\\\""))
-		flusher.Flush()
-	}
-
-	sendError := func(msg string) {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"%s\"}\n\n", strings.ReplaceAll(msg, "\"", "\\\""))
-		flusher.Flush()
+	stream := &sseStream{w: w, flusher: flusher}
+	id, err := runContainerWithProgress(context.WithoutCancel(r.Context()), stream, &req) // 客户端断开后继续执行
+	if err != nil {
+		stream.send("error", err.Error())
+		return
 	}
+	stream.success(id)
+}
 
-	sendSuccess := func(id string) {
-		fmt.Fprintf(w, "data: {\"type\":\"success\",\"id\":\"%s\"}\n\n", id)
-		flusher.Flush()
+// 拉取镜像（本地没有时）、创建并启动容器，每一步输出到 out，返回容器短 ID
+func runContainerWithProgress(ctx context.Context, out progressSink, req *ContainerRunRequest) (string, error) {
+	sendLog := func(msg string) {
+		out.send("log", msg)
 	}
 
-	log.Printf("[Container] Creating container (stream), image: %s, name: %s", req.Image, req.Name)
+	componentLogger("container").InfoContext(ctx, "Creating container (progress)", "image", req.Image, "name", req.Name)
 	sendLog(fmt.Sprintf("开始创建容器，镜像: %s", req.Image))
 
-	ctx := context.Background()
-
 	// 检查镜像是否存在
 	sendLog("检查本地镜像...")
-	_, _, err := dockerClient.ImageInspectWithRaw(ctx, req.Image)
+	_, _, err := getDockerClient().ImageInspectWithRaw(ctx, req.Image)
 	if err != nil {
 		// 镜像不存在，尝试拉取
 		sendLog(fmt.Sprintf("镜像 %s 不存在，开始拉取...", req.Image))
 		log.Printf("[Container] Image %s not found, pulling...", req.Image)
 		
-		reader, err := dockerClient.ImagePull(ctx, req.Image, types.ImagePullOptions{})
+		reader, err := getDockerClient().ImagePull(ctx, req.Image, types.ImagePullOptions{RegistryAuth: registryAuthFor(req.Image)})
 		if err != nil {
 			log.Printf("[Container] Failed to pull image: %v", err)
-			sendError(fmt.Sprintf("拉取镜像失败: %v", err))
-			return
+			return "", fmt.Errorf("拉取镜像失败: %v", err)
 		}
 		defer reader.Close()
 		
@@ -632,7 +735,7 @@ func handleContainerRunStream(w http.ResponseWriter, r *http.Request) {
 				if err == io.EOF {
 					break
 				}
-				continue
+				return "", fmt.Errorf("拉取镜像失败: %v", err) // 包括任务被取消
 			}
 			if pullStatus.Progress != "" {
 				sendLog(fmt.Sprintf("%s: %s %s", pullStatus.ID, pullStatus.Status, pullStatus.Progress))
@@ -649,7 +752,8 @@ func handleContainerRunStream(w http.ResponseWriter, r *http.Request) {
 	// 构建容器配置
 	sendLog("配置容器参数...")
 	config := &container.Config{
-		Image: req.Image,
+		Image:  req.Image,
+		Labels: req.Labels,
 	}
 
 	// 环境变量
@@ -702,39 +806,35 @@ func handleContainerRunStream(w http.ResponseWriter, r *http.Request) {
 
 	// 创建容器
 	sendLog("创建容器...")
-	resp, err := dockerClient.ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
+	resp, err := getDockerClient().ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
 	if err != nil {
-		log.Printf("[Container] Failed to create, image: %s, name: %s, error: %v", req.Image, req.Name, err)
-		sendError(fmt.Sprintf("创建容器失败: %v", err))
-		return
+		componentLogger("container").ErrorContext(ctx, "Failed to create", "image", req.Image, "name", req.Name, "error", err)
+		return "", fmt.Errorf("创建容器失败: %v", err)
 	}
 	sendLog(fmt.Sprintf("容器已创建，ID: %s", resp.ID[:12]))
 
 	// 启动容器
 	sendLog("启动容器...")
-	if err := dockerClient.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		log.Printf("[Container] Failed to start, id: %s, error: %v", resp.ID, err)
+	if err := getDockerClient().ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		componentLogger("container").ErrorContext(ctx, "Failed to start", "id", resp.ID, "error", err)
 		// 启动失败，删除已创建的容器
-		dockerClient.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
-		sendError(fmt.Sprintf("启动容器失败: %v", err))
-		return
+		getDockerClient().ContainerRemove(context.WithoutCancel(ctx), resp.ID, types.ContainerRemoveOptions{Force: true})
+		return "", fmt.Errorf("启动容器失败: %v", err)
 	}
 
-	log.Printf("[Container] Created successfully, id: %s, name: %s, image: %s", resp.ID[:12], req.Name, req.Image)
+	componentLogger("container").InfoContext(ctx, "Created successfully", "id", resp.ID[:12], "name", req.Name, "image", req.Image)
 	sendLog("容器启动成功！")
 
 	// 清除容器列表缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
-	sendSuccess(resp.ID[:12])
+	return resp.ID[:12], nil
 }
 
 // 执行原始 docker 命令（流式输出）
 func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -743,13 +843,13 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	cmd := strings.TrimSpace(req.Command)
 	if cmd == "" {
-		http.Error(w, "命令不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "命令不能为空")
 		return
 	}
 
@@ -760,7 +860,11 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 
 	// 安全检查：只允许 docker run 命令
 	if !strings.HasPrefix(cmd, "docker run ") && !strings.HasPrefix(cmd, "docker run\t") {
-		http.Error(w, "只支持 docker run 命令", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "只支持 docker run 命令")
+		return
+	}
+
+	if !requireDockerCLI(w, "docker run 命令模式") {
 		return
 	}
 
@@ -774,23 +878,21 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "SSE 不支持", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "SSE 不支持")
 		return
 	}
 
+	stream := &sseStream{w: w, flusher: flusher}
 	sendLog := func(msg string) {
-		fmt.Fprintf(w, "data: {\"type\":\"log\",\"message\":\"%s\"}\n\n", strings.ReplaceAll(msg, "\"", "\\\""))
-		flusher.Flush()
+		stream.send("log", msg)
 	}
 
 	sendError := func(msg string) {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"%s\"}\n\n", strings.ReplaceAll(msg, "\"", "\\\""))
-		flusher.Flush()
+		stream.send("error", msg)
 	}
 
 	sendSuccess := func(id string) {
-		fmt.Fprintf(w, "data: {\"type\":\"success\",\"id\":\"%s\"}\n\n", id)
-		flusher.Flush()
+		stream.success(id)
 	}
 
 	sendLog(fmt.Sprintf("执行命令: %s", cmd))
@@ -821,9 +923,13 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 读取 stdout
+	// 读取 stdout（stdout 和 stderr 都读完后才能调用 Wait）
 	var containerID string
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
+		defer recoverGoroutine(r.Context(), "docker run stdout")
+		defer wg.Done()
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -840,12 +946,15 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 
 	// 读取 stderr
 	go func() {
+		defer recoverGoroutine(r.Context(), "docker run stderr")
+		defer wg.Done()
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			line := scanner.Text()
 			sendLog(line)
 		}
 	}()
+	wg.Wait()
 
 	// 等待命令完成
 	if err := execCmd.Wait(); err != nil {
@@ -857,9 +966,7 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[Container] Raw command success, container ID: %s", containerID)
 
 	// 清除容器列表缓存
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
 	if containerID != "" {
 		sendSuccess(containerID)
@@ -871,51 +978,75 @@ func handleContainerRunRaw(w http.ResponseWriter, r *http.Request) {
 // 容器操作：启动/停止/重启/删除
 func handleContainerAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
 	var req struct {
-		ID     string `json:"id"`
-		Action string `json:"action"`
+		ID            string `json:"id"`
+		Action        string `json:"action"`
+		RemoveVolumes bool   `json:"remove_volumes"` // remove 时同时删除匿名卷
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
-	log.Printf("[Container] Action: %s, id: %s", req.Action, req.ID)
+	// 带 dry_run 参数的删除请求返回影响报告（?dry_run=true 只预览）
+	if dryRun, present := dryRunParam(r); present && req.Action == "remove" {
+		items, err := selectContainerRemoval(r.Context(), req.ID, req.RemoveVolumes)
+		if client.IsErrNotFound(err) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "容器不存在")
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取容器信息失败: %v", err))
+			return
+		}
+		ctx := context.WithoutCancel(r.Context())
+		report := applyImpact("container_remove", items, dryRun, func(item ImpactItem) error {
+			if item.Type == "container" {
+				return removeContainerToTrash(ctx, item.ID, r.Header.Get("X-Username"), req.RemoveVolumes)
+			}
+			return removeVolumeItem(ctx, item) // 匿名卷通常已随容器删除
+		})
+		if report.Executed {
+			InvalidateContainers()
+		}
+		writeImpactReport(w, r, report, items[0].Name)
+		return
+	}
+
+	componentLogger("container").InfoContext(r.Context(), "Action", "action", req.Action, "id", req.ID)
 
 	ctx := context.Background()
 	var err error
 
 	switch req.Action {
 	case "start":
-		err = dockerClient.ContainerStart(ctx, req.ID, types.ContainerStartOptions{})
+		err = getDockerClient().ContainerStart(ctx, req.ID, types.ContainerStartOptions{})
 	case "stop":
-		err = dockerClient.ContainerStop(ctx, req.ID, container.StopOptions{})
+		err = getDockerClient().ContainerStop(ctx, req.ID, container.StopOptions{})
 	case "restart":
-		err = dockerClient.ContainerRestart(ctx, req.ID, container.StopOptions{})
+		err = getDockerClient().ContainerRestart(ctx, req.ID, container.StopOptions{})
 	case "remove":
-		err = dockerClient.ContainerRemove(ctx, req.ID, types.ContainerRemoveOptions{Force: true})
+		// 先保存配置到回收站，可通过 /api/containers/trash/restore 恢复
+		err = removeContainerToTrash(ctx, req.ID, r.Header.Get("X-Username"), req.RemoveVolumes)
 	default:
-		http.Error(w, "不支持的操作", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "不支持的操作")
 		return
 	}
 
 	if err != nil {
-		log.Printf("[Container] Action failed, action: %s, id: %s, error: %v", req.Action, req.ID, err)
-		http.Error(w, fmt.Sprintf("操作失败: %v", err), http.StatusInternalServerError)
+		componentLogger("container").ErrorContext(r.Context(), "Action failed", "action", req.Action, "id", req.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("操作失败: %v", err))
 		return
 	}
 
-	log.Printf("[Container] Action success, action: %s, id: %s", req.Action, req.ID)
+	componentLogger("container").InfoContext(r.Context(), "Action success", "action", req.Action, "id", req.ID)
 
 	// 清除容器列表缓存，确保下次请求获取最新数据
-	containersCache.Lock()
-	containersCache.lastFetch = time.Time{}
-	containersCache.Unlock()
+	InvalidateContainers()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -925,13 +1056,15 @@ func handleContainerAction(w http.ResponseWriter, r *http.Request) {
 func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
 	containerID := r.URL.Query().Get("id")
 	if containerID == "" {
-		http.Error(w, "容器 ID 不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器 ID 不能为空")
 		return
 	}
 
-	// 检查客户端是否断开连接
+	// 检查客户端是否断开连接；服务关闭时同样结束日志流
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
+	stopOnShutdown := context.AfterFunc(serverCtx, cancel)
+	defer stopOnShutdown()
 
 	// 监听客户端断开
 	go func() {
@@ -947,9 +1080,9 @@ func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
 		Timestamps: false,
 	}
 
-	logs, err := dockerClient.ContainerLogs(ctx, containerID, options)
+	logs, err := getDockerClient().ContainerLogs(ctx, containerID, options)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取日志失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取日志失败: %v", err))
 		return
 	}
 	defer logs.Close()
@@ -963,10 +1096,15 @@ func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
 	// 创建刷新器
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "SSE 不支持", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "SSE 不支持")
 		return
 	}
 
+	streamContainerLogs(ctx, w, flusher, logs)
+}
+
+// 将 Docker 日志流转换为 SSE：每行日志为一条 data 消息，读取失败时发送 error 事件
+func streamContainerLogs(ctx context.Context, w io.Writer, flusher http.Flusher, logs io.Reader) {
 	// Docker 日志流式读取
 	// Docker 日志格式：每行前8字节是头部
 	// [STREAM_TYPE(1字节), PADDING(3字节), SIZE(4字节, 大端序)]
@@ -984,6 +1122,9 @@ func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
 		// 检查客户端是否断开
 		select {
 		case <-ctx.Done():
+			if serverCtx.Err() != nil {
+				sendShutdownEvent(w, flusher)
+			}
 			return
 		default:
 		}
@@ -991,15 +1132,19 @@ func handleContainerLogs(w http.ResponseWriter, r *http.Request) {
 		// 读取8字节头部
 		_, err := io.ReadFull(logs, header)
 		if err != nil {
+			if serverCtx.Err() != nil {
+				sendShutdownEvent(w, flusher)
+				return
+			}
 			if err == io.EOF {
 				break
 			}
 			if err == io.ErrUnexpectedEOF {
 				break
 			}
-			// 使用更小的错误消息
-			w.Write([]byte("data: [错误]\n\n"))
-			flusher.Flush()
+			// 使用命名事件，避免与日志行混在一起
+			fmt.Fprint(w, "event: stream-error\n")
+			sseWriteJSON(w, flusher, sseEvent{Type: "error", Message: fmt.Sprintf("读取日志失败: %v", err)})
 			break
 		}
 
@@ -1055,25 +1200,34 @@ func handleImages(w http.ResponseWriter, r *http.Request) {
 	// 检查是否强制刷新
 	forceRefresh := r.URL.Query().Get("refresh") == "true"
 
+	imageList, err := listImagesCached(forceRefresh)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取镜像列表失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=4") // 客户端缓存 4 秒
+	json.NewEncoder(w).Encode(imageList)
+}
+
+// 本机镜像列表（每个标签一条记录），缓存有效且不强制刷新时直接返回缓存
+func listImagesCached(forceRefresh bool) ([]ImageInfo, error) {
 	// 检查缓存（如果不是强制刷新）
 	if !forceRefresh {
 		imagesCache.RLock()
-		if time.Since(imagesCache.lastFetch) < cacheTTL*2 && len(imagesCache.data) > 0 {
+		if time.Since(imagesCache.lastFetch) < imagesCacheTTL() && len(imagesCache.data) > 0 {
 			data := imagesCache.data
 			imagesCache.RUnlock()
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("Cache-Control", "private, max-age=4") // 客户端缓存 4 秒
-			json.NewEncoder(w).Encode(data)
-			return
+			return data, nil
 		}
 		imagesCache.RUnlock()
 	}
 
 	// 从 Docker API 获取
-	images, err := dockerClient.ImageList(context.Background(), types.ImageListOptions{})
+	images, err := getDockerClient().ImageList(context.Background(), types.ImageListOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取镜像列表失败: %v", err), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	imageList := make([]ImageInfo, 0, len(images)*2) // 预分配容量（一个镜像可能有多个标签）
@@ -1140,15 +1294,13 @@ func handleImages(w http.ResponseWriter, r *http.Request) {
 	imagesCache.lastFetch = time.Now()
 	imagesCache.Unlock()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "private, max-age=4") // 客户端缓存 4 秒
-	json.NewEncoder(w).Encode(imageList)
+	return imageList, nil
 }
 
 // 构建镜像 (从 Dockerfile)
 func handleImageBuild(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1159,17 +1311,17 @@ func handleImageBuild(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	if req.ImageName == "" {
-		http.Error(w, "镜像名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "镜像名称不能为空")
 		return
 	}
 
 	if req.Dockerfile == "" {
-		http.Error(w, "Dockerfile 内容不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Dockerfile 内容不能为空")
 		return
 	}
 
@@ -1180,18 +1332,21 @@ func handleImageBuild(w http.ResponseWriter, r *http.Request) {
 	// 构建完整的镜像标签
 	imageTag := req.ImageName + ":" + req.Tag
 
-	// 创建临时目录作为构建上下文
-	tempDir, err := os.MkdirTemp("", "docker-build-")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("创建临时目录失败: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer os.RemoveAll(tempDir)
-
-	// 写入 Dockerfile
-	dockerfilePath := tempDir + "/Dockerfile"
-	if err := os.WriteFile(dockerfilePath, []byte(req.Dockerfile), 0644); err != nil {
-		http.Error(w, fmt.Sprintf("写入 Dockerfile 失败: %v", err), http.StatusInternalServerError)
+	// 后台任务方式：立即返回任务 ID，通过 /api/jobs/{id}/log 查看输出
+	if wantsAsync(r) {
+		writeJobSubmitted(w, r, "image_build", imageTag, true, func(ctx context.Context, out progressSink) (string, error) {
+			release, err := acquireOp(ctx, opBuild)
+			if err != nil {
+				return "", fmt.Errorf("操作繁忙: %v", err)
+			}
+			defer release()
+			out.send("start", fmt.Sprintf("开始构建镜像 %s", imageTag))
+			if err := buildImage(ctx, out, imageTag, req.Dockerfile); err != nil {
+				return "", fmt.Errorf("构建失败: %v", err)
+			}
+			out.send("log", fmt.Sprintf("镜像 %s 构建成功！", imageTag))
+			return imageTag, nil
+		})
 		return
 	}
 
@@ -1203,86 +1358,112 @@ func handleImageBuild(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "SSE 不支持", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "SSE 不支持")
 		return
 	}
 
+	stream := &sseStream{w: w, flusher: flusher}
+
 	// 发送开始消息
-	fmt.Fprintf(w, "data: {\"type\":\"start\",\"message\":\"开始构建镜像 %s\"}\n\n", imageTag)
-	flusher.Flush()
+	stream.send("start", fmt.Sprintf("开始构建镜像 %s", imageTag))
 
-	// 使用 docker build 命令构建（更简单可靠）
-	cmd := exec.Command("docker", "build", "-t", imageTag, tempDir)
-	
-	// 获取命令输出
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"获取输出失败: %v\"}\n\n", err)
-		flusher.Flush()
+	if err := buildImage(r.Context(), stream, imageTag, req.Dockerfile); err != nil {
+		stream.send("error", fmt.Sprintf("构建失败: %v", err))
 		return
 	}
-	
-	stderr, err := cmd.StderrPipe()
+	stream.send("success", fmt.Sprintf("镜像 %s 构建成功！", imageTag))
+}
+
+// 构建镜像，输出逐行发送到 out；没有 docker 命令时通过 Docker API 构建
+func buildImage(ctx context.Context, out progressSink, imageTag, dockerfile string) error {
+	if currentCapabilities().ImageBuild == "sdk" {
+		out.send("log", "未找到 docker 命令，通过 Docker API 构建")
+		if err := buildImageWithSDK(ctx, out, imageTag, dockerfile); err != nil {
+			return err
+		}
+		InvalidateImages()
+		return nil
+	}
+
+	// 创建临时目录作为构建上下文
+	tempDir, err := os.MkdirTemp("", "docker-build-")
 	if err != nil {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"获取错误输出失败: %v\"}\n\n", err)
-		flusher.Flush()
-		return
+		return fmt.Errorf("创建临时目录失败: %v", err)
 	}
+	defer os.RemoveAll(tempDir)
 
-	// 启动命令
-	if err := cmd.Start(); err != nil {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"启动构建失败: %v\"}\n\n", err)
-		flusher.Flush()
-		return
+	// 写入 Dockerfile
+	if err := os.WriteFile(tempDir+"/Dockerfile", []byte(dockerfile), 0644); err != nil {
+		return fmt.Errorf("写入 Dockerfile 失败: %v", err)
 	}
 
-	// 读取并发送输出
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			line := scanner.Text()
-			// 转义 JSON 特殊字符
-			line = strings.ReplaceAll(line, "\\", "\\\\")
-			line = strings.ReplaceAll(line, "\"", "\\\"")
-			line = strings.ReplaceAll(line, "\n", "\\n")
-			fmt.Fprintf(w, "data: {\"type\":\"log\",\"message\":\"%s\"}\n\n", line)
-			flusher.Flush()
-		}
-	}()
+	// 使用 docker build 命令构建（更简单可靠）
+	if err := runCommandProgress(ctx, out, tempDir, "docker", "build", "-t", imageTag, tempDir); err != nil {
+		return err
+	}
 
-	// 读取错误输出
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			line = strings.ReplaceAll(line, "\\", "\\\\")
-			line = strings.ReplaceAll(line, "\"", "\\\"")
-			line = strings.ReplaceAll(line, "\n", "\\n")
-			fmt.Fprintf(w, "data: {\"type\":\"log\",\"message\":\"%s\"}\n\n", line)
-			flusher.Flush()
-		}
-	}()
+	// 清除镜像缓存
+	InvalidateImages()
+	return nil
+}
 
-	// 等待命令完成
-	if err := cmd.Wait(); err != nil {
-		fmt.Fprintf(w, "data: {\"type\":\"error\",\"message\":\"构建失败: %v\"}\n\n", err)
-		flusher.Flush()
-		return
+// 通过 Docker API 构建镜像（构建上下文只包含 Dockerfile，与命令行构建一致），输出逐行发送到 stream
+func buildImageWithSDK(ctx context.Context, stream progressSink, imageTag, dockerfile string) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := tw.WriteHeader(&tar.Header{
+		Name:    "Dockerfile",
+		Mode:    0644,
+		Size:    int64(len(dockerfile)),
+		ModTime: time.Now(),
+	})
+	if err == nil {
+		_, err = tw.Write([]byte(dockerfile))
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("创建构建上下文失败: %v", err)
 	}
 
-	// 清除镜像缓存
-	imagesCache.Lock()
-	imagesCache.lastFetch = time.Time{}
-	imagesCache.Unlock()
+	resp, err := getDockerClient().ImageBuild(ctx, &buf, types.ImageBuildOptions{
+		Tags:       []string{imageTag},
+		Dockerfile: "Dockerfile",
+		Remove:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-	fmt.Fprintf(w, "data: {\"type\":\"success\",\"message\":\"镜像 %s 构建成功！\"}\n\n", imageTag)
-	flusher.Flush()
+	// 构建输出为 JSON 消息流，错误也在消息中返回
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if msg.Stream != "" {
+			for _, line := range strings.Split(strings.TrimRight(msg.Stream, "\n"), "\n") {
+				stream.send("log", line)
+			}
+		} else if msg.Status != "" {
+			stream.send("log", strings.TrimSpace(msg.ID+" "+msg.Status))
+		}
+	}
 }
 
+
 // 删除镜像
 func handleImageRemove(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1291,40 +1472,38 @@ func handleImageRemove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
-	log.Printf("[Image] Remove request, id: %s", req.ID)
+	componentLogger("image").InfoContext(r.Context(), "Remove request", "id", req.ID)
 
 	// 直接用传入的 ID 删除（Docker API 支持短 ID）
-	deleted, err := dockerClient.ImageRemove(context.Background(), req.ID, types.ImageRemoveOptions{})
+	deleted, err := getDockerClient().ImageRemove(context.Background(), req.ID, types.ImageRemoveOptions{})
 	if err != nil {
-		log.Printf("[Image] Remove failed, id: %s, error: %v", req.ID, err)
+		componentLogger("image").ErrorContext(r.Context(), "Remove failed", "id", req.ID, "error", err)
 		errMsg := err.Error()
 		// 友好的错误提示
 		if strings.Contains(errMsg, "is being used") || strings.Contains(errMsg, "using") {
-			http.Error(w, "删除失败: 镜像正在被容器使用，请先停止并删除相关容器", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeImageInUse, "删除失败: 镜像正在被容器使用，请先停止并删除相关容器")
 			return
 		}
 		if strings.Contains(errMsg, "has dependent child") || strings.Contains(errMsg, "image has dependent") {
-			http.Error(w, "删除失败: 镜像有子镜像依赖，请先删除依赖的镜像", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeImageHasDependents, "删除失败: 镜像有子镜像依赖，请先删除依赖的镜像")
 			return
 		}
 		if strings.Contains(errMsg, "image is referenced") {
-			http.Error(w, "删除失败: 镜像被其他镜像引用", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeImageReferenced, "删除失败: 镜像被其他镜像引用")
 			return
 		}
-		http.Error(w, fmt.Sprintf("删除失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
 		return
 	}
 
-	log.Printf("[Image] Remove success, id: %s, result: %+v", req.ID, deleted)
+	componentLogger("image").InfoContext(r.Context(), "Remove success", "id", req.ID, "deleted", len(deleted))
 
 	// 清除镜像缓存
-	imagesCache.Lock()
-	imagesCache.lastFetch = time.Time{}
-	imagesCache.Unlock()
+	InvalidateImages()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -1346,9 +1525,9 @@ type NetworkInfo struct {
 
 // 获取网络列表
 func handleNetworks(w http.ResponseWriter, r *http.Request) {
-	networks, err := dockerClient.NetworkList(context.Background(), types.NetworkListOptions{})
+	networks, err := getDockerClient().NetworkList(context.Background(), types.NetworkListOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取网络列表失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取网络列表失败: %v", err))
 		return
 	}
 
@@ -1388,7 +1567,7 @@ func handleNetworks(w http.ResponseWriter, r *http.Request) {
 // 创建网络
 func handleNetworkCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1401,12 +1580,12 @@ func handleNetworkCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "网络名称不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "网络名称不能为空")
 		return
 	}
 
@@ -1437,16 +1616,16 @@ func handleNetworkCreate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.Printf("[Network] Creating network, name: %s, driver: %s", req.Name, req.Driver)
+	componentLogger("network").InfoContext(r.Context(), "Creating network", "name", req.Name, "driver", req.Driver)
 
-	resp, err := dockerClient.NetworkCreate(context.Background(), req.Name, options)
+	resp, err := getDockerClient().NetworkCreate(context.Background(), req.Name, options)
 	if err != nil {
-		log.Printf("[Network] Create failed, name: %s, error: %v", req.Name, err)
-		http.Error(w, fmt.Sprintf("创建网络失败: %v", err), http.StatusInternalServerError)
+		componentLogger("network").ErrorContext(r.Context(), "Create failed", "name", req.Name, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建网络失败: %v", err))
 		return
 	}
 
-	log.Printf("[Network] Created successfully, name: %s, id: %s", req.Name, resp.ID[:12])
+	componentLogger("network").InfoContext(r.Context(), "Created successfully", "name", req.Name, "id", resp.ID[:12])
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": resp.ID})
@@ -1455,7 +1634,7 @@ func handleNetworkCreate(w http.ResponseWriter, r *http.Request) {
 // 删除网络
 func handleNetworkRemove(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1464,16 +1643,16 @@ func handleNetworkRemove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
-	log.Printf("[Network] Remove request, id: %s", req.ID)
+	componentLogger("network").InfoContext(r.Context(), "Remove request", "id", req.ID)
 
 	// 查找完整的网络 ID
-	networks, err := dockerClient.NetworkList(context.Background(), types.NetworkListOptions{})
+	networks, err := getDockerClient().NetworkList(context.Background(), types.NetworkListOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取网络列表失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取网络列表失败: %v", err))
 		return
 	}
 
@@ -1492,22 +1671,22 @@ func handleNetworkRemove(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if networkID == "" {
-		http.Error(w, "网络不存在", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "网络不存在")
 		return
 	}
 
-	err = dockerClient.NetworkRemove(context.Background(), networkID)
+	err = getDockerClient().NetworkRemove(context.Background(), networkID)
 	if err != nil {
-		log.Printf("[Network] Remove failed, name: %s, error: %v", networkName, err)
+		componentLogger("network").ErrorContext(r.Context(), "Remove failed", "name", networkName, "error", err)
 		if strings.Contains(err.Error(), "has active endpoints") {
-			http.Error(w, "网络正在被容器使用，请先断开连接", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "网络正在被容器使用，请先断开连接")
 			return
 		}
-		http.Error(w, fmt.Sprintf("删除网络失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除网络失败: %v", err))
 		return
 	}
 
-	log.Printf("[Network] Removed successfully, name: %s", networkName)
+	componentLogger("network").InfoContext(r.Context(), "Removed successfully", "name", networkName)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -1517,13 +1696,13 @@ func handleNetworkRemove(w http.ResponseWriter, r *http.Request) {
 func handleNetworkInspect(w http.ResponseWriter, r *http.Request) {
 	networkID := r.URL.Query().Get("id")
 	if networkID == "" {
-		http.Error(w, "网络 ID 不能为空", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "网络 ID 不能为空")
 		return
 	}
 
-	network, err := dockerClient.NetworkInspect(context.Background(), networkID, types.NetworkInspectOptions{})
+	network, err := getDockerClient().NetworkInspect(context.Background(), networkID, types.NetworkInspectOptions{})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("获取网络详情失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取网络详情失败: %v", err))
 		return
 	}
 
@@ -1565,7 +1744,7 @@ func handleNetworkInspect(w http.ResponseWriter, r *http.Request) {
 // 连接容器到网络
 func handleNetworkConnect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1576,7 +1755,7 @@ func handleNetworkConnect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
@@ -1587,9 +1766,9 @@ func handleNetworkConnect(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err := dockerClient.NetworkConnect(context.Background(), req.NetworkID, req.ContainerID, endpointConfig)
+	err := getDockerClient().NetworkConnect(context.Background(), req.NetworkID, req.ContainerID, endpointConfig)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("连接失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("连接失败: %v", err))
 		return
 	}
 
@@ -1600,7 +1779,7 @@ func handleNetworkConnect(w http.ResponseWriter, r *http.Request) {
 // 断开容器与网络的连接
 func handleNetworkDisconnect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -1611,13 +1790,13 @@ func handleNetworkDisconnect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
-	err := dockerClient.NetworkDisconnect(context.Background(), req.NetworkID, req.ContainerID, req.Force)
+	err := getDockerClient().NetworkDisconnect(context.Background(), req.NetworkID, req.ContainerID, req.Force)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("断开连接失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("断开连接失败: %v", err))
 		return
 	}
 
@@ -1625,52 +1804,96 @@ func handleNetworkDisconnect(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
-// 获取服务器 IP 地址
-func getServerIP() string {
-	// 方法1: 通过连接外部地址获取本机 IP（最准确）
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+// 本机的外网地址（IPv4 和 IPv6 各一个，未找到时为空）
+type ServerAddrs struct {
+	IPv4 string
+	IPv6 string
+}
+
+// 首选地址：优先 IPv4，IPv6-only 的服务器使用 IPv6
+func (a ServerAddrs) primary() string {
+	if a.IPv4 != "" {
+		return a.IPv4
+	}
+	return a.IPv6
+}
+
+// 所有找到的地址（先 IPv4 后 IPv6）
+func (a ServerAddrs) all() []string {
+	var ips []string
+	for _, ip := range []string{a.IPv4, a.IPv6} {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// 获取本机的 IPv4 和全局 IPv6 地址
+func getServerIPs() ServerAddrs {
+	return ServerAddrs{
+		IPv4: detectServerIP("udp4", "8.8.8.8:80", isServerIPv4),
+		IPv6: detectServerIP("udp6", "[2001:4860:4860::8888]:80", isServerIPv6),
+	}
+}
+
+func isServerIPv4(ip net.IP) bool {
+	return ip.To4() != nil && !ip.IsLoopback() && !ip.IsUnspecified()
+}
+
+// 全局单播 IPv6（排除链路本地和 ULA）
+func isServerIPv6(ip net.IP) bool {
+	return ip.To4() == nil && ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// Docker 默认网桥 (172.17.0.0/16)
+func isDockerBridgeIP(ip net.IP) bool {
+	v4 := ip.To4()
+	return v4 != nil && v4[0] == 172 && v4[1] == 17
+}
+
+// 获取指定协议族的本机 IP，usable 过滤不可用的地址
+func detectServerIP(network, probe string, usable func(net.IP) bool) string {
+	// 方法1: 通过连接外部地址获取本机 IP（最准确，UDP 不会实际发送数据）
+	conn, err := net.Dial(network, probe)
 	if err == nil {
 		defer conn.Close()
-		localAddr := conn.LocalAddr().(*net.UDPAddr)
-		return localAddr.IP.String()
+		if localAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok && usable(localAddr.IP) {
+			return localAddr.IP.String()
+		}
 	}
 
-	// 方法2: 获取第一个非回环的网络接口 IP
+	// 方法2: 从网络接口中查找，优先获取非 Docker 网桥的 IP
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
 		return ""
 	}
-
-	// 优先获取非 Docker 网桥的 IP
+	fallback := ""
 	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ip := ipnet.IP.String()
-				// 排除 Docker 默认网桥 (172.17.0.0/16)
-				if !strings.HasPrefix(ip, "172.17.") {
-					return ip
-				}
-			}
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || !usable(ipnet.IP) {
+			continue
 		}
-	}
-
-	// 如果都找不到，返回第一个非回环 IP
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				return ipnet.IP.String()
-			}
+		if !isDockerBridgeIP(ipnet.IP) {
+			return ipnet.IP.String()
+		}
+		if fallback == "" {
+			fallback = ipnet.IP.String()
 		}
 	}
+	return fallback
+}
 
-	return ""
+// 面板的访问地址（IPv6 地址加方括号）
+func panelURL(scheme, host, port string) string {
+	return scheme + "://" + net.JoinHostPort(host, port)
 }
 
 // 健康检查
 func handleHealth(w http.ResponseWriter, r *http.Request) {
-	_, err := dockerClient.Ping(context.Background())
+	_, err := getDockerClient().Ping(context.Background())
 	if err != nil {
-		http.Error(w, "Docker 连接失败", http.StatusServiceUnavailable)
+		writeError(w, http.StatusServiceUnavailable, ErrCodeServiceUnavailable, "Docker 连接失败")
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -1678,66 +1901,138 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	// 加载配置（--config 配置文件 + 环境变量）
+	initConfig()
+
 	// 初始化认证数据库
 	if err := initAuthDB(); err != nil {
 		log.Fatalf("初始化认证数据库失败: %v", err)
 	}
-
-	// 获取运行模式（master 或 worker）
-	mode := os.Getenv("MODE")
-	if mode == "" {
-		mode = ModeMaster // 默认 Master 模式
+	if err := initAuditLog(); err != nil {
+		log.Printf("警告: 初始化审计日志失败: %v", err)
+	}
+	if err := initSettings(); err != nil {
+		log.Printf("警告: 初始化面板设置失败: %v", err)
+	}
+	if err := initNotifications(); err != nil {
+		log.Printf("警告: 初始化通知渠道失败: %v", err)
+	}
+	if err := initTasks(); err != nil {
+		log.Printf("警告: 初始化定时任务失败: %v", err)
 	}
+	if err := initAutoUpdate(); err != nil {
+		log.Printf("警告: 初始化容器自动更新失败: %v", err)
+	}
+	if err := initApps(); err != nil {
+		log.Printf("警告: 初始化应用模板失败: %v", err)
+	}
+	if err := initContainerTrash(); err != nil {
+		log.Printf("警告: 初始化容器回收站失败: %v", err)
+	}
+	if err := initContainerDeps(); err != nil {
+		log.Printf("警告: 初始化容器依赖失败: %v", err)
+	}
+	if err := initFavorites(); err != nil {
+		log.Printf("警告: 初始化收藏失败: %v", err)
+	}
+	if err := initChunkedUploads(); err != nil {
+		log.Printf("警告: 初始化分片上传失败: %v", err)
+	}
+	initTerminalRecording()
+	initTerminalReaper()
+	initJobs()
+
+	// 运行模式（master 或 worker）
+	mode := appConfig.Mode
 	
 	// 初始化节点管理器
 	initNodeManager(mode)
 
+	// 初始化历史指标存储并启动后台系统采样器
+	if err := initMetricsStore(); err != nil {
+		log.Printf("警告: 初始化历史指标失败: %v", err)
+	}
+	startSystemSampler()
+
 	// 初始化 Docker 客户端
+	if err := initDockerEndpoint(); err != nil {
+		log.Printf("警告: 读取 Docker 连接设置失败，使用环境变量中的地址: %v", err)
+	}
 	if err := initDockerClient(); err != nil {
 		log.Fatalf("初始化 Docker 客户端失败: %v\n请确保 Docker 已安装并运行，且当前用户有 Docker 访问权限", err)
 	}
 
-	// 检查 Docker 连接
-	_, err := dockerClient.Ping(context.Background())
+	// 检查 Docker 连接和 API 版本兼容性（版本不兼容时继续运行，依赖 Docker 的接口返回 503）
+	compat, err := checkDockerCompat(context.Background())
+	if err != nil && fallbackDockerEndpoint() {
+		compat, err = checkDockerCompat(context.Background())
+	}
 	if err != nil {
 		log.Fatalf("无法连接到 Docker: %v\n请确保 Docker 服务正在运行", err)
 	}
+	dockerConnected.Store(true)
+	go superviseDocker() // 守护进程重启后自动重连
+	if !compat.Compatible {
+		log.Printf("警告: Docker API 版本不兼容，Docker 相关功能不可用: %s。%s", compat.Message, compat.Hint)
+	} else {
+		log.Printf("Docker API 版本: %s（守护进程 %s）", compat.ClientAPIVersion, compat.DaemonVersion)
 
-	// 获取端口（默认 9999）
-	port := os.Getenv("PORT")
-	if port == "" {
-		if mode == ModeWorker {
-			port = "10001" // Worker 默认端口
-		} else {
-			port = "9999" // Master 默认端口
+		// 启动 Docker 事件记录
+		if err := initDockerEvents(); err != nil {
+			log.Printf("警告: 初始化 Docker 事件记录失败: %v", err)
 		}
 	}
 
-	// 获取监听地址（默认 0.0.0.0，允许外网访问）
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "0.0.0.0"
+	// 检测 docker 命令和 compose 子命令（镜像构建、命令模式和 Compose 操作依赖命令行）
+	initCapabilities()
+
+	// 端口（Master 默认 9999，Worker 默认 10001）和监听地址（默认 0.0.0.0，允许外网访问）
+	port := appConfig.Port
+	host := appConfig.Host
+
+	// 创建监听器（ACME 模式监听 443 和 80，否则为 systemd 传入的 socket、unix socket 或 host:port）
+	var listener net.Listener
+	var listenTarget string
+	var acme *acmeService
+	if appConfig.tlsMode() == tlsModeACME {
+		listener, acme, listenTarget, err = listenACME(appConfig)
+	} else {
+		listener, listenTarget, err = createListener(appConfig)
+	}
+	if err != nil {
+		log.Fatalf("监听失败: %v", err)
+	}
+	unixSocket := listener.Addr().Network() == "unix"
+	if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok {
+		port = strconv.Itoa(tcpAddr.Port) // systemd 传入的 socket 或 PORT=0 时以实际端口为准
+	}
+	if unixSocket && mode == ModeWorker {
+		log.Fatalf("Worker 模式需要 Master 通过 TCP 访问，不支持监听 unix socket")
 	}
 
-	// 获取服务器 IP 地址
-	serverIP := getServerIP()
-	nodeAddress := serverIP
-	if nodeAddress == "" {
-		nodeAddress = "localhost"
+	// 获取服务器 IP 地址（监听 unix socket 时不需要）
+	var serverIPs ServerAddrs
+	if !unixSocket {
+		serverIPs = getServerIPs()
+	}
+	nodeHost := serverIPs.primary()
+	if nodeHost == "" {
+		nodeHost = "localhost"
 	}
-	nodeAddress = nodeAddress + ":" + port
+	nodeAddress := net.JoinHostPort(nodeHost, port)
+
+	// 退出时的收尾操作（Worker 向 Master 注销）
+	var onShutdown func()
 
 	// Worker 模式：向 Master 注册
 	if mode == ModeWorker {
-		masterURL := os.Getenv("MASTER_URL")
-		if masterURL == "" {
-			log.Fatalf("Worker 模式需要设置 MASTER_URL 环境变量")
-		}
+		masterURL := appConfig.MasterURL // 已在加载配置时校验
 		
 		// 生成节点 ID
 		hostname, _ := os.Hostname()
 		nodeID := fmt.Sprintf("%s-%s", hostname, port)
-		nodeName := os.Getenv("NODE_NAME")
+		slog.SetDefault(slog.Default().With("node", nodeID)) // Worker 的日志都带上节点 ID
+		nodeName := appConfig.NodeName
 		if nodeName == "" {
 			nodeName = hostname
 		}
@@ -1749,86 +2044,181 @@ func main() {
 		
 		// 启动心跳协程
 		go sendHeartbeatToMaster(masterURL, nodeID)
+		onShutdown = func() {
+			if err := deregisterFromMaster(masterURL, nodeID); err != nil {
+				log.Printf("警告: 向 Master 注销失败: %v", err)
+			}
+		}
 		log.Printf("Worker 节点已启动，Master: %s", masterURL)
 	}
 
 	// 配置 HTTP 服务器（优化内存和性能）
 	server := &http.Server{
-		Addr:           host + ":" + port,
-		ReadTimeout:    15 * time.Second,  // 读取超时
-		WriteTimeout:   0,                 // 禁用写入超时（SSE 流式响应需要长连接）
-		IdleTimeout:    120 * time.Second, // 空闲连接超时
-		MaxHeaderBytes: 1 << 20,           // 最大请求头 1MB
-		// 注意：WriteTimeout 设为 0 以支持 SSE 长连接（日志流、镜像拉取等）
+		Addr:              net.JoinHostPort(host, port),
+		Handler:           newServerHandler(),
+		ReadHeaderTimeout: 15 * time.Second,  // 读取请求头超时
+		IdleTimeout:       120 * time.Second, // 空闲连接超时
+		MaxHeaderBytes:    1 << 20,           // 最大请求头 1MB
+		// 注意：不设置 ReadTimeout/WriteTimeout，由 withRouteTimeouts 为普通接口设置超时，
+		// 流式响应（日志流、镜像构建）和大文件传输不受限制
 	}
 
 	// 认证相关路由（不需要认证）
-	http.HandleFunc("/api/auth/login", handleLogin)
-	http.HandleFunc("/api/health", handleHealth)
+	appMux.HandleFunc("/api/auth/login", handleLogin)
+	appMux.HandleFunc("/api/health", handleHealth)
+	appMux.HandleFunc("/api/health/status", handleHealthStatus) // 各依赖的状态，供监控使用
 	
 	// 需要认证的路由
-	http.HandleFunc("/api/auth/change-password", authMiddleware(handleChangePassword))
-	http.HandleFunc("/api/auth/logout", authMiddleware(handleLogout))
-	http.HandleFunc("/api/auth/me", authMiddleware(handleGetCurrentUser))
+	appMux.HandleFunc("/api/auth/change-password", authMiddleware(handleChangePassword))
+	appMux.HandleFunc("/api/auth/logout", authMiddleware(handleLogout))
+	appMux.HandleFunc("/api/auth/me", authMiddleware(handleGetCurrentUser))
+	appMux.HandleFunc("/api/auth/language", authMiddleware(handleUserLanguage)) // 错误和状态消息的语言偏好
+	appMux.HandleFunc("/api/settings", authMiddleware(handleSettings))               // 面板设置（运行时可修改，保存在数据库）
+	appMux.HandleFunc("/api/backup", authMiddleware(handleBackup))                   // 导出面板数据（数据库和编排项目）
+	appMux.HandleFunc("/api/restore", authMiddleware(handleRestore))                 // 从备份恢复面板数据
+	appMux.HandleFunc("/api/settings/config", authMiddleware(handleSettingsConfig)) // 当前生效的配置（密钥已脱敏）
+	appMux.HandleFunc("/api/settings/log-level", authMiddleware(handleSettingsLogLevel))
+	appMux.HandleFunc("/api/settings/docker", authMiddleware(handleDockerEndpoint))          // 查看或修改 Docker 连接地址
+	appMux.HandleFunc("/api/settings/docker/test", authMiddleware(handleDockerEndpointTest)) // 测试 Docker 连接地址
+
+	// 调试接口（需要 enable_debug，且始终需要登录）
+	registerDebugRoutes(appMux)
 	
 	// 设置路由（使用自定义 Handler 限制并发，需要认证）
-	http.HandleFunc("/api/system/stats", authOrNodeAuthMiddleware(handleSystemStats))
-	http.HandleFunc("/api/containers", authOrNodeAuthMiddleware(handleContainers)) // 支持用户认证或节点认证
-	http.HandleFunc("/api/containers/action", authMiddleware(handleContainerAction))
-	http.HandleFunc("/api/containers/run", authMiddleware(handleContainerRun))
-	http.HandleFunc("/api/containers/run/stream", authMiddleware(handleContainerRunStream))
-	http.HandleFunc("/api/containers/run/raw", authMiddleware(handleContainerRunRaw))
-	http.HandleFunc("/api/containers/logs", authMiddleware(handleContainerLogs)) // 日志流不限制超时
-	http.HandleFunc("/api/images", authOrNodeAuthMiddleware(handleImages)) // 支持用户认证或节点认证
-	http.HandleFunc("/api/images/remove", authMiddleware(handleImageRemove))
-	http.HandleFunc("/api/images/build", authMiddleware(handleImageBuild))
+	appMux.HandleFunc("/api/system/stats", authOrNodeAuthMiddleware(handleSystemStats))
+	appMux.HandleFunc("/api/system/network", authMiddleware(handleSystemNetwork))
+	appMux.HandleFunc("/api/system/cpu", authMiddleware(handleSystemCPU))
+	appMux.HandleFunc("/api/system/disks", authMiddleware(handleSystemDisks))
+	appMux.HandleFunc("/api/system/metrics", authMiddleware(handleSystemMetrics))
+	appMux.HandleFunc("/api/system/docker", authMiddleware(handleSystemDocker))
+	appMux.HandleFunc("/api/system/docker-compat", authMiddleware(handleDockerCompat)) // Docker API 版本兼容性
+	appMux.HandleFunc("/api/health/details", authMiddleware(handleHealthDetails)) // 各依赖的详细状态（版本、错误信息等）
+	appMux.HandleFunc("/api/system/docker-status", authMiddleware(handleDockerStatus)) // Docker 连接状态
+	appMux.HandleFunc("/api/system/capabilities", authMiddleware(handleSystemCapabilities)) // docker 命令行相关功能是否可用
+	appMux.HandleFunc("/api/system/sensors", authMiddleware(handleSystemSensors))
+	appMux.HandleFunc("/api/system/diskio", authMiddleware(handleSystemDiskIO))
+	appMux.HandleFunc("/api/system/gpu", authMiddleware(handleSystemGPU))
+	appMux.HandleFunc("/api/system/summary", authMiddleware(handleSystemSummary))
+	appMux.HandleFunc("/api/system/ports", authMiddleware(handleSystemPorts)) // 主机端口占用
+	appMux.HandleFunc("/api/events/recent", authMiddleware(handleRecentEvents))
+	appMux.HandleFunc("/api/notifications/channels", authMiddleware(handleNotifyChannels))
+	appMux.HandleFunc("/api/notifications/channels/create", authMiddleware(handleNotifyChannelSave))
+	appMux.HandleFunc("/api/notifications/channels/update", authMiddleware(handleNotifyChannelSave))
+	appMux.HandleFunc("/api/notifications/channels/delete", authMiddleware(handleNotifyChannelDelete))
+	appMux.HandleFunc("/api/notifications/channels/test", authMiddleware(handleNotifyChannelTest)) // 发送测试通知
+	appMux.HandleFunc("/api/notifications/deliveries", authMiddleware(handleNotifyDeliveries))    // 发送记录
+	appMux.HandleFunc("/api/tasks", authMiddleware(handleTasks)) // 定时任务
+	appMux.HandleFunc("/api/tasks/create", authMiddleware(handleTaskSave))
+	appMux.HandleFunc("/api/tasks/update", authMiddleware(handleTaskSave))
+	appMux.HandleFunc("/api/tasks/delete", authMiddleware(handleTaskDelete))
+	appMux.HandleFunc("/api/tasks/run", authMiddleware(handleTaskRun))   // 立即执行
+	appMux.HandleFunc("/api/tasks/runs", authMiddleware(handleTaskRuns)) // 执行记录
+	appMux.HandleFunc("/api/jobs", authMiddleware(handleJobs)) // 后台任务（?async=true 提交的耗时操作）
+	appMux.HandleFunc("/api/jobs/", authMiddleware(handleJob)) // {id}、{id}/log、{id}/cancel
+	appMux.HandleFunc("/api/auto-update", authMiddleware(handleAutoUpdate)) // 容器自动更新
+	appMux.HandleFunc("/api/auto-update/settings", authMiddleware(handleAutoUpdateSettings))
+	appMux.HandleFunc("/api/auto-update/container", authMiddleware(handleAutoUpdateContainer))
+	appMux.HandleFunc("/api/auto-update/check", authMiddleware(handleAutoUpdateCheck))
+	appMux.HandleFunc("/api/auto-update/history", authMiddleware(handleAutoUpdateHistory))
+	appMux.HandleFunc("/api/apps", authMiddleware(handleApps)) // 应用模板
+	appMux.HandleFunc("/api/apps/create", authMiddleware(handleAppTemplateSave))
+	appMux.HandleFunc("/api/apps/update", authMiddleware(handleAppTemplateSave))
+	appMux.HandleFunc("/api/apps/delete", authMiddleware(handleAppTemplateDelete))
+	appMux.HandleFunc("/api/apps/deploy", authMiddleware(handleAppDeploy))
+	appMux.HandleFunc("/api/apps/upgrade", authMiddleware(handleAppUpgrade)) // 拉取新标签并重建
+	appMux.HandleFunc("/api/containers", authOrNodeAuthMiddleware(handleContainers)) // 支持用户认证或节点认证
+	appMux.HandleFunc("/api/containers/action", authMiddleware(handleContainerAction))
+	appMux.HandleFunc("/api/containers/run", authMiddleware(handleContainerRun))
+	appMux.HandleFunc("/api/containers/run/stream", authMiddleware(handleContainerRunStream))
+	appMux.HandleFunc("/api/containers/run/raw", authMiddleware(handleContainerRunRaw))
+	appMux.HandleFunc("/api/containers/logs", authMiddleware(withOpLimit(opLogs, handleContainerLogs))) // 日志流不限制超时
+	appMux.HandleFunc("/api/images", authOrNodeAuthMiddleware(handleImages)) // 支持用户认证或节点认证
+	appMux.HandleFunc("/api/images/remove", authMiddleware(handleImageRemove))
+	appMux.HandleFunc("/api/images/prune", authMiddleware(handleImagePrune)) // ?dry_run=true 只预览
+	appMux.HandleFunc("/api/images/build", authMiddleware(withOpLimit(opBuild, handleImageBuild)))
+
+	// 资源清单导出（Master 调用 Worker 时使用节点认证）
+	appMux.HandleFunc("/api/export/inventory", authOrNodeAuthMiddleware(handleExportInventory))
+
+	// 全局搜索（容器、镜像、网络、compose 项目、节点）
+	appMux.HandleFunc("/api/search", authMiddleware(handleSearch))
+
+	// 收藏（每个用户独立）
+	appMux.HandleFunc("/api/favorites", authMiddleware(handleFavorites))
+	appMux.HandleFunc("/api/favorites/toggle", authMiddleware(handleFavoriteToggle))
 	
 	// 网络管理 API
-	http.HandleFunc("/api/networks", authMiddleware(handleNetworks))
-	http.HandleFunc("/api/networks/create", authMiddleware(handleNetworkCreate))
-	http.HandleFunc("/api/networks/remove", authMiddleware(handleNetworkRemove))
-	http.HandleFunc("/api/networks/inspect", authMiddleware(handleNetworkInspect))
-	http.HandleFunc("/api/networks/connect", authMiddleware(handleNetworkConnect))
-	http.HandleFunc("/api/networks/disconnect", authMiddleware(handleNetworkDisconnect))
+	appMux.HandleFunc("/api/networks", authMiddleware(handleNetworks))
+	appMux.HandleFunc("/api/networks/create", authMiddleware(handleNetworkCreate))
+	appMux.HandleFunc("/api/networks/remove", authMiddleware(handleNetworkRemove))
+	appMux.HandleFunc("/api/networks/prune", authMiddleware(handleNetworkPrune))
+	appMux.HandleFunc("/api/volumes/prune", authMiddleware(handleVolumePrune))
+	appMux.HandleFunc("/api/networks/inspect", authMiddleware(handleNetworkInspect))
+	appMux.HandleFunc("/api/networks/connect", authMiddleware(handleNetworkConnect))
+	appMux.HandleFunc("/api/networks/disconnect", authMiddleware(handleNetworkDisconnect))
 	
 	// 容器终端和文件管理 API
-	http.HandleFunc("/api/containers/exec", authMiddleware(handleContainerExec))
-	http.HandleFunc("/api/containers/terminal/ws", handleContainerTerminalWS) // WebSocket 不用 authMiddleware，在连接时验证
-	http.HandleFunc("/api/containers/files", authMiddleware(handleContainerFilesList))
-	http.HandleFunc("/api/containers/files/mkdir", authMiddleware(handleContainerFileMkdir))
-	http.HandleFunc("/api/containers/files/delete", authMiddleware(handleContainerFileDelete))
-	http.HandleFunc("/api/containers/files/upload", authMiddleware(handleContainerFileUpload))
-	http.HandleFunc("/api/containers/files/download", authMiddleware(handleContainerFileDownload))
-	http.HandleFunc("/api/containers/files/read", authMiddleware(handleContainerFileRead))
-	http.HandleFunc("/api/containers/files/write", authMiddleware(handleContainerFileWrite))
-	http.HandleFunc("/api/containers/inspect", authMiddleware(handleContainerInspect))
-	http.HandleFunc("/api/containers/update", authMiddleware(handleContainerUpdate))
-	http.HandleFunc("/api/containers/rename", authMiddleware(handleContainerRename))
-	http.HandleFunc("/api/containers/recreate", authMiddleware(handleContainerRecreate))
-	http.HandleFunc("/api/containers/stats", authMiddleware(handleContainerStats))
+	appMux.HandleFunc("/api/containers/exec", authMiddleware(withOpLimit(opExec, handleContainerExec)))
+	appMux.HandleFunc("/api/containers/terminal/ws", authMiddleware(withOpLimit(opExec, handleContainerTerminalWS))) // WebSocket 握手时携带 token Cookie
+	appMux.HandleFunc("/api/ws", authMiddleware(handleEventBusWS))                    // 实时事件（按主题订阅）
+	appMux.HandleFunc("/api/host/terminal", authMiddleware(handleHostTerminalWS))  // 主机终端，需 ENABLE_HOST_TERMINAL=true
+	appMux.HandleFunc("/api/host/files", authMiddleware(handleHostFilesList))            // 主机文件浏览，限定在 HOST_FILES_ROOTS 内
+	appMux.HandleFunc("/api/host/files/mkdir", authMiddleware(handleHostFileMkdir))
+	appMux.HandleFunc("/api/host/files/download", authMiddleware(handleHostFileDownload))
+	appMux.HandleFunc("/api/terminal/sessions", authMiddleware(handleTerminalSessions))  // 终端会话录像，需 TERMINAL_RECORDING
+	appMux.HandleFunc("/api/terminal/active", authMiddleware(handleTerminalActive))
+	appMux.HandleFunc("/api/terminal/kill", authMiddleware(handleTerminalKill))
+	appMux.HandleFunc("/api/containers/files", authMiddleware(withOpLimit(opFiles, handleContainerFilesList)))
+	appMux.HandleFunc("/api/containers/files/mkdir", authMiddleware(withOpLimit(opFiles, handleContainerFileMkdir)))
+	appMux.HandleFunc("/api/containers/files/delete", authMiddleware(withOpLimit(opFiles, handleContainerFileDelete)))
+	appMux.HandleFunc("/api/containers/files/upload", authMiddleware(withOpLimit(opFiles, handleContainerFileUpload)))
+	appMux.HandleFunc("/api/containers/files/download", authMiddleware(withOpLimit(opFiles, handleContainerFileDownload)))
+	appMux.HandleFunc("/api/containers/files/read", authMiddleware(withOpLimit(opFiles, handleContainerFileRead)))
+	appMux.HandleFunc("/api/containers/files/write", authMiddleware(withOpLimit(opFiles, handleContainerFileWrite)))
+	appMux.HandleFunc("/api/containers/files/rename", authMiddleware(withOpLimit(opFiles, handleContainerFileRename)))
+	appMux.HandleFunc("/api/containers/files/copy-path", authMiddleware(withOpLimit(opFiles, handleContainerFileCopy)))
+	appMux.HandleFunc("/api/containers/files/chmod", authMiddleware(withOpLimit(opFiles, handleContainerFileChmod)))
+	appMux.HandleFunc("/api/containers/files/chown", authMiddleware(withOpLimit(opFiles, handleContainerFileChown)))
+	appMux.HandleFunc("/api/containers/files/search", authMiddleware(withOpLimit(opFiles, handleContainerFileSearch)))
+	appMux.HandleFunc("/api/containers/files/extract", authMiddleware(withOpLimit(opFiles, handleContainerFileExtract)))
+	appMux.HandleFunc("/api/uploads/", authMiddleware(handleUploads)) // 分片上传：init、{id}、{id}/chunk、{id}/complete
+	appMux.HandleFunc("/api/containers/inspect", authMiddleware(handleContainerInspect))
+	appMux.HandleFunc("/api/containers/update", authMiddleware(handleContainerUpdate))
+	appMux.HandleFunc("/api/containers/rename", authMiddleware(handleContainerRename))
+	appMux.HandleFunc("/api/containers/recreate", authMiddleware(handleContainerRecreate))
+	appMux.HandleFunc("/api/containers/trash", authMiddleware(handleContainerTrash)) // 回收站（已删除容器的配置）
+	appMux.HandleFunc("/api/containers/trash/restore", authMiddleware(handleContainerTrashRestore))
+	appMux.HandleFunc("/api/containers/trash/delete", authMiddleware(handleContainerTrashDelete))
+	appMux.HandleFunc("/api/containers/deps", authMiddleware(handleContainerDeps))                    // 容器启动依赖
+	appMux.HandleFunc("/api/containers/start-with-deps", authMiddleware(handleContainerStartWithDeps)) // 按依赖顺序启动
+	appMux.HandleFunc("/api/containers/stats", authMiddleware(withOpLimit(opStats, handleContainerStats)))
+	appMux.HandleFunc("/api/containers/stats/all", authMiddleware(withOpLimit(opStats, handleContainerStatsAll)))
+	appMux.HandleFunc("/api/system/top-containers", authMiddleware(withOpLimit(opStats, handleTopContainers)))
 	
 	// Compose 管理 API
 	initCompose()
-	http.HandleFunc("/api/compose/list", authMiddleware(handleComposeList))
-	http.HandleFunc("/api/compose/create", authMiddleware(handleComposeCreate))
-	http.HandleFunc("/api/compose/file", authMiddleware(handleComposeGetFile))
-	http.HandleFunc("/api/compose/save", authMiddleware(handleComposeSaveFile))
-	http.HandleFunc("/api/compose/action", authMiddleware(handleComposeAction))
-	http.HandleFunc("/api/compose/status", authMiddleware(handleComposeStatus))
-	http.HandleFunc("/api/compose/delete", authMiddleware(handleComposeDelete))
+	appMux.HandleFunc("/api/compose/list", authMiddleware(handleComposeList))
+	appMux.HandleFunc("/api/compose/create", authMiddleware(handleComposeCreate))
+	appMux.HandleFunc("/api/compose/file", authMiddleware(handleComposeGetFile))
+	appMux.HandleFunc("/api/compose/save", authMiddleware(handleComposeSaveFile))
+	appMux.HandleFunc("/api/compose/action", authMiddleware(handleComposeAction))
+	appMux.HandleFunc("/api/compose/status", authMiddleware(handleComposeStatus))
+	appMux.HandleFunc("/api/compose/delete", authMiddleware(handleComposeDelete))
 
 	// 多节点管理 API（仅 Master 模式）
 	if mode == ModeMaster {
-		http.HandleFunc("/api/nodes", authMiddleware(handleNodesList)) // Web UI 访问需要用户认证
-		http.HandleFunc("/api/nodes/register", nodeAuthMiddleware(handleNodeRegister)) // Worker 注册需要节点认证
-		http.HandleFunc("/api/nodes/heartbeat", nodeAuthMiddleware(handleNodeHeartbeat)) // Worker 心跳需要节点认证
-		http.HandleFunc("/api/containers/schedule", authMiddleware(handleContainerSchedule)) // 跨节点调度需要用户认证
-		http.HandleFunc("/api/containers/all", authMiddleware(handleAllContainers))            // 获取所有节点的容器需要用户认证
+		appMux.HandleFunc("/api/nodes", authMiddleware(handleNodesList)) // Web UI 访问需要用户认证
+		appMux.HandleFunc("/api/nodes/settings", authMiddleware(handleNodeSettings))            // 修改节点标签和容器上限
+		appMux.HandleFunc("/api/nodes/register", nodeAuthMiddleware(handleNodeRegister)) // Worker 注册需要节点认证
+		appMux.HandleFunc("/api/nodes/heartbeat", nodeAuthMiddleware(handleNodeHeartbeat)) // Worker 心跳需要节点认证
+		appMux.HandleFunc("/api/nodes/deregister", nodeAuthMiddleware(handleNodeDeregister)) // Worker 退出时注销
+		appMux.HandleFunc("/api/containers/schedule", authMiddleware(handleContainerSchedule)) // 跨节点调度需要用户认证
+		appMux.HandleFunc("/api/containers/all", authMiddleware(handleAllContainers))            // 获取所有节点的容器需要用户认证
 	}
 	
 	// Worker 节点：容器创建 API（供 Master 调用，需要节点认证）
 	if mode == ModeWorker {
-		http.HandleFunc("/api/containers/create", nodeAuthMiddleware(handleContainerCreate))
+		appMux.HandleFunc("/api/containers/create", nodeAuthMiddleware(handleContainerCreate))
 	}
 
 	// 静态文件服务（处理所有其他路径）
@@ -1837,34 +2227,31 @@ func main() {
 	if err != nil {
 		log.Fatalf("无法加载静态文件: %v", err)
 	}
-	fileServer := http.FileServer(http.FS(staticFS))
-
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// 排除 API 路径（虽然正常不会走到这里，但作为兜底）
-		if strings.HasPrefix(r.URL.Path, "/api/") {
-			http.NotFound(w, r)
-			return
-		}
-
-		// 兼容 /static/ 前缀的请求
-		if strings.HasPrefix(r.URL.Path, "/static/") {
-			r.URL.Path = strings.TrimPrefix(r.URL.Path, "/static")
-		}
-
-		// 如果是根路径，http.FileServer 会自动寻找 index.html
-		// 但为了确保 SPA 路由（如果有）或明确行为，我们可以显式处理
-		// 这里直接交给 fileServer 处理即可
-		fileServer.ServeHTTP(w, r)
-	})
+	staticHandler, err := newStaticHandler(staticFS)
+	if err != nil {
+		log.Fatalf("无法加载静态文件: %v", err)
+	}
+	appMux.Handle("/", staticHandler) // ETag 和缓存控制，index.html 中的 js/css 带版本号
 
 	// 启动服务器
 	log.Printf("容器运维面板启动成功！")
-	log.Printf("监听地址: %s", server.Addr)
-	log.Printf("本地访问: http://localhost:%s", port)
-	if serverIP != "" {
-		log.Printf("外网访问: http://%s:%s", serverIP, port)
-	} else {
-		log.Printf("外网访问: http://<服务器IP>:%s", port)
+	log.Printf("监听地址: %s", listenTarget)
+	if acme != nil {
+		for _, domain := range appConfig.acmeDomains() {
+			log.Printf("外网访问: https://%s", domain)
+		}
+	} else if !unixSocket {
+		scheme := "http"
+		if serverUsesTLS() {
+			scheme = "https"
+		}
+		log.Printf("本地访问: %s", panelURL(scheme, "localhost", port))
+		for _, ip := range serverIPs.all() {
+			log.Printf("外网访问: %s", panelURL(scheme, ip, port))
+		}
+		if len(serverIPs.all()) == 0 {
+			log.Printf("外网访问: %s://<服务器IP>:%s", scheme, port)
+		}
 	}
 	if mode == ModeMaster {
 		log.Printf("Master 节点: 管理所有 Worker 节点")
@@ -1878,8 +2265,13 @@ func main() {
 	// 设置 GC 目标百分比（降低内存占用）
 	debug.SetGCPercent(100) // 默认 100，可以设置为更激进的值
 	
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
-	}
+	go func() {
+		if err := serveHTTP(server, listener, appConfig, acme); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+
+	// 收到 SIGINT/SIGTERM 后优雅关闭
+	waitForShutdown(server, onShutdown)
 }
 
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 历史指标保留策略
+const (
+	metricsRawRetention  = 24 * time.Hour      // 原始数据保留 24 小时
+	metricsAggRetention  = 30 * 24 * time.Hour // 10 分钟聚合数据保留 30 天
+	metricsAggBucket     = 600                 // 聚合粒度（秒）
+	metricsMaxPoints     = 2000                // 单次查询最大点数
+	metricsFlushInterval = 30 * time.Second    // 批量写入间隔
+)
+
+// 一次指标采样
+type MetricSample struct {
+	Time      int64
+	CPU       float64
+	Memory    float64
+	Disk      float64
+	NetworkRx float64
+	NetworkTx float64
+}
+
+// 历史指标记录器
+var metricsRecorder struct {
+	interval   time.Duration
+	lastRecord time.Time
+	ch         chan MetricSample
+}
+
+// 初始化历史指标存储（metrics_interval 为采样间隔秒数，默认 60，设置为 0 关闭）
+func initMetricsStore() error {
+	interval := appConfig.MetricsInterval
+	if interval == 0 {
+		log.Printf("历史指标记录已关闭")
+		return nil
+	}
+
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS metrics_raw (
+		ts INTEGER PRIMARY KEY,
+		cpu REAL, memory REAL, disk REAL, net_rx REAL, net_tx REAL
+	);
+	CREATE TABLE IF NOT EXISTS metrics_10m (
+		ts INTEGER PRIMARY KEY,
+		cpu REAL, memory REAL, disk REAL, net_rx REAL, net_tx REAL
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建指标表失败: %v", err)
+	}
+
+	metricsRecorder.interval = time.Duration(interval) * time.Second
+	metricsRecorder.ch = make(chan MetricSample, 256)
+	backgroundTasks.Add(1)
+	go runMetricsWriter()
+
+	log.Printf("历史指标记录已启用，间隔: %v", metricsRecorder.interval)
+	return nil
+}
+
+// 记录一次采样（由后台采样器调用，非阻塞）
+func recordMetrics() {
+	if metricsRecorder.ch == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(metricsRecorder.lastRecord) < metricsRecorder.interval {
+		return
+	}
+	metricsRecorder.lastRecord = now
+
+	cpu, _ := getCPUUsage()
+	memory, _ := getMemoryUsage()
+	disk, _ := getDiskUsage()
+	rx, tx := getNetworkRate()
+
+	sample := MetricSample{
+		Time:      now.Unix(),
+		CPU:       cpu,
+		Memory:    memory,
+		Disk:      disk,
+		NetworkRx: rx,
+		NetworkTx: tx,
+	}
+
+	// 写入队列已满时丢弃本次采样，避免阻塞采样器
+	select {
+	case metricsRecorder.ch <- sample:
+	default:
+		log.Printf("[Metrics] Write queue full, sample dropped")
+	}
+}
+
+// 批量写入、降采样和清理（独立协程）
+func runMetricsWriter() {
+	defer backgroundTasks.Done()
+
+	ticker := time.NewTicker(metricsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]MetricSample, 0, 64)
+	for {
+		select {
+		case <-serverCtx.Done():
+			// 退出前写入尚未落盘的采样
+			if len(batch) > 0 {
+				if err := writeMetricsBatch(batch); err != nil {
+					log.Printf("[Metrics] Write failed: %v", err)
+				}
+			}
+			return
+		case s := <-metricsRecorder.ch:
+			batch = append(batch, s)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				if err := writeMetricsBatch(batch); err != nil {
+					log.Printf("[Metrics] Write failed: %v", err)
+				}
+				batch = batch[:0]
+			}
+			if err := compactMetrics(); err != nil {
+				log.Printf("[Metrics] Compact failed: %v", err)
+			}
+		}
+	}
+}
+
+// 批量写入原始数据
+func writeMetricsBatch(batch []MetricSample) error {
+	tx, err := authDB.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO metrics_raw (ts, cpu, memory, disk, net_rx, net_tx) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range batch {
+		if _, err := stmt.Exec(s.Time, s.CPU, s.Memory, s.Disk, s.NetworkRx, s.NetworkTx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 将已完成的 10 分钟区间聚合到 metrics_10m，并清理过期数据
+func compactMetrics() error {
+	now := time.Now().Unix()
+	currentBucket := now / metricsAggBucket * metricsAggBucket
+
+	_, err := authDB.Exec(`
+		INSERT OR REPLACE INTO metrics_10m (ts, cpu, memory, disk, net_rx, net_tx)
+		SELECT (ts / ?) * ?, AVG(cpu), AVG(memory), AVG(disk), AVG(net_rx), AVG(net_tx)
+		FROM metrics_raw
+		WHERE ts >= ? AND ts < ?
+		GROUP BY ts / ?`,
+		metricsAggBucket, metricsAggBucket, currentBucket-2*metricsAggBucket, currentBucket, metricsAggBucket,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := authDB.Exec("DELETE FROM metrics_raw WHERE ts < ?", now-int64(metricsRawRetention.Seconds())); err != nil {
+		return err
+	}
+	_, err = authDB.Exec("DELETE FROM metrics_10m WHERE ts < ?", now-int64(metricsAggRetention.Seconds()))
+	return err
+}
+
+// 解析时间范围（支持 Go duration 格式以及 "7d" 这样的天数）
+func parseMetricsRange(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// 历史指标查询 API（?range=6h&step=60）
+func handleSystemMetrics(w http.ResponseWriter, r *http.Request) {
+	if metricsRecorder.ch == nil {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeServiceUnavailable, "历史指标记录未启用")
+		return
+	}
+
+	rangeStr := r.URL.Query().Get("range")
+	if rangeStr == "" {
+		rangeStr = "1h"
+	}
+	rng, err := parseMetricsRange(rangeStr)
+	if err != nil || rng <= 0 || rng > metricsAggRetention {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "range 参数无效（最大 30d）")
+		return
+	}
+
+	step := int64(60)
+	if v := r.URL.Query().Get("step"); v != "" {
+		step, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || step <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "step 参数无效")
+			return
+		}
+	}
+
+	// 超过 24 小时使用聚合表，步长不小于聚合粒度
+	table := "metrics_raw"
+	if rng > metricsRawRetention {
+		table = "metrics_10m"
+		if step < metricsAggBucket {
+			step = metricsAggBucket
+		}
+	}
+
+	// 限制点数
+	rangeSec := int64(rng.Seconds())
+	if rangeSec/step > metricsMaxPoints {
+		step = (rangeSec + metricsMaxPoints - 1) / metricsMaxPoints
+	}
+	// 聚合表的步长取聚合粒度的整数倍，否则各点包含的聚合桶数量不一，部分桶会重复计入
+	if table == "metrics_10m" {
+		step = (step + metricsAggBucket - 1) / metricsAggBucket * metricsAggBucket
+	}
+
+	end := time.Now().Unix() / step * step
+	start := end - rangeSec/step*step
+
+	rows, err := authDB.Query(fmt.Sprintf(`
+		SELECT (ts / ?) * ?, AVG(cpu), AVG(memory), AVG(disk), AVG(net_rx), AVG(net_tx)
+		FROM %s
+		WHERE ts >= ? AND ts < ?
+		GROUP BY ts / ?`, table),
+		step, step, start, end+step, step,
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询指标失败: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	buckets := make(map[int64][5]float64)
+	for rows.Next() {
+		var ts int64
+		var v [5]float64
+		if err := rows.Scan(&ts, &v[0], &v[1], &v[2], &v[3], &v[4]); err != nil {
+			continue
+		}
+		buckets[ts] = v
+	}
+
+	// 按步长对齐，缺失的点填充 null
+	n := (end-start)/step + 1
+	timestamps := make([]int64, 0, n)
+	series := make([][]*float64, 5)
+	for i := range series {
+		series[i] = make([]*float64, 0, n)
+	}
+	for ts := start; ts <= end; ts += step {
+		timestamps = append(timestamps, ts)
+		v, ok := buckets[ts]
+		for i := range series {
+			if ok {
+				val := v[i]
+				series[i] = append(series[i], &val)
+			} else {
+				series[i] = append(series[i], nil)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"step":       step,
+		"timestamps": timestamps,
+		"cpu":        series[0],
+		"memory":     series[1],
+		"disk":       series[2],
+		"network_rx": series[3],
+		"network_tx": series[4],
+	})
+}
@@ -2,9 +2,10 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -39,6 +40,22 @@ type NodeInfo struct {
 	Containers  int       `json:"containers"`  // 容器数量
 	LastSeen    time.Time `json:"last_seen"`   // 最后心跳时间
 	Labels      map[string]string `json:"labels"` // 节点标签
+	MaxContainers int     `json:"max_containers"` // 最大容器数（0 表示不限制）
+	Capacity    string    `json:"capacity"`    // 容量显示，如 "3/5"
+}
+
+// 更新容量显示（调用方需持有写锁）
+func (n *NodeInfo) refreshCapacity() {
+	if n.MaxContainers > 0 {
+		n.Capacity = fmt.Sprintf("%d/%d", n.Containers, n.MaxContainers)
+	} else {
+		n.Capacity = fmt.Sprintf("%d/-", n.Containers)
+	}
+}
+
+// 节点是否已达容器上限（调用方需持有读锁）
+func (n *NodeInfo) atCapacity() bool {
+	return n.MaxContainers > 0 && n.Containers >= n.MaxContainers
 }
 
 // 节点管理器（Master 节点使用）
@@ -58,11 +75,15 @@ func initNodeManager(mode string) {
 	}
 	
 	if mode == ModeMaster {
+		if err := initNodeSettingsDB(); err != nil {
+			componentLogger("node").Warn("初始化节点设置失败", "error", err)
+		}
+
 		// Master 节点：启动节点管理服务
 		go nodeManager.startHealthCheck()
-		log.Printf("节点管理器已启动 (Master 模式)")
+		componentLogger("node").Info("节点管理器已启动", "mode", ModeMaster)
 	} else {
-		log.Printf("节点管理器已启动 (Worker 模式)")
+		componentLogger("node").Info("节点管理器已启动", "mode", ModeWorker)
 	}
 }
 
@@ -71,11 +92,27 @@ func (nm *NodeManager) RegisterNode(node *NodeInfo) error {
 	nm.Lock()
 	defer nm.Unlock()
 	
+	previous := ""
+	if old, exists := nm.nodes[node.ID]; exists {
+		previous = old.Status
+	}
 	node.LastSeen = time.Now()
 	node.Status = NodeStatusOnline
+
+	// 应用持久化的节点设置（标签和容器上限）
+	if settings, err := loadNodeSettings(node.ID); err == nil && settings != nil {
+		if len(settings.Labels) > 0 {
+			node.Labels = settings.Labels
+		}
+		node.MaxContainers = settings.MaxContainers
+	}
+	node.refreshCapacity()
 	nm.nodes[node.ID] = node
+	if previous != NodeStatusOnline {
+		publishNodeStatus(node, previous)
+	}
 	
-	log.Printf("节点已注册: %s (%s) - %s", node.Name, node.ID, node.Address)
+	componentLogger("node").Info("节点已注册", "name", node.Name, "node_id", node.ID, "address", node.Address)
 	return nil
 }
 
@@ -85,8 +122,12 @@ func (nm *NodeManager) UpdateNodeStatus(nodeID string, status string) {
 	defer nm.Unlock()
 	
 	if node, exists := nm.nodes[nodeID]; exists {
+		previous := node.Status
 		node.Status = status
 		node.LastSeen = time.Now()
+		if previous != status {
+			publishNodeStatus(node, previous)
+		}
 	}
 }
 
@@ -101,6 +142,21 @@ func (nm *NodeManager) UpdateNodeResources(nodeID string, cpu, memory, disk floa
 		node.Disk = disk
 		node.Containers = containers
 		node.LastSeen = time.Now()
+		node.refreshCapacity()
+	}
+}
+
+// 更新节点设置（标签和容器上限）
+func (nm *NodeManager) UpdateNodeSettings(nodeID string, labels map[string]string, maxContainers int) {
+	nm.Lock()
+	defer nm.Unlock()
+
+	if node, exists := nm.nodes[nodeID]; exists {
+		if labels != nil {
+			node.Labels = labels
+		}
+		node.MaxContainers = maxContainers
+		node.refreshCapacity()
 	}
 }
 
@@ -130,16 +186,25 @@ func (nm *NodeManager) SelectBestNode() (*NodeInfo, error) {
 	nm.RLock()
 	defer nm.RUnlock()
 	
+	logger := componentLogger("scheduler")
 	var bestNode *NodeInfo
 	minLoad := 100.0
 	
 	for _, node := range nm.nodes {
 		if node.Status != NodeStatusOnline {
+			logger.Debug("跳过离线节点", "node_id", node.ID, "status", node.Status)
+			continue
+		}
+
+		// 跳过已达容器上限的节点
+		if node.atCapacity() {
+			logger.Debug("跳过已达容器上限的节点", "node_id", node.ID, "containers", node.Containers, "max_containers", node.MaxContainers)
 			continue
 		}
 		
 		// 简单的负载计算：CPU + Memory
 		load := (node.CPU + node.Memory) / 2
+		logger.Debug("候选节点", "node_id", node.ID, "cpu", node.CPU, "memory", node.Memory, "load", load)
 		if load < minLoad {
 			minLoad = load
 			bestNode = node
@@ -149,6 +214,7 @@ func (nm *NodeManager) SelectBestNode() (*NodeInfo, error) {
 	if bestNode == nil {
 		return nil, fmt.Errorf("没有可用的在线节点")
 	}
+	logger.Debug("选择节点", "node_id", bestNode.ID, "load", minLoad)
 	
 	return bestNode, nil
 }
@@ -158,8 +224,13 @@ func (nm *NodeManager) startHealthCheck() {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 	
-	for range ticker.C {
-		nm.checkNodeHealth()
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+			nm.checkNodeHealth()
+		}
 	}
 }
 
@@ -174,18 +245,91 @@ func (nm *NodeManager) checkNodeHealth() {
 		if now.Sub(node.LastSeen) > 30*time.Second {
 			if node.Status == NodeStatusOnline {
 				node.Status = NodeStatusOffline
-				log.Printf("节点离线: %s (%s)", node.Name, node.ID)
+				publishNodeStatus(node, NodeStatusOnline)
+				componentLogger("node").Warn("节点离线", "name", node.Name, "node_id", node.ID)
 			}
 		}
 	}
 }
 
+// ========== 节点设置持久化 ==========
+
+// 节点设置
+type NodeSettings struct {
+	NodeID        string            `json:"node_id"`
+	Labels        map[string]string `json:"labels"`
+	MaxContainers int               `json:"max_containers"`
+}
+
+// 初始化节点设置表
+func initNodeSettingsDB() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS node_settings (
+		node_id TEXT PRIMARY KEY,
+		labels TEXT NOT NULL DEFAULT '{}',
+		max_containers INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建节点设置表失败: %v", err)
+	}
+	return nil
+}
+
+// 读取节点设置（不存在时返回 nil）
+func loadNodeSettings(nodeID string) (*NodeSettings, error) {
+	if authDB == nil {
+		return nil, nil
+	}
+
+	var labelsJSON string
+	settings := &NodeSettings{NodeID: nodeID}
+	err := authDB.QueryRow(
+		"SELECT labels, max_containers FROM node_settings WHERE node_id = ?",
+		nodeID,
+	).Scan(&labelsJSON, &settings.MaxContainers)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询节点设置失败: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(labelsJSON), &settings.Labels); err != nil {
+		settings.Labels = make(map[string]string)
+	}
+	return settings, nil
+}
+
+// 保存节点设置
+func saveNodeSettings(settings *NodeSettings) error {
+	labels := settings.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labelsJSON, _ := json.Marshal(labels)
+
+	_, err := authDB.Exec(`
+		INSERT INTO node_settings (node_id, labels, max_containers, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(node_id) DO UPDATE SET
+			labels = excluded.labels,
+			max_containers = excluded.max_containers,
+			updated_at = CURRENT_TIMESTAMP`,
+		settings.NodeID, string(labelsJSON), settings.MaxContainers,
+	)
+	if err != nil {
+		return fmt.Errorf("保存节点设置失败: %v", err)
+	}
+	return nil
+}
+
 // ========== HTTP API Handlers ==========
 
 // 获取所有节点列表（Master）
 func handleNodesList(w http.ResponseWriter, r *http.Request) {
 	if nodeManager == nil || nodeManager.mode != ModeMaster {
-		http.Error(w, "当前节点不是 Master 模式", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
 		return
 	}
 	
@@ -194,29 +338,120 @@ func handleNodesList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(nodes)
 }
 
+// 修改节点设置（标签、最大容器数）
+func handleNodeSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	if nodeManager == nil || nodeManager.mode != ModeMaster {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
+		return
+	}
+
+	var req NodeSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	if req.NodeID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "节点 ID 不能为空")
+		return
+	}
+
+	if req.MaxContainers < 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "最大容器数不能为负数")
+		return
+	}
+
+	// 未传标签时保留已有标签
+	if req.Labels == nil {
+		if node, exists := nodeManager.GetNode(req.NodeID); exists {
+			nodeManager.RLock()
+			req.Labels = node.Labels
+			nodeManager.RUnlock()
+		} else if old, err := loadNodeSettings(req.NodeID); err == nil && old != nil {
+			req.Labels = old.Labels
+		}
+	}
+
+	if err := saveNodeSettings(&req); err != nil {
+		componentLogger("node").Error("Save settings failed", "node_id", req.NodeID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+
+	nodeManager.UpdateNodeSettings(req.NodeID, req.Labels, req.MaxContainers)
+	componentLogger("node").Info("Settings updated", "node_id", req.NodeID, "max_containers", req.MaxContainers)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
 // 节点注册 API（Worker 向 Master 注册）
 func handleNodeRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 	
 	if nodeManager == nil || nodeManager.mode != ModeMaster {
-		http.Error(w, "当前节点不是 Master 模式", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
 		return
 	}
 	
 	var node NodeInfo
 	if err := json.NewDecoder(r.Body).Decode(&node); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 	
+	// 地址为 host:port，IPv6 地址需加方括号（Master 据此拼接 Worker 的 URL）
+	if _, _, err := net.SplitHostPort(node.Address); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("节点地址无效: %s", node.Address))
+		return
+	}
+
 	if err := nodeManager.RegisterNode(&node); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 节点注销 API（Worker 退出时调用，立即标记为离线）
+func handleNodeDeregister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	
+	if nodeManager == nil || nodeManager.mode != ModeMaster {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
+		return
+	}
+	
+	var req struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NodeID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	
+	// 只允许节点注销自己
+	if req.NodeID != r.Header.Get("X-Node-ID") {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "节点 ID 不匹配")
 		return
 	}
 	
+	nodeManager.UpdateNodeStatus(req.NodeID, NodeStatusOffline)
+	componentLogger("node").Info("节点已注销", "node_id", req.NodeID)
+	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
@@ -224,12 +459,12 @@ func handleNodeRegister(w http.ResponseWriter, r *http.Request) {
 // 节点心跳 API（Worker 向 Master 发送心跳）
 func handleNodeHeartbeat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 	
 	if nodeManager == nil || nodeManager.mode != ModeMaster {
-		http.Error(w, "当前节点不是 Master 模式", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
 		return
 	}
 	
@@ -242,7 +477,7 @@ func handleNodeHeartbeat(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 	
@@ -257,14 +492,20 @@ func sendHeartbeatToMaster(masterURL string, nodeID string) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 	
-	for range ticker.C {
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
 		// 获取当前节点资源信息
 		cpu, _ := getCPUUsage()
 		memory, _ := getMemoryUsage()
 		disk, _ := getDiskUsage()
 		
 		// 获取容器数量
-		containers, err := dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+		containers, err := getDockerClient().ContainerList(context.Background(), types.ContainerListOptions{All: true})
 		containerCount := 0
 		if err == nil {
 			containerCount = len(containers)
@@ -290,13 +531,41 @@ func sendHeartbeatToMaster(masterURL string, nodeID string) {
 		
 		resp, err := http.DefaultClient.Do(httpReq)
 		if err != nil {
-			log.Printf("发送心跳失败: %v", err)
+			componentLogger("node").Warn("发送心跳失败", "master", masterURL, "error", err)
 			continue
 		}
 		resp.Body.Close()
 	}
 }
 
+// Worker 节点：退出时向 Master 注销
+func deregisterFromMaster(masterURL, nodeID string) error {
+	jsonData, _ := json.Marshal(map[string]string{"node_id": nodeID})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", masterURL+"/api/nodes/deregister", strings.NewReader(string(jsonData)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Node-ID", nodeID)
+	httpReq.Header.Set("X-Node-Token", generateNodeToken(nodeID))
+	
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("注销失败: %d", resp.StatusCode)
+	}
+	
+	componentLogger("node").Info("已向 Master 注销", "master", masterURL)
+	return nil
+}
+
 // Worker 节点：向 Master 注册
 func registerToMaster(masterURL string, nodeID, nodeName, nodeAddress string) error {
 	node := NodeInfo{
@@ -330,7 +599,7 @@ func registerToMaster(masterURL string, nodeID, nodeName, nodeAddress string) er
 		return fmt.Errorf("注册失败: %d", resp.StatusCode)
 	}
 	
-	log.Printf("已向 Master 注册: %s", masterURL)
+	componentLogger("node").Info("已向 Master 注册", "master", masterURL)
 	return nil
 }
 
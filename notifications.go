@@ -0,0 +1,876 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 通知渠道类型
+const (
+	channelWebhook  = "webhook"  // 通用 webhook（JSON，可选 HMAC 签名）
+	channelDingTalk = "dingtalk" // 钉钉群机器人
+	channelWeCom    = "wecom"    // 企业微信群机器人
+	channelTelegram = "telegram" // Telegram Bot
+	channelEmail    = "email"    // SMTP 邮件
+)
+
+// 通知级别
+const (
+	notifyInfo     = "info"
+	notifyWarning  = "warning"
+	notifyCritical = "critical"
+)
+
+// 通知发送策略
+const (
+	notifyQueueSize      = 256
+	notifyMaxAttempts    = 3                    // 每个渠道最多尝试次数
+	notifyRetryBase      = 2 * time.Second      // 重试退避起始时间，每次翻倍
+	notifyAttemptTimeout = 10 * time.Second     // 单次发送超时
+	notifySecretMask     = "******"             // 接口中返回的脱敏密钥
+	notifyWebhookSigHdr  = "X-Rabbit-Signature" // 通用 webhook 签名：sha256=hex(HMAC(secret, timestamp + "." + body))
+	notifyWebhookTimeHdr = "X-Rabbit-Timestamp"
+)
+
+// 一条通知（各功能调用 Notify 发送，由本模块按渠道类型生成消息）
+type Notification struct {
+	Event   string            `json:"event"` // 事件类型，如 container_die、node_offline、docker_disconnect
+	Level   string            `json:"level"` // info、warning、critical
+	Title   string            `json:"title"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // 附加信息（容器名、节点等）
+	Time    time.Time         `json:"time"`
+	Host    string            `json:"host,omitempty"` // 发出通知的面板主机名
+}
+
+// 渠道配置（按类型使用其中的字段）
+type ChannelConfig struct {
+	URL      string `json:"url,omitempty"`       // webhook、钉钉、企业微信的地址
+	Secret   string `json:"secret,omitempty"`    // webhook 签名密钥、钉钉加签密钥
+	BotToken string `json:"bot_token,omitempty"` // Telegram
+	ChatID   string `json:"chat_id,omitempty"`   // Telegram
+	SMTPHost string `json:"smtp_host,omitempty"` // 邮件
+	SMTPPort int    `json:"smtp_port,omitempty"`
+	SMTPTLS  bool   `json:"smtp_tls,omitempty"` // 直接使用 TLS（465 端口），否则服务器支持时使用 STARTTLS
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"` // 逗号分隔
+}
+
+// 通知渠道
+type NotifyChannel struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	Enabled   bool          `json:"enabled"`
+	Events    []string      `json:"events"` // 订阅的事件，为空表示全部
+	Config    ChannelConfig `json:"config"`
+	CreatedAt string        `json:"created_at,omitempty"`
+	UpdatedAt string        `json:"updated_at,omitempty"`
+}
+
+// 发送记录
+type NotifyDelivery struct {
+	ID          int64  `json:"id"`
+	Time        int64  `json:"time"` // Unix 秒
+	ChannelID   int64  `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	Event       string `json:"event"`
+	Title       string `json:"title"`
+	Status      string `json:"status"` // success 或 failed
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+var (
+	notifyQueue      = make(chan Notification, notifyQueueSize)
+	notifyHTTPClient = &http.Client{Timeout: notifyAttemptTimeout}
+)
+
+// 初始化通知渠道和发送记录表，启动发送协程
+func initNotifications() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS notify_channels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		events TEXT NOT NULL DEFAULT '',
+		config TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS notify_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time INTEGER NOT NULL,
+		channel_id INTEGER NOT NULL,
+		channel_name TEXT,
+		event TEXT NOT NULL,
+		title TEXT,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		error TEXT,
+		duration_ms INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_notify_deliveries_time ON notify_deliveries(time);`)
+	if err != nil {
+		return fmt.Errorf("创建通知表失败: %v", err)
+	}
+
+	go dispatchNotifications()
+	go pruneNotifyDeliveries()
+	return nil
+}
+
+// 发送通知（异步，不阻塞调用方；队列满时丢弃并记录日志；设置中关闭通知时直接忽略）
+func Notify(n Notification) {
+	if !settingBool("notify.enabled") {
+		return
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if n.Level == "" {
+		n.Level = notifyInfo
+	}
+	if n.Host == "" {
+		n.Host, _ = os.Hostname()
+	}
+	select {
+	case notifyQueue <- n:
+	default:
+		log.Printf("[Notify] Queue full, dropping %s notification: %s", n.Event, n.Title)
+	}
+}
+
+// 从队列取出通知，发送到订阅了该事件的所有渠道（各渠道并行，互不影响）
+func dispatchNotifications() {
+	for {
+		var n Notification
+		select {
+		case <-serverCtx.Done():
+			return
+		case n = <-notifyQueue:
+		}
+
+		channels, err := loadNotifyChannels()
+		if err != nil {
+			log.Printf("[Notify] Load channels failed: %v", err)
+			continue
+		}
+		for _, ch := range channels {
+			if !ch.Enabled || !ch.subscribes(n.Event) {
+				continue
+			}
+			go func(ch NotifyChannel) {
+				defer recoverGoroutine(serverCtx, "notify "+ch.Name)
+				deliverWithRetry(ch, n)
+			}(ch)
+		}
+	}
+}
+
+// 渠道是否订阅了该事件
+func (ch *NotifyChannel) subscribes(event string) bool {
+	if len(ch.Events) == 0 {
+		return true
+	}
+	for _, e := range ch.Events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// 发送到单个渠道，失败时按退避重试，最后写入发送记录
+func deliverWithRetry(ch NotifyChannel, n Notification) *NotifyDelivery {
+	start := time.Now()
+	backoff := notifyRetryBase
+	var err error
+	attempts := 0
+	for attempts < notifyMaxAttempts {
+		attempts++
+		if err = sendNotification(&ch, &n); err == nil {
+			break
+		}
+		log.Printf("[Notify] Send to %s (%s) failed, attempt %d/%d: %v", ch.Name, ch.Type, attempts, notifyMaxAttempts, err)
+		if attempts == notifyMaxAttempts {
+			break
+		}
+		select {
+		case <-serverCtx.Done():
+			return recordNotifyDelivery(&ch, &n, attempts, err, time.Since(start))
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+	return recordNotifyDelivery(&ch, &n, attempts, err, time.Since(start))
+}
+
+// 写入发送记录
+func recordNotifyDelivery(ch *NotifyChannel, n *Notification, attempts int, sendErr error, duration time.Duration) *NotifyDelivery {
+	d := &NotifyDelivery{
+		Time:        time.Now().Unix(),
+		ChannelID:   ch.ID,
+		ChannelName: ch.Name,
+		Event:       n.Event,
+		Title:       n.Title,
+		Status:      "success",
+		Attempts:    attempts,
+		DurationMs:  duration.Milliseconds(),
+	}
+	if sendErr != nil {
+		d.Status = "failed"
+		d.Error = sendErr.Error()
+	}
+	res, err := authDB.Exec(
+		"INSERT INTO notify_deliveries (time, channel_id, channel_name, event, title, status, attempts, error, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.Time, d.ChannelID, d.ChannelName, d.Event, d.Title, d.Status, d.Attempts, d.Error, d.DurationMs,
+	)
+	if err != nil {
+		log.Printf("[Notify] Save delivery failed: %v", err)
+		return d
+	}
+	d.ID, _ = res.LastInsertId()
+	return d
+}
+
+// 定期清理过期的发送记录
+func pruneNotifyDeliveries() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cutoff := time.Now().Add(-settingDays("retention.notify_deliveries_days")).Unix()
+		if _, err := authDB.Exec("DELETE FROM notify_deliveries WHERE time < ?", cutoff); err != nil {
+			log.Printf("[Notify] Prune deliveries failed: %v", err)
+		}
+	}
+}
+
+// 按渠道类型发送一次
+func sendNotification(ch *NotifyChannel, n *Notification) error {
+	switch ch.Type {
+	case channelWebhook:
+		return sendWebhook(&ch.Config, n)
+	case channelDingTalk:
+		return sendDingTalk(&ch.Config, n)
+	case channelWeCom:
+		return sendWeCom(&ch.Config, n)
+	case channelTelegram:
+		return sendTelegram(&ch.Config, n)
+	case channelEmail:
+		return sendEmail(&ch.Config, n)
+	}
+	return fmt.Errorf("不支持的通知渠道类型: %s", ch.Type)
+}
+
+// 通知的纯文本内容（标题、正文、附加信息按键名排序）
+func notificationText(n *Notification, bullet string) string {
+	var b strings.Builder
+	b.WriteString(n.Message)
+	keys := make([]string, 0, len(n.Fields))
+	for k := range n.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if n.Message != "" && len(keys) > 0 {
+		b.WriteString("\n")
+	}
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n%s%s: %s", bullet, k, n.Fields[k])
+	}
+	fmt.Fprintf(&b, "\n\n%s · %s", n.Host, n.Time.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+// 级别对应的标题前缀
+func notificationPrefix(level string) string {
+	switch level {
+	case notifyCritical:
+		return "[严重] "
+	case notifyWarning:
+		return "[警告] "
+	}
+	return ""
+}
+
+// POST JSON，检查 HTTP 状态码，返回响应体
+func postNotifyJSON(target string, payload interface{}, header http.Header) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rabbit-panel")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// 通用 webhook：POST 通知的 JSON，配置密钥时附带 HMAC-SHA256 签名
+func sendWebhook(cfg *ChannelConfig, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		header.Set(notifyWebhookTimeHdr, ts)
+		header.Set(notifyWebhookSigHdr, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	_, err = postNotifyJSON(cfg.URL, json.RawMessage(body), header)
+	return err
+}
+
+// 钉钉和企业微信的响应（errcode 非 0 表示失败）
+func checkRobotResponse(body []byte) error {
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("响应格式错误: %s", strings.TrimSpace(string(body)))
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+// 钉钉群机器人（markdown 消息，配置加签密钥时在地址上附加 timestamp 和 sign）
+func sendDingTalk(cfg *ChannelConfig, n *Notification) error {
+	target := cfg.URL
+	if cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write([]byte(ts + "\n" + cfg.Secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + "timestamp=" + ts + "&sign=" + sign
+	}
+	title := notificationPrefix(n.Level) + n.Title
+	body, err := postNotifyJSON(target, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  "### " + title + "\n\n" + notificationText(n, "- "),
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(body)
+}
+
+// 企业微信群机器人（markdown 消息）
+func sendWeCom(cfg *ChannelConfig, n *Notification) error {
+	body, err := postNotifyJSON(cfg.URL, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "### " + notificationPrefix(n.Level) + n.Title + "\n" + notificationText(n, "> "),
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return checkRobotResponse(body)
+}
+
+// Telegram Bot（纯文本消息，避免转义问题）
+func sendTelegram(cfg *ChannelConfig, n *Notification) error {
+	target := "https://api.telegram.org/bot" + cfg.BotToken + "/sendMessage"
+	body, err := postNotifyJSON(target, map[string]string{
+		"chat_id": cfg.ChatID,
+		"text":    notificationPrefix(n.Level) + n.Title + "\n\n" + notificationText(n, ""),
+	}, nil)
+	if err != nil {
+		// 错误信息中不能带上地址里的 bot token
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), cfg.BotToken, notifySecretMask))
+	}
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || !resp.OK {
+		return fmt.Errorf("Telegram 发送失败: %s", resp.Description)
+	}
+	return nil
+}
+
+// 收件人列表
+func (cfg *ChannelConfig) recipients() []string {
+	var to []string
+	for _, addr := range strings.Split(cfg.To, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// SMTP 邮件
+func sendEmail(cfg *ChannelConfig, n *Notification) error {
+	to := cfg.recipients()
+	subject := "[Rabbit Panel] " + notificationPrefix(n.Level) + n.Title
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(notificationText(n, "")))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)
+	}
+	if !cfg.SMTPTLS {
+		// smtp.SendMail 在服务器支持时自动使用 STARTTLS
+		return smtp.SendMail(addr, auth, cfg.From, to, msg.Bytes())
+	}
+
+	dialer := &net.Dialer{Timeout: notifyAttemptTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.SMTPHost})
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(notifyAttemptTimeout))
+	c, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(msg.Bytes()); err != nil {
+		wc.Close()
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// 校验 http/https 地址
+func validateNotifyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("地址无效: %s（应为 http:// 或 https:// 地址）", raw)
+	}
+	return nil
+}
+
+// 校验渠道
+func (ch *NotifyChannel) validate() error {
+	ch.Name = strings.TrimSpace(ch.Name)
+	if ch.Name == "" {
+		return fmt.Errorf("渠道名称不能为空")
+	}
+	cfg := &ch.Config
+	switch ch.Type {
+	case channelWebhook, channelDingTalk, channelWeCom:
+		return validateNotifyURL(cfg.URL)
+	case channelTelegram:
+		if cfg.BotToken == "" || cfg.ChatID == "" {
+			return fmt.Errorf("Telegram 渠道需要 bot_token 和 chat_id")
+		}
+	case channelEmail:
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.recipients()) == 0 {
+			return fmt.Errorf("邮件渠道需要 smtp_host、from 和 to")
+		}
+		if cfg.SMTPPort == 0 {
+			cfg.SMTPPort = 25
+			if cfg.SMTPTLS {
+				cfg.SMTPPort = 465
+			}
+		}
+		if cfg.SMTPPort < 0 || cfg.SMTPPort > 65535 {
+			return fmt.Errorf("smtp_port 无效: %d", cfg.SMTPPort)
+		}
+	default:
+		return fmt.Errorf("不支持的通知渠道类型: %s（支持 webhook、dingtalk、wecom、telegram、email）", ch.Type)
+	}
+	return nil
+}
+
+// 返回给前端时隐藏密钥
+func (ch NotifyChannel) masked() NotifyChannel {
+	for _, s := range []*string{&ch.Config.Secret, &ch.Config.BotToken, &ch.Config.Password} {
+		if *s != "" {
+			*s = notifySecretMask
+		}
+	}
+	return ch
+}
+
+// 修改时未改动的密钥（仍为脱敏值）沿用原来的值
+func (ch *NotifyChannel) keepSecrets(old *NotifyChannel) {
+	pairs := [][2]*string{
+		{&ch.Config.Secret, &old.Config.Secret},
+		{&ch.Config.BotToken, &old.Config.BotToken},
+		{&ch.Config.Password, &old.Config.Password},
+	}
+	for _, p := range pairs {
+		if *p[0] == notifySecretMask {
+			*p[0] = *p[1]
+		}
+	}
+}
+
+// 读取渠道（id 为 0 时读取全部）
+func queryNotifyChannels(id int64) ([]NotifyChannel, error) {
+	query := "SELECT id, name, type, enabled, events, config, created_at, updated_at FROM notify_channels"
+	var args []interface{}
+	if id != 0 {
+		query += " WHERE id = ?"
+		args = append(args, id)
+	}
+	rows, err := authDB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make([]NotifyChannel, 0)
+	for rows.Next() {
+		var ch NotifyChannel
+		var events, config string
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.Type, &ch.Enabled, &events, &config, &ch.CreatedAt, &ch.UpdatedAt); err != nil {
+			return nil, err
+		}
+		ch.Events = splitNotifyEvents(events)
+		if err := json.Unmarshal([]byte(config), &ch.Config); err != nil {
+			log.Printf("[Notify] Invalid config for channel %d: %v", ch.ID, err)
+			continue
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+func loadNotifyChannels() ([]NotifyChannel, error) {
+	return queryNotifyChannels(0)
+}
+
+func loadNotifyChannel(id int64) (*NotifyChannel, error) {
+	channels, err := queryNotifyChannels(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &channels[0], nil
+}
+
+func splitNotifyEvents(s string) []string {
+	events := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// 保存渠道（ID 为 0 时新建）
+func saveNotifyChannel(ch *NotifyChannel) error {
+	config, _ := json.Marshal(ch.Config)
+	events := strings.Join(ch.Events, ",")
+	if ch.ID == 0 {
+		res, err := authDB.Exec(
+			"INSERT INTO notify_channels (name, type, enabled, events, config) VALUES (?, ?, ?, ?, ?)",
+			ch.Name, ch.Type, ch.Enabled, events, string(config),
+		)
+		if err != nil {
+			return err
+		}
+		ch.ID, _ = res.LastInsertId()
+		return nil
+	}
+	_, err := authDB.Exec(
+		"UPDATE notify_channels SET name = ?, type = ?, enabled = ?, events = ?, config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		ch.Name, ch.Type, ch.Enabled, events, string(config), ch.ID,
+	)
+	return err
+}
+
+// 渠道列表
+func handleNotifyChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	channels, err := loadNotifyChannels()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	for i := range channels {
+		channels[i] = channels[i].masked()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
+
+// 新建（/create）或修改（/update，需要 id）渠道
+func handleNotifyChannelSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var ch NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/create") {
+		ch.ID = 0
+	} else if ch.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	action := "notify_channel_create"
+	if ch.ID != 0 {
+		old, err := loadNotifyChannel(ch.ID)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "通知渠道不存在")
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+			return
+		}
+		ch.keepSecrets(old)
+		action = "notify_channel_update"
+	}
+	if err := ch.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if err := saveNotifyChannel(&ch); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), action, ch.Name, ch.Type, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ch.masked())
+}
+
+// 删除渠道（发送记录保留）
+func handleNotifyChannelDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	ch, err := loadNotifyChannel(req.ID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "通知渠道不存在")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	if _, err := authDB.Exec("DELETE FROM notify_channels WHERE id = ?", req.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "notify_channel_delete", ch.Name, ch.Type, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 发送测试通知（只尝试一次，结果写入发送记录）
+// 请求中带 id 时测试已保存的渠道（可同时传入修改后的配置，脱敏的密钥沿用已保存的值），否则测试请求中的配置
+func handleNotifyChannelTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var ch NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	if ch.ID != 0 {
+		old, err := loadNotifyChannel(ch.ID)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "通知渠道不存在")
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+			return
+		}
+		if ch.Type == "" {
+			ch = *old
+		} else {
+			ch.keepSecrets(old)
+		}
+	}
+	if err := ch.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	host, _ := os.Hostname()
+	n := Notification{
+		Event:   "test",
+		Level:   notifyInfo,
+		Title:   "测试通知",
+		Message: "这是一条来自 Rabbit Panel 的测试通知，收到说明渠道配置正确。",
+		Fields:  map[string]string{"channel": ch.Name},
+		Time:    time.Now(),
+		Host:    host,
+	}
+	start := time.Now()
+	err := sendNotification(&ch, &n)
+	d := recordNotifyDelivery(&ch, &n, 1, err, time.Since(start))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// 发送记录（?channel_id=1&status=failed&limit=100，最新的在前）
+func handleNotifyDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	q := r.URL.Query()
+	query := "SELECT id, time, channel_id, channel_name, event, title, status, attempts, error, duration_ms FROM notify_deliveries WHERE 1 = 1"
+	var args []interface{}
+	if v := q.Get("channel_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "channel_id 参数无效")
+			return
+		}
+		query += " AND channel_id = ?"
+		args = append(args, id)
+	}
+	if v := q.Get("status"); v != "" {
+		query += " AND status = ?"
+		args = append(args, v)
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := authDB.Query(query, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	deliveries := make([]NotifyDelivery, 0)
+	for rows.Next() {
+		var d NotifyDelivery
+		var channelName, title, errMsg sql.NullString
+		if err := rows.Scan(&d.ID, &d.Time, &d.ChannelID, &channelName, &d.Event, &title, &d.Status, &d.Attempts, &errMsg, &d.DurationMs); err != nil {
+			continue
+		}
+		d.ChannelName, d.Title, d.Error = channelName.String, title.String, errMsg.String
+		deliveries = append(deliveries, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
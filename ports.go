@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 主机端口占用检测：容器发布的端口（来自容器列表缓存）+ 主机上实际监听的端口
+// Linux 读取 /proc/net/{tcp,tcp6,udp,udp6}，其他平台无法列出监听端口，检查时退化为尝试绑定再关闭
+// 创建/重建容器前检查，被占用时返回 409 并说明占用者；force 用于 SO_REUSEPORT 等有意共用端口的场景
+
+// 无法确定所属进程时的占用者
+const unknownPortOwner = "未知进程"
+
+// 端口占用
+type PortUse struct {
+	Port      int    `json:"port"`
+	Proto     string `json:"proto"`               // tcp / udp
+	Kind      string `json:"kind"`                // container（容器发布）/ process（主机进程）
+	Owner     string `json:"owner"`               // 占用者描述：容器名或进程名
+	Container string `json:"container,omitempty"` // 容器 ID
+	PID       int    `json:"pid,omitempty"`       // 进程 ID（能确定时）
+	Address   string `json:"address,omitempty"`   // 监听地址
+}
+
+// 主机监听的端口（由平台相关代码提供）
+type hostListener struct {
+	Port    int
+	Proto   string
+	Address string
+	PID     int
+	Process string
+}
+
+// 需要占用的主机端口
+type portRequest struct {
+	Port  string // 主机端口
+	Proto string // tcp / udp，为空时按 tcp
+}
+
+type portConflictError struct {
+	conflicts []PortUse
+}
+
+func (e *portConflictError) Error() string {
+	c := e.conflicts[0]
+	var msg string
+	switch {
+	case c.Kind == "container":
+		msg = fmt.Sprintf("主机端口 %d/%s 已被容器 %s 占用", c.Port, c.Proto, c.Owner)
+	case c.Owner == unknownPortOwner:
+		msg = fmt.Sprintf("主机端口 %d/%s 已被占用", c.Port, c.Proto)
+	default:
+		msg = fmt.Sprintf("主机端口 %d/%s 已被进程 %s 占用", c.Port, c.Proto, c.Owner)
+	}
+	if len(e.conflicts) > 1 {
+		msg += fmt.Sprintf("（另有 %d 个端口冲突）", len(e.conflicts)-1)
+	}
+	return msg
+}
+
+// 容器发布的主机端口，ContainerInfo.Ports 格式为 "8080:80/tcp, :443/tcp"
+func containerPortUses(c ContainerInfo) []PortUse {
+	var uses []PortUse
+	for _, p := range strings.Split(c.Ports, ",") {
+		p = strings.TrimSpace(p)
+		host, rest, ok := strings.Cut(p, ":")
+		if !ok || host == "" {
+			continue
+		}
+		port, err := strconv.Atoi(host)
+		if err != nil {
+			continue
+		}
+		proto := "tcp"
+		if _, pr, ok := strings.Cut(rest, "/"); ok && pr != "" {
+			proto = pr
+		}
+		uses = append(uses, PortUse{Port: port, Proto: proto, Kind: "container", Owner: c.Name, Container: c.ID})
+	}
+	return uses
+}
+
+// 创建容器时需要的主机端口（/api/containers/run 只映射 TCP）
+func (req *ContainerRunRequest) hostPorts() []portRequest {
+	var ports []portRequest
+	for _, p := range req.Ports {
+		if p.Host != "" && p.Container != "" {
+			ports = append(ports, portRequest{Port: p.Host, Proto: "tcp"})
+		}
+	}
+	return ports
+}
+
+// 当前的端口占用。主机监听中已由容器发布的端口（docker-proxy）归到容器名下；
+// 无法列出主机监听时返回 listenErr，容器部分仍然有效
+func hostPortUsage(ctx context.Context) (uses []PortUse, listenErr error) {
+	seen := map[string]bool{}
+	if dockerAvailable() {
+		containers, _ := listContainersCached(ctx) // 获取失败时只看主机监听
+		for _, c := range containers {
+			for _, u := range containerPortUses(c) {
+				key := fmt.Sprintf("%d/%s", u.Port, u.Proto)
+				if seen[key] {
+					continue // 同一端口的 IPv4 / IPv6 绑定
+				}
+				seen[key] = true
+				uses = append(uses, u)
+			}
+		}
+	}
+
+	listeners, listenErr := hostListeners()
+	for _, l := range listeners {
+		key := fmt.Sprintf("%d/%s", l.Port, l.Proto)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		owner := l.Process
+		if owner == "" {
+			owner = unknownPortOwner
+		}
+		uses = append(uses, PortUse{Port: l.Port, Proto: l.Proto, Kind: "process", Owner: owner, PID: l.PID, Address: l.Address})
+	}
+
+	sort.Slice(uses, func(i, j int) bool {
+		if uses[i].Port != uses[j].Port {
+			return uses[i].Port < uses[j].Port
+		}
+		return uses[i].Proto < uses[j].Proto
+	})
+	return uses, listenErr
+}
+
+// 尝试绑定端口再关闭，用于无法列出主机监听的平台
+func probePort(port int, proto string) bool {
+	addr := fmt.Sprintf(":%d", port)
+	if proto == "udp" {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// 检查将要占用的主机端口，exclude 为容器 ID 或名称（重建时排除旧容器自己占用的端口）
+// 有冲突时返回 *portConflictError
+func checkPortConflicts(ctx context.Context, ports []portRequest, exclude string) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	uses, listenErr := hostPortUsage(ctx)
+
+	exclude = strings.TrimPrefix(exclude, "/")
+	byKey := map[string]PortUse{}
+	for _, u := range uses {
+		if u.Kind == "container" && exclude != "" &&
+			(u.Owner == exclude || strings.HasPrefix(exclude, u.Container) || strings.HasPrefix(u.Container, exclude)) {
+			continue
+		}
+		byKey[fmt.Sprintf("%d/%s", u.Port, u.Proto)] = u
+	}
+
+	var conflicts []PortUse
+	checked := map[string]bool{}
+	for _, p := range ports {
+		port, err := strconv.Atoi(strings.TrimSpace(p.Port))
+		if err != nil || port <= 0 {
+			continue // 端口范围等格式交给 Docker 校验
+		}
+		proto := p.Proto
+		if proto == "" {
+			proto = "tcp"
+		}
+		key := fmt.Sprintf("%d/%s", port, proto)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		if u, ok := byKey[key]; ok {
+			conflicts = append(conflicts, u)
+			continue
+		}
+		if listenErr != nil && !probePort(port, proto) {
+			conflicts = append(conflicts, PortUse{Port: port, Proto: proto, Kind: "process", Owner: unknownPortOwner})
+		}
+	}
+	if len(conflicts) > 0 {
+		return &portConflictError{conflicts: conflicts}
+	}
+	return nil
+}
+
+// 查看主机端口占用
+// GET /api/system/ports?port=8080
+func handleSystemPorts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	filter := 0
+	if v := r.URL.Query().Get("port"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 65535 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "端口参数无效")
+			return
+		}
+		filter = n
+	}
+
+	uses, listenErr := hostPortUsage(r.Context())
+	if uses == nil {
+		uses = []PortUse{}
+	}
+	if filter > 0 {
+		filtered := []PortUse{}
+		for _, u := range uses {
+			if u.Port == filter {
+				filtered = append(filtered, u)
+			}
+		}
+		uses = filtered
+	}
+
+	resp := map[string]interface{}{"ports": uses}
+	if listenErr != nil {
+		resp["listeners_error"] = listenErr.Error() // 只有容器发布的端口
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,141 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 读取 /proc/net 中处于监听状态的 TCP 端口和未连接的 UDP 端口，并通过 /proc/<pid>/fd 找到所属进程
+func hostListeners() ([]hostListener, error) {
+	var listeners []hostListener
+	inodes := map[string][]int{} // socket inode -> listeners 下标
+	read := 0
+	for _, f := range []struct{ file, proto string }{
+		{"/proc/net/tcp", "tcp"}, {"/proc/net/tcp6", "tcp"},
+		{"/proc/net/udp", "udp"}, {"/proc/net/udp6", "udp"},
+	} {
+		entries, err := readProcNetSockets(f.file, f.proto)
+		if err != nil {
+			continue // 未启用 IPv6 时没有 tcp6/udp6
+		}
+		read++
+		for _, e := range entries {
+			inodes[e.inode] = append(inodes[e.inode], len(listeners))
+			listeners = append(listeners, e.hostListener)
+		}
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("无法读取 /proc/net")
+	}
+	if len(inodes) > 0 {
+		resolveSocketOwners(inodes, listeners)
+	}
+	return listeners, nil
+}
+
+type procNetSocket struct {
+	hostListener
+	inode string
+}
+
+// 解析 /proc/net/{tcp,udp}[6]：local_address 为十六进制 IP:端口，st 0A 为 LISTEN；UDP 取远端为空的套接字
+func readProcNetSockets(path, proto string) ([]procNetSocket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sockets []procNetSocket
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		local, remote, state, inode := fields[1], fields[2], fields[3], fields[9]
+		if proto == "tcp" && state != "0A" {
+			continue
+		}
+		if proto == "udp" && !strings.HasSuffix(remote, ":0000") {
+			continue
+		}
+		hexAddr, hexPort, ok := strings.Cut(local, ":")
+		if !ok {
+			continue
+		}
+		port, err := strconv.ParseUint(hexPort, 16, 16)
+		if err != nil || port == 0 {
+			continue
+		}
+		sockets = append(sockets, procNetSocket{
+			hostListener: hostListener{Port: int(port), Proto: proto, Address: parseProcNetIP(hexAddr)},
+			inode:        inode,
+		})
+	}
+	return sockets, scanner.Err()
+}
+
+// /proc/net 中的地址按 32 位字逐个以主机字节序（小端）存放
+func parseProcNetIP(s string) string {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b)%4 != 0 {
+		return ""
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return net.IP(b).String()
+}
+
+// 遍历 /proc/<pid>/fd 找到持有这些 socket 的进程（无权限读取的进程跳过）
+func resolveSocketOwners(inodes map[string][]int, listeners []hostListener) {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+	remaining := len(inodes)
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var comm string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			idx, ok := inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")]
+			if !ok || listeners[idx[0]].PID != 0 {
+				continue
+			}
+			if comm == "" {
+				data, _ := os.ReadFile(filepath.Join("/proc", p.Name(), "comm"))
+				comm = strings.TrimSpace(string(data))
+			}
+			for _, i := range idx {
+				listeners[i].PID = pid
+				listeners[i].Process = comm
+			}
+			remaining--
+		}
+		if remaining == 0 {
+			return
+		}
+	}
+}
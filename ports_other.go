@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// 非 Linux 平台没有 /proc/net，检查端口时改为尝试绑定
+func hostListeners() ([]hostListener, error) {
+	return nil, errors.New("当前平台不支持列出主机监听端口")
+}
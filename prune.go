@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+)
+
+// 破坏性操作的影响预览：先选出将被删除的对象，dry_run=true 时只返回这份列表，
+// 否则逐个删除同一份列表中的对象，预览和实际执行的选择逻辑完全一致
+
+// 受影响的对象
+type ImpactItem struct {
+	Type  string `json:"type"` // container / image / volume / network
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`            // 可回收的空间（字节），-1 表示未知
+	Error string `json:"error,omitempty"` // 实际执行时删除失败的原因
+}
+
+// 影响报告，预览和实际执行返回相同的结构
+type ImpactReport struct {
+	Action         string       `json:"action"`
+	Executed       bool         `json:"executed"` // false 表示 dry run，未做任何修改
+	Items          []ImpactItem `json:"items"`
+	Failed         int          `json:"failed"`          // 删除失败的对象数
+	ReclaimedBytes int64        `json:"reclaimed_bytes"` // 预览时为估算值，执行后为删除成功的对象之和
+	Reclaimed      string       `json:"reclaimed"`
+	Output         string       `json:"output"` // 命令输出（compose down），其他操作为空
+}
+
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// 请求中的 dry_run 参数，present 表示请求带有该参数（用于兼容原有响应格式的接口）
+func dryRunParam(r *http.Request) (dryRun, present bool) {
+	v, ok := r.URL.Query()["dry_run"]
+	if !ok {
+		return false, false
+	}
+	// 只写 ?dry_run 或值无法识别时按预览处理，避免误删
+	dryRun, err := strconv.ParseBool(v[0])
+	return dryRun || err != nil, true
+}
+
+// 汇总选出的对象，非 dry run 时逐个调用 remove 删除
+func applyImpact(action string, items []ImpactItem, dryRun bool, remove func(ImpactItem) error) *ImpactReport {
+	report := &ImpactReport{Action: action, Executed: !dryRun, Items: items}
+	if report.Items == nil {
+		report.Items = []ImpactItem{}
+	}
+	for i := range report.Items {
+		item := &report.Items[i]
+		if !dryRun && remove != nil {
+			if err := remove(*item); err != nil {
+				item.Error = err.Error()
+				report.Failed++
+				continue
+			}
+		}
+		if item.Size > 0 {
+			report.ReclaimedBytes += item.Size
+		}
+	}
+	report.Reclaimed = units.HumanSize(float64(report.ReclaimedBytes))
+	return report
+}
+
+// 被容器（包括已停止的）使用的镜像 ID
+func imagesInUse(ctx context.Context) (map[string]bool, error) {
+	containers, err := getDockerClient().ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("获取容器列表失败: %v", err)
+	}
+	used := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		used[c.ImageID] = true
+	}
+	return used, nil
+}
+
+// 可清理的镜像：默认只选悬空镜像，all 时选所有未被容器使用的镜像
+// 较新的镜像排在前面，先删除子镜像再删除父镜像
+func selectPrunableImages(ctx context.Context, all bool) ([]ImpactItem, error) {
+	used, err := imagesInUse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := types.ImageListOptions{SharedSize: true}
+	if !all {
+		opts.Filters = filters.NewArgs(filters.Arg("dangling", "true"))
+	}
+	images, err := getDockerClient().ImageList(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("获取镜像列表失败: %v", err)
+	}
+	sort.Slice(images, func(a, b int) bool { return images[a].Created > images[b].Created })
+
+	items := []ImpactItem{}
+	for _, img := range images {
+		if used[img.ID] {
+			continue
+		}
+		name := shortImageID(img.ID)
+		tags := imageTags(img.RepoTags)
+		if len(tags) > 0 {
+			name = strings.Join(tags, ", ")
+		}
+		// 与其他镜像共享的层不会被释放
+		size := img.Size
+		if img.SharedSize > 0 {
+			size -= img.SharedSize
+		}
+		items = append(items, ImpactItem{Type: "image", ID: img.ID, Name: name, Size: size})
+	}
+	return items, nil
+}
+
+func imageTags(repoTags []string) []string {
+	var tags []string
+	for _, tag := range repoTags {
+		if tag != "<none>:<none>" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// 删除镜像：有多个标签时逐个取消标签（取消最后一个标签时删除镜像），不强制删除
+func removeImageItem(ctx context.Context, item ImpactItem) error {
+	cli := getDockerClient()
+	img, _, err := cli.ImageInspectWithRaw(ctx, item.ID)
+	if client.IsErrNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	refs := imageTags(img.RepoTags)
+	if len(refs) == 0 {
+		refs = []string{item.ID}
+	}
+	for _, ref := range refs {
+		if _, err := cli.ImageRemove(ctx, ref, types.ImageRemoveOptions{PruneChildren: true}); err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 卷的大小和引用数（DiskUsage 需要遍历卷目录，卷较多时较慢）
+func volumeUsage(ctx context.Context) ([]*volume.Volume, error) {
+	du, err := getDockerClient().DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("获取卷列表失败: %v", err)
+	}
+	return du.Volumes, nil
+}
+
+func volumeSize(v *volume.Volume) int64 {
+	if v.UsageData == nil {
+		return -1
+	}
+	return v.UsageData.Size
+}
+
+// 匿名卷：Docker 23 起带有 com.docker.volume.anonymous 标签，更早的版本按名称判断
+func isAnonymousVolume(v *volume.Volume) bool {
+	if _, ok := v.Labels["com.docker.volume.anonymous"]; ok {
+		return true
+	}
+	return anonymousVolumeName.MatchString(v.Name)
+}
+
+// 可清理的卷：没有被任何容器引用的卷，默认只选匿名卷（与 docker volume prune 一致），all 时包括命名卷
+func selectPrunableVolumes(ctx context.Context, all bool) ([]ImpactItem, error) {
+	volumes, err := volumeUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := []ImpactItem{}
+	for _, v := range volumes {
+		if v.UsageData != nil && v.UsageData.RefCount > 0 {
+			continue
+		}
+		if !all && !isAnonymousVolume(v) {
+			continue
+		}
+		items = append(items, ImpactItem{Type: "volume", ID: v.Name, Name: v.Name, Size: volumeSize(v)})
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].Name < items[b].Name })
+	return items, nil
+}
+
+func removeVolumeItem(ctx context.Context, item ImpactItem) error {
+	if err := getDockerClient().VolumeRemove(ctx, item.ID, false); err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// 可清理的网络：没有连接任何容器（包括已停止的）的自定义网络，不包括内置网络和 Swarm 网络
+func selectPrunableNetworks(ctx context.Context) ([]ImpactItem, error) {
+	cli := getDockerClient()
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("获取容器列表失败: %v", err)
+	}
+	used := map[string]bool{}
+	for _, c := range containers {
+		if c.NetworkSettings == nil {
+			continue
+		}
+		for _, ep := range c.NetworkSettings.Networks {
+			used[ep.NetworkID] = true
+		}
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取网络列表失败: %v", err)
+	}
+	items := []ImpactItem{}
+	for _, n := range networks {
+		switch n.Name {
+		case "bridge", "host", "none":
+			continue
+		}
+		if n.Scope == "swarm" || n.Ingress || used[n.ID] || len(n.Containers) > 0 {
+			continue
+		}
+		items = append(items, ImpactItem{Type: "network", ID: n.ID, Name: n.Name})
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].Name < items[b].Name })
+	return items, nil
+}
+
+func removeNetworkItem(ctx context.Context, item ImpactItem) error {
+	if err := getDockerClient().NetworkRemove(ctx, item.ID); err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// 删除容器影响的对象：容器本身（可写层大小），removeVolumes 时还包括容器的匿名卷
+// （docker rm -v 只删除匿名卷，命名卷和绑定目录保留）
+func selectContainerRemoval(ctx context.Context, id string, removeVolumes bool) ([]ImpactItem, error) {
+	info, _, err := getDockerClient().ContainerInspectWithRaw(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(-1)
+	if info.SizeRw != nil {
+		size = *info.SizeRw
+	}
+	items := []ImpactItem{{Type: "container", ID: info.ID, Name: strings.TrimPrefix(info.Name, "/"), Size: size}}
+	if !removeVolumes {
+		return items, nil
+	}
+
+	var sizes map[string]int64
+	for _, m := range info.Mounts {
+		if m.Type != mount.TypeVolume || !anonymousVolumeName.MatchString(m.Name) {
+			continue
+		}
+		if sizes == nil {
+			sizes = map[string]int64{}
+			if volumes, err := volumeUsage(ctx); err == nil {
+				for _, v := range volumes {
+					sizes[v.Name] = volumeSize(v)
+				}
+			}
+		}
+		volSize, ok := sizes[m.Name]
+		if !ok {
+			volSize = -1
+		}
+		items = append(items, ImpactItem{Type: "volume", ID: m.Name, Name: m.Name, Size: volSize})
+	}
+	return items, nil
+}
+
+// compose 项目名称：优先使用已有容器上的标签，没有容器时按 compose 的规则由目录名生成
+func composeProjectLabel(ctx context.Context, projectDir, project string) string {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "ps", "-a", "--format", "{{.Project}}")
+	cmd.Dir = projectDir
+	if out, err := cmd.Output(); err == nil {
+		if name, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n"); name != "" {
+			return name
+		}
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return -1
+	}, project)
+	return strings.TrimLeft(name, "_-")
+}
+
+// compose down 的选项
+type ComposeDownOptions struct {
+	Volumes       bool   `json:"volumes"`        // -v：同时删除 compose 文件中声明的卷和匿名卷
+	RemoveOrphans bool   `json:"remove_orphans"` // --remove-orphans：删除 compose 文件中已不存在的服务的容器
+	Rmi           string `json:"rmi"`            // --rmi：local 删除没有自定义标签的镜像（构建的镜像），all 删除服务使用的所有镜像
+}
+
+func (o ComposeDownOptions) args() []string {
+	args := []string{"compose", "down"}
+	if o.Volumes {
+		args = append(args, "-v")
+	}
+	if o.RemoveOrphans {
+		args = append(args, "--remove-orphans")
+	}
+	if o.Rmi != "" {
+		args = append(args, "--rmi", o.Rmi)
+	}
+	return args
+}
+
+// compose down 影响的对象：按 compose 标签选出项目的容器、网络，-v 时包括卷，--rmi 时包括镜像
+func selectComposeDown(ctx context.Context, projectDir, project string, opts ComposeDownOptions) ([]ImpactItem, error) {
+	cli := getDockerClient()
+	name := composeProjectLabel(ctx, projectDir, project)
+	byProject := filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+name))
+
+	// compose 文件中的服务，不在其中的容器是孤儿容器，没有 --remove-orphans 时不会删除
+	var services map[string]bool
+	cmd := exec.CommandContext(ctx, "docker", "compose", "config", "--services")
+	cmd.Dir = projectDir
+	if out, err := cmd.Output(); err == nil {
+		services = map[string]bool{}
+		for _, s := range strings.Fields(string(out)) {
+			services[s] = true
+		}
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Size: true, Filters: byProject})
+	if err != nil {
+		return nil, fmt.Errorf("获取容器列表失败: %v", err)
+	}
+	items := []ImpactItem{}
+	images := map[string]ImpactItem{}
+	for _, c := range containers {
+		service := c.Labels["com.docker.compose.service"]
+		if services != nil && !services[service] && !opts.RemoveOrphans {
+			continue
+		}
+		cname := c.ID[:12]
+		if len(c.Names) > 0 {
+			cname = strings.TrimPrefix(c.Names[0], "/")
+		}
+		items = append(items, ImpactItem{Type: "container", ID: c.ID, Name: cname, Size: c.SizeRw})
+
+		// local 只包括没有在 compose 文件中指定 image 的服务（镜像名为 <项目>-<服务> 或 <项目>_<服务>）
+		local := c.Image == name+"-"+service || c.Image == name+"_"+service ||
+			strings.HasPrefix(c.Image, name+"-"+service+":") || strings.HasPrefix(c.Image, name+"_"+service+":")
+		if opts.Rmi == "all" || (opts.Rmi == "local" && local) {
+			images[c.ImageID] = ImpactItem{Type: "image", ID: c.ImageID, Name: c.Image, Size: -1}
+		}
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].Name < items[b].Name })
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: byProject})
+	if err != nil {
+		return nil, fmt.Errorf("获取网络列表失败: %v", err)
+	}
+	for _, n := range networks {
+		items = append(items, ImpactItem{Type: "network", ID: n.ID, Name: n.Name})
+	}
+
+	if opts.Volumes {
+		volumes, err := volumeUsage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range volumes {
+			if v.Labels["com.docker.compose.project"] == name {
+				items = append(items, ImpactItem{Type: "volume", ID: v.Name, Name: v.Name, Size: volumeSize(v)})
+			}
+		}
+		// 匿名卷没有 compose 标签，随容器一起删除
+		for _, c := range containers {
+			if services != nil && !services[c.Labels["com.docker.compose.service"]] && !opts.RemoveOrphans {
+				continue
+			}
+			for _, m := range c.Mounts {
+				if m.Type == mount.TypeVolume && anonymousVolumeName.MatchString(m.Name) {
+					items = append(items, ImpactItem{Type: "volume", ID: m.Name, Name: m.Name, Size: -1})
+				}
+			}
+		}
+	}
+
+	if len(images) > 0 {
+		list, err := cli.ImageList(ctx, types.ImageListOptions{SharedSize: true})
+		if err == nil {
+			for _, img := range list {
+				if item, ok := images[img.ID]; ok {
+					item.Size = img.Size
+					if img.SharedSize > 0 {
+						item.Size -= img.SharedSize
+					}
+					images[img.ID] = item
+				}
+			}
+		}
+		for _, item := range images {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// 清理请求：{"all": true}，请求体可以为空
+func decodePruneRequest(w http.ResponseWriter, r *http.Request) (all bool, ok bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return false, false
+	}
+	var req struct {
+		All bool `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return false, false
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return false, false
+	}
+	return req.All, true
+}
+
+func writeImpactReport(w http.ResponseWriter, r *http.Request, report *ImpactReport, target string) {
+	if report.Executed {
+		detail := fmt.Sprintf("%d 个对象，释放 %s", len(report.Items)-report.Failed, report.Reclaimed)
+		if report.Failed > 0 {
+			detail += fmt.Sprintf("，%d 个失败", report.Failed)
+		}
+		writeAuditLog(r.Header.Get("X-Username"), report.Action, target, detail, r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// 清理未使用的镜像（?dry_run=true 只预览）
+func handleImagePrune(w http.ResponseWriter, r *http.Request) {
+	all, ok := decodePruneRequest(w, r)
+	if !ok {
+		return
+	}
+	dryRun, _ := dryRunParam(r)
+	items, err := selectPrunableImages(r.Context(), all)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	report := applyImpact("image_prune", items, dryRun, func(item ImpactItem) error {
+		return removeImageItem(r.Context(), item)
+	})
+	if report.Executed {
+		InvalidateImages()
+	}
+	writeImpactReport(w, r, report, "images")
+}
+
+// 清理未使用的卷（?dry_run=true 只预览）
+func handleVolumePrune(w http.ResponseWriter, r *http.Request) {
+	all, ok := decodePruneRequest(w, r)
+	if !ok {
+		return
+	}
+	dryRun, _ := dryRunParam(r)
+	items, err := selectPrunableVolumes(r.Context(), all)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	report := applyImpact("volume_prune", items, dryRun, func(item ImpactItem) error {
+		return removeVolumeItem(r.Context(), item)
+	})
+	writeImpactReport(w, r, report, "volumes")
+}
+
+// 清理未使用的网络（?dry_run=true 只预览）
+func handleNetworkPrune(w http.ResponseWriter, r *http.Request) {
+	if _, ok := decodePruneRequest(w, r); !ok {
+		return
+	}
+	dryRun, _ := dryRunParam(r)
+	items, err := selectPrunableNetworks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	report := applyImpact("network_prune", items, dryRun, func(item ImpactItem) error {
+		return removeNetworkItem(r.Context(), item)
+	})
+	writeImpactReport(w, r, report, "networks")
+}
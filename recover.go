@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// 进程启动以来恢复的 panic 次数（调试接口 /api/debug/runtime 中的 panics）
+var panicCount atomic.Int64
+
+// 记录 panic 的堆栈，日志带上 context 中的请求 ID
+func logPanic(ctx context.Context, where string, v interface{}) {
+	panicCount.Add(1)
+	componentLogger("panic").ErrorContext(ctx, "Recovered panic",
+		"where", where,
+		"panic", fmt.Sprint(v),
+		"stack", string(debug.Stack()),
+	)
+}
+
+// 在处理请求时派生的协程中恢复 panic（WebSocket 读写循环、SSE 推送、管道复制等）
+// 用法：defer recoverGoroutine(r.Context(), "terminal output")，放在协程的第一个 defer，
+// 这样其他 defer（关闭连接、通知退出）照常执行
+func recoverGoroutine(ctx context.Context, where string) {
+	if v := recover(); v != nil {
+		logPanic(ctx, where, v)
+	}
+}
+
+// 记录响应是否已开始的 ResponseWriter
+type recoveryWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (rw *recoveryWriter) WriteHeader(code int) {
+	rw.started = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recoveryWriter) Write(p []byte) (int, error) {
+	rw.started = true
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *recoveryWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		rw.started = true
+		f.Flush()
+	}
+}
+
+func (rw *recoveryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	rw.started = true
+	return h.Hijack()
+}
+
+func (rw *recoveryWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// 恢复处理请求时的 panic：记录堆栈和请求 ID，响应尚未开始时返回 500 JSON 错误
+// 响应已开始（流式响应、WebSocket）时无法再返回错误，只能中断连接
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// 标准库用于中断响应的 panic，交给 net/http 处理
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logPanic(r.Context(), r.Method+" "+r.URL.Path, v)
+			if rw.started {
+				panic(http.ErrAbortHandler)
+			}
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "服务器内部错误")
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// 同 recoverGoroutine，并将 panic 转为错误交给 onPanic（关闭管道、通知等待方），避免等待方永久阻塞
+func recoverGoroutineWith(ctx context.Context, where string, onPanic func(err error)) {
+	if v := recover(); v != nil {
+		logPanic(ctx, where, v)
+		onPanic(fmt.Errorf("内部错误: %v", v))
+	}
+}
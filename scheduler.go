@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
 
@@ -24,23 +23,24 @@ type ScheduleRequest struct {
 	Labels      map[string]string `json:"labels"`     // 标签
 	NodeID      string            `json:"node_id"`     // 指定节点（可选）
 	Constraints map[string]string `json:"constraints"` // 调度约束（可选）
+	Force       bool              `json:"force"`       // 忽略目标节点的主机端口冲突
 }
 
 // 跨节点创建容器（调度）
 func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
 	if nodeManager == nil || nodeManager.mode != ModeMaster {
-		http.Error(w, "当前节点不是 Master 模式", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
 		return
 	}
 
 	var req ScheduleRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
@@ -53,23 +53,31 @@ func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 		var exists bool
 		targetNode, exists = nodeManager.GetNode(req.NodeID)
 		if !exists || targetNode == nil {
-			http.Error(w, fmt.Sprintf("节点不存在: %s", req.NodeID), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("节点不存在: %s", req.NodeID))
 			return
 		}
 		if targetNode.Status != NodeStatusOnline {
-			http.Error(w, fmt.Sprintf("节点不在线: %s", req.NodeID), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("节点不在线: %s", req.NodeID))
+			return
+		}
+		nodeManager.RLock()
+		atCapacity := targetNode.atCapacity()
+		containers, maxContainers := targetNode.Containers, targetNode.MaxContainers
+		nodeManager.RUnlock()
+		if atCapacity {
+			writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("节点容器数已达上限 (%d/%d)", containers, maxContainers))
 			return
 		}
 	} else {
 		// 自动选择最佳节点
 		targetNode, err = nodeManager.SelectBestNode()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			return
 		}
 	}
 
-	log.Printf("调度容器到节点: %s (%s)", targetNode.Name, targetNode.Address)
+	componentLogger("scheduler").Info("调度容器到节点", "image", req.Image, "name", targetNode.Name, "node_id", targetNode.ID, "address", targetNode.Address)
 
 	// 调用目标节点的 API 创建容器
 	containerConfig := map[string]interface{}{
@@ -78,6 +86,7 @@ func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 		"ports": req.Ports,
 		"env":   req.Env,
 		"labels": req.Labels,
+		"force":  req.Force,
 	}
 
 	jsonData, _ := json.Marshal(containerConfig)
@@ -89,7 +98,7 @@ func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 	
 	httpReq, err := http.NewRequest("POST", workerURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("创建请求失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建请求失败: %v", err))
 		return
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -98,14 +107,18 @@ func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 	
 	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("调用 Worker 节点失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("调用 Worker 节点失败: %v", err))
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		http.Error(w, fmt.Sprintf("Worker 节点错误: %s", string(body)), resp.StatusCode)
+		code, message := parseAPIError(body)
+		if code == "" {
+			code = errorCodeForStatus(resp.StatusCode)
+		}
+		writeError(w, resp.StatusCode, code, fmt.Sprintf("Worker 节点错误: %s", message))
 		return
 	}
 
@@ -124,7 +137,7 @@ func handleContainerSchedule(w http.ResponseWriter, r *http.Request) {
 // 在 Worker 节点创建容器（供 Master 调用）
 func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
 		return
 	}
 
@@ -134,13 +147,26 @@ func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
 		Ports  map[string]string `json:"ports"`
 		Env    map[string]string `json:"env"`
 		Labels map[string]string `json:"labels"`
+		Force  bool              `json:"force"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "请求参数错误", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
 		return
 	}
 
+	// 检查本机端口占用
+	if !req.Force {
+		ports := make([]portRequest, 0, len(req.Ports))
+		for hostPort := range req.Ports {
+			ports = append(ports, portRequest{Port: hostPort})
+		}
+		if err := checkPortConflicts(r.Context(), ports, ""); err != nil {
+			writeError(w, http.StatusConflict, ErrCodeConflict, err.Error())
+			return
+		}
+	}
+
 	// 构建环境变量
 	env := make([]string, 0, len(req.Env))
 	for k, v := range req.Env {
@@ -157,7 +183,7 @@ func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
 		if len(parts) == 2 {
 			port, err := nat.NewPort("tcp", parts[1])
 			if err != nil {
-				http.Error(w, fmt.Sprintf("无效的端口: %s", parts[1]), http.StatusBadRequest)
+				writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("无效的端口: %s", parts[1]))
 				return
 			}
 			exposedPorts[port] = struct{}{}
@@ -183,15 +209,15 @@ func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
 
 	// 创建容器
 	ctx := context.Background()
-	createResp, err := dockerClient.ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
+	createResp, err := getDockerClient().ContainerCreate(ctx, config, hostConfig, nil, nil, req.Name)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("创建容器失败: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建容器失败: %v", err))
 		return
 	}
 
 	// 启动容器
-	if err := dockerClient.ContainerStart(ctx, createResp.ID, types.ContainerStartOptions{}); err != nil {
-		http.Error(w, fmt.Sprintf("启动容器失败: %v", err), http.StatusInternalServerError)
+	if err := getDockerClient().ContainerStart(ctx, createResp.ID, types.ContainerStartOptions{}); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("启动容器失败: %v", err))
 		return
 	}
 
@@ -206,7 +232,7 @@ func handleContainerCreate(w http.ResponseWriter, r *http.Request) {
 // 获取所有节点的容器列表
 func handleAllContainers(w http.ResponseWriter, r *http.Request) {
 	if nodeManager == nil || nodeManager.mode != ModeMaster {
-		http.Error(w, "当前节点不是 Master 模式", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "当前节点不是 Master 模式")
 		return
 	}
 
@@ -214,7 +240,7 @@ func handleAllContainers(w http.ResponseWriter, r *http.Request) {
 	allContainers := make([]map[string]interface{}, 0)
 
 	// 获取本地容器
-	localContainers, _ := dockerClient.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	localContainers, _ := getDockerClient().ContainerList(context.Background(), types.ContainerListOptions{All: true})
 	for _, c := range localContainers {
 		allContainers = append(allContainers, map[string]interface{}{
 			"node_id": "local",
@@ -242,7 +268,7 @@ func handleAllContainers(w http.ResponseWriter, r *http.Request) {
 		
 		httpReq, err := http.NewRequest("GET", workerURL, nil)
 		if err != nil {
-			log.Printf("创建请求失败: %v", err)
+			componentLogger("scheduler").Error("创建请求失败", "node_id", node.ID, "error", err)
 			continue
 		}
 		httpReq.Header.Set("X-Node-ID", masterNodeID)
@@ -250,7 +276,7 @@ func handleAllContainers(w http.ResponseWriter, r *http.Request) {
 		
 		resp, err := http.DefaultClient.Do(httpReq)
 		if err != nil {
-			log.Printf("获取节点 %s 容器列表失败: %v", node.Name, err)
+			componentLogger("scheduler").Warn("获取节点容器列表失败", "name", node.Name, "node_id", node.ID, "error", err)
 			continue
 		}
 		defer resp.Body.Close()
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 全局搜索：在容器、镜像、网络、compose 项目和节点中按名称查找（不区分大小写的子串匹配）
+// 容器和镜像使用列表缓存；Master 模式下包括 Worker 节点的容器（单独缓存，见 cachedWorkerContainers）
+
+const (
+	searchLimitDefault = 20  // 每类结果的默认上限
+	searchLimitMax     = 100 // ?limit= 的最大值
+
+	workerContainersTTL     = 15 * time.Second // Worker 容器列表缓存有效期
+	workerContainersTimeout = 5 * time.Second  // 请求单个 Worker 的超时
+)
+
+// 搜索结果，包含前端跳转到对应页面所需的字段
+type SearchResult struct {
+	Page    string `json:"page"` // 前端页面：containers / images / networks / compose / nodes
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	NodeID  string `json:"node_id,omitempty"` // 容器所在节点，local 表示本机
+	Node    string `json:"node,omitempty"`
+	Project string `json:"project,omitempty"` // compose 项目
+	Matched string `json:"matched"`           // 命中的字段：name / image / label:<键> / tag / project / service
+	Detail  string `json:"detail,omitempty"`  // 辅助信息（状态、镜像、驱动等）
+}
+
+// 一类结果
+type SearchCategory struct {
+	Total     int            `json:"total"`     // 命中总数
+	Truncated bool           `json:"truncated"` // 超出上限时只返回前 limit 条
+	Items     []SearchResult `json:"items"`
+}
+
+type SearchResponse struct {
+	Query      string         `json:"query"`
+	Containers SearchCategory `json:"containers"`
+	Images     SearchCategory `json:"images"`
+	Networks   SearchCategory `json:"networks"`
+	Compose    SearchCategory `json:"compose"`
+	Nodes      SearchCategory `json:"nodes"`
+	Errors     []string       `json:"errors,omitempty"` // 部分数据获取失败时的原因
+}
+
+// Worker 节点容器列表缓存（节点 ID -> 容器），获取失败的节点保留上一次的数据
+var workerContainersCache struct {
+	sync.Mutex
+	data      map[string][]ContainerInfo
+	lastFetch time.Time
+}
+
+// 获取 Worker 节点容器列表（通过节点认证调用 Worker 的 /api/containers）
+func fetchWorkerContainers(ctx context.Context, address string) ([]ContainerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, workerContainersTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/api/containers", address), nil)
+	if err != nil {
+		return nil, err
+	}
+	masterNodeID := "master"
+	httpReq.Header.Set("X-Node-ID", masterNodeID)
+	httpReq.Header.Set("X-Node-Token", generateNodeToken(masterNodeID))
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("节点返回状态码 %d", resp.StatusCode)
+	}
+	var containers []ContainerInfo
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return containers, nil
+}
+
+// 所有在线 Worker 节点的容器，缓存过期时并发刷新
+func cachedWorkerContainers(ctx context.Context) (map[string][]ContainerInfo, []string) {
+	workerContainersCache.Lock()
+	defer workerContainersCache.Unlock()
+
+	if workerContainersCache.data != nil && time.Since(workerContainersCache.lastFetch) < workerContainersTTL {
+		return workerContainersCache.data, nil
+	}
+
+	type nodeAddr struct{ id, name, address string }
+	var online []nodeAddr
+	nodeManager.RLock()
+	for _, n := range nodeManager.nodes {
+		if n.Status == NodeStatusOnline {
+			online = append(online, nodeAddr{n.ID, n.Name, n.Address})
+		}
+	}
+	nodeManager.RUnlock()
+
+	data := make(map[string][]ContainerInfo, len(online))
+	var errs []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, n := range online {
+		wg.Add(1)
+		go func(n nodeAddr) {
+			defer recoverGoroutine(ctx, "search worker containers")
+			defer wg.Done()
+			containers, err := fetchWorkerContainers(ctx, n.address)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", n.name, err))
+				if old, ok := workerContainersCache.data[n.id]; ok {
+					data[n.id] = old
+				}
+				return
+			}
+			data[n.id] = containers
+		}(n)
+	}
+	wg.Wait()
+
+	workerContainersCache.data = data
+	workerContainersCache.lastFetch = time.Now()
+	sort.Strings(errs)
+	return data, errs
+}
+
+// 搜索匹配器：不区分大小写的子串匹配，记录命中结果并按相关度排序
+type searchMatcher struct {
+	q     string
+	limit int
+}
+
+func (m searchMatcher) match(s string) bool {
+	return s != "" && strings.Contains(strings.ToLower(s), m.q)
+}
+
+// 相关度：完全相同 < 前缀匹配 < 子串匹配
+func (m searchMatcher) rank(s string) int {
+	s = strings.ToLower(s)
+	switch {
+	case s == m.q:
+		return 0
+	case strings.HasPrefix(s, m.q):
+		return 1
+	}
+	return 2
+}
+
+// 排序并截断到 limit 条
+func (m searchMatcher) category(items []SearchResult) SearchCategory {
+	sort.SliceStable(items, func(a, b int) bool {
+		ra, rb := m.rank(items[a].Name), m.rank(items[b].Name)
+		if ra != rb {
+			return ra < rb
+		}
+		return strings.ToLower(items[a].Name) < strings.ToLower(items[b].Name)
+	})
+	c := SearchCategory{Total: len(items), Items: items}
+	if len(items) > m.limit {
+		c.Items = items[:m.limit]
+		c.Truncated = true
+	}
+	if c.Items == nil {
+		c.Items = []SearchResult{}
+	}
+	return c
+}
+
+// 容器命中的字段：名称、镜像、标签的键或值
+func (m searchMatcher) containerField(c ContainerInfo) string {
+	if m.match(c.Name) {
+		return "name"
+	}
+	if m.match(c.Image) {
+		return "image"
+	}
+	keys := make([]string, 0, len(c.Labels))
+	for k := range c.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if m.match(k) || m.match(c.Labels[k]) {
+			return "label:" + k
+		}
+	}
+	return ""
+}
+
+func (m searchMatcher) containerResult(c ContainerInfo, nodeID, node string) (SearchResult, bool) {
+	field := m.containerField(c)
+	if field == "" {
+		return SearchResult{}, false
+	}
+	return SearchResult{
+		Page:    "containers",
+		ID:      c.ID,
+		Name:    c.Name,
+		NodeID:  nodeID,
+		Node:    node,
+		Project: c.Labels["com.docker.compose.project"],
+		Matched: field,
+		Detail:  c.Image + " · " + c.State,
+	}, true
+}
+
+// 全局搜索
+// GET /api/search?q=redis&limit=20
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "缺少搜索关键字")
+		return
+	}
+	limit := searchLimitDefault
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > searchLimitMax {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
+
+	m := searchMatcher{q: strings.ToLower(q), limit: limit}
+	resp := SearchResponse{Query: q}
+	var containers, images, networks, compose, nodes []SearchResult
+
+	// 本机容器（compose 服务也从容器标签中获取）
+	var localContainers []ContainerInfo
+	if dockerAvailable() {
+		list, err := listContainersCached(r.Context())
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("获取容器列表失败: %v", err))
+		}
+		localContainers = list
+	} else {
+		resp.Errors = append(resp.Errors, "Docker 守护进程未连接")
+	}
+	for _, c := range localContainers {
+		if res, ok := m.containerResult(c, "local", localNodeName()); ok {
+			containers = append(containers, res)
+		}
+	}
+
+	// Worker 节点容器
+	if nodeManager != nil && nodeManager.mode == ModeMaster {
+		workerData, errs := cachedWorkerContainers(r.Context())
+		for _, e := range errs {
+			resp.Errors = append(resp.Errors, "获取节点容器失败 "+e)
+		}
+		for _, n := range nodeManager.GetAllNodes() {
+			nodeManager.RLock()
+			id, name, address, status := n.ID, n.Name, n.Address, n.Status
+			nodeManager.RUnlock()
+
+			if m.match(name) {
+				nodes = append(nodes, SearchResult{Page: "nodes", ID: id, Name: name, Matched: "name", Detail: address + " · " + status})
+			}
+			for _, c := range workerData[id] {
+				if res, ok := m.containerResult(c, id, name); ok {
+					containers = append(containers, res)
+				}
+			}
+		}
+	}
+
+	if dockerAvailable() {
+		// 镜像标签
+		imageList, err := listImagesCached(false)
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("获取镜像列表失败: %v", err))
+		}
+		for _, img := range imageList {
+			ref := img.Name + ":" + img.Tag
+			if img.Name == "<none>" || !m.match(ref) {
+				continue
+			}
+			images = append(images, SearchResult{Page: "images", ID: img.ID, Name: ref, Matched: "tag", Detail: img.Size})
+		}
+
+		// 网络
+		netList, err := getDockerClient().NetworkList(r.Context(), types.NetworkListOptions{})
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("获取网络列表失败: %v", err))
+		}
+		for _, n := range netList {
+			if m.match(n.Name) {
+				networks = append(networks, SearchResult{Page: "networks", ID: shortImageID(n.ID), Name: n.Name, Matched: "name", Detail: n.Driver})
+			}
+		}
+	}
+
+	// compose 项目（目录名）和服务（容器标签）
+	projects := map[string]bool{}
+	if entries, err := os.ReadDir(composeBaseDir); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				projects[e.Name()] = true
+				if m.match(e.Name()) {
+					compose = append(compose, SearchResult{Page: "compose", Name: e.Name(), Project: e.Name(), Matched: "project"})
+				}
+			}
+		}
+	}
+	services := map[string]bool{}
+	for _, c := range localContainers {
+		project, service := c.Labels["com.docker.compose.project"], c.Labels["com.docker.compose.service"]
+		if project == "" || service == "" || services[project+"/"+service] || !m.match(service) {
+			continue
+		}
+		services[project+"/"+service] = true
+		res := SearchResult{Page: "compose", Name: service, Project: project, Matched: "service"}
+		if !projects[project] {
+			res.Detail = "不是面板管理的项目"
+		}
+		compose = append(compose, res)
+	}
+
+	resp.Containers = m.category(containers)
+	resp.Images = m.category(images)
+	resp.Networks = m.category(networks)
+	resp.Compose = m.category(compose)
+	resp.Nodes = m.category(nodes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 温度读数
+type TemperatureReading struct {
+	Name    string  `json:"name"`
+	Celsius float64 `json:"celsius"`
+}
+
+// 风扇读数
+type FanReading struct {
+	Name string `json:"name"`
+	RPM  int64  `json:"rpm"`
+}
+
+// 读取 sysfs 中的整数文件
+func readSysInt(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// 读取 sysfs 中的字符串文件
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// 读取硬件传感器（温度和风扇），没有传感器时返回空列表
+func readSensors() ([]TemperatureReading, []FanReading) {
+	temps := make([]TemperatureReading, 0)
+	fans := make([]FanReading, 0)
+
+	// hwmon：包含带标签的温度和风扇
+	hwmons, _ := filepath.Glob("/sys/class/hwmon/hwmon*")
+	for _, dir := range hwmons {
+		chip := readSysString(filepath.Join(dir, "name"))
+		if chip == "" {
+			chip = filepath.Base(dir)
+		}
+
+		inputs, _ := filepath.Glob(filepath.Join(dir, "temp*_input"))
+		for _, input := range inputs {
+			milli, ok := readSysInt(input)
+			if !ok {
+				continue
+			}
+			prefix := strings.TrimSuffix(input, "_input")
+			label := readSysString(prefix + "_label")
+			if label == "" {
+				label = filepath.Base(prefix)
+			}
+			temps = append(temps, TemperatureReading{
+				Name:    chip + " " + label,
+				Celsius: float64(milli) / 1000,
+			})
+		}
+
+		fanInputs, _ := filepath.Glob(filepath.Join(dir, "fan*_input"))
+		for _, input := range fanInputs {
+			rpm, ok := readSysInt(input)
+			if !ok {
+				continue
+			}
+			prefix := strings.TrimSuffix(input, "_input")
+			label := readSysString(prefix + "_label")
+			if label == "" {
+				label = filepath.Base(prefix)
+			}
+			fans = append(fans, FanReading{Name: chip + " " + label, RPM: rpm})
+		}
+	}
+
+	// thermal_zone：hwmon 不可用时的补充（部分 ARM 设备只有 thermal_zone）
+	if len(temps) == 0 {
+		zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+		for _, dir := range zones {
+			milli, ok := readSysInt(filepath.Join(dir, "temp"))
+			if !ok {
+				continue
+			}
+			name := readSysString(filepath.Join(dir, "type"))
+			if name == "" {
+				name = filepath.Base(dir)
+			}
+			temps = append(temps, TemperatureReading{Name: name, Celsius: float64(milli) / 1000})
+		}
+	}
+
+	sort.Slice(temps, func(i, j int) bool { return temps[i].Name < temps[j].Name })
+	sort.Slice(fans, func(i, j int) bool { return fans[i].Name < fans[j].Name })
+	return temps, fans
+}
+
+// 获取最高温度（没有传感器时返回 0）
+func getMaxTemperature() float64 {
+	temps, _ := readSensors()
+	maxTemp := 0.0
+	for _, t := range temps {
+		if t.Celsius > maxTemp {
+			maxTemp = t.Celsius
+		}
+	}
+	return maxTemp
+}
+
+// 硬件传感器 API
+func handleSystemSensors(w http.ResponseWriter, r *http.Request) {
+	temps, fans := readSensors()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"temperatures": temps,
+		"fans":         fans,
+	})
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// 面板设置：保存在 auth.db 的 settings 表中，可在运行时修改，无需重启
+// 配置文件和环境变量的值作为部分设置的默认值；保存的值无效时（如升级后校验更严格）使用默认值并记录警告，不影响启动
+
+// 设置值的类型
+const (
+	settingTypeString = "string"
+	settingTypeInt    = "int"
+	settingTypeBool   = "bool"
+)
+
+// 设置项定义
+type settingDef struct {
+	Key         string
+	Section     string
+	Type        string
+	Default     interface{} // string、int64 或 bool
+	Secret      bool        // 只写：接口中返回脱敏值
+	Description string
+	validate    func(v interface{}) error // 可选，类型已检查
+	apply       func(v interface{})       // 可选，启动时和修改后调用（同步到模块的变量）
+}
+
+// 接口中的设置项
+type SettingItem struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"`
+	Value       interface{} `json:"value"`
+	Default     interface{} `json:"default"`
+	Secret      bool        `json:"secret,omitempty"`
+	Modified    bool        `json:"modified"` // 是否与默认值不同（已保存到数据库）
+	Description string      `json:"description"`
+}
+
+var (
+	settingsMu       sync.RWMutex
+	settingDefs      = map[string]*settingDef{}
+	settingValues    = map[string]interface{}{} // 已保存的值（未保存的使用默认值）
+	settingListeners = map[string][]func(v interface{}){}
+)
+
+// 注册设置项定义（默认值依赖配置，需在加载配置后调用）
+func defineSettings() []*settingDef {
+	positiveDays := func(v interface{}) error {
+		if n := v.(int64); n <= 0 || n > 3650 {
+			return fmt.Errorf("应为 1-3650 天")
+		}
+		return nil
+	}
+	return []*settingDef{
+		{
+			Key: "log.level", Section: "general", Type: settingTypeString, Default: appConfig.LogLevel,
+			Description: "日志级别：debug、info、warn、error",
+			validate: func(v interface{}) error {
+				_, err := parseLogLevel(v.(string))
+				return err
+			},
+			apply: func(v interface{}) {
+				if level, err := parseLogLevel(v.(string)); err == nil {
+					logLevel.Set(level)
+				}
+			},
+		},
+		{
+			Key: "cache.ttl", Section: "general", Type: settingTypeInt, Default: int64(appConfig.CacheTTL),
+			Description: "容器列表缓存有效期（秒），0 关闭缓存",
+			validate: func(v interface{}) error {
+				if n := v.(int64); n < 0 || n > 3600 {
+					return fmt.Errorf("应为 0-3600 秒")
+				}
+				return nil
+			},
+			apply: func(v interface{}) { cacheTTL = time.Duration(v.(int64)) * time.Second },
+		},
+		{
+			Key: "notify.enabled", Section: "notifications", Type: settingTypeBool, Default: true,
+			Description: "是否发送通知（关闭时所有渠道都不发送）",
+		},
+		{
+			Key: "auto_update.paused", Section: "auto_update", Type: settingTypeBool, Default: false,
+			Description: "暂停容器自动更新（仍可手动检查）",
+		},
+		{
+			Key: "auto_update.interval_minutes", Section: "auto_update", Type: settingTypeInt, Default: int64(defaultAutoUpdateInterval),
+			Description: "自动更新检查间隔（分钟）",
+			validate: func(v interface{}) error {
+				if v.(int64) < minAutoUpdateInterval {
+					return fmt.Errorf("不能小于 %d", minAutoUpdateInterval)
+				}
+				return nil
+			},
+		},
+		{
+			Key: "registry.server", Section: "registry", Type: settingTypeString, Default: "",
+			Description: "镜像仓库地址（如 registry.example.com），为空表示 Docker Hub；拉取该仓库的镜像时使用下面的账号",
+			validate: func(v interface{}) error {
+				if strings.ContainsAny(v.(string), "/ ") {
+					return fmt.Errorf("应为仓库主机名（可带端口），如 registry.example.com")
+				}
+				return nil
+			},
+		},
+		{
+			Key: "registry.username", Section: "registry", Type: settingTypeString, Default: "",
+			Description: "镜像仓库用户名",
+		},
+		{
+			Key: "registry.password", Section: "registry", Type: settingTypeString, Default: "", Secret: true,
+			Description: "镜像仓库密码或访问令牌",
+		},
+		{
+			Key: "retention.events_days", Section: "retention", Type: settingTypeInt, Default: int64(7),
+			Description: "Docker 事件记录保留天数", validate: positiveDays,
+		},
+		{
+			Key: "retention.notify_deliveries_days", Section: "retention", Type: settingTypeInt, Default: int64(30),
+			Description: "通知发送记录保留天数", validate: positiveDays,
+		},
+		{
+			Key: "retention.task_runs_days", Section: "retention", Type: settingTypeInt, Default: int64(30),
+			Description: "定时任务执行记录保留天数", validate: positiveDays,
+		},
+		{
+			Key: "retention.auto_update_history_days", Section: "retention", Type: settingTypeInt, Default: int64(90),
+			Description: "自动更新记录保留天数", validate: positiveDays,
+		},
+		{
+			Key: "retention.trash_days", Section: "retention", Type: settingTypeInt, Default: int64(7),
+			Description: "回收站中已删除容器的保留天数", validate: positiveDays,
+		},
+	}
+}
+
+// 初始化设置表并读取保存的值
+func initSettings() error {
+	// 先注册定义，即使读取失败也能使用默认值
+	settingsMu.Lock()
+	for _, def := range defineSettings() {
+		settingDefs[def.Key] = def
+	}
+	settingsMu.Unlock()
+
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("创建设置表失败: %v", err)
+	}
+
+	rows, err := authDB.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return fmt.Errorf("读取设置失败: %v", err)
+	}
+	defer rows.Close()
+
+	settingsMu.Lock()
+	for rows.Next() {
+		var key, raw string
+		if rows.Scan(&key, &raw) != nil {
+			continue
+		}
+		def, ok := settingDefs[key]
+		if !ok {
+			log.Printf("[Settings] Ignoring unknown setting %s", key)
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+			log.Printf("警告: 设置 %s 的值无法解析，使用默认值: %v", key, err)
+			continue
+		}
+		value, err := def.normalize(decoded)
+		if err != nil {
+			log.Printf("警告: 设置 %s 的值无效，使用默认值: %v", key, err)
+			continue
+		}
+		settingValues[key] = value
+	}
+	settingsMu.Unlock()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取设置失败: %v", err)
+	}
+
+	for key, def := range settingDefs {
+		if def.apply != nil {
+			def.apply(getSetting(key))
+		}
+	}
+	return nil
+}
+
+// 检查类型并校验，返回规范化的值（int64、bool 或 string）
+func (def *settingDef) normalize(v interface{}) (interface{}, error) {
+	var value interface{}
+	switch def.Type {
+	case settingTypeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("应为字符串")
+		}
+		value = strings.TrimSpace(s)
+	case settingTypeInt:
+		switch n := v.(type) {
+		case float64:
+			if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+				return nil, fmt.Errorf("应为整数")
+			}
+			value = int64(n)
+		case int64:
+			value = n
+		case int:
+			value = int64(n)
+		default:
+			return nil, fmt.Errorf("应为整数")
+		}
+	case settingTypeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("应为 true 或 false")
+		}
+		value = b
+	}
+	if def.validate != nil {
+		if err := def.validate(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// 当前值（未保存时为默认值）
+func getSetting(key string) interface{} {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	if v, ok := settingValues[key]; ok {
+		return v
+	}
+	if def, ok := settingDefs[key]; ok {
+		return def.Default
+	}
+	return nil
+}
+
+// 类型化的读取（键不存在时返回零值）
+func settingString(key string) string {
+	s, _ := getSetting(key).(string)
+	return s
+}
+
+func settingInt(key string) int64 {
+	n, _ := getSetting(key).(int64)
+	return n
+}
+
+func settingBool(key string) bool {
+	b, _ := getSetting(key).(bool)
+	return b
+}
+
+// 以天为单位的保留时间
+func settingDays(key string) time.Duration {
+	return time.Duration(settingInt(key)) * 24 * time.Hour
+}
+
+// 注册设置修改的回调（值保存后调用，不在持有锁时调用）
+func onSettingChange(key string, fn func(v interface{})) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	settingListeners[key] = append(settingListeners[key], fn)
+}
+
+// 校验批量修改，返回规范化的值（nil 表示恢复默认值，脱敏的密钥不修改）
+func validateSettings(changes map[string]interface{}) (map[string]interface{}, error) {
+	settingsMu.RLock()
+	normalized := make(map[string]interface{}, len(changes))
+	for key, v := range changes {
+		def, ok := settingDefs[key]
+		if !ok {
+			settingsMu.RUnlock()
+			return nil, fmt.Errorf("未知的设置项: %s", key)
+		}
+		if v == nil {
+			normalized[key] = nil
+			continue
+		}
+		// 脱敏值表示不修改
+		if s, ok := v.(string); ok && def.Secret && s == notifySecretMask {
+			continue
+		}
+		value, err := def.normalize(v)
+		if err != nil {
+			settingsMu.RUnlock()
+			return nil, fmt.Errorf("%s 设置无效: %v", key, err)
+		}
+		normalized[key] = value
+	}
+	settingsMu.RUnlock()
+	return normalized, nil
+}
+
+// 在一个事务中保存已校验的修改，然后更新内存中的值并通知回调，返回修改的键
+func saveSettings(normalized map[string]interface{}) ([]string, error) {
+	tx, err := authDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for key, value := range normalized {
+		if value == nil {
+			_, err = tx.Exec("DELETE FROM settings WHERE key = ?", key)
+		} else {
+			data, _ := json.Marshal(value)
+			_, err = tx.Exec(`
+				INSERT INTO settings (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+				ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+				key, string(data))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		def       *settingDef
+		value     interface{}
+		listeners []func(v interface{})
+	}
+	var applied []change
+	keys := make([]string, 0, len(normalized))
+	settingsMu.Lock()
+	for key, value := range normalized {
+		def := settingDefs[key]
+		if value == nil {
+			delete(settingValues, key)
+			value = def.Default
+		} else {
+			settingValues[key] = value
+		}
+		keys = append(keys, key)
+		applied = append(applied, change{def, value, settingListeners[key]})
+	}
+	settingsMu.Unlock()
+
+	for _, c := range applied {
+		if c.def.apply != nil {
+			c.def.apply(c.value)
+		}
+		for _, fn := range c.listeners {
+			fn(c.value)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// 按分组返回所有设置项（密钥只返回是否已设置）
+func settingsBySection() map[string][]SettingItem {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	sections := make(map[string][]SettingItem)
+	for key, def := range settingDefs {
+		value, modified := settingValues[key]
+		if !modified {
+			value = def.Default
+		}
+		item := SettingItem{
+			Key:         key,
+			Type:        def.Type,
+			Value:       value,
+			Default:     def.Default,
+			Secret:      def.Secret,
+			Modified:    modified,
+			Description: def.Description,
+		}
+		if def.Secret {
+			if value != "" {
+				item.Value = notifySecretMask
+			}
+			item.Default = ""
+		}
+		sections[def.Section] = append(sections[def.Section], item)
+	}
+	for _, items := range sections {
+		sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	}
+	return sections
+}
+
+// 拉取镜像时使用的仓库凭据（镜像不属于设置中的仓库或未设置账号时返回空字符串）
+func registryAuthFor(ref string) string {
+	username := settingString("registry.username")
+	if username == "" {
+		return ""
+	}
+	if registryHost(ref) != normalizeRegistryHost(settingString("registry.server")) {
+		return ""
+	}
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      username,
+		Password:      settingString("registry.password"),
+		ServerAddress: settingString("registry.server"),
+	})
+	if err != nil {
+		log.Printf("[Settings] Encode registry auth failed: %v", err)
+		return ""
+	}
+	return auth
+}
+
+// 镜像引用所属的仓库（第一段含 . 或 : 或为 localhost 时为仓库地址，否则为 Docker Hub）
+func registryHost(ref string) string {
+	if i := strings.Index(ref, "/"); i > 0 {
+		first := ref[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			return normalizeRegistryHost(first)
+		}
+	}
+	return "docker.io"
+}
+
+func normalizeRegistryHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	switch host {
+	case "", "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return host
+}
+
+// 查看（GET）或修改（PUT {"key": value, ...}，值为 null 时恢复默认值）设置
+func handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var changes map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil || len(changes) == 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+			return
+		}
+		normalized, err := validateSettings(changes)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		keys, err := saveSettings(normalized)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+			return
+		}
+		if len(keys) > 0 {
+			log.Printf("[Settings] Updated: %s", strings.Join(keys, ", "))
+			writeAuditLog(r.Header.Get("X-Username"), "settings_update", strings.Join(keys, ","), "", r.RemoteAddr)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settingsBySection())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 服务根 context：收到退出信号时取消，后台协程和长连接据此退出
+var serverCtx, cancelServerCtx = context.WithCancel(context.Background())
+
+// 退出前需要等待收尾的后台任务（如写入剩余的指标数据）
+var backgroundTasks sync.WaitGroup
+
+// 优雅关闭时等待进行中请求的时间（shutdown_timeout / SHUTDOWN_TIMEOUT，单位秒，默认 30）
+var shutdownTimeout = 30 * time.Second
+
+// 后台任务收尾的等待时间
+const backgroundStopTimeout = 5 * time.Second
+
+// 向 SSE 客户端发送服务关闭事件（命名事件，不会混入普通消息）
+func sendShutdownEvent(w io.Writer, flusher http.Flusher) {
+	fmt.Fprint(w, "event: shutdown\ndata: {\"type\":\"shutdown\",\"message\":\"服务器正在关闭\"}\n\n")
+	flusher.Flush()
+}
+
+// 等待退出信号并优雅关闭服务（onShutdown 在停止接收请求前调用，如 Worker 向 Master 注销）
+func waitForShutdown(server *http.Server, onShutdown func()) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	sig := <-sigCh
+	log.Printf("收到信号 %v，开始关闭服务（最长等待 %v）...", sig, shutdownTimeout)
+
+	// 再次收到信号时立即退出
+	go func() {
+		<-sigCh
+		log.Printf("再次收到退出信号，强制退出")
+		os.Exit(1)
+	}()
+
+	// 通知后台协程和长连接（日志流、终端）退出
+	cancelServerCtx()
+	closeAllTerminals("server shutting down")
+
+	if onShutdown != nil {
+		onShutdown()
+	}
+
+	// 停止接收新请求，等待进行中的请求（如镜像构建）完成
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("等待请求完成超时，强制关闭连接: %v", err)
+		server.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		backgroundTasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(backgroundStopTimeout):
+		log.Printf("等待后台任务退出超时")
+	}
+
+	if cli := getDockerClient(); cli != nil {
+		cli.Close()
+	}
+	if authDB != nil {
+		authDB.Close()
+	}
+	log.Printf("服务已停止")
+}
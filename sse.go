@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SSE 事件（流式创建容器、构建镜像等接口使用）
+type sseEvent struct {
+	Type    string `json:"type"` // start / log / error / success
+	Message string `json:"message,omitempty"`
+	ID      string `json:"id,omitempty"`
+}
+
+// 以 SSE 格式写入一条 JSON 事件（由 json.Marshal 负责转义，换行、控制字符和多字节字符都不会破坏事件格式）
+func sseWriteJSON(w io.Writer, flusher http.Flusher, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// 可在多个协程中并发写入的 SSE 事件流（如同时读取命令的 stdout 和 stderr）
+type sseStream struct {
+	mu      sync.Mutex
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (s *sseStream) send(eventType, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sseWriteJSON(s.w, s.flusher, sseEvent{Type: eventType, Message: message})
+}
+
+func (s *sseStream) success(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sseWriteJSON(s.w, s.flusher, sseEvent{Type: "success", ID: id})
+}
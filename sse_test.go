@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 解析 SSE 响应中的 data 事件
+func parseSSEEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			t.Fatalf("unexpected line %q", line)
+		}
+		var e sseEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("decode %q: %v", data, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// 消息中的换行、引号、反斜杠和控制字符不能破坏事件格式
+func TestSSEWriteJSON(t *testing.T) {
+	messages := []string{
+		"plain",
+		"line 1\nline 2\r\nline 3",
+		`say "hi" and \n literally`,
+		"data: fake event\n\ndata: {\"type\":\"success\"}",
+		"tab\there, bell\a, nul\x00",
+		"中文日志 ✓",
+		"",
+	}
+	rec := httptest.NewRecorder()
+	for _, m := range messages {
+		if err := sseWriteJSON(rec, rec, sseEvent{Type: "log", Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !rec.Flushed {
+		t.Error("events were not flushed")
+	}
+
+	// 每个事件恰好占一行 data 和一个空行
+	body := rec.Body.String()
+	if got := strings.Count(body, "\n\n"); got != len(messages) {
+		t.Errorf("%d event separators, want %d:\n%s", got, len(messages), body)
+	}
+	events := parseSSEEvents(t, body)
+	if len(events) != len(messages) {
+		t.Fatalf("%d events, want %d", len(events), len(messages))
+	}
+	for i, e := range events {
+		if e.Type != "log" || e.Message != messages[i] {
+			t.Errorf("event %d = %+v, want message %q", i, e, messages[i])
+		}
+	}
+}
+
+// 并发写入时事件不会交错
+func TestSSEStreamConcurrent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	s := &sseStream{w: rec, flusher: rec}
+
+	const writers, perWriter = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				s.send("log", strings.Repeat("x\n\"", 100))
+			}
+		}()
+	}
+	wg.Wait()
+	s.success("abc")
+
+	events := parseSSEEvents(t, rec.Body.String())
+	if len(events) != writers*perWriter+1 {
+		t.Fatalf("%d events, want %d", len(events), writers*perWriter+1)
+	}
+	if last := events[len(events)-1]; last.Type != "success" || last.ID != "abc" {
+		t.Errorf("last event = %+v", last)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// 日志流读取失败时发送带实际错误的 JSON error 事件
+func TestStreamContainerLogsError(t *testing.T) {
+	frame := []byte{1, 0, 0, 0, 0, 0, 0, 6}
+	frame = append(frame, "hello\n"...)
+	logs := io.MultiReader(bytes.NewReader(frame), errReader{errors.New("connection reset by peer")})
+
+	rec := httptest.NewRecorder()
+	streamContainerLogs(context.Background(), rec, rec, logs)
+
+	body := rec.Body.String()
+	line, rest, _ := strings.Cut(body, "\n\n")
+	if line != "data: hello" {
+		t.Errorf("first event = %q, want log line", line)
+	}
+	data, ok := strings.CutPrefix(rest, "event: stream-error\n")
+	if !ok {
+		t.Fatalf("missing stream-error event:\n%s", body)
+	}
+	events := parseSSEEvents(t, data)
+	if len(events) != 1 || events[0].Type != "error" || !strings.Contains(events[0].Message, "connection reset by peer") {
+		t.Errorf("error events = %+v", events)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 静态资源的缓存策略：
+// - index.html：no-cache，每次都向服务器确认（ETag 未变时返回 304）
+// - 带版本号的资源（index.html 中引用的 js/css 会加上 ?v=内容哈希）：长期缓存，内容变化后 URL 随之变化
+// - 其他资源：no-cache，依靠 ETag 确认
+const (
+	cacheControlRevalidate = "no-cache"
+	cacheControlImmutable  = "public, max-age=31536000, immutable"
+)
+
+// index.html 中引用的本地 js/css（外部 CDN 地址不处理）
+var staticAssetRefPattern = regexp.MustCompile(`(src|href)="((?:js|css)/[^"?#]+)"`)
+
+// 嵌入静态文件的处理器（ETag 在启动时计算一次）
+type staticHandler struct {
+	fileServer http.Handler
+	etags      map[string]string // 文件路径（不含开头的 /）-> ETag
+	versions   map[string]string // 文件路径 -> 版本号（内容哈希前缀）
+	indexPage  []byte
+	indexETag  string
+}
+
+// 计算内容的强 ETag 和版本号
+func contentHash(data []byte) (etag, version string) {
+	sum := sha256.Sum256(data)
+	h := hex.EncodeToString(sum[:])
+	return `"` + h[:32] + `"`, h[:12]
+}
+
+// 创建静态文件处理器：计算所有文件的哈希，生成带版本号和 <base> 标签的首页
+func newStaticHandler(staticFS fs.FS) (*staticHandler, error) {
+	h := &staticHandler{
+		fileServer: http.FileServer(http.FS(staticFS)),
+		etags:      make(map[string]string),
+		versions:   make(map[string]string),
+	}
+	err := fs.WalkDir(staticFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(staticFS, p)
+		if err != nil {
+			return err
+		}
+		h.etags[p], h.versions[p] = contentHash(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := fs.ReadFile(staticFS, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	page = staticAssetRefPattern.ReplaceAllFunc(page, func(m []byte) []byte {
+		sub := staticAssetRefPattern.FindSubmatch(m)
+		version, ok := h.versions[string(sub[2])]
+		if !ok {
+			return m
+		}
+		return []byte(string(sub[1]) + `="` + string(sub[2]) + "?v=" + version + `"`)
+	})
+	if h.indexPage, err = injectBaseTag(page); err != nil {
+		return nil, err
+	}
+	h.indexETag, _ = contentHash(h.indexPage)
+	return h, nil
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 排除 API 路径（虽然正常不会走到这里，但作为兜底）
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "接口不存在")
+		return
+	}
+
+	// 兼容 /static/ 前缀的请求
+	if strings.HasPrefix(r.URL.Path, "/static/") {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/static")
+	}
+
+	if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+		w.Header().Set("Cache-Control", cacheControlRevalidate)
+		w.Header().Set("ETag", h.indexETag)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeContent(w, r, "index.html", time.Time{}, bytes.NewReader(h.indexPage))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if etag, ok := h.etags[name]; ok {
+		// http.FileServer 会根据已设置的 ETag 处理 If-None-Match 并返回 304
+		w.Header().Set("ETag", etag)
+		if v := r.URL.Query().Get("v"); v != "" && v == h.versions[name] {
+			w.Header().Set("Cache-Control", cacheControlImmutable)
+		} else {
+			w.Header().Set("Cache-Control", cacheControlRevalidate)
+		}
+	}
+	h.fileServer.ServeHTTP(w, r)
+}
@@ -4,11 +4,12 @@
 
 let systemStatsInterval = null;
 let containersInterval = null;
+let dockerStatusInterval = null;
 
 // 加载系统监控数据
 async function loadSystemStats() {
     try {
-        const response = await authFetch('/api/system/stats');
+        const response = await authFetch('api/system/stats');
         if (!response.ok) throw new Error('获取系统信息失败');
         const data = await response.json();
         
@@ -29,14 +30,80 @@ function showMainPage() {
     loadContainers();
     loadImages();
     loadComposeProjects();
+    checkDockerStatus();
+    loadCapabilities();
     startIntervals();
 }
 
+// 检查依赖 docker 命令行的功能，不可用时隐藏 Compose 标签页和 docker run 命令模式
+async function loadCapabilities() {
+    try {
+        const response = await authFetch('api/system/capabilities');
+        if (!response.ok) return;
+        const caps = await response.json();
+        const composeTab = document.querySelector('.tab-btn[data-tab="compose"]');
+        if (composeTab) {
+            composeTab.classList.toggle('hidden', !caps.compose_ops);
+            composeTab.title = caps.compose_ops ? '' : (caps.compose.hint || '');
+        }
+        const cmdModeBtn = DOM.get('cc-mode-cmd');
+        if (cmdModeBtn) {
+            cmdModeBtn.disabled = !caps.run_command;
+            cmdModeBtn.classList.toggle('opacity-50', !caps.run_command);
+            cmdModeBtn.title = caps.run_command ? '' : (caps.docker_cli.hint || '');
+        }
+    } catch (error) {
+        console.error('检查功能可用性失败:', error);
+    }
+}
+
+// 检查 Docker 连接状态和 API 版本兼容性，断开或不兼容时显示提示横幅
+async function checkDockerStatus() {
+    try {
+        const response = await authFetch('api/system/docker-status');
+        if (!response.ok) return;
+        const status = await response.json();
+        const banner = DOM.get('docker-compat-banner');
+        if (!status.connected) {
+            const since = status.down_since ? new Date(status.down_since).toLocaleString() : '-';
+            banner.innerHTML = `
+                <div class="font-semibold">${escapeHtml(t('docker.disconnected'))}</div>
+                <div class="mt-1">${escapeHtml(t('docker.downSince', { time: since }))}</div>
+                <div class="mt-1">${escapeHtml(status.last_error || '')}</div>
+            `;
+            banner.classList.remove('hidden');
+            return;
+        }
+        const compat = status.compat;
+        if (!compat || compat.compatible) {
+            banner.classList.add('hidden');
+            return;
+        }
+        const versions = t('docker.versions', {
+            client: compat.client_api_version || '-',
+            daemon: compat.daemon_version || '-',
+            min: compat.daemon_min_api_version || '-',
+            max: compat.daemon_api_version || '-'
+        });
+        banner.innerHTML = `
+            <div class="font-semibold">${escapeHtml(t('docker.incompatible'))}</div>
+            <div class="mt-1">${escapeHtml(versions)}</div>
+            <div class="mt-1">${escapeHtml(compat.hint || '')}</div>
+        `;
+        banner.classList.remove('hidden');
+    } catch (error) {
+        console.error('检查 Docker 状态失败:', error);
+    }
+}
+
 // 启动定时器
 function startIntervals() {
     if (!systemStatsInterval) {
         systemStatsInterval = setInterval(loadSystemStats, 5000);
     }
+    if (!dockerStatusInterval) {
+        dockerStatusInterval = setInterval(checkDockerStatus, 10000);
+    }
     startContainersAutoRefresh();
 }
 
@@ -46,6 +113,10 @@ function stopAllIntervals() {
         clearInterval(systemStatsInterval);
         systemStatsInterval = null;
     }
+    if (dockerStatusInterval) {
+        clearInterval(dockerStatusInterval);
+        dockerStatusInterval = null;
+    }
     stopContainersAutoRefresh();
 }
 
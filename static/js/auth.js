@@ -8,12 +8,16 @@ let needChangePassword = false;
 // 检查登录状态
 async function checkAuth() {
     try {
-        const response = await fetch('/api/auth/me', { credentials: 'include' });
+        const response = await fetch('api/auth/me', { credentials: 'include' });
         if (response.ok) {
             const data = await response.json();
             authToken = document.cookie.match(/token=([^;]+)/)?.[1] || '';
             needChangePassword = data.need_change_password;
             DOM.get('current-user').textContent = `用户: ${data.username}`;
+            // 使用服务端保存的语言偏好
+            if (data.language && typeof i18n !== 'undefined') {
+                i18n.setLanguage(data.language.startsWith('zh') ? 'zh' : 'en', false);
+            }
             return true;
         }
         return false;
@@ -30,7 +34,7 @@ async function handleLogin(event) {
     const errorDiv = DOM.get('login-error');
 
     try {
-        const response = await fetch('/api/auth/login', {
+        const response = await fetch('api/auth/login', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
@@ -50,7 +54,7 @@ async function handleLogin(event) {
                 showMainPage();
             }
         } else {
-            errorDiv.textContent = data.error || '登录失败';
+            errorDiv.textContent = errorMessage(data) || '登录失败';
             errorDiv.classList.remove('hidden');
         }
     } catch (error) {
@@ -89,7 +93,7 @@ async function handleChangePassword(event) {
     const oldPassword = oldPasswordDiv.classList.contains('hidden') ? '' : oldPasswordInput.value;
 
     try {
-        const response = await fetch('/api/auth/change-password', {
+        const response = await fetch('api/auth/change-password', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
@@ -105,7 +109,7 @@ async function handleChangePassword(event) {
             showMainPage();
             showToast('密码修改成功', 'success');
         } else {
-            errorDiv.textContent = data.error || '修改密码失败';
+            errorDiv.textContent = errorMessage(data) || '修改密码失败';
             errorDiv.classList.remove('hidden');
         }
     } catch (error) {
@@ -117,7 +121,7 @@ async function handleChangePassword(event) {
 // 登出
 async function handleLogout() {
     try {
-        await fetch('/api/auth/logout', { method: 'POST', credentials: 'include' });
+        await fetch('api/auth/logout', { method: 'POST', credentials: 'include' });
     } catch (error) {
         console.error('登出失败:', error);
     }
@@ -151,7 +155,7 @@ function showChangePasswordModal(showOldPassword = false) {
 async function authFetch(url, options = {}) {
     const defaultOptions = {
         credentials: 'include',
-        headers: { 'Content-Type': 'application/json', ...options.headers }
+        headers: { 'Content-Type': 'application/json', 'Accept-Language': apiLanguage(), ...options.headers }
     };
 
     const response = await fetch(url, { ...defaultOptions, ...options });
@@ -163,12 +167,33 @@ async function authFetch(url, options = {}) {
 
     if (response.status === 403) {
         const data = await response.json();
-        if (data.need_change_password) {
+        if (data.error && data.error.code === 'NEED_CHANGE_PASSWORD') {
             DOM.get('main-page').classList.add('hidden');
             showChangePasswordModal(true);
         }
-        throw new Error(data.error || '禁止访问');
+        throw new Error(errorMessage(data) || '禁止访问');
     }
 
     return response;
 }
+
+// 接口错误信息使用的语言，与界面语言一致
+function apiLanguage() {
+    return typeof i18n !== 'undefined' && i18n.currentLang === 'en' ? 'en' : 'zh-CN';
+}
+
+// 错误响应中的错误信息（{"error": {"code": "...", "message": "..."}}）
+function errorMessage(data) {
+    if (!data || !data.error) return '';
+    return typeof data.error === 'string' ? data.error : (data.error.message || '');
+}
+
+// 读取失败响应的错误信息，非 JSON 响应（如反向代理的错误页）直接返回文本
+async function readError(response, fallback = '') {
+    const text = await response.text();
+    try {
+        return errorMessage(JSON.parse(text)) || fallback;
+    } catch (e) {
+        return text || fallback;
+    }
+}
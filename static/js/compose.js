@@ -7,7 +7,7 @@ let composeProjects = [];
 
 // 加载项目列表
 function loadComposeProjects() {
-    fetch('/api/compose/list?t=' + Date.now(), { credentials: 'include' })
+    fetch('api/compose/list?t=' + Date.now(), { credentials: 'include' })
         .then(res => {
             if (res.status === 401) { handleLogout(); return; }
             return res.json();
@@ -69,7 +69,7 @@ function renderComposeList() {
 
 // 加载列表中项目的状态指示器
 function loadComposeListStatus(name) {
-    fetch(`/api/compose/status?project=${name}`, { credentials: 'include' })
+    fetch(`api/compose/status?project=${name}`, { credentials: 'include' })
         .then(res => res.json())
         .then(data => {
             // 桌面端状态点
@@ -165,7 +165,7 @@ function loadComposeStatus(name) {
     
     if (containersList) containersList.innerHTML = '<div class="text-gray-400 text-xs">加载中...</div>';
     
-    fetch(`/api/compose/status?project=${name}`, { credentials: 'include' })
+    fetch(`api/compose/status?project=${name}`, { credentials: 'include' })
         .then(res => res.json())
         .then(data => {
             // 更新状态徽章
@@ -212,7 +212,7 @@ function loadComposeStatus(name) {
 
 // 加载 compose 文件
 function loadComposeFile(name) {
-    fetch(`/api/compose/file?project=${name}`, { credentials: 'include' })
+    fetch(`api/compose/file?project=${name}`, { credentials: 'include' })
         .then(res => res.text())
         .then(text => {
             const isMobile = window.innerWidth < 768;
@@ -267,7 +267,7 @@ function saveCurrentComposeFile() {
     const editor = DOM.get(isMobile ? 'compose-mobile-editor' : 'compose-detail-editor');
     if (!editor) return;
     
-    fetch('/api/compose/save', {
+    fetch('api/compose/save', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
@@ -277,7 +277,7 @@ function saveCurrentComposeFile() {
         if (res.ok) {
             showToast('文件已保存', 'success');
         } else {
-            showToast(await res.text(), 'error', { title: '保存失败' });
+            showToast(await readError(res), 'error', { title: '保存失败' });
         }
     });
 }
@@ -307,14 +307,21 @@ async function composeAction(action) {
     }
     
     try {
-        const res = await fetch('/api/compose/action', {
+        const res = await fetch('api/compose/action', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
             body: JSON.stringify({ project: currentComposeProject, action })
         });
         
-        const text = await res.text();
+        let text = await res.text();
+        if (!res.ok) {
+            // 失败时输出在错误详情中
+            try {
+                const data = JSON.parse(text);
+                text = `${errorMessage(data)}\n${(data.error.details && data.error.details.output) || ''}`;
+            } catch (e) {}
+        }
         if (outputDiv) {
             outputDiv.textContent += text;
             outputDiv.scrollTop = outputDiv.scrollHeight;
@@ -348,7 +355,7 @@ async function deleteComposeProject() {
     if (!confirmed) return;
     
     try {
-        const res = await fetch('/api/compose/delete', {
+        const res = await fetch('api/compose/delete', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             credentials: 'include',
@@ -362,7 +369,7 @@ async function deleteComposeProject() {
             DOM.get('compose-detail-empty').classList.remove('hidden');
             loadComposeProjects();
         } else {
-            showToast(await res.text(), 'error', { title: '删除失败' });
+            showToast(await readError(res), 'error', { title: '删除失败' });
         }
     } catch (err) {
         showToast(err.message, 'error');
@@ -388,7 +395,7 @@ function createComposeProject() {
         return;
     }
 
-    fetch('/api/compose/create', {
+    fetch('api/compose/create', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
@@ -402,7 +409,7 @@ function createComposeProject() {
             // 自动选中新项目
             setTimeout(() => selectComposeProject(name), 300);
         } else {
-            showToast(await res.text(), 'error', { title: '创建失败' });
+            showToast(await readError(res), 'error', { title: '创建失败' });
         }
     });
 }
@@ -20,8 +20,8 @@ async function loadContainers(force = false) {
     if (containerActionInProgress && !force) return;
     
     try {
-        const response = await authFetch('/api/containers');
-        if (!response.ok) throw new Error(await response.text() || '获取容器列表失败');
+        const response = await authFetch('api/containers');
+        if (!response.ok) throw new Error(await readError(response, '获取容器列表失败'));
         
         const data = await response.json();
         if (!Array.isArray(data)) {
@@ -117,6 +117,11 @@ function renderContainersTable(data) {
         const filesBtn = isRunning ?
             `<button onclick="openFilesModal('${container.id}', '${escapedName}')" class="action-btn bg-indigo-500 text-white rounded text-xs hover:bg-indigo-600">${t('container.files')}</button>` : '';
         
+        // 自动更新开关（开启时高亮）
+        const autoUpdateBtn = container.auto_update ?
+            `<button onclick="toggleAutoUpdate('${escapedName}', false)" class="action-btn bg-emerald-500 text-white rounded text-xs hover:bg-emerald-600" title="${t('container.autoUpdate.on')}">${t('container.autoUpdate')} ✓</button>` :
+            `<button onclick="toggleAutoUpdate('${escapedName}', true)" class="action-btn bg-gray-400 text-white rounded text-xs hover:bg-gray-500" title="${t('container.autoUpdate.off')}">${t('container.autoUpdate')}</button>`;
+        
         // 资源列：优先使用缓存数据，避免闪烁
         let resourcesCell = '-';
         if (isRunning) {
@@ -143,6 +148,7 @@ function renderContainersTable(data) {
                         <button onclick="viewLogs('${container.id}', '${escapedName}')" class="action-btn bg-purple-500 text-white rounded text-xs hover:bg-purple-600">${t('container.logs')}</button>
                         ${terminalBtn}${filesBtn}
                         <button onclick="openContainerConfigModal('${container.id}')" class="action-btn bg-teal-500 text-white rounded text-xs hover:bg-teal-600">${t('container.config')}</button>
+                        ${autoUpdateBtn}
                         <button onclick="containerAction('${container.id}', 'remove', '${escapedName}')" class="action-btn bg-red-500 text-white rounded text-xs hover:bg-red-600">${t('container.remove')}</button>
                     </div>
                 </td>
@@ -163,7 +169,7 @@ async function containerAction(id, action, containerName) {
     if (action === 'remove') {
         const confirmed = await showConfirm({
             title: '删除容器',
-            message: `确定要删除容器 <strong>${containerName || id}</strong> 吗？<br><span style="color:#ef4444;font-size:12px;">此操作不可恢复！</span>`,
+            message: `确定要删除容器 <strong>${containerName || id}</strong> 吗？<br><span style="color:#ef4444;font-size:12px;">容器配置会保留在回收站中，可以恢复；容器内写入的数据和匿名卷无法恢复。</span>`,
             type: 'danger',
             confirmText: '确认删除'
         });
@@ -189,12 +195,12 @@ async function containerAction(id, action, containerName) {
     });
 
     try {
-        const response = await authFetch('/api/containers/action', {
+        const response = await authFetch('api/containers/action', {
             method: 'POST',
             body: JSON.stringify({ id, action })
         });
 
-        if (!response.ok) throw new Error(await response.text());
+        if (!response.ok) throw new Error(await readError(response));
         showToast(`容器 ${containerName || id} ${actionMap[action]}成功`, 'success', { title: actionMap[action] + '成功' });
     } catch (error) {
         showToast(error.message, 'error', { title: actionMap[action] + '失败' });
@@ -205,6 +211,22 @@ async function containerAction(id, action, containerName) {
     }
 }
 
+// 开启或关闭容器自动更新
+async function toggleAutoUpdate(name, enabled) {
+    try {
+        const response = await authFetch('api/auto-update/container', {
+            method: 'POST',
+            body: JSON.stringify({ name, enabled })
+        });
+        if (!response.ok) throw new Error(await readError(response));
+        showToast(`${name}: ${enabled ? t('container.autoUpdate.on') : t('container.autoUpdate.off')}`, 'success');
+    } catch (error) {
+        showToast(error.message, 'error', { title: t('container.autoUpdate') });
+    } finally {
+        loadContainers(true);
+    }
+}
+
 // 刷新容器
 async function refreshContainers() {
     const icon = DOM.get('refresh-containers-icon');
@@ -741,7 +763,7 @@ async function submitCreateContainer(e) {
     
     try {
         // 使用 credentials: 'include' 发送 Cookie，和 authFetch 保持一致
-        const response = await fetch('/api/containers/run/stream', {
+        const response = await fetch('api/containers/run/stream', {
             method: 'POST',
             credentials: 'include',
             headers: {
@@ -751,12 +773,7 @@ async function submitCreateContainer(e) {
         });
         
         if (!response.ok) {
-            let errMsg = await response.text();
-            // 尝试解析 JSON 错误
-            try {
-                const errJson = JSON.parse(errMsg);
-                errMsg = errJson.error || errJson.message || errMsg;
-            } catch (e) {}
+            const errMsg = await readError(response);
             appendLog(errMsg, 'error');
             showToast(errMsg, 'error', { title: '创建失败' });
             return;
@@ -849,7 +866,9 @@ async function loadContainerStats(containerId) {
     if (!el) return;
     
     try {
-        const response = await authFetch(`/api/containers/stats?id=${containerId}`);
+        const response = await authFetch(`api/containers/stats?id=${containerId}`);
+        // 服务端并发已满时保留上一次的数据，等下一轮刷新
+        if (response.status === 429) return;
         if (!response.ok) {
             el.textContent = '-';
             delete containerStatsCache[containerId];
@@ -914,7 +933,7 @@ async function submitRawDockerCommand() {
     };
     
     try {
-        const response = await fetch('/api/containers/run/raw', {
+        const response = await fetch('api/containers/run/raw', {
             method: 'POST',
             credentials: 'include',
             headers: { 'Content-Type': 'application/json' },
@@ -922,11 +941,7 @@ async function submitRawDockerCommand() {
         });
         
         if (!response.ok) {
-            let errMsg = await response.text();
-            try {
-                const errJson = JSON.parse(errMsg);
-                errMsg = errJson.error || errJson.message || errMsg;
-            } catch (e) {}
+            const errMsg = await readError(response);
             appendLog(errMsg, 'error');
             showToast(errMsg, 'error', { title: '执行失败' });
             return;
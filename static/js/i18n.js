@@ -78,6 +78,9 @@ const i18n = {
             'container.files': '文件',
             'container.config': '配置',
             'container.remove': '删除',
+            'container.autoUpdate': '自动更新',
+            'container.autoUpdate.on': '已开启自动更新',
+            'container.autoUpdate.off': '未开启自动更新',
             'container.empty': '暂无匹配的容器',
             
             // 创建容器
@@ -230,15 +233,25 @@ const i18n = {
             'files.enterDirName': '请输入目录名称',
             'files.createSuccess': '目录创建成功',
             'files.createFailed': '创建失败',
-            'files.sizeLimit': '文件大小不能超过 10MB',
             'files.uploadSuccess': '上传成功',
             'files.uploadFailed': '上传失败',
             'files.downloadFailed': '下载失败',
             'files.readFailed': '读取文件失败',
+            'files.binaryFile': '二进制文件，无法在编辑器中打开，请下载查看',
+            'files.truncatedReadOnly': '文件过大，只显示前 1MB，已设为只读',
+            'docker.incompatible': 'Docker API 版本不兼容，容器、镜像等功能不可用',
+            'docker.versions': '面板 API 版本 {client}，Docker 守护进程 {daemon}（支持 API {min} ~ {max}）',
+            'docker.disconnected': 'Docker 守护进程未连接，正在自动重连，容器、镜像等功能暂不可用',
+            'docker.downSince': '断开时间: {time}',
             'files.directory': '目录',
             'files.file': '文件',
             'files.confirmDelete': '确定要删除这个',
             'files.download': '下载',
+            'files.rename': '重命名/移动',
+            'files.enterNewPath': '请输入新路径',
+            'files.confirmOverwrite': '目标已存在，是否覆盖？',
+            'files.renameSuccess': '移动成功',
+            'files.renameFailed': '移动失败',
             
             // 容器配置
             'config.noPorts': '无端口映射',
@@ -343,6 +356,9 @@ const i18n = {
             'container.files': 'Files',
             'container.config': 'Config',
             'container.remove': 'Remove',
+            'container.autoUpdate': 'Auto-update',
+            'container.autoUpdate.on': 'Auto-update enabled',
+            'container.autoUpdate.off': 'Auto-update disabled',
             'container.empty': 'No containers found',
             
             // Create Container
@@ -495,15 +511,25 @@ const i18n = {
             'files.enterDirName': 'Enter directory name',
             'files.createSuccess': 'Directory created',
             'files.createFailed': 'Create failed',
-            'files.sizeLimit': 'File size cannot exceed 10MB',
             'files.uploadSuccess': 'Upload successful',
             'files.uploadFailed': 'Upload failed',
             'files.downloadFailed': 'Download failed',
             'files.readFailed': 'Failed to read file',
+            'files.binaryFile': 'Binary file cannot be opened in the editor, please download it',
+            'files.truncatedReadOnly': 'File too large, showing the first 1MB as read-only',
+            'docker.incompatible': 'Docker API version mismatch, containers, images and related features are unavailable',
+            'docker.versions': 'Panel API version {client}, Docker daemon {daemon} (supports API {min} - {max})',
+            'docker.disconnected': 'Docker daemon is unreachable, reconnecting automatically. Containers, images and related features are temporarily unavailable',
+            'docker.downSince': 'Down since: {time}',
             'files.directory': 'directory',
             'files.file': 'file',
             'files.confirmDelete': 'Are you sure to delete this',
             'files.download': 'Download',
+            'files.rename': 'Rename/Move',
+            'files.enterNewPath': 'Enter new path',
+            'files.confirmOverwrite': 'Destination exists. Overwrite?',
+            'files.renameSuccess': 'Moved successfully',
+            'files.renameFailed': 'Move failed',
             
             // Container config
             'config.noPorts': 'No port mappings',
@@ -564,9 +590,22 @@ const i18n = {
     
     // 切换语言
     toggle() {
-        this.currentLang = this.currentLang === 'zh' ? 'en' : 'zh';
+        this.setLanguage(this.currentLang === 'zh' ? 'en' : 'zh', true);
+    },
+
+    // 设置语言，save 为 true 时同时保存为用户偏好（接口错误信息随之切换语言）
+    setLanguage(lang, save) {
+        if (!this.messages[lang] || lang === this.currentLang) return;
+        this.currentLang = lang;
         localStorage.setItem('rabbit-panel-lang', this.currentLang);
         this.updateUI();
+        if (!save) return;
+        if (typeof authFetch === 'function') {
+            authFetch('api/auth/language', {
+                method: 'POST',
+                body: JSON.stringify({ language: lang === 'zh' ? 'zh-CN' : 'en' })
+            }).catch(() => {});
+        }
         // 刷新数据显示
         if (typeof loadContainers === 'function') loadContainers(true);
         if (typeof loadImages === 'function') loadImages(true);
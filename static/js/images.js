@@ -17,9 +17,9 @@ window.paginators['images-pagination'] = imagePaginator;
 // 加载镜像列表（支持强制刷新）
 async function loadImages(forceRefresh = false) {
     try {
-        const url = forceRefresh ? '/api/images?refresh=true' : '/api/images';
+        const url = forceRefresh ? 'api/images?refresh=true' : 'api/images';
         const response = await authFetch(url);
-        if (!response.ok) throw new Error(await response.text() || '获取镜像列表失败');
+        if (!response.ok) throw new Error(await readError(response, '获取镜像列表失败'));
         
         const data = await response.json();
         if (!Array.isArray(data)) {
@@ -116,7 +116,7 @@ async function removeImage(id, name) {
     filterImages();
 
     try {
-        const response = await authFetch('/api/images/remove', {
+        const response = await authFetch('api/images/remove', {
             method: 'POST',
             body: JSON.stringify({ id })
         });
@@ -127,7 +127,7 @@ async function removeImage(id, name) {
             imagePaginator.setData(allImagesData);
             applyImageSort();
             filterImages();
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         showToast(`镜像 ${name} 已删除`, 'success', { title: '删除成功' });
         // 强制刷新列表
@@ -230,7 +230,7 @@ async function buildImage() {
     btn.innerHTML = '<span class="inline-flex items-center"><svg class="animate-spin -ml-1 mr-2 h-4 w-4" xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24"><circle class="opacity-25" cx="12" cy="12" r="10" stroke="currentColor" stroke-width="4"></circle><path class="opacity-75" fill="currentColor" d="M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z"></path></svg>' + t('build.building') + '</span>';
 
     try {
-        const response = await authFetch('/api/images/build', {
+        const response = await authFetch('api/images/build', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -25,7 +25,7 @@ function viewLogs(id, name) {
         logEventSource = null;
     }
 
-    const eventSource = new EventSource(`/api/containers/logs?id=${id}`);
+    const eventSource = new EventSource(`api/containers/logs?id=${id}`);
     logEventSource = eventSource;
 
     eventSource.onmessage = function(event) {
@@ -43,6 +43,26 @@ function viewLogs(id, name) {
         }
     };
 
+    // 服务器关闭时停止日志流，避免浏览器自动重连
+    eventSource.addEventListener('shutdown', function() {
+        currentLogContent += '\n[服务器正在关闭，日志流已断开]';
+        logContainer.textContent = currentLogContent;
+        eventSource.close();
+        logEventSource = null;
+    });
+
+    // 读取日志失败：显示错误并停止，避免浏览器自动重连后重复输出
+    eventSource.addEventListener('stream-error', function(event) {
+        let message = '读取日志失败';
+        try {
+            message = JSON.parse(event.data).message || message;
+        } catch (e) {}
+        currentLogContent += `\n[${message}]`;
+        logContainer.textContent = currentLogContent;
+        eventSource.close();
+        logEventSource = null;
+    });
+
     eventSource.onerror = function(error) {
         console.error('日志流错误:', error);
         if (eventSource.readyState === EventSource.CLOSED) {
@@ -17,8 +17,8 @@ window.paginators['networks-pagination'] = networkPaginator;
 // 加载网络列表
 async function loadNetworks() {
     try {
-        const response = await authFetch('/api/networks');
-        if (!response.ok) throw new Error(await response.text() || '获取网络列表失败');
+        const response = await authFetch('api/networks');
+        if (!response.ok) throw new Error(await readError(response, '获取网络列表失败'));
         
         const data = await response.json();
         if (!Array.isArray(data)) {
@@ -104,13 +104,13 @@ async function removeNetwork(id, name) {
     if (!confirmed) return;
 
     try {
-        const response = await authFetch('/api/networks/remove', {
+        const response = await authFetch('api/networks/remove', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ id })
         });
 
-        if (!response.ok) throw new Error(await response.text());
+        if (!response.ok) throw new Error(await readError(response));
         showToast(t('network.deleteSuccess'), 'success');
         loadNetworks();
     } catch (error) {
@@ -160,13 +160,13 @@ async function createNetwork() {
     btn.innerHTML = t('common.loading');
 
     try {
-        const response = await authFetch('/api/networks/create', {
+        const response = await authFetch('api/networks/create', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ name, driver, subnet, gateway, internal })
         });
 
-        if (!response.ok) throw new Error(await response.text());
+        if (!response.ok) throw new Error(await readError(response));
         
         showToast(t('network.createSuccess'), 'success');
         closeCreateNetworkModal();
@@ -182,8 +182,8 @@ async function createNetwork() {
 // 查看网络详情
 async function viewNetworkDetail(id) {
     try {
-        const response = await authFetch('/api/networks/inspect?id=' + id);
-        if (!response.ok) throw new Error(await response.text());
+        const response = await authFetch('api/networks/inspect?id=' + id);
+        if (!response.ok) throw new Error(await readError(response));
         
         const network = await response.json();
         
@@ -78,8 +78,9 @@ function initXterm(containerId) {
 
 // 连接 WebSocket
 function connectTerminalWS(containerId) {
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/api/containers/terminal/ws?id=${containerId}`;
+    // 相对于 <base> 解析，支持部署在 URL 前缀下
+    const wsUrl = new URL(`api/containers/terminal/ws?id=${containerId}`, document.baseURI);
+    wsUrl.protocol = wsUrl.protocol === 'https:' ? 'wss:' : 'ws:';
     
     term.writeln('\x1b[33mConnecting to container...\x1b[0m');
     
@@ -187,10 +188,10 @@ async function loadFilesList(path) {
     tbody.innerHTML = '<tr><td colspan="5" class="px-4 py-8 text-center text-gray-500">' + t('common.loading') + '</td></tr>';
     
     try {
-        const response = await authFetch('/api/containers/files?id=' + currentFileContainer + '&path=' + encodeURIComponent(currentFilePath));
+        const response = await authFetch('api/containers/files?id=' + currentFileContainer + '&path=' + encodeURIComponent(currentFilePath));
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         const files = await response.json();
@@ -228,6 +229,9 @@ function renderFilesList(files) {
         } else {
             html += '<span>' + icon + ' ' + escapeHtml(file.name) + '</span>';
         }
+        if (file.link_target) {
+            html += '<span class="text-xs text-gray-400"> → ' + escapeHtml(file.link_target) + '</span>';
+        }
         html += '</td>';
         html += '<td class="px-4 py-2 text-sm text-gray-500">' + size + '</td>';
         html += '<td class="px-4 py-2 text-sm text-gray-500">' + file.mode + '</td>';
@@ -237,6 +241,7 @@ function renderFilesList(files) {
             html += '<button onclick="downloadFile(\'' + file.path + '\')" class="text-blue-500 hover:text-blue-700 text-sm" title="' + t('files.download') + '">⬇️</button>';
             html += '<button onclick="editFile(\'' + file.path + '\')" class="text-green-500 hover:text-green-700 text-sm" title="' + t('files.edit') + '">✏️</button>';
         }
+        html += '<button onclick="renameFile(\'' + file.path + '\')" class="text-gray-500 hover:text-gray-700 text-sm" title="' + t('files.rename') + '">🔀</button>';
         html += '<button onclick="deleteFile(\'' + file.path + '\', ' + file.is_dir + ')" class="text-red-500 hover:text-red-700 text-sm" title="' + t('common.delete') + '">🗑️</button>';
         html += '</div></td></tr>';
     }
@@ -258,7 +263,7 @@ async function createDirectory() {
     if (!name) return;
     
     try {
-        const response = await authFetch('/api/containers/files/mkdir', {
+        const response = await authFetch('api/containers/files/mkdir', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -268,7 +273,7 @@ async function createDirectory() {
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('files.createSuccess'), 'success');
@@ -287,28 +292,22 @@ async function handleFileUpload(input) {
     const file = input.files[0];
     if (!file) return;
     
-    // 限制文件大小 10MB
-    if (file.size > 10 * 1024 * 1024) {
-        showToast(t('files.sizeLimit'), 'error');
-        return;
-    }
-    
     try {
-        const content = await readFileAsBase64(file);
-        
-        const response = await authFetch('/api/containers/files/upload', {
+        // multipart 流式上传，字段需位于文件之前
+        const formData = new FormData();
+        formData.append('container_id', currentFileContainer);
+        formData.append('path', currentFilePath);
+        formData.append('size', file.size);
+        formData.append('file', file);
+
+        const response = await authFetch('api/containers/files/upload', {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({
-                container_id: currentFileContainer,
-                path: currentFilePath,
-                filename: file.name,
-                content: content
-            })
+            headers: {},
+            body: formData
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('files.uploadSuccess'), 'success');
@@ -320,22 +319,9 @@ async function handleFileUpload(input) {
     input.value = '';
 }
 
-// 读取文件为 Base64
-function readFileAsBase64(file) {
-    return new Promise((resolve, reject) => {
-        const reader = new FileReader();
-        reader.onload = () => {
-            const base64 = reader.result.split(',')[1];
-            resolve(base64);
-        };
-        reader.onerror = reject;
-        reader.readAsDataURL(file);
-    });
-}
-
 // 下载文件
 function downloadFile(path) {
-    const url = '/api/containers/files/download?id=' + currentFileContainer + '&path=' + encodeURIComponent(path);
+    const url = 'api/containers/files/download?id=' + currentFileContainer + '&path=' + encodeURIComponent(path);
     
     // 创建带认证的下载链接
     authFetch(url).then(response => {
@@ -355,16 +341,29 @@ function downloadFile(path) {
 // 编辑文件
 async function editFile(path) {
     try {
-        const response = await authFetch('/api/containers/files/read?id=' + currentFileContainer + '&path=' + encodeURIComponent(path));
+        const response = await authFetch('api/containers/files/read?id=' + currentFileContainer + '&path=' + encodeURIComponent(path));
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         const data = await response.json();
         
+        // 二进制文件不在编辑器中打开
+        if (data.binary) {
+            showToast(t('files.binaryFile') + ' (' + formatBytes(data.size) + ')', 'warning');
+            return;
+        }
+        
+        // 被截断的文件只读显示，避免保存时丢失后面的内容
+        const textarea = document.getElementById('edit-file-content');
+        textarea.readOnly = data.truncated;
+        if (data.truncated) {
+            showToast(t('files.truncatedReadOnly') + ' (' + formatBytes(data.size) + ')', 'warning');
+        }
+        
         document.getElementById('edit-file-path').value = path;
-        document.getElementById('edit-file-content').value = data.content;
+        textarea.value = data.content;
         document.getElementById('file-edit-modal').classList.add('active');
     } catch (error) {
         showToast(t('files.readFailed') + ': ' + error.message, 'error');
@@ -374,10 +373,16 @@ async function editFile(path) {
 // 保存文件
 async function saveEditedFile() {
     const path = document.getElementById('edit-file-path').value;
-    const content = document.getElementById('edit-file-content').value;
+    const textarea = document.getElementById('edit-file-content');
+    const content = textarea.value;
+    
+    if (textarea.readOnly) {
+        showToast(t('files.truncatedReadOnly'), 'warning');
+        return;
+    }
     
     try {
-        const response = await authFetch('/api/containers/files/write', {
+        const response = await authFetch('api/containers/files/write', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -388,7 +393,7 @@ async function saveEditedFile() {
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('common.saveSuccess'), 'success');
@@ -405,7 +410,7 @@ async function deleteFile(path, isDir) {
     if (!confirm(t('files.confirmDelete') + ' ' + type + '?\n' + path)) return;
     
     try {
-        const response = await authFetch('/api/containers/files/delete', {
+        const response = await authFetch('api/containers/files/delete', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -415,7 +420,7 @@ async function deleteFile(path, isDir) {
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('common.deleteSuccess'), 'success');
@@ -425,6 +430,43 @@ async function deleteFile(path, isDir) {
     }
 }
 
+// 重命名或移动文件
+async function renameFile(path, overwrite = false, destination = null) {
+    if (!destination) {
+        destination = prompt(t('files.enterNewPath'), path);
+        if (!destination || destination === path) return;
+    }
+
+    try {
+        const response = await authFetch('api/containers/files/rename', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                container_id: currentFileContainer,
+                source: path,
+                destination: destination,
+                overwrite: overwrite
+            })
+        });
+
+        if (response.status === 409 && !overwrite) {
+            if (confirm(t('files.confirmOverwrite') + '\n' + destination)) {
+                return renameFile(path, true, destination);
+            }
+            return;
+        }
+
+        if (!response.ok) {
+            throw new Error(await readError(response));
+        }
+
+        showToast(t('files.renameSuccess'), 'success');
+        loadFilesList();
+    } catch (error) {
+        showToast(t('files.renameFailed') + ': ' + error.message, 'error');
+    }
+}
+
 // 格式化文件大小
 function formatFileSize(bytes) {
     if (bytes === 0) return '0 B';
@@ -465,8 +507,8 @@ function initConfigTabs() {
 // 打开容器配置模态框
 async function openContainerConfigModal(containerId) {
     try {
-        const response = await authFetch('/api/containers/inspect?id=' + containerId);
-        if (!response.ok) throw new Error(await response.text());
+        const response = await authFetch('api/containers/inspect?id=' + containerId);
+        if (!response.ok) throw new Error(await readError(response));
         
         const config = await response.json();
         currentContainerConfig = config;
@@ -620,7 +662,7 @@ async function saveContainerConfig() {
     const cpus = document.getElementById('config-cpus').value;
     
     try {
-        const updateResponse = await authFetch('/api/containers/update', {
+        const updateResponse = await authFetch('api/containers/update', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -632,7 +674,7 @@ async function saveContainerConfig() {
         });
         
         if (!updateResponse.ok) {
-            throw new Error(await updateResponse.text());
+            throw new Error(await readError(updateResponse));
         }
         
         showToast(t('config.updateSuccess'), 'success');
@@ -654,7 +696,7 @@ async function renameContainer() {
     }
     
     try {
-        const response = await authFetch('/api/containers/rename', {
+        const response = await authFetch('api/containers/rename', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -664,7 +706,7 @@ async function renameContainer() {
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('config.renameSuccess'), 'success');
@@ -858,7 +900,7 @@ async function executeRecreateContainer() {
     if (closeBtn) closeBtn.style.pointerEvents = 'none';
     
     try {
-        const response = await authFetch('/api/containers/recreate', {
+        const response = await authFetch('api/containers/recreate', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -878,7 +920,7 @@ async function executeRecreateContainer() {
         });
         
         if (!response.ok) {
-            throw new Error(await response.text());
+            throw new Error(await readError(response));
         }
         
         showToast(t('config.recreateSuccess'), 'success');
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestStaticHandler(t *testing.T) *staticHandler {
+	t.Helper()
+	h, err := newStaticHandler(fstest.MapFS{
+		"index.html": {Data: []byte(`<html><head>
+<link href="css/style.css" rel="stylesheet">
+<script src="https://cdn.example.com/lib.js"></script>
+<script src="js/app.js"></script>
+</head><body></body></html>`)},
+		"js/app.js":     {Data: []byte("console.log('app')")},
+		"css/style.css": {Data: []byte("body{}")},
+		"img/logo.png":  {Data: []byte("\x89PNG")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func serveStatic(h http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// 首页引用的本地 js/css 带上内容版本号，外部地址不变
+func TestStaticIndexVersions(t *testing.T) {
+	h := newTestStaticHandler(t)
+	rec := serveStatic(h, "/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`href="css/style.css?v=` + h.versions["css/style.css"] + `"`,
+		`src="js/app.js?v=` + h.versions["js/app.js"] + `"`,
+		`src="https://cdn.example.com/lib.js"`,
+		`<base href=`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("index.html missing %s:\n%s", want, body)
+		}
+	}
+	if got := rec.Header().Get("ETag"); got != h.indexETag {
+		t.Errorf("ETag = %q, want %q", got, h.indexETag)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != cacheControlRevalidate {
+		t.Errorf("Cache-Control = %q", got)
+	}
+}
+
+// If-None-Match 与 ETag 匹配时返回 304，/static/ 前缀与根路径等价
+func TestStaticConditionalRequests(t *testing.T) {
+	h := newTestStaticHandler(t)
+	appETag := h.etags["js/app.js"]
+	appVersion := h.versions["js/app.js"]
+
+	cases := []struct {
+		name        string
+		target      string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    string
+		wantCache   string
+		wantBody    string
+	}{
+		{"index", "/", "", http.StatusOK, h.indexETag, cacheControlRevalidate, ""},
+		{"index not modified", "/", h.indexETag, http.StatusNotModified, h.indexETag, cacheControlRevalidate, ""},
+		{"index.html not modified", "/index.html", h.indexETag, http.StatusNotModified, h.indexETag, cacheControlRevalidate, ""},
+		{"index stale etag", "/", `"stale"`, http.StatusOK, h.indexETag, cacheControlRevalidate, ""},
+		{"asset", "/js/app.js", "", http.StatusOK, appETag, cacheControlRevalidate, "console.log('app')"},
+		{"asset not modified", "/js/app.js", appETag, http.StatusNotModified, appETag, cacheControlRevalidate, ""},
+		{"asset weak etag", "/js/app.js", "W/" + appETag, http.StatusNotModified, appETag, cacheControlRevalidate, ""},
+		{"asset etag list", "/js/app.js", `"other", ` + appETag, http.StatusNotModified, appETag, cacheControlRevalidate, ""},
+		{"asset wildcard", "/js/app.js", "*", http.StatusNotModified, appETag, cacheControlRevalidate, ""},
+		{"asset stale etag", "/js/app.js", h.etags["css/style.css"], http.StatusOK, appETag, cacheControlRevalidate, "console.log('app')"},
+		{"versioned asset", "/js/app.js?v=" + appVersion, "", http.StatusOK, appETag, cacheControlImmutable, "console.log('app')"},
+		{"outdated version", "/js/app.js?v=000000000000", "", http.StatusOK, appETag, cacheControlRevalidate, "console.log('app')"},
+		{"static prefix", "/static/js/app.js", "", http.StatusOK, appETag, cacheControlRevalidate, "console.log('app')"},
+		{"static prefix not modified", "/static/js/app.js", appETag, http.StatusNotModified, appETag, cacheControlRevalidate, ""},
+		{"static prefix versioned", "/static/js/app.js?v=" + appVersion, "", http.StatusOK, appETag, cacheControlImmutable, "console.log('app')"},
+		{"static prefix index", "/static/index.html", h.indexETag, http.StatusNotModified, h.indexETag, cacheControlRevalidate, ""},
+		{"missing", "/js/missing.js", "", http.StatusNotFound, "", "", ""},
+		{"static prefix missing", "/static/js/missing.js", "", http.StatusNotFound, "", "", ""},
+		{"api", "/api/unknown", "", http.StatusNotFound, "", "", ""},
+	}
+	for _, c := range cases {
+		rec := serveStatic(h, c.target, map[string]string{"If-None-Match": c.ifNoneMatch})
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: status %d, want %d", c.name, rec.Code, c.wantStatus)
+			continue
+		}
+		if got := rec.Header().Get("ETag"); got != c.wantETag {
+			t.Errorf("%s: ETag %q, want %q", c.name, got, c.wantETag)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != c.wantCache {
+			t.Errorf("%s: Cache-Control %q, want %q", c.name, got, c.wantCache)
+		}
+		if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 with body %q", c.name, rec.Body)
+		}
+		if c.wantBody != "" && rec.Body.String() != c.wantBody {
+			t.Errorf("%s: body %q, want %q", c.name, rec.Body, c.wantBody)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// 面板版本（构建时可通过 -ldflags "-X main.panelVersion=..." 覆盖）
+var panelVersion = "v1.3.5"
+
+// 主机概要缓存时间
+const hostSummaryTTL = 30 * time.Second
+
+// 主机概要（采集失败的字段为 null）
+type HostSummary struct {
+	Hostname          *string `json:"hostname"`
+	OS                *string `json:"os"`
+	Kernel            *string `json:"kernel"`
+	Arch              string  `json:"arch"`
+	PanelVersion      string  `json:"panel_version"`
+	DockerVersion     *string `json:"docker_version"`
+	MemoryTotal       *uint64 `json:"memory_total"` // 字节
+	CPUCores          int     `json:"cpu_cores"`
+	Containers        *int    `json:"containers"`
+	ContainersRunning *int    `json:"containers_running"`
+	ContainersStopped *int    `json:"containers_stopped"`
+	Images            *int    `json:"images"`
+	Networks          *int    `json:"networks"`
+	Volumes           *int    `json:"volumes"`
+}
+
+var hostSummaryCache struct {
+	sync.Mutex
+	data      *HostSummary
+	lastFetch time.Time
+}
+
+// 读取 /etc/os-release 中的发行版名称
+func readOSRelease() string {
+	file, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	var name, version string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "PRETTY_NAME":
+			return value
+		case "NAME":
+			name = value
+		case "VERSION":
+			version = value
+		}
+	}
+	return strings.TrimSpace(name + " " + version)
+}
+
+// 采集主机概要
+func collectHostSummary(ctx context.Context) *HostSummary {
+	summary := &HostSummary{
+		Arch:         runtime.GOARCH,
+		PanelVersion: panelVersion,
+		CPUCores:     runtime.NumCPU(),
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		summary.Hostname = &hostname
+	}
+
+	osName := readOSRelease()
+	if osName == "" {
+		if platform, _, version, err := host.PlatformInformationWithContext(ctx); err == nil && platform != "" {
+			osName = strings.TrimSpace(platform + " " + version)
+		}
+	}
+	if osName != "" {
+		summary.OS = &osName
+	}
+
+	if kernel, err := host.KernelVersionWithContext(ctx); err == nil && kernel != "" {
+		summary.Kernel = &kernel
+	}
+	if arch, err := host.KernelArch(); err == nil && arch != "" {
+		summary.Arch = arch
+	}
+
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		summary.MemoryTotal = &vm.Total
+	}
+
+	cli := getDockerClient()
+	if cli == nil {
+		return summary
+	}
+
+	if info, err := getDockerEngineInfo(ctx); err == nil {
+		summary.DockerVersion = &info.EngineVersion
+		summary.Containers = &info.Containers
+		summary.ContainersRunning = &info.ContainersRunning
+		stopped := info.ContainersStopped + info.ContainersPaused
+		summary.ContainersStopped = &stopped
+		summary.Images = &info.Images
+	}
+
+	if networks, err := cli.NetworkList(ctx, types.NetworkListOptions{}); err == nil {
+		n := len(networks)
+		summary.Networks = &n
+	}
+
+	if volumes, err := cli.VolumeList(ctx, volume.ListOptions{}); err == nil {
+		n := len(volumes.Volumes)
+		summary.Volumes = &n
+	}
+
+	return summary
+}
+
+// 获取主机概要（带缓存）
+func getHostSummary(ctx context.Context) *HostSummary {
+	hostSummaryCache.Lock()
+	defer hostSummaryCache.Unlock()
+
+	if hostSummaryCache.data != nil && time.Since(hostSummaryCache.lastFetch) < hostSummaryTTL {
+		return hostSummaryCache.data
+	}
+
+	hostSummaryCache.data = collectHostSummary(ctx)
+	hostSummaryCache.lastFetch = time.Now()
+	return hostSummaryCache.data
+}
+
+// 主机概要 API（仪表盘头部使用）
+func handleSystemSummary(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// 主机概要带缓存，收藏按当前用户实时获取
+	resp := struct {
+		*HostSummary
+		Favorites []FavoriteState `json:"favorites"`
+	}{HostSummary: getHostSummary(ctx), Favorites: []FavoriteState{}}
+	if username := r.Header.Get("X-Username"); username != "" && dockerAvailable() {
+		if states, err := favoriteStates(ctx, username); err == nil {
+			resp.Favorites = states
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 后台采样间隔
+const samplerInterval = 2 * time.Second
+
+// 网卡流量信息
+type NetInterfaceStats struct {
+	Name    string  `json:"name"`
+	RxBytes uint64  `json:"rx_bytes"` // 累计接收字节
+	TxBytes uint64  `json:"tx_bytes"` // 累计发送字节
+	RxRate  float64 `json:"rx_rate"`  // 接收速率（字节/秒）
+	TxRate  float64 `json:"tx_rate"`  // 发送速率（字节/秒）
+}
+
+// 网卡流量缓存（由后台采样器更新）
+var (
+	netStatsCache struct {
+		sync.RWMutex
+		lastCounters map[string][2]uint64
+		lastTime     time.Time
+		interfaces   []NetInterfaceStats
+	}
+)
+
+// 启动后台采样器（定期采集 CPU 和网络数据）
+func startSystemSampler() {
+	go func() {
+		ticker := time.NewTicker(samplerInterval)
+		defer ticker.Stop()
+
+		sampleSystem()
+		for {
+			select {
+			case <-serverCtx.Done():
+				return
+			case <-ticker.C:
+				sampleSystem()
+			}
+		}
+	}()
+	log.Printf("系统采样器已启动，间隔: %v", samplerInterval)
+}
+
+// 执行一次采样
+func sampleSystem() {
+	if err := sampleCPU(); err != nil {
+		log.Printf("[Sampler] CPU sample failed: %v", err)
+	}
+	if err := sampleNetwork(); err != nil {
+		log.Printf("[Sampler] Network sample failed: %v", err)
+	}
+	if err := sampleDiskIO(); err != nil {
+		log.Printf("[Sampler] Disk IO sample failed: %v", err)
+	}
+	recordMetrics()
+	publishSystemStats()
+}
+
+// 采样 CPU（与上一次采样做差值计算使用率）
+func sampleCPU() error {
+	cpu, cores, err := readCPUStats()
+	if err != nil {
+		return err
+	}
+
+	cpuStatsCache.Lock()
+	defer cpuStatsCache.Unlock()
+
+	if len(cpuStatsCache.lastCPU) == len(cpu) {
+		cpuStatsCache.cpuUsage = calcCPUUsage(cpuStatsCache.lastCPU, cpu)
+	}
+
+	// 核心数量变化（CPU 热插拔）时重新开始计算
+	coreUsage := make([]float64, len(cores))
+	if len(cpuStatsCache.lastCores) == len(cores) {
+		for i := range cores {
+			coreUsage[i] = calcCPUUsage(cpuStatsCache.lastCores[i], cores[i])
+		}
+	}
+
+	cpuStatsCache.lastCPU = cpu
+	cpuStatsCache.lastCores = cores
+	cpuStatsCache.coreUsage = coreUsage
+	cpuStatsCache.lastTime = time.Now()
+	return nil
+}
+
+// CPU 型号信息
+type CPUInfo struct {
+	ModelName string `json:"model_name"`
+	Cores     int    `json:"cores"`   // 物理核心数
+	Threads   int    `json:"threads"` // 逻辑处理器数
+}
+
+// 读取 /proc/cpuinfo
+func readCPUInfo() CPUInfo {
+	info := CPUInfo{Threads: runtime.NumCPU()}
+
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		info.Cores = info.Threads
+		return info
+	}
+	defer file.Close()
+
+	// 通过 (physical id, core id) 去重统计物理核心
+	physicalCores := make(map[string]struct{})
+	processors := 0
+	physicalID := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			processors++
+		case "model name", "Model", "cpu model":
+			if info.ModelName == "" {
+				info.ModelName = value
+			}
+		case "physical id":
+			physicalID = value
+		case "core id":
+			physicalCores[physicalID+":"+value] = struct{}{}
+		}
+	}
+
+	if processors > 0 {
+		info.Threads = processors
+	}
+	info.Cores = len(physicalCores)
+	if info.Cores == 0 {
+		info.Cores = info.Threads
+	}
+	return info
+}
+
+// 每核 CPU 使用率 API
+func handleSystemCPU(w http.ResponseWriter, r *http.Request) {
+	cpuStatsCache.RLock()
+	usage := cpuStatsCache.cpuUsage
+	cores := make([]float64, len(cpuStatsCache.coreUsage))
+	copy(cores, cpuStatsCache.coreUsage)
+	cpuStatsCache.RUnlock()
+
+	info := readCPUInfo()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"usage":      usage,
+		"per_core":   cores,
+		"model_name": info.ModelName,
+		"cores":      info.Cores,
+		"threads":    info.Threads,
+		"time":       time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// 读取 /proc/net/dev，返回每个网卡的累计收发字节
+func readProcNetDev() (map[string][2]uint64, error) {
+	file, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counters := make(map[string][2]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// 格式: "  eth0: 1234 12 0 0 0 0 0 0 5678 34 0 0 0 0 0 0"
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:idx])
+		fields := strings.Fields(line[idx+1:])
+		if len(fields) < 9 {
+			continue
+		}
+		var rx, tx uint64
+		fmt.Sscanf(fields[0], "%d", &rx)
+		fmt.Sscanf(fields[8], "%d", &tx)
+		counters[name] = [2]uint64{rx, tx}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// 采样网络流量
+func sampleNetwork() error {
+	counters, err := readNetDev()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	netStatsCache.Lock()
+	defer netStatsCache.Unlock()
+
+	elapsed := now.Sub(netStatsCache.lastTime).Seconds()
+	interfaces := make([]NetInterfaceStats, 0, len(counters))
+	for name, c := range counters {
+		stat := NetInterfaceStats{Name: name, RxBytes: c[0], TxBytes: c[1]}
+		if last, ok := netStatsCache.lastCounters[name]; ok && elapsed > 0 {
+			// 计数器回绕或网卡重启时本次速率记为 0，避免出现异常尖峰
+			if c[0] >= last[0] {
+				stat.RxRate = float64(c[0]-last[0]) / elapsed
+			}
+			if c[1] >= last[1] {
+				stat.TxRate = float64(c[1]-last[1]) / elapsed
+			}
+		}
+		interfaces = append(interfaces, stat)
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+
+	netStatsCache.lastCounters = counters
+	netStatsCache.lastTime = now
+	netStatsCache.interfaces = interfaces
+	return nil
+}
+
+// 是否为虚拟网卡（回环、Docker 网桥、veth 等）
+func isVirtualInterface(name string) bool {
+	return name == "lo" || name == "docker0" ||
+		strings.HasPrefix(name, "veth") || strings.HasPrefix(name, "br-")
+}
+
+// 获取网卡流量（includeVirtual 为 false 时排除虚拟网卡）
+func getNetworkStats(includeVirtual bool) []NetInterfaceStats {
+	netStatsCache.RLock()
+	defer netStatsCache.RUnlock()
+
+	result := make([]NetInterfaceStats, 0, len(netStatsCache.interfaces))
+	for _, s := range netStatsCache.interfaces {
+		if !includeVirtual && isVirtualInterface(s.Name) {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// 获取总网络速率（排除虚拟网卡）
+func getNetworkRate() (rx, tx float64) {
+	for _, s := range getNetworkStats(false) {
+		rx += s.RxRate
+		tx += s.TxRate
+	}
+	return rx, tx
+}
+
+// 磁盘 IO 信息
+type DiskIOStats struct {
+	Name       string  `json:"name"`
+	ReadBytes  uint64  `json:"read_bytes"`  // 累计读取字节
+	WriteBytes uint64  `json:"write_bytes"` // 累计写入字节
+	ReadRate   float64 `json:"read_rate"`   // 读取速率（字节/秒）
+	WriteRate  float64 `json:"write_rate"`  // 写入速率（字节/秒）
+}
+
+// 磁盘 IO 缓存（由后台采样器更新）
+var (
+	diskIOCache struct {
+		sync.RWMutex
+		lastCounters map[string][2]uint64
+		lastTime     time.Time
+		devices      []DiskIOStats
+	}
+)
+
+// 计算计数器增量（wrap 为计数器回绕上限，0 表示不回绕；其他倒退视为重置）
+func counterDelta(last, cur, wrap uint64) uint64 {
+	if cur >= last {
+		return cur - last
+	}
+	if wrap > 0 && last < wrap {
+		return wrap - last + cur
+	}
+	return 0
+}
+
+// 采样磁盘 IO
+func sampleDiskIO() error {
+	counters, err := readDiskIO()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	diskIOCache.Lock()
+	defer diskIOCache.Unlock()
+
+	elapsed := now.Sub(diskIOCache.lastTime).Seconds()
+	devices := make([]DiskIOStats, 0, len(counters))
+	for name, c := range counters {
+		stat := DiskIOStats{Name: name, ReadBytes: c[0], WriteBytes: c[1]}
+		if last, ok := diskIOCache.lastCounters[name]; ok && elapsed > 0 {
+			stat.ReadRate = float64(counterDelta(last[0], c[0], diskIOCounterWrap)) / elapsed
+			stat.WriteRate = float64(counterDelta(last[1], c[1], diskIOCounterWrap)) / elapsed
+		}
+		devices = append(devices, stat)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+
+	diskIOCache.lastCounters = counters
+	diskIOCache.lastTime = now
+	diskIOCache.devices = devices
+	return nil
+}
+
+// 获取磁盘 IO 明细
+func getDiskIOStats() []DiskIOStats {
+	diskIOCache.RLock()
+	defer diskIOCache.RUnlock()
+
+	result := make([]DiskIOStats, len(diskIOCache.devices))
+	copy(result, diskIOCache.devices)
+	return result
+}
+
+// 获取磁盘总读写速率
+func getDiskIORate() (read, write float64) {
+	for _, d := range getDiskIOStats() {
+		read += d.ReadRate
+		write += d.WriteRate
+	}
+	return read, write
+}
+
+// 磁盘 IO API
+func handleSystemDiskIO(w http.ResponseWriter, r *http.Request) {
+	devices := getDiskIOStats()
+	read, write := getDiskIORate()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"disk_read":  read,
+		"disk_write": write,
+		"devices":    devices,
+		"time":       time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// 读取系统负载（/proc/loadavg），读取失败时返回 0
+func getLoadAverage() (load1, load5, load15 float64) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, 0, 0
+	}
+	fmt.Sscanf(string(data), "%f %f %f", &load1, &load5, &load15)
+	return load1, load5, load15
+}
+
+// 读取系统运行时间（秒，/proc/uptime），读取失败时返回 0
+func getUptime() int64 {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	var uptime float64
+	fmt.Sscanf(string(data), "%f", &uptime)
+	return int64(uptime)
+}
+
+// 磁盘（挂载点）使用情况
+type DiskUsage struct {
+	Device     string  `json:"device"`
+	MountPoint string  `json:"mount_point"`
+	FSType     string  `json:"fs_type"`
+	Total      uint64  `json:"total"`
+	Used       uint64  `json:"used"`
+	Free       uint64  `json:"free"` // 普通用户可用空间
+	Percent    float64 `json:"percent"`
+}
+
+// 计算磁盘使用率（与 df 的 Use% 计算方式一致）
+func diskPercent(used, free uint64) float64 {
+	if used+free == 0 {
+		return 0
+	}
+	return 100.0 * float64(used) / float64(used+free)
+}
+
+// 所有挂载点磁盘使用 API
+func handleSystemDisks(w http.ResponseWriter, r *http.Request) {
+	disks, err := listDisks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取磁盘信息失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(disks)
+}
+
+// 网卡流量 API（?all=true 包含虚拟网卡）
+func handleSystemNetwork(w http.ResponseWriter, r *http.Request) {
+	includeVirtual := r.URL.Query().Get("all") == "true"
+	interfaces := getNetworkStats(includeVirtual)
+
+	var rx, tx float64
+	for _, s := range interfaces {
+		rx += s.RxRate
+		tx += s.TxRate
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"network_rx": rx,
+		"network_tx": tx,
+		"interfaces": interfaces,
+		"time":       time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+// ========== Docker 引擎信息 ==========
+
+// Docker 引擎信息
+type DockerEngineInfo struct {
+	EngineVersion     string   `json:"engine_version"`
+	APIVersion        string   `json:"api_version"`
+	MinAPIVersion     string   `json:"min_api_version"`
+	ClientAPIVersion  string   `json:"client_api_version"` // 协商后的客户端 API 版本
+	OS                string   `json:"os"`
+	Arch              string   `json:"arch"`
+	KernelVersion     string   `json:"kernel_version"`
+	StorageDriver     string   `json:"storage_driver"`
+	CgroupDriver      string   `json:"cgroup_driver"`
+	CgroupVersion     string   `json:"cgroup_version"`
+	RootDir           string   `json:"root_dir"`
+	RegistryMirrors   []string `json:"registry_mirrors"`
+	Images            int      `json:"images"`
+	Containers        int      `json:"containers"`
+	ContainersRunning int      `json:"containers_running"`
+	ContainersPaused  int      `json:"containers_paused"`
+	ContainersStopped int      `json:"containers_stopped"`
+	Warnings          []string `json:"warnings"`
+}
+
+// Docker 引擎信息缓存（Info() 开销较大，缓存 60 秒）
+var (
+	dockerInfoCache struct {
+		sync.RWMutex
+		data      *DockerEngineInfo
+		lastFetch time.Time
+	}
+	dockerInfoCacheTTL = 60 * time.Second
+)
+
+// 获取 Docker 引擎信息（带缓存）
+func getDockerEngineInfo(ctx context.Context) (*DockerEngineInfo, error) {
+	dockerInfoCache.RLock()
+	if dockerInfoCache.data != nil && time.Since(dockerInfoCache.lastFetch) < dockerInfoCacheTTL {
+		data := dockerInfoCache.data
+		dockerInfoCache.RUnlock()
+		return data, nil
+	}
+	dockerInfoCache.RUnlock()
+
+	info, err := getDockerClient().Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := getDockerClient().ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DockerEngineInfo{
+		EngineVersion:     version.Version,
+		APIVersion:        version.APIVersion,
+		MinAPIVersion:     version.MinAPIVersion,
+		ClientAPIVersion:  getDockerClient().ClientVersion(),
+		OS:                info.OperatingSystem,
+		Arch:              info.Architecture,
+		KernelVersion:     info.KernelVersion,
+		StorageDriver:     info.Driver,
+		CgroupDriver:      info.CgroupDriver,
+		CgroupVersion:     info.CgroupVersion,
+		RootDir:           info.DockerRootDir,
+		RegistryMirrors:   []string{},
+		Images:            info.Images,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersPaused:  info.ContainersPaused,
+		ContainersStopped: info.ContainersStopped,
+		Warnings:          info.Warnings,
+	}
+	if info.RegistryConfig != nil {
+		result.RegistryMirrors = append(result.RegistryMirrors, info.RegistryConfig.Mirrors...)
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+
+	dockerInfoCache.Lock()
+	dockerInfoCache.data = result
+	dockerInfoCache.lastFetch = time.Now()
+	dockerInfoCache.Unlock()
+
+	return result, nil
+}
+
+// Docker 引擎信息 API
+func handleSystemDocker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	info, err := getDockerEngineInfo(ctx)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("获取 Docker 信息失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,827 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+)
+
+// 定时任务类型
+const (
+	taskContainerRestart = "container_restart"
+	taskContainerStop    = "container_stop"
+	taskContainerStart   = "container_start"
+	taskComposeUpdate    = "compose_update" // docker compose pull + up -d
+	taskImagePrune       = "image_prune"
+	taskVolumeBackup     = "volume_backup" // 打包卷内容到 data_dir/backups/volumes/<卷名>
+	taskContainerExec    = "container_exec"
+)
+
+// 定时任务执行策略
+const (
+	taskRunTimeout        = 30 * time.Minute // 单次执行超时
+	taskOutputLimit       = 4096             // 执行记录中保留的输出（末尾部分）
+	taskBackupImage       = "busybox:latest" // 卷备份的辅助容器镜像（只创建不启动）
+	taskDefaultBackupKeep = 7                // 每个卷默认保留的备份数
+)
+
+var taskVolumeNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// 任务参数（按类型使用其中的字段）
+type TaskParams struct {
+	Container string   `json:"container,omitempty"` // 容器 ID 或名称
+	Project   string   `json:"project,omitempty"`   // Compose 项目
+	Volume    string   `json:"volume,omitempty"`    // 卷名
+	Keep      int      `json:"keep,omitempty"`      // 卷备份保留份数，默认 7
+	All       bool     `json:"all,omitempty"`       // 镜像清理：清理所有未使用的镜像（默认只清理悬空镜像）
+	Command   []string `json:"command,omitempty"`   // 容器内执行的命令，如 ["sh", "-c", "rm -rf /tmp/cache/*"]
+	ExecOptions
+}
+
+// 定时任务
+type Task struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Cron      string     `json:"cron"`
+	Type      string     `json:"type"`
+	Params    TaskParams `json:"params"`
+	Enabled   bool       `json:"enabled"`
+	NextRun   int64      `json:"next_run,omitempty"` // Unix 秒，未启用时为空
+	Running   bool       `json:"running"`
+	LastRun   *TaskRun   `json:"last_run,omitempty"`
+	CreatedAt string     `json:"created_at,omitempty"`
+	UpdatedAt string     `json:"updated_at,omitempty"`
+}
+
+// 执行记录
+type TaskRun struct {
+	ID         int64  `json:"id"`
+	TaskID     int64  `json:"task_id"`
+	TaskName   string `json:"task_name"`
+	Trigger    string `json:"trigger"` // schedule 或 manual
+	Start      int64  `json:"start"`   // Unix 秒
+	DurationMs int64  `json:"duration_ms"`
+	Status     string `json:"status"` // success 或 failed
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// 正在执行的任务（同一任务不并发执行）
+var runningTasks sync.Map // map[int64]struct{}
+
+// 初始化定时任务表，启动调度协程
+func initTasks() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		cron TEXT NOT NULL,
+		type TEXT NOT NULL,
+		params TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS task_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		task_name TEXT,
+		trigger TEXT NOT NULL,
+		start INTEGER NOT NULL,
+		duration_ms INTEGER,
+		status TEXT NOT NULL,
+		output TEXT,
+		error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_runs_task ON task_runs(task_id, id);
+	CREATE INDEX IF NOT EXISTS idx_task_runs_start ON task_runs(start);`)
+	if err != nil {
+		return fmt.Errorf("创建定时任务表失败: %v", err)
+	}
+
+	go runTaskScheduler()
+	go pruneTaskRuns()
+	return nil
+}
+
+// 调度协程：每分钟整点检查一次，只执行当前这一分钟应执行的任务
+// 面板停止期间或进程挂起期间错过的执行直接跳过，不会在恢复后集中补执行
+func runTaskScheduler() {
+	for {
+		now := time.Now()
+		wake := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-time.After(wake.Sub(now)):
+		}
+
+		// 唤醒明显晚于预定时间（如系统挂起）时跳过这一分钟
+		if time.Since(wake) > 30*time.Second {
+			log.Printf("[Tasks] Scheduler woke up late (%s), skipping missed runs", time.Since(wake).Round(time.Second))
+			continue
+		}
+
+		tasks, err := loadTasks()
+		if err != nil {
+			log.Printf("[Tasks] Load tasks failed: %v", err)
+			continue
+		}
+		for _, t := range tasks {
+			if !t.Enabled {
+				continue
+			}
+			sched, err := parseCron(t.Cron)
+			if err != nil || !sched.matches(wake) {
+				continue
+			}
+			startTask(t, "schedule")
+		}
+	}
+}
+
+// 在后台执行任务（任务正在执行时跳过并返回 false）
+func startTask(t Task, trigger string) bool {
+	if _, busy := runningTasks.LoadOrStore(t.ID, struct{}{}); busy {
+		log.Printf("[Tasks] Task %d (%s) is still running, skipping %s run", t.ID, t.Name, trigger)
+		return false
+	}
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		defer runningTasks.Delete(t.ID)
+		defer recoverGoroutine(serverCtx, "task "+t.Name)
+		executeTask(t, trigger)
+	}()
+	return true
+}
+
+// 执行任务并写入执行记录，失败时发送通知
+func executeTask(t Task, trigger string) {
+	ctx, cancel := context.WithTimeout(serverCtx, taskRunTimeout)
+	defer cancel()
+
+	log.Printf("[Tasks] Running task %d (%s, %s, %s)", t.ID, t.Name, t.Type, trigger)
+	start := time.Now()
+	output, err := func() (output string, err error) {
+		defer recoverGoroutineWith(ctx, "task "+t.Name, func(e error) { err = e })
+		return runTask(ctx, &t)
+	}()
+	run := TaskRun{
+		TaskID:     t.ID,
+		TaskName:   t.Name,
+		Trigger:    trigger,
+		Start:      start.Unix(),
+		DurationMs: time.Since(start).Milliseconds(),
+		Status:     "success",
+		Output:     tailOutput(output, taskOutputLimit),
+	}
+	if err != nil {
+		run.Status = "failed"
+		run.Error = err.Error()
+		log.Printf("[Tasks] Task %d (%s) failed: %v", t.ID, t.Name, err)
+		Notify(Notification{
+			Event:   "task_failed",
+			Level:   notifyWarning,
+			Title:   fmt.Sprintf("定时任务执行失败: %s", t.Name),
+			Message: err.Error(),
+			Fields:  map[string]string{"task": t.Name, "type": t.Type, "trigger": trigger},
+		})
+	}
+
+	if _, err := authDB.Exec(
+		"INSERT INTO task_runs (task_id, task_name, trigger, start, duration_ms, status, output, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		run.TaskID, run.TaskName, run.Trigger, run.Start, run.DurationMs, run.Status, run.Output, run.Error,
+	); err != nil {
+		log.Printf("[Tasks] Save run failed: %v", err)
+	}
+}
+
+// 保留输出的末尾部分
+func tailOutput(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[len(s)-limit:]
+	// 避免截断在多字节字符中间
+	for i := 0; i < len(s) && i < 4; i++ {
+		if s[i]&0xC0 != 0x80 {
+			return "...\n" + s[i:]
+		}
+	}
+	return "...\n" + s
+}
+
+// 按类型执行任务，返回输出
+func runTask(ctx context.Context, t *Task) (string, error) {
+	p := &t.Params
+	if t.Type != taskComposeUpdate && !dockerAvailable() {
+		return "", fmt.Errorf("Docker 守护进程未连接，正在自动重连")
+	}
+	switch t.Type {
+	case taskContainerRestart:
+		err := getDockerClient().ContainerRestart(ctx, p.Container, container.StopOptions{})
+		InvalidateContainers()
+		return "", err
+	case taskContainerStop:
+		err := getDockerClient().ContainerStop(ctx, p.Container, container.StopOptions{})
+		InvalidateContainers()
+		return "", err
+	case taskContainerStart:
+		err := getDockerClient().ContainerStart(ctx, p.Container, types.ContainerStartOptions{})
+		InvalidateContainers()
+		return "", err
+	case taskComposeUpdate:
+		return runComposeUpdate(ctx, p.Project)
+	case taskImagePrune:
+		return runImagePrune(ctx, p.All)
+	case taskVolumeBackup:
+		return runVolumeBackup(ctx, p.Volume, p.Keep)
+	case taskContainerExec:
+		return runTaskExec(ctx, p)
+	}
+	return "", fmt.Errorf("不支持的任务类型: %s", t.Type)
+}
+
+// docker compose pull && docker compose up -d
+func runComposeUpdate(ctx context.Context, project string) (string, error) {
+	if caps := currentCapabilities(); !caps.Compose.Available {
+		return "", fmt.Errorf("Compose 操作不可用: %s", caps.Compose.Hint)
+	}
+	var output bytes.Buffer
+	for _, args := range [][]string{{"compose", "pull"}, {"compose", "up", "-d"}} {
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Dir = filepath.Join(composeBaseDir, project)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		fmt.Fprintf(&output, "$ docker %s\n", strings.Join(args, " "))
+		if err := cmd.Run(); err != nil {
+			return output.String(), fmt.Errorf("docker %s 失败: %v", strings.Join(args, " "), err)
+		}
+	}
+	InvalidateContainers()
+	InvalidateImages()
+	return output.String(), nil
+}
+
+// 清理未使用的镜像（默认只清理悬空镜像），与 /api/images/prune 使用同一套选择逻辑
+func runImagePrune(ctx context.Context, all bool) (string, error) {
+	items, err := selectPrunableImages(ctx, all)
+	if err != nil {
+		return "", err
+	}
+	report := applyImpact("image_prune", items, false, func(item ImpactItem) error {
+		return removeImageItem(ctx, item)
+	})
+	InvalidateImages()
+	var output strings.Builder
+	for _, item := range report.Items {
+		if item.Error != "" {
+			fmt.Fprintf(&output, "Failed: %s: %s\n", item.Name, item.Error)
+		} else {
+			fmt.Fprintf(&output, "Deleted: %s (%s)\n", item.Name, units.HumanSize(float64(item.Size)))
+		}
+	}
+	fmt.Fprintf(&output, "Total reclaimed space: %s\n", report.Reclaimed)
+	return output.String(), nil
+}
+
+// 卷备份目录（每个卷一个子目录）
+func volumeBackupDir(volume string) string {
+	return filepath.Join(appConfig.DataDir, "backups", "volumes", volume)
+}
+
+// 备份卷：创建挂载该卷的辅助容器（不启动），从中复制出卷内容并压缩，保留最近 keep 份
+func runVolumeBackup(ctx context.Context, volume string, keep int) (string, error) {
+	cli := getDockerClient()
+	if _, err := cli.VolumeInspect(ctx, volume); err != nil {
+		return "", err
+	}
+	if keep <= 0 {
+		keep = taskDefaultBackupKeep
+	}
+
+	var output strings.Builder
+	if _, _, err := cli.ImageInspectWithRaw(ctx, taskBackupImage); err != nil {
+		fmt.Fprintf(&output, "Pulling %s\n", taskBackupImage)
+		reader, err := cli.ImagePull(ctx, taskBackupImage, types.ImagePullOptions{})
+		if err != nil {
+			return output.String(), fmt.Errorf("拉取镜像失败: %v", err)
+		}
+		io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+	created, err := cli.ContainerCreate(ctx,
+		&container.Config{Image: taskBackupImage, Cmd: []string{"true"}},
+		&container.HostConfig{Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: volume, Target: "/volume", ReadOnly: true}}},
+		nil, nil, "")
+	if err != nil {
+		return output.String(), fmt.Errorf("创建备份容器失败: %v", err)
+	}
+	defer cli.ContainerRemove(context.Background(), created.ID, types.ContainerRemoveOptions{Force: true})
+
+	archive, _, err := cli.CopyFromContainer(ctx, created.ID, "/volume/.")
+	if err != nil {
+		return output.String(), fmt.Errorf("读取卷内容失败: %v", err)
+	}
+	defer archive.Close()
+
+	dir := volumeBackupDir(volume)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return output.String(), fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	target := filepath.Join(dir, time.Now().Format("20060102-150405")+".tar.gz")
+	if err := writeGzipFile(target, archive); err != nil {
+		os.Remove(target)
+		return output.String(), fmt.Errorf("写入备份失败: %v", err)
+	}
+	if info, err := os.Stat(target); err == nil {
+		fmt.Fprintf(&output, "Backup written to %s (%s)\n", target, units.HumanSize(float64(info.Size())))
+	}
+
+	// 只保留最近 keep 份（文件名中的时间可按字典序排序）
+	old, _ := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	sort.Strings(old)
+	for len(old) > keep {
+		os.Remove(old[0])
+		fmt.Fprintf(&output, "Removed old backup %s\n", filepath.Base(old[0]))
+		old = old[1:]
+	}
+	return output.String(), nil
+}
+
+// 只保留末尾输出的缓冲区（至少保留 limit+1 字节，tailOutput 据此判断是否截断）
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > 2*b.limit {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.limit-1:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+// 是否有输出被丢弃
+func (b *tailBuffer) truncated() bool {
+	return len(b.buf) > b.limit
+}
+
+// 压缩写入文件（先写临时文件，完成后重命名）
+func writeGzipFile(target string, r io.Reader) error {
+	tmp := target + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	_, err = io.Copy(gz, r)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// 在容器中执行命令，退出码非 0 视为失败
+func runTaskExec(ctx context.Context, p *TaskParams) (string, error) {
+	config := types.ExecConfig{AttachStdout: true, AttachStderr: true, Cmd: p.Command}
+	p.ExecOptions.apply(&config)
+	tag := newExecTag() // 超时时用于终止进程
+	config.Env = append(config.Env, tag)
+
+	cli := getDockerClient()
+	execID, err := cli.ContainerExecCreate(ctx, p.Container, config)
+	if err != nil {
+		return "", fmt.Errorf("创建执行实例失败: %v", err)
+	}
+	resp, err := cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", fmt.Errorf("附加执行实例失败: %v", err)
+	}
+	defer resp.Close()
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	// 执行记录只保留末尾部分，长时间运行的命令不需要缓存全部输出
+	output := &tailBuffer{limit: taskOutputLimit}
+	_, err = stdcopy.StdCopy(output, output, resp.Reader)
+	if ctx.Err() != nil {
+		killErr := signalExecProcess(p.Container, execID.ID, tag, "KILL")
+		if killErr != nil {
+			log.Printf("[Tasks] Kill exec %s in container %s failed: %v", execID.ID, p.Container, killErr)
+			return output.String(), fmt.Errorf("命令执行超时（%s），进程可能仍在运行: %v", taskRunTimeout, killErr)
+		}
+		return output.String(), fmt.Errorf("命令执行超时（%s），已终止进程", taskRunTimeout)
+	}
+	if err != nil && err != io.EOF {
+		return output.String(), fmt.Errorf("读取输出失败: %v", err)
+	}
+	inspect, err := cli.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return output.String(), err
+	}
+	if inspect.ExitCode != 0 {
+		return output.String(), fmt.Errorf("命令退出码 %d", inspect.ExitCode)
+	}
+	return output.String(), nil
+}
+
+// 定期清理过期的执行记录
+func pruneTaskRuns() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cutoff := time.Now().Add(-settingDays("retention.task_runs_days")).Unix()
+		if _, err := authDB.Exec("DELETE FROM task_runs WHERE start < ?", cutoff); err != nil {
+			log.Printf("[Tasks] Prune runs failed: %v", err)
+		}
+	}
+}
+
+// 校验任务
+func (t *Task) validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("任务名称不能为空")
+	}
+	sched, err := parseCron(t.Cron)
+	if err != nil {
+		return err
+	}
+	if sched.next(time.Now()).IsZero() {
+		return fmt.Errorf("cron 表达式不会触发: %s", t.Cron)
+	}
+	p := &t.Params
+	switch t.Type {
+	case taskContainerRestart, taskContainerStop, taskContainerStart:
+		if p.Container == "" {
+			return fmt.Errorf("容器ID不能为空")
+		}
+	case taskContainerExec:
+		if p.Container == "" {
+			return fmt.Errorf("容器ID不能为空")
+		}
+		if len(p.Command) == 0 {
+			return fmt.Errorf("命令不能为空")
+		}
+		return p.ExecOptions.validate()
+	case taskComposeUpdate:
+		if p.Project == "" {
+			return fmt.Errorf("项目名称不能为空")
+		}
+		if p.Project != filepath.Base(p.Project) || p.Project == ".." {
+			return fmt.Errorf("Compose 项目名称无效: %s", p.Project)
+		}
+		if _, err := os.Stat(filepath.Join(composeBaseDir, p.Project)); os.IsNotExist(err) {
+			return fmt.Errorf("项目不存在")
+		}
+	case taskVolumeBackup:
+		if !taskVolumeNamePattern.MatchString(p.Volume) {
+			return fmt.Errorf("卷名称无效: %s", p.Volume)
+		}
+		if p.Keep < 0 {
+			return fmt.Errorf("keep 参数无效")
+		}
+	case taskImagePrune:
+	default:
+		return fmt.Errorf("不支持的任务类型: %s", t.Type)
+	}
+	return nil
+}
+
+// 读取任务（id 为 0 时读取全部），附带下次执行时间、执行状态和最近一次执行记录
+func queryTasks(id int64) ([]Task, error) {
+	query := "SELECT id, name, cron, type, params, enabled, created_at, updated_at FROM tasks"
+	var args []interface{}
+	if id != 0 {
+		query += " WHERE id = ?"
+		args = append(args, id)
+	}
+	rows, err := authDB.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]Task, 0)
+	for rows.Next() {
+		var t Task
+		var params string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Cron, &t.Type, &params, &t.Enabled, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &t.Params); err != nil {
+			log.Printf("[Tasks] Invalid params for task %d: %v", t.ID, err)
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func loadTasks() ([]Task, error) {
+	return queryTasks(0)
+}
+
+func loadTask(id int64) (*Task, error) {
+	tasks, err := queryTasks(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &tasks[0], nil
+}
+
+// 补充接口展示用的状态
+func (t *Task) fillStatus() {
+	if sched, err := parseCron(t.Cron); err == nil && t.Enabled {
+		if next := sched.next(time.Now()); !next.IsZero() {
+			t.NextRun = next.Unix()
+		}
+	}
+	_, t.Running = runningTasks.Load(t.ID)
+	if runs, err := queryTaskRuns(t.ID, "", 1); err == nil && len(runs) > 0 {
+		t.LastRun = &runs[0]
+	}
+}
+
+// 读取执行记录（最新的在前）
+func queryTaskRuns(taskID int64, status string, limit int) ([]TaskRun, error) {
+	query := "SELECT id, task_id, task_name, trigger, start, duration_ms, status, output, error FROM task_runs WHERE 1 = 1"
+	var args []interface{}
+	if taskID != 0 {
+		query += " AND task_id = ?"
+		args = append(args, taskID)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := authDB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]TaskRun, 0)
+	for rows.Next() {
+		var run TaskRun
+		var taskName, output, errMsg sql.NullString
+		var duration sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.TaskID, &taskName, &run.Trigger, &run.Start, &duration, &run.Status, &output, &errMsg); err != nil {
+			continue
+		}
+		run.TaskName, run.Output, run.Error = taskName.String, output.String, errMsg.String
+		run.DurationMs = duration.Int64
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// 任务列表
+func handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	tasks, err := loadTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	for i := range tasks {
+		tasks[i].fillStatus()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// 新建（/create）或修改（/update，需要 id）任务
+func handleTaskSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var t Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	creating := strings.HasSuffix(r.URL.Path, "/create")
+	if creating {
+		t.ID = 0
+	} else if t.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	if err := t.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	params, _ := json.Marshal(t.Params)
+	action := "task_create"
+	if creating {
+		res, err := authDB.Exec(
+			"INSERT INTO tasks (name, cron, type, params, enabled) VALUES (?, ?, ?, ?, ?)",
+			t.Name, t.Cron, t.Type, string(params), t.Enabled,
+		)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+			return
+		}
+		t.ID, _ = res.LastInsertId()
+	} else {
+		res, err := authDB.Exec(
+			"UPDATE tasks SET name = ?, cron = ?, type = ?, params = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			t.Name, t.Cron, t.Type, string(params), t.Enabled, t.ID,
+		)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存失败: %v", err))
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "定时任务不存在")
+			return
+		}
+		action = "task_update"
+	}
+	writeAuditLog(r.Header.Get("X-Username"), action, t.Name, fmt.Sprintf("%s %s", t.Type, t.Cron), r.RemoteAddr)
+
+	saved, err := loadTask(t.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	saved.fillStatus()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// 解析请求中的任务 ID 并读取任务
+func taskFromRequest(w http.ResponseWriter, r *http.Request) (*Task, bool) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return nil, false
+	}
+	t, err := loadTask(req.ID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "定时任务不存在")
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return nil, false
+	}
+	return t, true
+}
+
+// 删除任务（执行记录保留）
+func handleTaskDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	t, ok := taskFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := authDB.Exec("DELETE FROM tasks WHERE id = ?", t.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "task_delete", t.Name, t.Type, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// 立即执行任务（后台执行，结果见执行记录；未启用的任务也可手动执行）
+func handleTaskRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	t, ok := taskFromRequest(w, r)
+	if !ok {
+		return
+	}
+	// ?dry_run=true 预览清理任务将删除的对象，不执行任务
+	if dryRun, _ := dryRunParam(r); dryRun {
+		if t.Type != taskImagePrune {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "该类型的任务不支持预览")
+			return
+		}
+		if !dockerAvailable() {
+			writeDockerUnavailable(w)
+			return
+		}
+		items, err := selectPrunableImages(r.Context(), t.Params.All)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+			return
+		}
+		writeImpactReport(w, r, applyImpact(taskImagePrune, items, true, nil), t.Name)
+		return
+	}
+	if !startTask(*t, "manual") {
+		writeError(w, http.StatusConflict, ErrCodeConflict, "任务正在执行中")
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "task_run", t.Name, t.Type, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+}
+
+// 执行记录（?task_id=1&status=failed&limit=100，最新的在前）
+func handleTaskRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	q := r.URL.Query()
+	var taskID int64
+	if v := q.Get("task_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "task_id 参数无效")
+			return
+		}
+		taskID = id
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
+
+	runs, err := queryTaskRuns(taskID, q.Get("status"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
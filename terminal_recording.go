@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 终端录像配置
+// terminal_recording：off（默认）、output（只录制输出）、all（同时录制输入）
+// terminal_recording_max_mb：单个录像大小上限，默认 50MB
+// terminal_recording_days：录像保留天数，默认 30 天
+var (
+	terminalRecordingMode = "off"
+	terminalRecordingMax  = int64(50 << 20)
+	terminalRecordingDays = int64(30)
+)
+
+// 录像清理间隔
+const terminalRecordingGCInterval = time.Hour
+
+// 录像目录
+func sessionsDir() string {
+	return filepath.Join(dataDir, "sessions")
+}
+
+// 终端会话录像（asciicast v2 格式，每行一个带时间戳的事件）
+type sessionRecorder struct {
+	mu        sync.Mutex
+	id        int64
+	file      *os.File
+	started   time.Time
+	bytes     int64
+	input     bool
+	truncated bool
+}
+
+// 开始录制终端会话，未启用录像时返回 nil（nil 的录像器所有方法都是空操作）
+func startSessionRecording(username, containerID, shell, remoteAddr string) *sessionRecorder {
+	if terminalRecordingMode != "output" && terminalRecordingMode != "all" {
+		return nil
+	}
+
+	started := time.Now()
+	result, err := authDB.Exec(
+		"INSERT INTO terminal_sessions (username, container_id, shell, started_at, remote_addr) VALUES (?, ?, ?, ?, ?)",
+		username, containerID, shell, started.Unix(), remoteAddr,
+	)
+	if err != nil {
+		log.Printf("[Recording] Save session failed: %v", err)
+		return nil
+	}
+	id, _ := result.LastInsertId()
+
+	if err := os.MkdirAll(sessionsDir(), 0700); err != nil {
+		log.Printf("[Recording] Create sessions dir failed: %v", err)
+		return nil
+	}
+	filePath := filepath.Join(sessionsDir(), fmt.Sprintf("%s-%d.cast", started.Format("20060102-150405"), id))
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		log.Printf("[Recording] Create recording file failed: %v", err)
+		return nil
+	}
+	authDB.Exec("UPDATE terminal_sessions SET file_path = ? WHERE id = ?", filePath, id)
+
+	header, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     80,
+		"height":    24,
+		"timestamp": started.Unix(),
+		"title":     fmt.Sprintf("%s@%s", username, containerID),
+	})
+	file.Write(append(header, '\n'))
+
+	writeAuditLog(username, "terminal_start", containerID, fmt.Sprintf("session=%d shell=%s", id, shell), remoteAddr)
+
+	return &sessionRecorder{
+		id:      id,
+		file:    file,
+		started: started,
+		input:   terminalRecordingMode == "all",
+	}
+}
+
+// 写入一个事件（超过大小上限后停止录制）
+func (s *sessionRecorder) record(kind string, data []byte) {
+	if s == nil || len(data) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.truncated {
+		return
+	}
+	if s.bytes+int64(len(data)) > terminalRecordingMax {
+		s.truncated = true
+		line, _ := json.Marshal([]interface{}{time.Since(s.started).Seconds(), "o", "\r\n[recording truncated: size limit reached]\r\n"})
+		s.file.Write(append(line, '\n'))
+		return
+	}
+
+	line, _ := json.Marshal([]interface{}{time.Since(s.started).Seconds(), kind, string(data)})
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		log.Printf("[Recording] Write session %d failed: %v", s.id, err)
+		s.truncated = true
+		return
+	}
+	s.bytes += int64(len(data))
+}
+
+// 记录容器输出
+func (s *sessionRecorder) output(data []byte) {
+	s.record("o", data)
+}
+
+// 记录用户输入（仅 TERMINAL_RECORDING=all 时）
+func (s *sessionRecorder) inputData(data []byte) {
+	if s != nil && s.input {
+		s.record("i", data)
+	}
+}
+
+// 记录终端大小变化
+func (s *sessionRecorder) resize(cols, rows int) {
+	s.record("r", []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+// 结束录制，更新会话记录
+func (s *sessionRecorder) close(username, containerID, remoteAddr string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file.Close()
+	if _, err := authDB.Exec("UPDATE terminal_sessions SET ended_at = ?, bytes = ? WHERE id = ?",
+		time.Now().Unix(), s.bytes, s.id); err != nil {
+		log.Printf("[Recording] Update session %d failed: %v", s.id, err)
+	}
+	writeAuditLog(username, "terminal_end", containerID,
+		fmt.Sprintf("session=%d bytes=%d duration=%s", s.id, s.bytes, time.Since(s.started).Round(time.Second)), remoteAddr)
+}
+
+// 终端会话记录
+type TerminalSession struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	ContainerID string `json:"container_id"`
+	Shell       string `json:"shell"`
+	StartedAt   int64  `json:"started_at"`
+	EndedAt     *int64 `json:"ended_at"` // 会话进行中时为 null
+	Bytes       int64  `json:"bytes"`
+	HasFile     bool   `json:"has_file"`
+}
+
+// 终端会话列表；?id=N 下载对应的录像文件
+func handleTerminalSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "参数错误")
+			return
+		}
+		var filePath string
+		if err := authDB.QueryRow("SELECT COALESCE(file_path, '') FROM terminal_sessions WHERE id = ?", id).Scan(&filePath); err != nil || filePath == "" {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "录像不存在")
+			return
+		}
+		writeAuditLog(r.Header.Get("X-Username"), "terminal_recording_download", idStr, "", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/x-asciicast")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(filePath)))
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+
+	rows, err := authDB.Query(`SELECT id, COALESCE(username, ''), container_id, COALESCE(shell, ''), started_at, ended_at, bytes, COALESCE(file_path, '')
+		FROM terminal_sessions ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	sessions := []TerminalSession{}
+	for rows.Next() {
+		var s TerminalSession
+		var filePath string
+		if err := rows.Scan(&s.ID, &s.Username, &s.ContainerID, &s.Shell, &s.StartedAt, &s.EndedAt, &s.Bytes, &filePath); err != nil {
+			continue
+		}
+		s.HasFile = filePath != ""
+		sessions = append(sessions, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recording": terminalRecordingMode == "output" || terminalRecordingMode == "all",
+		"sessions":  sessions,
+	})
+}
+
+// 初始化终端录像（启动定期清理）
+func initTerminalRecording() {
+	go func() {
+		ticker := time.NewTicker(terminalRecordingGCInterval)
+		defer ticker.Stop()
+		for {
+			cleanupTerminalRecordings()
+			select {
+			case <-serverCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 删除超过保留期限的录像和会话记录
+func cleanupTerminalRecordings() {
+	cutoff := time.Now().Add(-time.Duration(terminalRecordingDays) * 24 * time.Hour).Unix()
+
+	rows, err := authDB.Query("SELECT id, COALESCE(file_path, '') FROM terminal_sessions WHERE started_at < ? AND ended_at IS NOT NULL", cutoff)
+	if err != nil {
+		log.Printf("[Recording] Query expired sessions failed: %v", err)
+		return
+	}
+	var ids []int64
+	var files []string
+	for rows.Next() {
+		var id int64
+		var filePath string
+		if rows.Scan(&id, &filePath) == nil {
+			ids = append(ids, id)
+			files = append(files, filePath)
+		}
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if files[i] != "" {
+			if err := os.Remove(files[i]); err != nil && !os.IsNotExist(err) {
+				log.Printf("[Recording] Remove %s failed: %v", files[i], err)
+				continue
+			}
+		}
+		authDB.Exec("DELETE FROM terminal_sessions WHERE id = ?", id)
+	}
+	if len(ids) > 0 {
+		log.Printf("[Recording] Removed %d expired terminal sessions", len(ids))
+	}
+}
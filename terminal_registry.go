@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 终端会话限制
+// terminal_max_per_user：每个用户同时打开的终端数，默认 5
+// terminal_idle_timeout：无输入超过该时间（分钟）的终端会被关闭，默认 30
+var (
+	terminalMaxPerUser  = 5
+	terminalIdleTimeout = 30 * time.Minute
+)
+
+// 空闲检查间隔
+const terminalReapInterval = time.Minute
+
+// 活动的终端会话
+type activeTerminal struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"` // container / host
+	Username    string    `json:"username"`
+	ContainerID string    `json:"container_id,omitempty"`
+	Shell       string    `json:"shell"`
+	RemoteAddr  string    `json:"remote_addr"`
+	StartedAt   time.Time `json:"started_at"`
+
+	lastActivity atomic.Int64  // 最后一次输入的时间（UnixNano）
+	closed       chan struct{} // 会话被强制关闭时关闭
+	closeOnce    sync.Once
+	reason       string
+}
+
+var activeTerminals = struct {
+	sync.Mutex
+	items map[string]*activeTerminal
+}{items: make(map[string]*activeTerminal)}
+
+// 注册终端会话，超过用户并发上限时返回错误
+func registerTerminal(kind, username, containerID, shell, remoteAddr string) (*activeTerminal, error) {
+	activeTerminals.Lock()
+	defer activeTerminals.Unlock()
+
+	count := 0
+	for _, t := range activeTerminals.items {
+		if t.Username == username {
+			count++
+		}
+	}
+	if count >= terminalMaxPerUser {
+		return nil, fmt.Errorf("终端会话数已达上限（%d 个），请先关闭其他终端", terminalMaxPerUser)
+	}
+
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	t := &activeTerminal{
+		ID:          hex.EncodeToString(buf),
+		Kind:        kind,
+		Username:    username,
+		ContainerID: containerID,
+		Shell:       shell,
+		RemoteAddr:  remoteAddr,
+		StartedAt:   time.Now(),
+		closed:      make(chan struct{}),
+	}
+	t.touch()
+	activeTerminals.items[t.ID] = t
+	backgroundTasks.Add(1) // 服务退出时等待终端完成收尾（录像、审计日志）
+	return t, nil
+}
+
+// 注销终端会话
+func (t *activeTerminal) unregister() {
+	activeTerminals.Lock()
+	delete(activeTerminals.items, t.ID)
+	activeTerminals.Unlock()
+	backgroundTasks.Done()
+}
+
+// 记录用户活动
+func (t *activeTerminal) touch() {
+	t.lastActivity.Store(time.Now().UnixNano())
+}
+
+// 最后活动时间
+func (t *activeTerminal) lastActive() time.Time {
+	return time.Unix(0, t.lastActivity.Load())
+}
+
+// 强制关闭会话（处理函数收到后关闭连接和 shell）
+func (t *activeTerminal) terminate(reason string) {
+	t.closeOnce.Do(func() {
+		t.reason = reason
+		close(t.closed)
+	})
+}
+
+// 定期关闭空闲的终端
+func initTerminalReaper() {
+	go func() {
+		ticker := time.NewTicker(terminalReapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-serverCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			activeTerminals.Lock()
+			for _, t := range activeTerminals.items {
+				if time.Since(t.lastActive()) > terminalIdleTimeout {
+					log.Printf("[Terminal] Reaping idle session %s (user: %s, container: %s)", t.ID, t.Username, t.ContainerID)
+					t.terminate("idle timeout")
+				}
+			}
+			activeTerminals.Unlock()
+		}
+	}()
+}
+
+// 关闭所有终端（服务退出时）
+func closeAllTerminals(reason string) {
+	activeTerminals.Lock()
+	defer activeTerminals.Unlock()
+	for _, t := range activeTerminals.items {
+		t.terminate(reason)
+	}
+}
+
+// 活动终端列表
+func handleTerminalActive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	type terminalInfo struct {
+		*activeTerminal
+		LastActivity time.Time `json:"last_activity"`
+	}
+
+	activeTerminals.Lock()
+	list := make([]terminalInfo, 0, len(activeTerminals.items))
+	for _, t := range activeTerminals.items {
+		list = append(list, terminalInfo{activeTerminal: t, LastActivity: t.lastActive()})
+	}
+	activeTerminals.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions":     list,
+		"max_per_user": terminalMaxPerUser,
+		"idle_timeout": int(terminalIdleTimeout.Seconds()),
+	})
+}
+
+// 强制关闭终端会话
+func handleTerminalKill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	activeTerminals.Lock()
+	t, ok := activeTerminals.items[req.ID]
+	activeTerminals.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "会话不存在")
+		return
+	}
+
+	username := r.Header.Get("X-Username")
+	t.terminate("killed by " + username)
+	writeAuditLog(username, "terminal_kill", t.ContainerID, fmt.Sprintf("session=%s user=%s", t.ID, t.Username), r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
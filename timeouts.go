@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 请求读写超时
+// 服务器本身不设置 ReadTimeout/WriteTimeout（否则日志流、镜像构建、大文件传输会被中断），
+// 由 withRouteTimeouts 按路由设置连接的读写截止时间
+const (
+	routeTimeoutDefault = 60 * time.Second // 普通接口
+	routeTimeoutLong    = 30 * time.Minute // 耗时操作：拉取镜像、compose、创建/重建容器等
+)
+
+// 按路由设置的超时，0 表示不限制（流式响应、WebSocket、大文件传输）
+var routeTimeouts = map[string]time.Duration{
+	// 流式响应
+	"/api/containers/logs":       0,
+	"/api/containers/run/stream": 0,
+	"/api/containers/run/raw":    0,
+	"/api/images/build":          0,
+
+	// WebSocket
+	"/api/containers/terminal/ws": 0,
+	"/api/host/terminal":          0,
+	"/api/ws":                     0, // 实时事件
+
+	// 文件传输
+	"/api/containers/files/upload":   0,
+	"/api/containers/files/download": 0,
+	"/api/containers/files/extract":  0,
+	"/api/terminal/sessions":         0,
+	"/api/backup":                    0, // 打包数据库和编排项目
+	"/api/restore":                   0, // 上传并解压备份
+
+	// 耗时操作
+	"/api/images":                     routeTimeoutLong,
+	"/api/images/prune":               routeTimeoutLong,
+	"/api/volumes/prune":              routeTimeoutLong,
+	"/api/compose/action":             routeTimeoutLong,
+	"/api/containers/run":             routeTimeoutLong,
+	"/api/containers/recreate":        routeTimeoutLong,
+	"/api/containers/create":          routeTimeoutLong,
+	"/api/containers/schedule":        routeTimeoutLong,
+	"/api/containers/exec":            routeTimeoutLong,
+	"/api/containers/files/copy-path": routeTimeoutLong,
+	"/api/containers/files/rename":    routeTimeoutLong,
+	"/api/apps/deploy":                routeTimeoutLong, // 拉取镜像并创建容器
+	"/api/apps/upgrade":               routeTimeoutLong,
+	"/api/containers/trash/restore":   routeTimeoutLong, // 镜像不存在时先拉取
+	"/api/containers/start-with-deps": routeTimeoutLong, // 逐个等待依赖健康（每步最长 600s）
+}
+
+// 不限制超时的路由前缀
+var routeTimeoutExemptPrefixes = []string{
+	"/api/uploads/",     // 分片上传（单个分片最大 64MB，complete 需要复制整个文件）
+	"/api/debug/pprof/", // profile、trace 按 seconds 参数采样
+	"/api/jobs/",        // 后台任务输出 ?follow=true 持续推送到任务结束
+}
+
+// 获取路由的超时时间
+func routeTimeout(p string) time.Duration {
+	if d, ok := routeTimeouts[p]; ok {
+		return d
+	}
+	for _, prefix := range routeTimeoutExemptPrefixes {
+		if strings.HasPrefix(p, prefix) {
+			return 0
+		}
+	}
+	return routeTimeoutDefault
+}
+
+// 为每个请求设置连接的读写截止时间
+// 不限制的路由显式清除截止时间，避免继承同一 keep-alive 连接上前一个请求设置的值
+func withRouteTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var deadline time.Time
+		if d := routeTimeout(r.URL.Path); d > 0 {
+			deadline = time.Now().Add(d)
+		}
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// HTTPS 模式
+// acme：按 acme_domains 自动申请和续期 Let's Encrypt 证书，80 端口处理 HTTP-01 验证，面板监听 443
+// files：使用 tls_cert_file / tls_key_file 指定的证书，监听地址不变
+// 两者都未配置时使用 HTTP
+const (
+	tlsModeOff   = ""
+	tlsModeFiles = "files"
+	tlsModeACME  = "acme"
+)
+
+// ACME 模式固定使用的端口（HTTP-01 验证只能使用 80 端口）
+const (
+	acmeHTTPPort  = "80"
+	acmeHTTPSPort = "443"
+)
+
+var acmeDomainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+
+// 逗号分隔的 ACME 域名
+func (c *Config) acmeDomains() []string {
+	var domains []string
+	for _, d := range strings.Split(c.ACMEDomains, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// ACME 证书缓存目录（未设置时为 data_dir/acme）
+func (c *Config) acmeCacheDir() string {
+	if c.ACMECacheDir != "" {
+		return c.ACMECacheDir
+	}
+	return filepath.Join(c.DataDir, "acme")
+}
+
+// 当前的 HTTPS 模式
+func (c *Config) tlsMode() string {
+	if len(c.acmeDomains()) > 0 {
+		return tlsModeACME
+	}
+	if c.TLSCertFile != "" {
+		return tlsModeFiles
+	}
+	return tlsModeOff
+}
+
+// 校验 HTTPS 配置
+func (c *Config) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file 和 tls_key_file 必须同时配置")
+	}
+	mode := c.tlsMode()
+	if mode == tlsModeOff {
+		return nil
+	}
+	if c.Mode == ModeWorker {
+		return fmt.Errorf("Worker 模式由 Master 通过 HTTP 访问，不支持 HTTPS")
+	}
+	if c.Listen != "" {
+		return fmt.Errorf("监听 unix socket 时不支持 HTTPS，请在反向代理上配置证书")
+	}
+	if mode == tlsModeFiles {
+		return nil
+	}
+
+	if c.TLSCertFile != "" {
+		return fmt.Errorf("acme_domains 和 tls_cert_file 不能同时配置")
+	}
+	for _, d := range c.acmeDomains() {
+		if !acmeDomainPattern.MatchString(d) {
+			return fmt.Errorf("acme_domains 中的域名无效: %s", d)
+		}
+	}
+	if c.ACMEEmail != "" && !strings.Contains(c.ACMEEmail, "@") {
+		return fmt.Errorf("acme_email 配置无效: %s", c.ACMEEmail)
+	}
+	return nil
+}
+
+// ACME 模式的证书管理和 80 端口监听
+type acmeService struct {
+	manager   *autocert.Manager
+	challenge net.Listener
+}
+
+// 启用 ACME：创建证书管理器，监听 443（面板）和 80（HTTP-01 验证、重定向到 HTTPS）
+// 端口无法绑定时返回明确的错误，不会退回到 HTTP
+func listenACME(cfg *Config) (net.Listener, *acmeService, string, error) {
+	cacheDir := cfg.acmeCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, nil, "", fmt.Errorf("创建 ACME 证书目录失败: %v", err)
+	}
+	domains := cfg.acmeDomains()
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Email:      cfg.ACMEEmail,
+	}
+
+	httpsAddr := net.JoinHostPort(cfg.Host, acmeHTTPSPort)
+	listener, err := net.Listen("tcp", httpsAddr)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("ACME 模式无法监听 %s: %v（需要 root 权限或 CAP_NET_BIND_SERVICE，且端口未被占用）", httpsAddr, err)
+	}
+	httpAddr := net.JoinHostPort(cfg.Host, acmeHTTPPort)
+	challenge, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		listener.Close()
+		return nil, nil, "", fmt.Errorf("ACME 模式无法监听 %s: %v（HTTP-01 验证需要 80 端口，需要 root 权限或 CAP_NET_BIND_SERVICE，且端口未被占用）", httpAddr, err)
+	}
+
+	log.Printf("[ACME] Certificates for %s cached in %s", strings.Join(domains, ", "), cacheDir)
+	target := fmt.Sprintf("%s（HTTPS，Let's Encrypt: %s），%s（HTTP-01 验证）", httpsAddr, strings.Join(domains, ", "), httpAddr)
+	return listener, &acmeService{manager: manager, challenge: challenge}, target, nil
+}
+
+// 启动服务：按 HTTPS 模式选择 TLS 配置，ACME 模式同时在 80 端口处理验证请求
+func serveHTTP(server *http.Server, listener net.Listener, cfg *Config, acme *acmeService) error {
+	switch cfg.tlsMode() {
+	case tlsModeACME:
+		challengeServer := &http.Server{
+			Handler:           acme.manager.HTTPHandler(nil), // 验证请求之外的请求重定向到 HTTPS
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+		go func() {
+			if err := challengeServer.Serve(acme.challenge); err != nil && err != http.ErrServerClosed {
+				log.Printf("[ACME] HTTP-01 challenge server stopped: %v", err)
+			}
+		}()
+		go func() {
+			<-serverCtx.Done()
+			challengeServer.Close()
+		}()
+
+		server.TLSConfig = acme.manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+		return server.ServeTLS(listener, "", "")
+	case tlsModeFiles:
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	return server.Serve(listener)
+}
+
+// 是否通过 HTTPS 提供服务（Cookie 设置 Secure）
+func serverUsesTLS() bool {
+	return appConfig.tlsMode() != tlsModeOff
+}
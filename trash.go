@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 容器回收站：面板删除容器前保存完整的 inspect 结果，可按原配置重新创建
+// 只保存容器配置，不备份数据卷内容
+
+const trashVolumeNotice = "回收站只保存容器配置，不包含数据卷内容：命名卷和绑定目录按原名称/路径重新挂载（已删除的命名卷会创建为新的空卷），匿名卷和容器内写入的文件无法恢复"
+
+// 回收站条目
+type TrashEntry struct {
+	ID          int64  `json:"id"`
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	DeletedBy   string `json:"deleted_by"`
+	DeletedAt   int64  `json:"deleted_at"` // Unix 秒
+	ExpiresAt   int64  `json:"expires_at"` // 超过保留时间后自动清理
+}
+
+// 创建回收站表并启动定期清理
+func initContainerTrash() error {
+	_, err := authDB.Exec(`
+	CREATE TABLE IF NOT EXISTS container_trash (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		container_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		image TEXT,
+		spec TEXT NOT NULL,
+		deleted_by TEXT,
+		deleted_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_container_trash_deleted_at ON container_trash(deleted_at);`)
+	if err != nil {
+		return fmt.Errorf("创建回收站表失败: %v", err)
+	}
+
+	go pruneContainerTrash()
+	return nil
+}
+
+func pruneContainerTrash() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-settingDays("retention.trash_days")).Unix()
+		if res, err := authDB.Exec("DELETE FROM container_trash WHERE deleted_at < ?", cutoff); err != nil {
+			log.Printf("[Trash] Prune failed: %v", err)
+		} else if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("[Trash] Purged %d expired containers", n)
+		}
+
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 保存容器配置到回收站后删除容器（保存失败时不删除；删除失败时撤销保存）
+// removeVolumes 时同时删除容器的匿名卷（无法从回收站恢复）
+func removeContainerToTrash(ctx context.Context, id, username string, removeVolumes bool) error {
+	cli := getDockerClient()
+	info, err := cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return fmt.Errorf("获取容器配置失败: %v", err)
+	}
+	spec, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("保存容器配置失败: %v", err)
+	}
+	res, err := authDB.Exec(
+		"INSERT INTO container_trash (container_id, name, image, spec, deleted_by, deleted_at) VALUES (?, ?, ?, ?, ?, ?)",
+		info.ID, strings.TrimPrefix(info.Name, "/"), info.Config.Image, string(spec), username, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("保存到回收站失败，未删除容器: %v", err)
+	}
+	trashID, _ := res.LastInsertId()
+
+	if err := cli.ContainerRemove(ctx, info.ID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: removeVolumes}); err != nil {
+		authDB.Exec("DELETE FROM container_trash WHERE id = ?", trashID)
+		return err
+	}
+	return nil
+}
+
+// 回收站列表（最近删除的在前）
+func handleContainerTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	rows, err := authDB.Query("SELECT id, container_id, name, image, deleted_by, deleted_at FROM container_trash ORDER BY deleted_at DESC, id DESC")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("查询失败: %v", err))
+		return
+	}
+	defer rows.Close()
+
+	keep := int64(settingDays("retention.trash_days") / time.Second)
+	entries := make([]TrashEntry, 0)
+	for rows.Next() {
+		var e TrashEntry
+		var image, deletedBy sql.NullString
+		if err := rows.Scan(&e.ID, &e.ContainerID, &e.Name, &image, &deletedBy, &e.DeletedAt); err != nil {
+			continue
+		}
+		e.Image, e.DeletedBy = image.String, deletedBy.String
+		e.ExpiresAt = e.DeletedAt + keep
+		entries = append(entries, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// 读取回收站条目的 inspect 结果
+func loadTrashSpec(id int64) (*TrashEntry, *types.ContainerJSON, error) {
+	var e TrashEntry
+	var image, deletedBy sql.NullString
+	var spec string
+	err := authDB.QueryRow(
+		"SELECT id, container_id, name, image, spec, deleted_by, deleted_at FROM container_trash WHERE id = ?", id,
+	).Scan(&e.ID, &e.ContainerID, &e.Name, &image, &spec, &deletedBy, &e.DeletedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.Image, e.DeletedBy = image.String, deletedBy.String
+	var info types.ContainerJSON
+	if err := json.Unmarshal([]byte(spec), &info); err != nil || info.ContainerJSONBase == nil || info.Config == nil {
+		return nil, nil, fmt.Errorf("回收站中的容器配置已损坏")
+	}
+	return &e, &info, nil
+}
+
+// 按保存的配置重新创建容器（原容器删除时在运行则启动），成功后从回收站移除
+func handleContainerTrashRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"` // 可选，默认使用原名称
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	entry, info, err := loadTrashSpec(req.ID)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "回收站中没有该容器")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	if !dockerAvailable() {
+		writeDockerUnavailable(w)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = entry.Name
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+	defer cancel()
+	cli := getDockerClient()
+
+	// 镜像已被删除时重新拉取（使用镜像 ID 创建的容器无法拉取）
+	image := info.Config.Image
+	if _, _, err := cli.ImageInspectWithRaw(ctx, image); err != nil {
+		if strings.HasPrefix(image, "sha256:") {
+			writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("镜像已不存在: %s", image))
+			return
+		}
+		reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: registryAuthFor(image)})
+		if err == nil {
+			_, err = io.Copy(io.Discard, reader)
+			reader.Close()
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("拉取镜像失败: %v", err))
+			return
+		}
+	}
+
+	id, err := createFromInspect(ctx, *info, "", name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+		return
+	}
+	started := false
+	if info.State != nil && info.State.Running {
+		if err := cli.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+			cli.ContainerRemove(context.WithoutCancel(ctx), id, types.ContainerRemoveOptions{Force: true})
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("启动容器失败: %v", err))
+			return
+		}
+		started = true
+	}
+	InvalidateContainers()
+
+	if _, err := authDB.Exec("DELETE FROM container_trash WHERE id = ?", entry.ID); err != nil {
+		log.Printf("[Trash] Remove restored entry %d failed: %v", entry.ID, err)
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "container_restore", name, "trash #"+strconv.FormatInt(entry.ID, 10), r.RemoteAddr)
+
+	mounts := make([]map[string]string, 0, len(info.Mounts))
+	for _, m := range info.Mounts {
+		mounts = append(mounts, map[string]string{
+			"type":        string(m.Type),
+			"name":        m.Name,
+			"source":      m.Source,
+			"destination": m.Destination,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":           "success",
+		"id":               id,
+		"name":             name,
+		"started":          started,
+		"volumes_restored": false,
+		"volume_notice":    trashVolumeNotice,
+		"mounts":           mounts,
+	})
+}
+
+// 从回收站永久删除
+func handleContainerTrashDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+	var name string
+	if err := authDB.QueryRow("SELECT name FROM container_trash WHERE id = ?", req.ID).Scan(&name); err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "回收站中没有该容器")
+		return
+	}
+	if _, err := authDB.Exec("DELETE FROM container_trash WHERE id = ?", req.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("删除失败: %v", err))
+		return
+	}
+	writeAuditLog(r.Header.Get("X-Username"), "container_trash_delete", name, "", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
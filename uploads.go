@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// 分片上传的文件大小上限（max_chunked_upload_size / MAX_CHUNKED_UPLOAD_SIZE，单位 MB，默认 10240）
+var chunkedUploadMaxSize int64 = 10240 << 20
+
+// 分片上传参数
+const (
+	uploadDefaultChunkSize = 8 << 20        // 默认分片大小 8MB
+	uploadMaxChunkSize     = 64 << 20       // 最大分片大小 64MB
+	uploadExpiry           = 24 * time.Hour // 超过该时间无活动的上传会被清理
+	uploadGCInterval       = 10 * time.Minute
+)
+
+// 分片上传任务（元数据保存在 DATA_DIR/uploads/<id>/meta.json，重启后可继续上传）
+type chunkedUpload struct {
+	mu sync.Mutex
+
+	ID          string    `json:"upload_id"`
+	ContainerID string    `json:"container_id"`
+	Path        string    `json:"path"` // 容器内目标目录
+	FileName    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	ChunkSize   int64     `json:"chunk_size"`
+	Received    []bool    `json:"received"`
+	Username    string    `json:"username"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	writing    map[int]bool // 正在写入的分片，由 mu 保护
+	completing bool         // 正在写入容器，由 mu 保护
+}
+
+var chunkedUploads = struct {
+	sync.Mutex
+	items map[string]*chunkedUpload
+}{items: make(map[string]*chunkedUpload)}
+
+// 上传临时目录
+func uploadsDir() string {
+	return filepath.Join(dataDir, "uploads")
+}
+
+func (u *chunkedUpload) dir() string {
+	return filepath.Join(uploadsDir(), u.ID)
+}
+
+func (u *chunkedUpload) dataFile() string {
+	return filepath.Join(u.dir(), "data")
+}
+
+func (u *chunkedUpload) totalChunks() int {
+	if u.Size == 0 {
+		return 1
+	}
+	return int((u.Size + u.ChunkSize - 1) / u.ChunkSize)
+}
+
+// 第 index 个分片的预期大小
+func (u *chunkedUpload) chunkLength(index int) int64 {
+	start := int64(index) * u.ChunkSize
+	if remain := u.Size - start; remain < u.ChunkSize {
+		return remain
+	}
+	return u.ChunkSize
+}
+
+// 保存元数据（调用方需持有 u.mu）
+func (u *chunkedUpload) saveMeta() error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(u.dir(), "meta.json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(u.dir(), "meta.json"))
+}
+
+// 上传状态（调用方需持有 u.mu）
+func (u *chunkedUpload) status() map[string]interface{} {
+	received := make([]int, 0, len(u.Received))
+	missing := 0
+	for i, ok := range u.Received {
+		if ok {
+			received = append(received, i)
+		} else {
+			missing++
+		}
+	}
+	return map[string]interface{}{
+		"upload_id":    u.ID,
+		"container_id": u.ContainerID,
+		"path":         u.Path,
+		"filename":     u.FileName,
+		"size":         u.Size,
+		"chunk_size":   u.ChunkSize,
+		"total_chunks": u.totalChunks(),
+		"received":     received,
+		"missing":      missing,
+		"expires_at":   u.UpdatedAt.Add(uploadExpiry).Unix(),
+	}
+}
+
+// 加载未完成的上传并启动清理协程
+func initChunkedUploads() error {
+	if err := os.MkdirAll(uploadsDir(), 0700); err != nil {
+		return fmt.Errorf("创建上传目录失败: %v", err)
+	}
+
+	entries, err := os.ReadDir(uploadsDir())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(uploadsDir(), entry.Name(), "meta.json"))
+		if err != nil {
+			os.RemoveAll(filepath.Join(uploadsDir(), entry.Name()))
+			continue
+		}
+		u := &chunkedUpload{}
+		if err := json.Unmarshal(data, u); err != nil || u.ID != entry.Name() {
+			os.RemoveAll(filepath.Join(uploadsDir(), entry.Name()))
+			continue
+		}
+		chunkedUploads.items[u.ID] = u
+	}
+
+	go gcChunkedUploads()
+	return nil
+}
+
+// 定期清理过期的上传
+func gcChunkedUploads() {
+	ticker := time.NewTicker(uploadGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serverCtx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		chunkedUploads.Lock()
+		for id, u := range chunkedUploads.items {
+			u.mu.Lock()
+			expired := time.Since(u.UpdatedAt) > uploadExpiry
+			u.mu.Unlock()
+			if expired {
+				delete(chunkedUploads.items, id)
+				os.RemoveAll(u.dir())
+				log.Printf("[Uploads] Expired upload removed: %s (%s)", id, u.FileName)
+			}
+		}
+		chunkedUploads.Unlock()
+	}
+}
+
+func getChunkedUpload(id string) *chunkedUpload {
+	chunkedUploads.Lock()
+	defer chunkedUploads.Unlock()
+	return chunkedUploads.items[id]
+}
+
+func removeChunkedUpload(u *chunkedUpload) {
+	chunkedUploads.Lock()
+	delete(chunkedUploads.items, u.ID)
+	chunkedUploads.Unlock()
+	os.RemoveAll(u.dir())
+}
+
+// 分片上传路由：
+//
+//	POST   /api/uploads/init
+//	GET    /api/uploads/{id}
+//	PUT    /api/uploads/{id}/chunk?index=N
+//	POST   /api/uploads/{id}/complete
+//	DELETE /api/uploads/{id}
+func handleUploads(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/uploads/"), "/")
+	if rest == "init" {
+		handleUploadInit(w, r)
+		return
+	}
+
+	id, action, _ := strings.Cut(rest, "/")
+	u := getChunkedUpload(id)
+	// 只有创建者可以查看、上传、完成或取消（其他用户的任务按不存在处理，不暴露任务 ID）
+	if u == nil || u.Username != r.Header.Get("X-Username") {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "上传任务不存在或已过期")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		u.mu.Lock()
+		status := u.status()
+		u.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	case action == "" && r.Method == http.MethodDelete:
+		removeChunkedUpload(u)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	case action == "chunk" && r.Method == http.MethodPut:
+		handleUploadChunk(w, r, u)
+	case action == "complete" && r.Method == http.MethodPost:
+		handleUploadComplete(w, r, u)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+	}
+}
+
+// 创建分片上传
+func handleUploadInit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "方法不允许")
+		return
+	}
+
+	var req struct {
+		ContainerID string `json:"container_id"`
+		Path        string `json:"path"`
+		FileName    string `json:"filename"`
+		Size        int64  `json:"size"`
+		ChunkSize   int64  `json:"chunk_size"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "请求参数错误")
+		return
+	}
+
+	if req.ContainerID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "容器ID不能为空")
+		return
+	}
+	destDir, err := cleanContainerPath(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	fileName := path.Base(req.FileName)
+	if req.FileName == "" || fileName != req.FileName || fileName == "." || fileName == ".." {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "文件名无效")
+		return
+	}
+	if req.Size < 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "size 参数无效")
+		return
+	}
+	if req.Size > chunkedUploadMaxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("上传内容超过上限 (%d MB)", chunkedUploadMaxSize>>20))
+		return
+	}
+	if req.ChunkSize == 0 {
+		req.ChunkSize = uploadDefaultChunkSize
+	}
+	if req.ChunkSize < 0 || req.ChunkSize > uploadMaxChunkSize {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("chunk_size 参数无效（最大 %d MB）", uploadMaxChunkSize>>20))
+		return
+	}
+
+	// 临时文件需要完整保存，检查数据目录剩余空间（扣除其他未完成上传尚未写入的部分）。
+	// 检查和登记期间持有锁，避免并发创建的上传同时通过检查
+	chunkedUploads.Lock()
+	defer chunkedUploads.Unlock()
+	if usage, err := disk.Usage(uploadsDir()); err == nil && uint64(req.Size+pendingUploadBytes()) > usage.Free {
+		writeError(w, http.StatusInsufficientStorage, ErrCodeInsufficientSpace, "数据目录剩余空间不足")
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("生成上传ID失败: %v", err))
+		return
+	}
+
+	now := time.Now()
+	u := &chunkedUpload{
+		ID:          hex.EncodeToString(idBytes),
+		ContainerID: req.ContainerID,
+		Path:        destDir,
+		FileName:    fileName,
+		Size:        req.Size,
+		ChunkSize:   req.ChunkSize,
+		Username:    r.Header.Get("X-Username"),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	u.Received = make([]bool, u.totalChunks())
+
+	if err := os.MkdirAll(u.dir(), 0700); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建上传目录失败: %v", err))
+		return
+	}
+	file, err := os.OpenFile(u.dataFile(), os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		err = file.Truncate(u.Size)
+		file.Close()
+	}
+	if err == nil {
+		err = u.saveMeta()
+	}
+	if err != nil {
+		os.RemoveAll(u.dir())
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("创建上传任务失败: %v", err))
+		return
+	}
+
+	chunkedUploads.items[u.ID] = u
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u.status())
+}
+
+// 未完成的上传还需要写入的数据量（临时文件是稀疏文件，未收到的分片还没有占用磁盘，调用方需持有 chunkedUploads 锁）
+func pendingUploadBytes() int64 {
+	var pending int64
+	for _, u := range chunkedUploads.items {
+		u.mu.Lock()
+		for i, ok := range u.Received {
+			if !ok {
+				pending += u.chunkLength(i)
+			}
+		}
+		u.mu.Unlock()
+	}
+	return pending
+}
+
+// 上传一个分片（请求体为分片原始数据，X-Chunk-SHA256 为其 SHA-256）
+func handleUploadChunk(w http.ResponseWriter, r *http.Request, u *chunkedUpload) {
+	index, err := strconv.Atoi(r.URL.Query().Get("index"))
+	if err != nil || index < 0 || index >= u.totalChunks() {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "index 参数无效")
+		return
+	}
+	expectedHash := strings.ToLower(r.Header.Get("X-Chunk-SHA256"))
+	if expectedHash == "" {
+		expectedHash = strings.ToLower(r.URL.Query().Get("sha256"))
+	}
+	if len(expectedHash) != sha256.Size*2 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "缺少分片 SHA-256（X-Chunk-SHA256）")
+		return
+	}
+
+	// 分片直接写入最终位置：写入前标记为未接收，校验通过后才重新标记，
+	// 重传已接收的分片失败时该分片需要再次上传，不会带着损坏的数据完成上传
+	u.mu.Lock()
+	if u.completing {
+		u.mu.Unlock()
+		writeError(w, http.StatusConflict, ErrCodeConflict, "上传正在完成，不能再上传分片")
+		return
+	}
+	if u.writing[index] {
+		u.mu.Unlock()
+		writeError(w, http.StatusConflict, ErrCodeConflict, fmt.Sprintf("分片 %d 正在上传", index))
+		return
+	}
+	if u.writing == nil {
+		u.writing = make(map[int]bool)
+	}
+	u.writing[index] = true
+	var metaErr error
+	if u.Received[index] {
+		u.Received[index] = false
+		metaErr = u.saveMeta() // 重启后也不能把写了一半的分片当作已接收
+	}
+	u.mu.Unlock()
+	defer func() {
+		u.mu.Lock()
+		delete(u.writing, index)
+		u.mu.Unlock()
+	}()
+	if metaErr != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存上传进度失败: %v", metaErr))
+		return
+	}
+
+	expectedLen := u.chunkLength(index)
+	body := http.MaxBytesReader(w, r.Body, expectedLen+1)
+
+	file, err := os.OpenFile(u.dataFile(), os.O_WRONLY, 0600)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("打开临时文件失败: %v", err))
+		return
+	}
+	defer file.Close()
+
+	// 边写入边计算哈希，校验失败时该分片保持未接收状态
+	hasher := sha256.New()
+	offsetWriter := io.NewOffsetWriter(file, int64(index)*u.ChunkSize)
+	// 只写入 expectedLen 字节，多出的数据不能写进下一个分片的区域
+	n, err := io.CopyN(io.MultiWriter(offsetWriter, hasher), body, expectedLen)
+	if err == io.EOF {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("分片大小不匹配: 期望 %d，实际 %d", expectedLen, n))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("写入分片失败: %v", err))
+		return
+	}
+	// 再读一个字节（不写入）判断请求体是否超长
+	if m, _ := io.ReadFull(body, make([]byte, 1)); m > 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("分片大小超过 %d 字节", expectedLen))
+		return
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != expectedHash {
+		writeError(w, http.StatusUnprocessableEntity, ErrCodeUnprocessable, "分片 SHA-256 校验失败")
+		return
+	}
+	if err := file.Sync(); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("写入分片失败: %v", err))
+		return
+	}
+
+	u.mu.Lock()
+	u.Received[index] = true
+	u.UpdatedAt = time.Now()
+	err = u.saveMeta()
+	status := u.status()
+	u.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("保存上传进度失败: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// 完成上传：所有分片到齐后写入容器
+func handleUploadComplete(w http.ResponseWriter, r *http.Request, u *chunkedUpload) {
+	u.mu.Lock()
+	if u.completing {
+		u.mu.Unlock()
+		writeError(w, http.StatusConflict, ErrCodeConflict, "上传正在完成")
+		return
+	}
+	for i, ok := range u.Received {
+		if !ok {
+			status := u.status()
+			u.mu.Unlock()
+			writeErrorDetails(w, http.StatusConflict, ErrCodeUploadChunksMissing, fmt.Sprintf("分片 %d 尚未上传", i), status)
+			return
+		}
+	}
+	// 分片写入中的分片未标记为已接收，上面已拒绝；此后拒绝新的分片和重复的 complete
+	u.completing = true
+	u.UpdatedAt = time.Now()
+	u.mu.Unlock()
+	completed := false
+	defer func() {
+		if !completed {
+			u.mu.Lock()
+			u.completing = false
+			u.mu.Unlock()
+		}
+	}()
+
+	file, err := os.Open(u.dataFile())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("打开临时文件失败: %v", err))
+		return
+	}
+	defer file.Close()
+
+	release, err := acquireOp(r.Context(), opFiles)
+	if err != nil {
+		writeOpBusy(w, opFiles, err)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
+	defer cancel()
+
+	written, err := streamFileToContainer(ctx, u.ContainerID, u.Path, u.FileName, u.Size, file)
+	if err != nil {
+		// 保留临时文件，允许重试 complete
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, fmt.Sprintf("写入容器失败: %v", err))
+		return
+	}
+
+	file.Close()
+	completed = true
+	removeChunkedUpload(u)
+	log.Printf("[Uploads] Upload completed: %s -> %s:%s (%d bytes)", u.FileName, u.ContainerID, u.Path, written)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"bytes":  written,
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// 创建一个 8 字节、分片大小 4 字节的上传任务
+func newTestUpload(t *testing.T) *chunkedUpload {
+	t.Helper()
+	oldDataDir := dataDir
+	dataDir = t.TempDir()
+	t.Cleanup(func() { dataDir = oldDataDir })
+
+	u := &chunkedUpload{ID: "test-upload", Size: 8, ChunkSize: 4, Username: "alice"}
+	u.Received = make([]bool, u.totalChunks())
+	if err := os.MkdirAll(u.dir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(u.dataFile(), make([]byte, u.Size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	chunkedUploads.Lock()
+	chunkedUploads.items[u.ID] = u
+	chunkedUploads.Unlock()
+	t.Cleanup(func() {
+		chunkedUploads.Lock()
+		delete(chunkedUploads.items, u.ID)
+		chunkedUploads.Unlock()
+	})
+	return u
+}
+
+func putChunk(u *chunkedUpload, username string, index string, data []byte) *httptest.ResponseRecorder {
+	sum := sha256.Sum256(data)
+	req := httptest.NewRequest(http.MethodPut, "/api/uploads/"+u.ID+"/chunk?index="+index, bytes.NewReader(data))
+	req.Header.Set("X-Chunk-SHA256", hex.EncodeToString(sum[:]))
+	req.Header.Set("X-Username", username)
+	rec := httptest.NewRecorder()
+	handleUploads(rec, req)
+	return rec
+}
+
+// 超长的分片被拒绝，且不会写入下一个分片的区域
+func TestUploadChunkOversized(t *testing.T) {
+	u := newTestUpload(t)
+
+	if rec := putChunk(u, "alice", "0", []byte("abcdX")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("oversized chunk: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := putChunk(u, "alice", "0", []byte("abc")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("short chunk: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	data, err := os.ReadFile(u.dataFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[4:], make([]byte, 4)) {
+		t.Errorf("next chunk region overwritten: %q", data[4:])
+	}
+	if u.Received[0] {
+		t.Error("rejected chunk marked as received")
+	}
+
+	if rec := putChunk(u, "alice", "0", []byte("abcd")); rec.Code != http.StatusOK {
+		t.Fatalf("valid chunk: status %d, body %s", rec.Code, rec.Body)
+	}
+	if !u.Received[0] {
+		t.Error("valid chunk not marked as received")
+	}
+}
+
+// 其他用户不能操作上传任务
+func TestUploadOwner(t *testing.T) {
+	u := newTestUpload(t)
+
+	if rec := putChunk(u, "bob", "0", []byte("abcd")); rec.Code != http.StatusNotFound {
+		t.Errorf("chunk by other user: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	for _, c := range []struct{ method, path string }{
+		{http.MethodGet, "/api/uploads/" + u.ID},
+		{http.MethodPost, "/api/uploads/" + u.ID + "/complete"},
+		{http.MethodDelete, "/api/uploads/" + u.ID},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("X-Username", "bob")
+		rec := httptest.NewRecorder()
+		handleUploads(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s by other user: status %d, want %d", c.method, c.path, rec.Code, http.StatusNotFound)
+		}
+	}
+	if getChunkedUpload(u.ID) == nil {
+		t.Error("upload removed by other user")
+	}
+}
+
+// 超过分片上传大小上限的任务不能创建；未完成上传的剩余大小计入空间检查
+func TestUploadInitLimits(t *testing.T) {
+	u := newTestUpload(t)
+
+	oldMax := chunkedUploadMaxSize
+	chunkedUploadMaxSize = 1 << 20
+	t.Cleanup(func() { chunkedUploadMaxSize = oldMax })
+
+	body := `{"container_id":"c1","path":"/data","filename":"dump.sql","size":1048577}`
+	rec := httptest.NewRecorder()
+	handleUploadInit(rec, httptest.NewRequest(http.MethodPost, "/api/uploads/init", bytes.NewReader([]byte(body))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	chunkedUploads.Lock()
+	defer chunkedUploads.Unlock()
+	if got := pendingUploadBytes(); got != 8 {
+		t.Errorf("pending bytes = %d, want 8", got)
+	}
+	u.Received[0] = true
+	if got := pendingUploadBytes(); got != 4 {
+		t.Errorf("pending bytes after first chunk = %d, want 4", got)
+	}
+}
+
+// 重传已接收的分片失败后需要重新上传；正在完成的上传拒绝分片和重复的 complete
+func TestUploadChunkResend(t *testing.T) {
+	u := newTestUpload(t)
+
+	for _, c := range []struct{ index, data string }{{"0", "abcd"}, {"1", "efgh"}} {
+		if rec := putChunk(u, "alice", c.index, []byte(c.data)); rec.Code != http.StatusOK {
+			t.Fatalf("chunk %s: status %d, body %s", c.index, rec.Code, rec.Body)
+		}
+	}
+
+	// 请求体与哈希不符（哈希按 "abcd" 计算）
+	sum := sha256.Sum256([]byte("abcd"))
+	req := httptest.NewRequest(http.MethodPut, "/api/uploads/"+u.ID+"/chunk?index=0", bytes.NewReader([]byte("XXXX")))
+	req.Header.Set("X-Chunk-SHA256", hex.EncodeToString(sum[:]))
+	req.Header.Set("X-Username", "alice")
+	rec := httptest.NewRecorder()
+	handleUploads(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad resend: status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if u.Received[0] {
+		t.Error("chunk overwritten by a failed resend still marked as received")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/uploads/"+u.ID+"/complete", nil)
+	req.Header.Set("X-Username", "alice")
+	rec = httptest.NewRecorder()
+	handleUploads(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("complete with missing chunk: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	u.mu.Lock()
+	u.completing = true
+	u.mu.Unlock()
+	if rec := putChunk(u, "alice", "0", []byte("abcd")); rec.Code != http.StatusConflict {
+		t.Errorf("chunk while completing: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = httptest.NewRecorder()
+	handleUploads(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("second complete: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+}